@@ -14,62 +14,145 @@ const (
 
 	// NonceSize is the standard nonce length for AES-GCM.
 	NonceSize = 12
+
+	// TagSize is the length of the authentication tag appended to every
+	// ciphertext by both AES-256-GCM and XChaCha20-Poly1305.
+	TagSize = 16
 )
 
 // Encrypt encrypts plaintext using AES-256-GCM with the given key.
 // Key must be exactly 32 bytes. Returns ciphertext with nonce prepended.
 func Encrypt(key, plaintext []byte) ([]byte, error) {
-	if len(key) != KeySize {
-		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
-	}
+	return EncryptWithAD(key, plaintext, nil)
+}
 
-	block, err := aes.NewCipher(key)
+// Decrypt decrypts ciphertext produced by Encrypt.
+// Key must be exactly 32 bytes. Expects nonce prepended to ciphertext.
+func Decrypt(key, ciphertext []byte) ([]byte, error) {
+	return DecryptWithAD(key, ciphertext, nil)
+}
+
+// EncryptWithAD is like Encrypt but also authenticates ad, associated data
+// that is not encrypted or included in the output. The same ad must be
+// passed to DecryptWithAD, which binds the ciphertext to its context, such
+// as a record id, so it cannot be moved elsewhere.
+func EncryptWithAD(key, plaintext, ad []byte) ([]byte, error) {
+	s, err := NewSealer(key)
 	if err != nil {
-		return nil, fmt.Errorf("create cipher: %w", err)
+		return nil, err
 	}
+	return s.Encrypt(plaintext, ad)
+}
 
-	gcm, err := cipher.NewGCM(block)
+// DecryptWithAD decrypts ciphertext produced by EncryptWithAD with the same
+// associated data.
+func DecryptWithAD(key, ciphertext, ad []byte) ([]byte, error) {
+	s, err := NewSealer(key)
 	if err != nil {
-		return nil, fmt.Errorf("create GCM: %w", err)
+		return nil, err
 	}
+	return s.Decrypt(ciphertext, ad)
+}
+
+// Sealer encrypts and decrypts with one cipher suite under a single key. It
+// sets up the cipher once, so it is cheaper than Encrypt and Decrypt when one key
+// seals many messages. A Sealer is safe for concurrent use. The expanded key
+// schedule it holds cannot be erased; drop the Sealer when done with it.
+type Sealer struct {
+	suite Suite
+	aead  cipher.AEAD
+}
+
+// NewSealer returns an AES-256-GCM Sealer for key, which must be exactly
+// 32 bytes.
+func NewSealer(key []byte) (*Sealer, error) {
+	return NewSuiteSealer(AES256GCM, key)
+}
+
+// Suite returns the cipher suite the Sealer uses.
+func (s *Sealer) Suite() Suite { return s.suite }
+
+// NonceSize returns the nonce length Seal and Open expect.
+func (s *Sealer) NonceSize() int { return s.aead.NonceSize() }
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err := rand.Read(nonce); err != nil {
+// Encrypt encrypts plaintext under a random nonce and authenticates ad,
+// which may be nil. Returns ciphertext with the nonce prepended, in the same
+// format as the package-level Encrypt or EncryptXChaCha for the suite.
+func (s *Sealer) Encrypt(plaintext, ad []byte) ([]byte, error) {
+	n := s.aead.NonceSize()
+	out := make([]byte, n, n+len(plaintext)+TagSize)
+	if _, err := rand.Read(out); err != nil {
 		return nil, fmt.Errorf("generate nonce: %w", err)
 	}
 
-	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
-	return ciphertext, nil
+	return s.aead.Seal(out, out, plaintext, ad), nil
 }
 
-// Decrypt decrypts ciphertext produced by Encrypt.
-// Key must be exactly 32 bytes. Expects nonce prepended to ciphertext.
-func Decrypt(key, ciphertext []byte) ([]byte, error) {
-	if len(key) != KeySize {
-		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+// Decrypt decrypts ciphertext produced by Encrypt with the same ad.
+func (s *Sealer) Decrypt(ciphertext, ad []byte) ([]byte, error) {
+	n := s.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, errors.New("ciphertext too short")
 	}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, fmt.Errorf("create cipher: %w", err)
-	}
+	nonce := ciphertext[:n]
+	ct := ciphertext[n:]
 
-	gcm, err := cipher.NewGCM(block)
+	plaintext, err := s.aead.Open(nil, nonce, ct, ad)
 	if err != nil {
-		return nil, fmt.Errorf("create GCM: %w", err)
+		return nil, fmt.Errorf("decrypt: %w", err)
 	}
 
-	if len(ciphertext) < gcm.NonceSize() {
-		return nil, errors.New("ciphertext too short")
+	return plaintext, nil
+}
+
+// Seal encrypts plaintext under the caller's nonce, authenticates ad, and
+// appends the ciphertext and tag to dst, returning the extended slice. The
+// nonce is not included in the output. To encrypt in place and avoid any
+// allocation, pass plaintext[:0] as dst with TagSize spare capacity.
+//
+// The nonce must be NonceSize() bytes and must never be reused with the
+// same key; doing so breaks both confidentiality and authenticity. Prefer
+// Encrypt unless nonces come from a counter or are otherwise guaranteed
+// unique.
+func (s *Sealer) Seal(dst, nonce, plaintext, ad []byte) ([]byte, error) {
+	if n := s.aead.NonceSize(); len(nonce) != n {
+		return nil, fmt.Errorf("nonce must be %d bytes, got %d", n, len(nonce))
 	}
+	return s.aead.Seal(dst, nonce, plaintext, ad), nil
+}
 
-	nonce := ciphertext[:gcm.NonceSize()]
-	ct := ciphertext[gcm.NonceSize():]
+// Open decrypts ciphertext produced by Seal with the same nonce and ad and
+// appends the plaintext to dst. To decrypt in place, pass ciphertext[:0] as
+// dst. On error the contents of dst's spare capacity are unspecified.
+func (s *Sealer) Open(dst, nonce, ciphertext, ad []byte) ([]byte, error) {
+	if n := s.aead.NonceSize(); len(nonce) != n {
+		return nil, fmt.Errorf("nonce must be %d bytes, got %d", n, len(nonce))
+	}
 
-	plaintext, err := gcm.Open(nil, nonce, ct, nil)
+	plaintext, err := s.aead.Open(dst, nonce, ciphertext, ad)
 	if err != nil {
 		return nil, fmt.Errorf("decrypt: %w", err)
 	}
 
 	return plaintext, nil
 }
+
+// newGCM returns an AES-256-GCM AEAD for key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create GCM: %w", err)
+	}
+
+	return gcm, nil
+}
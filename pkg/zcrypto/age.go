@@ -1,45 +1,55 @@
 package zcrypto
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 
 	"filippo.io/age"
+	"filippo.io/age/armor"
 )
 
-// EncryptAge encrypts src to dst using age's scrypt recipient format.
-// Output is compatible with `age -d -p`.
-func EncryptAge(password string, src io.Reader, dst io.Writer) error {
-	r, err := age.NewScryptRecipient(password)
-	if err != nil {
-		return fmt.Errorf("age encrypt: %w", err)
-	}
+// ageArmorPeek is how far decryption looks for an armor header: the
+// leading whitespace the age CLI tolerates, plus the header itself.
+const ageArmorPeek = 1024 + len(armor.Header)
 
-	w, err := age.Encrypt(dst, r)
-	if err != nil {
-		return fmt.Errorf("age encrypt: %w", err)
-	}
+// AgeOption configures age encryption.
+type AgeOption func(*ageConfig)
 
-	if _, err := io.Copy(w, src); err != nil {
-		return fmt.Errorf("age encrypt: %w", err)
+type ageConfig struct {
+	armor bool
+}
+
+// WithArmor writes ASCII-armored output, a PEM-style block that survives
+// being pasted into chat or a note field, like `age -a`. Decryption detects
+// armored input by itself.
+func WithArmor() AgeOption {
+	return func(c *ageConfig) {
+		c.armor = true
 	}
+}
 
-	if err := w.Close(); err != nil {
+// EncryptAge encrypts src to dst using age's scrypt recipient format.
+// Output is compatible with `age -d -p`.
+func EncryptAge(password string, src io.Reader, dst io.Writer, opts ...AgeOption) error {
+	r, err := age.NewScryptRecipient(password)
+	if err != nil {
 		return fmt.Errorf("age encrypt: %w", err)
 	}
 
-	return nil
+	return ageEncrypt(src, dst, opts, r)
 }
 
 // DecryptAge decrypts an age-encrypted stream using a password.
-// Input from `age -e -p` is accepted.
+// Input from `age -e -p` is accepted, armored or not.
 func DecryptAge(password string, src io.Reader, dst io.Writer) error {
 	id, err := age.NewScryptIdentity(password)
 	if err != nil {
 		return fmt.Errorf("age decrypt: %w", err)
 	}
 
-	r, err := age.Decrypt(src, id)
+	r, err := age.Decrypt(ageInput(src), id)
 	if err != nil {
 		return fmt.Errorf("age decrypt: %w", err)
 	}
@@ -51,52 +61,99 @@ func DecryptAge(password string, src io.Reader, dst io.Writer) error {
 	return nil
 }
 
-// EncryptAgeKey encrypts src to dst for one or more age X25519 public key recipients.
-// Each recipient string must be a valid age public key (e.g. "age1...").
+// EncryptAgeKey encrypts src to dst for one or more public key recipients.
+// Each recipient string must be an age public key (e.g. "age1...") or an
+// ssh-ed25519 or ssh-rsa public key in authorized_keys form.
 // Output is compatible with `age -r <pubkey>`.
-func EncryptAgeKey(recipients []string, src io.Reader, dst io.Writer) error {
+func EncryptAgeKey(recipients []string, src io.Reader, dst io.Writer, opts ...AgeOption) error {
 	parsed := make([]age.Recipient, 0, len(recipients))
 	for _, s := range recipients {
-		r, err := age.ParseX25519Recipient(s)
+		r, err := ageRecipient(s)
 		if err != nil {
 			return fmt.Errorf("age encrypt: parse recipient: %w", err)
 		}
 		parsed = append(parsed, r)
 	}
 
-	w, err := age.Encrypt(dst, parsed...)
+	return ageEncrypt(src, dst, opts, parsed...)
+}
+
+// DecryptAgeKey decrypts an age-encrypted stream using an identity (private key).
+// The identity string must be an age secret key (e.g. "AGE-SECRET-KEY-1..."),
+// an unencrypted identity file holding several, or an unencrypted SSH
+// private key; see ParseAgeIdentityFile for protected ones.
+// Compatible with files encrypted via `age -r <pubkey>`, armored or not.
+func DecryptAgeKey(identity string, src io.Reader, dst io.Writer) error {
+	lines, err := ParseAgeIdentityFile([]byte(identity), "")
 	if err != nil {
-		return fmt.Errorf("age encrypt: %w", err)
+		return fmt.Errorf("age decrypt: parse identity: %w", err)
 	}
 
-	if _, err := io.Copy(w, src); err != nil {
-		return fmt.Errorf("age encrypt: %w", err)
+	ids := make([]age.Identity, 0, len(lines))
+	for _, line := range lines {
+		id, err := ageIdentity(line)
+		if err != nil {
+			return fmt.Errorf("age decrypt: parse identity: %w", err)
+		}
+		ids = append(ids, id)
 	}
 
-	if err := w.Close(); err != nil {
-		return fmt.Errorf("age encrypt: %w", err)
+	r, err := age.Decrypt(ageInput(src), ids...)
+	if err != nil {
+		return fmt.Errorf("age decrypt: %w", err)
+	}
+
+	if _, err := io.Copy(dst, r); err != nil {
+		return fmt.Errorf("age decrypt: %w", err)
 	}
 
 	return nil
 }
 
-// DecryptAgeKey decrypts an age-encrypted stream using an X25519 identity (private key).
-// The identity string must be a valid age secret key (e.g. "AGE-SECRET-KEY-1...").
-// Compatible with files encrypted via `age -r <pubkey>`.
-func DecryptAgeKey(identity string, src io.Reader, dst io.Writer) error {
-	id, err := age.ParseX25519Identity(identity)
-	if err != nil {
-		return fmt.Errorf("age decrypt: parse identity: %w", err)
+// ageEncrypt copies src to dst encrypted to the recipients, armored if
+// configured.
+func ageEncrypt(src io.Reader, dst io.Writer, opts []AgeOption, recipients ...age.Recipient) error {
+	var cfg ageConfig
+	for _, o := range opts {
+		o(&cfg)
 	}
 
-	r, err := age.Decrypt(src, id)
+	out := dst
+	var a io.WriteCloser
+	if cfg.armor {
+		a = armor.NewWriter(dst)
+		out = a
+	}
+
+	w, err := age.Encrypt(out, recipients...)
 	if err != nil {
-		return fmt.Errorf("age decrypt: %w", err)
+		return fmt.Errorf("age encrypt: %w", err)
 	}
 
-	if _, err := io.Copy(dst, r); err != nil {
-		return fmt.Errorf("age decrypt: %w", err)
+	if _, err := io.Copy(w, src); err != nil {
+		return fmt.Errorf("age encrypt: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("age encrypt: %w", err)
+	}
+
+	if a != nil {
+		if err := a.Close(); err != nil {
+			return fmt.Errorf("age encrypt: %w", err)
+		}
 	}
 
 	return nil
 }
+
+// ageInput returns src with armor removed if it is armored, allowing the
+// leading whitespace the age CLI does.
+func ageInput(src io.Reader) io.Reader {
+	br := bufio.NewReaderSize(src, ageArmorPeek)
+	start, _ := br.Peek(ageArmorPeek)
+	if bytes.HasPrefix(bytes.TrimSpace(start), []byte(armor.Header)) {
+		return armor.NewReader(br)
+	}
+	return br
+}
@@ -0,0 +1,226 @@
+package zcrypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrAgePassphraseRequired is returned by ParseAgeIdentityFile when the
+// file is passphrase-protected and no passphrase was given, so callers can
+// prompt for one and try again.
+var ErrAgePassphraseRequired = errors.New("identity file is passphrase-protected")
+
+// ageEncryptedPrefix starts every binary age file.
+const ageEncryptedPrefix = "age-encryption.org/"
+
+// GenerateAgeIdentity creates a new age X25519 identity, returned in its
+// "AGE-SECRET-KEY-1..." encoding. Share AgeRecipient of it, never the
+// identity itself.
+func GenerateAgeIdentity() (string, error) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", fmt.Errorf("generate age identity: %w", err)
+	}
+	return id.String(), nil
+}
+
+// AgeRecipient returns the public recipient for an identity: "age1..." for
+// age keys, or an authorized_keys line for an unencrypted SSH private key.
+func AgeRecipient(identity string) (string, error) {
+	identity = strings.TrimSpace(identity)
+
+	switch {
+	case strings.HasPrefix(identity, "AGE-SECRET-KEY-PQ-1"):
+		id, err := age.ParseHybridIdentity(identity)
+		if err != nil {
+			return "", fmt.Errorf("parse age identity: %w", err)
+		}
+		return id.Recipient().String(), nil
+	case strings.HasPrefix(identity, "AGE-SECRET-KEY-1"):
+		id, err := age.ParseX25519Identity(identity)
+		if err != nil {
+			return "", fmt.Errorf("parse age identity: %w", err)
+		}
+		return id.Recipient().String(), nil
+	case strings.HasPrefix(identity, "-----BEGIN"):
+		signer, err := ssh.ParsePrivateKey([]byte(identity))
+		if err != nil {
+			return "", fmt.Errorf("parse ssh identity: %w", err)
+		}
+		pub := signer.PublicKey()
+		if t := pub.Type(); t != ssh.KeyAlgoED25519 && t != ssh.KeyAlgoRSA {
+			return "", fmt.Errorf("unsupported ssh key type %q", t)
+		}
+		return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))), nil
+	default:
+		return "", errors.New("unknown identity type")
+	}
+}
+
+// AgeIdentityFile formats an age identity the way age-keygen writes it,
+// with its creation time and public key as comments above it.
+func AgeIdentityFile(identity string) ([]byte, error) {
+	identity = strings.TrimSpace(identity)
+	if !strings.HasPrefix(identity, "AGE-SECRET-KEY-") {
+		return nil, errors.New("not an age identity")
+	}
+
+	recipient, err := AgeRecipient(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# created: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# public key: %s\n", recipient)
+	fmt.Fprintf(&b, "%s\n", identity)
+	return b.Bytes(), nil
+}
+
+// EncryptAgeIdentityFile protects an identity file with a passphrase,
+// like `age-keygen | age -p`. ParseAgeIdentityFile reads the result, as
+// does `age -d -i`. WithArmor makes it printable, for paper backups.
+func EncryptAgeIdentityFile(file []byte, passphrase string, opts ...AgeOption) ([]byte, error) {
+	var b bytes.Buffer
+	if err := EncryptAge(passphrase, bytes.NewReader(file), &b, opts...); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// ParseAgeIdentityFile returns the identities in an identity file: age
+// keys one per line, with blank lines and "#" comments ignored, or a single
+// SSH private key. The passphrase decrypts a file protected with
+// EncryptAgeIdentityFile, armored or not, or a passphrase-protected SSH key, and is ignored
+// otherwise; without it such files return ErrAgePassphraseRequired.
+//
+// SSH keys are returned in unencrypted PEM form. Every identity returned
+// is accepted by DecryptAgeKey and AgeRecipient.
+func ParseAgeIdentityFile(data []byte, passphrase string) ([]string, error) {
+	data = bytes.TrimSpace(data)
+
+	switch {
+	case isAgeEncrypted(data):
+		if passphrase == "" {
+			return nil, ErrAgePassphraseRequired
+		}
+		var plain bytes.Buffer
+		defer func() { Erase(plain.Bytes()) }()
+		if err := DecryptAge(passphrase, bytes.NewReader(data), &plain); err != nil {
+			return nil, fmt.Errorf("decrypt identity file: %w", err)
+		}
+		if isAgeEncrypted(bytes.TrimSpace(plain.Bytes())) {
+			return nil, errors.New("identity file is encrypted twice")
+		}
+		return ParseAgeIdentityFile(plain.Bytes(), "")
+	case bytes.HasPrefix(data, []byte("-----BEGIN")):
+		id, err := parseSSHIdentityFile(data, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return []string{id}, nil
+	}
+
+	var ids []string
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// don't echo the line: it may be a mangled secret key
+		if _, err := ageIdentity(line); err != nil {
+			return nil, fmt.Errorf("line %d: malformed identity", n+1)
+		}
+		ids = append(ids, line)
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no identities found")
+	}
+	return ids, nil
+}
+
+// isAgeEncrypted reports whether data is an age file, armored or not.
+func isAgeEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(ageEncryptedPrefix)) || bytes.HasPrefix(data, []byte(armor.Header))
+}
+
+// parseSSHIdentityFile decrypts an SSH private key if needed and returns it
+// as unencrypted PEM.
+func parseSSHIdentityFile(data []byte, passphrase string) (string, error) {
+	_, err := ssh.ParseRawPrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	switch {
+	case err == nil:
+		if _, err := agessh.ParseIdentity(data); err != nil {
+			return "", fmt.Errorf("parse ssh identity: %w", err)
+		}
+		return string(data), nil
+	case !errors.As(err, &missing):
+		return "", fmt.Errorf("parse ssh identity: %w", err)
+	case passphrase == "":
+		return "", ErrAgePassphraseRequired
+	}
+
+	key, err := ssh.ParseRawPrivateKeyWithPassphrase(data, []byte(passphrase))
+	if err != nil {
+		return "", fmt.Errorf("decrypt ssh identity: %w", err)
+	}
+	switch k := key.(type) {
+	case *ed25519.PrivateKey:
+		key = *k
+	case ed25519.PrivateKey, *rsa.PrivateKey:
+	default:
+		return "", fmt.Errorf("unsupported ssh key type %T", key)
+	}
+
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		return "", fmt.Errorf("encode ssh identity: %w", err)
+	}
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+// ageIdentity parses one identity: an age X25519 or hybrid key, or an
+// unencrypted SSH private key.
+func ageIdentity(s string) (age.Identity, error) {
+	s = strings.TrimSpace(s)
+
+	switch {
+	case strings.HasPrefix(s, "AGE-SECRET-KEY-PQ-1"):
+		return age.ParseHybridIdentity(s)
+	case strings.HasPrefix(s, "AGE-SECRET-KEY-1"):
+		return age.ParseX25519Identity(s)
+	case strings.HasPrefix(s, "-----BEGIN"):
+		return agessh.ParseIdentity([]byte(s))
+	default:
+		return nil, errors.New("unknown identity type")
+	}
+}
+
+// ageRecipient parses one recipient: an age X25519 or hybrid public key,
+// or an ssh-ed25519 or ssh-rsa public key.
+func ageRecipient(s string) (age.Recipient, error) {
+	s = strings.TrimSpace(s)
+
+	switch {
+	case strings.HasPrefix(s, "age1pq1"):
+		return age.ParseHybridRecipient(s)
+	case strings.HasPrefix(s, "age1"):
+		return age.ParseX25519Recipient(s)
+	case strings.HasPrefix(s, "ssh-"):
+		return agessh.ParseRecipient(s)
+	default:
+		return nil, errors.New("unknown recipient type")
+	}
+}
@@ -0,0 +1,278 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func ageRoundTrip(t *testing.T, recipient, identity string) {
+	t.Helper()
+
+	var encrypted bytes.Buffer
+	if err := zcrypto.EncryptAgeKey([]string{recipient}, strings.NewReader("payload"), &encrypted); err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	var decrypted bytes.Buffer
+	if err := zcrypto.DecryptAgeKey(identity, &encrypted, &decrypted); err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if decrypted.String() != "payload" {
+		t.Fatalf("got %q, want %q", decrypted.String(), "payload")
+	}
+}
+
+func TestGenerateAgeIdentity(t *testing.T) {
+	id, err := zcrypto.GenerateAgeIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(id, "AGE-SECRET-KEY-1") {
+		t.Fatalf("identity %q has the wrong prefix", id)
+	}
+
+	recipient, err := zcrypto.AgeRecipient(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(recipient, "age1") {
+		t.Fatalf("recipient %q has the wrong prefix", recipient)
+	}
+
+	ageRoundTrip(t, recipient, id)
+}
+
+func TestAgeRecipientHybrid(t *testing.T) {
+	id, err := age.GenerateHybridIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipient, err := zcrypto.AgeRecipient(id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recipient != id.Recipient().String() {
+		t.Fatalf("got %q, want %q", recipient, id.Recipient().String())
+	}
+
+	ageRoundTrip(t, recipient, id.String())
+}
+
+func TestAgeRecipientInvalid(t *testing.T) {
+	for _, s := range []string{"", "age1notakey", "AGE-SECRET-KEY-1NOTAKEY", "-----BEGIN NOTHING-----"} {
+		if _, err := zcrypto.AgeRecipient(s); err == nil {
+			t.Fatalf("AgeRecipient(%q): expected error", s)
+		}
+	}
+}
+
+func TestAgeIdentityFile(t *testing.T) {
+	id, err := zcrypto.GenerateAgeIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, err := zcrypto.AgeRecipient(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := zcrypto.AgeIdentityFile(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(file)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "# created: ") ||
+		lines[1] != "# public key: "+recipient || lines[2] != id {
+		t.Fatalf("unexpected identity file:\n%s", file)
+	}
+
+	ids, err := zcrypto.ParseAgeIdentityFile(file, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != id {
+		t.Fatalf("got %q, want [%q]", ids, id)
+	}
+
+	// DecryptAgeKey takes the whole file too
+	ageRoundTrip(t, recipient, string(file))
+
+	if _, err := zcrypto.AgeIdentityFile("age1notanidentity"); err == nil {
+		t.Fatal("expected error for a recipient")
+	}
+}
+
+func TestParseAgeIdentityFileMultiple(t *testing.T) {
+	a, _ := zcrypto.GenerateAgeIdentity()
+	b, _ := zcrypto.GenerateAgeIdentity()
+	file := "# work key\n" + a + "\n\n# personal key\r\n" + b + "\r\n"
+
+	ids, err := zcrypto.ParseAgeIdentityFile([]byte(file), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != a || ids[1] != b {
+		t.Fatalf("got %q", ids)
+	}
+
+	// either key decrypts with the whole file
+	recipient, _ := zcrypto.AgeRecipient(b)
+	ageRoundTrip(t, recipient, file)
+}
+
+func TestParseAgeIdentityFileInvalid(t *testing.T) {
+	id, _ := zcrypto.GenerateAgeIdentity()
+	last := "Q"
+	if strings.HasSuffix(id, last) {
+		last = "P"
+	}
+	mangled := id[:len(id)-1] + last
+
+	tests := map[string]string{
+		"empty":         "",
+		"only comments": "# nothing here\n",
+		"recipient":     "age1qyqszqgpqyqszqgpqyqszqgpqyqszqgpqyqszqgpqyqszqgpqyqs3290gq",
+		"mangled":       "# key\n" + mangled,
+	}
+
+	for name, file := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := zcrypto.ParseAgeIdentityFile([]byte(file), "")
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if strings.Contains(err.Error(), "AGE-SECRET-KEY") {
+				t.Fatalf("error leaks key material: %v", err)
+			}
+		})
+	}
+}
+
+func TestEncryptedAgeIdentityFile(t *testing.T) {
+	id, _ := zcrypto.GenerateAgeIdentity()
+	file, err := zcrypto.AgeIdentityFile(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	protected, err := zcrypto.EncryptAgeIdentityFile(file, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(protected, []byte(id)) {
+		t.Fatal("protected file contains the identity")
+	}
+
+	if _, err := zcrypto.ParseAgeIdentityFile(protected, ""); !errors.Is(err, zcrypto.ErrAgePassphraseRequired) {
+		t.Fatalf("no passphrase: got %v, want ErrAgePassphraseRequired", err)
+	}
+	if _, err := zcrypto.ParseAgeIdentityFile(protected, "wrong"); err == nil {
+		t.Fatal("expected error for wrong passphrase")
+	}
+
+	ids, err := zcrypto.ParseAgeIdentityFile(protected, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != id {
+		t.Fatalf("got %q, want [%q]", ids, id)
+	}
+
+	armored, err := zcrypto.EncryptAgeIdentityFile(file, "correct horse", zcrypto.WithArmor())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zcrypto.ParseAgeIdentityFile(armored, ""); !errors.Is(err, zcrypto.ErrAgePassphraseRequired) {
+		t.Fatalf("armored, no passphrase: got %v, want ErrAgePassphraseRequired", err)
+	}
+	ids, err = zcrypto.ParseAgeIdentityFile(armored, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != id {
+		t.Fatalf("armored: got %q, want [%q]", ids, id)
+	}
+}
+
+func sshKeyPEM(t *testing.T, key any, passphrase string) (identity []byte, recipient string) {
+	t.Helper()
+
+	var block *pem.Block
+	var err error
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(key, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(block), strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+}
+
+func TestAgeSSHKeys(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		key  any
+	}{
+		{"ed25519", edKey},
+		{"rsa", rsaKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, recipient := sshKeyPEM(t, tt.key, "")
+
+			got, err := zcrypto.AgeRecipient(string(identity))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != recipient {
+				t.Fatalf("recipient = %q, want %q", got, recipient)
+			}
+
+			ageRoundTrip(t, recipient+" user@host", string(identity))
+
+			// a passphrase-protected key is decrypted by ParseAgeIdentityFile
+			protected, _ := sshKeyPEM(t, tt.key, "hunter2")
+			if _, err := zcrypto.ParseAgeIdentityFile(protected, ""); !errors.Is(err, zcrypto.ErrAgePassphraseRequired) {
+				t.Fatalf("no passphrase: got %v, want ErrAgePassphraseRequired", err)
+			}
+			if _, err := zcrypto.ParseAgeIdentityFile(protected, "wrong"); err == nil {
+				t.Fatal("expected error for wrong passphrase")
+			}
+			ids, err := zcrypto.ParseAgeIdentityFile(protected, "hunter2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			ageRoundTrip(t, recipient, ids[0])
+		})
+	}
+}
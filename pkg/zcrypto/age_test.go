@@ -2,6 +2,7 @@ package zcrypto_test
 
 import (
 	"bytes"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -310,3 +311,155 @@ func TestInteropKeyEncryptAgeCLIDecryptZcrypto(t *testing.T) {
 func writeFile(path string, data []byte) error {
 	return os.WriteFile(path, data, 0o600)
 }
+
+func TestAgeArmor(t *testing.T) {
+	id, err := zcrypto.GenerateAgeIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipient, err := zcrypto.AgeRecipient(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		encrypt func(src io.Reader, dst io.Writer, opts ...zcrypto.AgeOption) error
+		decrypt func(src io.Reader, dst io.Writer) error
+	}{
+		{
+			name: "password",
+			encrypt: func(src io.Reader, dst io.Writer, opts ...zcrypto.AgeOption) error {
+				return zcrypto.EncryptAge("hunter2", src, dst, opts...)
+			},
+			decrypt: func(src io.Reader, dst io.Writer) error {
+				return zcrypto.DecryptAge("hunter2", src, dst)
+			},
+		},
+		{
+			name: "key",
+			encrypt: func(src io.Reader, dst io.Writer, opts ...zcrypto.AgeOption) error {
+				return zcrypto.EncryptAgeKey([]string{recipient}, src, dst, opts...)
+			},
+			decrypt: func(src io.Reader, dst io.Writer) error {
+				return zcrypto.DecryptAgeKey(id, src, dst)
+			},
+		},
+	}
+
+	plaintext := strings.Repeat("paste me into a chat window\n", 20)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var armored bytes.Buffer
+			if err := tt.encrypt(strings.NewReader(plaintext), &armored, zcrypto.WithArmor()); err != nil {
+				t.Fatalf("encrypt: %v", err)
+			}
+
+			text := armored.String()
+			if !strings.HasPrefix(text, "-----BEGIN AGE ENCRYPTED FILE-----\n") ||
+				!strings.HasSuffix(text, "-----END AGE ENCRYPTED FILE-----\n") {
+				t.Fatalf("not armored:\n%s", text)
+			}
+			for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+				if len(line) > 64 {
+					t.Fatalf("armored line longer than 64 columns: %q", line)
+				}
+			}
+
+			// armor is detected, including after whitespace picked up in
+			// copy and paste
+			inputs := map[string]string{
+				"armored":           text,
+				"leading newlines":  "\n\r\n" + text,
+				"crlf line endings": strings.ReplaceAll(text, "\n", "\r\n"),
+			}
+			for name, in := range inputs {
+				var decrypted bytes.Buffer
+				if err := tt.decrypt(strings.NewReader(in), &decrypted); err != nil {
+					t.Fatalf("%s: decrypt: %v", name, err)
+				}
+				if decrypted.String() != plaintext {
+					t.Fatalf("%s: got %q, want %q", name, decrypted.String(), plaintext)
+				}
+			}
+
+			// binary output still decrypts
+			var binary, decrypted bytes.Buffer
+			if err := tt.encrypt(strings.NewReader(plaintext), &binary); err != nil {
+				t.Fatalf("encrypt: %v", err)
+			}
+			if !strings.HasPrefix(binary.String(), "age-encryption.org/v1\n") {
+				t.Fatal("output is armored without WithArmor")
+			}
+			if err := tt.decrypt(&binary, &decrypted); err != nil {
+				t.Fatalf("decrypt binary: %v", err)
+			}
+
+			// a corrupted armor body fails
+			corrupt := strings.Replace(text, "\n", "\n!", 2)
+			if err := tt.decrypt(strings.NewReader(corrupt), io.Discard); err == nil {
+				t.Fatal("expected error for corrupted armor")
+			}
+		})
+	}
+}
+
+func TestInteropArmorZcryptoDecryptAgeCLI(t *testing.T) {
+	ageBin := ageCLI(t)
+
+	password := "interop-test-password"
+	plaintext := "armored by zcrypto"
+
+	var encrypted bytes.Buffer
+	if err := zcrypto.EncryptAge(password, strings.NewReader(plaintext), &encrypted, zcrypto.WithArmor()); err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	cmd := exec.Command(ageBin, "-d")
+	cmd.Stdin = &encrypted
+	cmd.Env = append(cmd.Environ(), "AGE_PASSPHRASE="+password)
+
+	out, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			t.Fatalf("age -d: %v\nstderr: %s", err, ee.Stderr)
+		}
+		t.Fatalf("age -d: %v", err)
+	}
+
+	if string(out) != plaintext {
+		t.Fatalf("age CLI decrypted %q, want %q", string(out), plaintext)
+	}
+}
+
+func TestInteropArmorAgeCLIDecryptZcrypto(t *testing.T) {
+	ageBin := ageCLI(t)
+
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("generate identity: %v", err)
+	}
+
+	plaintext := "armored by age CLI"
+
+	cmd := exec.Command(ageBin, "-e", "-a", "-r", id.Recipient().String())
+	cmd.Stdin = strings.NewReader(plaintext)
+
+	encrypted, err := cmd.Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			t.Fatalf("age -e -a -r: %v\nstderr: %s", err, ee.Stderr)
+		}
+		t.Fatalf("age -e -a -r: %v", err)
+	}
+
+	var decrypted bytes.Buffer
+	if err := zcrypto.DecryptAgeKey(id.String(), bytes.NewReader(encrypted), &decrypted); err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+
+	if decrypted.String() != plaintext {
+		t.Fatalf("got %q, want %q", decrypted.String(), plaintext)
+	}
+}
@@ -0,0 +1,334 @@
+package zcrypto
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash"
+	"math/bits"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/blake2b"
+)
+
+// golang.org/x/crypto/argon2 implements Argon2i and Argon2id without a
+// secret or associated data. KeePass databases may use Argon2d, and their
+// parameters may carry both, so this is a portable port of that package
+// (RFC 9106) with the variant, secret and associated data exposed. Use
+// x/crypto/argon2 everywhere else.
+
+// Argon2Variant selects the Argon2 variant Argon2Key computes.
+type Argon2Variant int
+
+// Argon2 variants, as numbered by RFC 9106.
+const (
+	Argon2d  Argon2Variant = 0
+	Argon2id Argon2Variant = 2
+)
+
+// Argon2Params are the costs and optional inputs of Argon2Key.
+type Argon2Params struct {
+	Time    uint32 // passes over memory
+	Memory  uint32 // KiB
+	Threads uint8
+	Secret  []byte // optional secret key, K in RFC 9106
+	Data    []byte // optional associated data, X in RFC 9106
+}
+
+// Argon2Key derives keyLen bytes from password and salt with Argon2d or
+// Argon2id, for reading formats such as KeePass databases that choose the
+// variant or use Argon2's secret and associated data. Costs outside the
+// bounds VerifyPassword accepts are rejected, so parameters read from an
+// untrusted file cannot exhaust memory or CPU. Use DeriveKey or
+// HashPassword for new data.
+func Argon2Key(v Argon2Variant, password, salt []byte, p Argon2Params, keyLen uint32) ([]byte, error) {
+	cfg := hashConfig{time: p.Time, memory: p.Memory, threads: p.Threads}
+	if err := cfg.check(); err != nil {
+		return nil, err
+	}
+	if v == Argon2id && len(p.Secret) == 0 && len(p.Data) == 0 && keyLen >= 4 {
+		return argon2.IDKey(password, salt, p.Time, p.Memory, p.Threads, keyLen), nil
+	}
+	return argon2Key(v, password, salt, p.Secret, p.Data, p.Time, p.Memory, p.Threads, keyLen)
+}
+
+const (
+	argon2Version    = 0x13
+	argon2BlockWords = 128
+	argon2SyncPoints = 4
+)
+
+type argon2Block [argon2BlockWords]uint64
+
+// argon2Key derives keyLen bytes from password and salt with Argon2d or
+// Argon2id. memory is in KiB; it is rounded down to a multiple of
+// 4*threads, and raised to at least 8*threads, as RFC 9106 requires.
+func argon2Key(mode Argon2Variant, password, salt, secret, data []byte, time, memory uint32, threads uint8, keyLen uint32) ([]byte, error) {
+	if mode != Argon2d && mode != Argon2id {
+		return nil, errors.New("unsupported argon2 variant")
+	}
+	if time < 1 || threads < 1 || keyLen < 4 {
+		return nil, errors.New("invalid argon2 parameters")
+	}
+
+	h0 := argon2InitHash(mode, password, salt, secret, data, time, memory, uint32(threads), keyLen)
+	memory = memory / (argon2SyncPoints * uint32(threads)) * (argon2SyncPoints * uint32(threads))
+	if memory < 2*argon2SyncPoints*uint32(threads) {
+		memory = 2 * argon2SyncPoints * uint32(threads)
+	}
+
+	B := argon2InitBlocks(&h0, memory, uint32(threads))
+	argon2ProcessBlocks(B, mode, time, memory, uint32(threads))
+	return argon2ExtractKey(B, memory, uint32(threads), keyLen), nil
+}
+
+func argon2InitHash(mode Argon2Variant, password, salt, secret, data []byte, time, memory, threads, keyLen uint32) [blake2b.Size + 8]byte {
+	var (
+		h0     [blake2b.Size + 8]byte
+		params [24]byte
+	)
+	b2, _ := blake2b.New512(nil)
+	binary.LittleEndian.PutUint32(params[0:4], threads)
+	binary.LittleEndian.PutUint32(params[4:8], keyLen)
+	binary.LittleEndian.PutUint32(params[8:12], memory)
+	binary.LittleEndian.PutUint32(params[12:16], time)
+	binary.LittleEndian.PutUint32(params[16:20], argon2Version)
+	binary.LittleEndian.PutUint32(params[20:24], uint32(mode))
+	b2.Write(params[:])
+	for _, b := range [][]byte{password, salt, secret, data} {
+		var n [4]byte
+		binary.LittleEndian.PutUint32(n[:], uint32(len(b)))
+		b2.Write(n[:])
+		b2.Write(b)
+	}
+	b2.Sum(h0[:0])
+	return h0
+}
+
+func argon2InitBlocks(h0 *[blake2b.Size + 8]byte, memory, threads uint32) []argon2Block {
+	var block0 [1024]byte
+	B := make([]argon2Block, memory)
+	for lane := uint32(0); lane < threads; lane++ {
+		j := lane * (memory / threads)
+		binary.LittleEndian.PutUint32(h0[blake2b.Size+4:], lane)
+		for k := uint32(0); k < 2; k++ {
+			binary.LittleEndian.PutUint32(h0[blake2b.Size:], k)
+			argon2HashLong(block0[:], h0[:])
+			for i := range B[j+k] {
+				B[j+k][i] = binary.LittleEndian.Uint64(block0[i*8:])
+			}
+		}
+	}
+	return B
+}
+
+func argon2ProcessBlocks(B []argon2Block, mode Argon2Variant, time, memory, threads uint32) {
+	lanes := memory / threads
+	segments := lanes / argon2SyncPoints
+
+	processSegment := func(n, slice, lane uint32, wg *sync.WaitGroup) {
+		defer wg.Done()
+
+		// Argon2id takes data-independent addresses for the first half of
+		// the first pass, Argon2d never does
+		independent := mode == Argon2id && n == 0 && slice < argon2SyncPoints/2
+
+		var addresses, in, zero argon2Block
+		if independent {
+			in[0] = uint64(n)
+			in[1] = uint64(lane)
+			in[2] = uint64(slice)
+			in[3] = uint64(memory)
+			in[4] = uint64(time)
+			in[5] = uint64(mode)
+		}
+
+		index := uint32(0)
+		if n == 0 && slice == 0 {
+			// the first two blocks come from argon2InitBlocks
+			index = 2
+			if independent {
+				in[6]++
+				argon2Compress(&addresses, &in, &zero, false)
+				argon2Compress(&addresses, &addresses, &zero, false)
+			}
+		}
+
+		offset := lane*lanes + slice*segments + index
+		for index < segments {
+			prev := offset - 1
+			if index == 0 && slice == 0 {
+				prev += lanes
+			}
+			var random uint64
+			if independent {
+				if index%argon2BlockWords == 0 {
+					in[6]++
+					argon2Compress(&addresses, &in, &zero, false)
+					argon2Compress(&addresses, &addresses, &zero, false)
+				}
+				random = addresses[index%argon2BlockWords]
+			} else {
+				random = B[prev][0]
+			}
+			ref := argon2IndexAlpha(random, lanes, segments, threads, n, slice, lane, index)
+			argon2Compress(&B[offset], &B[prev], &B[ref], true)
+			index, offset = index+1, offset+1
+		}
+	}
+
+	for n := uint32(0); n < time; n++ {
+		for slice := uint32(0); slice < argon2SyncPoints; slice++ {
+			var wg sync.WaitGroup
+			for lane := uint32(0); lane < threads; lane++ {
+				wg.Add(1)
+				go processSegment(n, slice, lane, &wg)
+			}
+			wg.Wait()
+		}
+	}
+}
+
+func argon2ExtractKey(B []argon2Block, memory, threads, keyLen uint32) []byte {
+	lanes := memory / threads
+	for lane := uint32(0); lane < threads-1; lane++ {
+		for i, v := range B[lane*lanes+lanes-1] {
+			B[memory-1][i] ^= v
+		}
+	}
+
+	var block [1024]byte
+	for i, v := range B[memory-1] {
+		binary.LittleEndian.PutUint64(block[i*8:], v)
+	}
+	key := make([]byte, keyLen)
+	argon2HashLong(key, block[:])
+
+	clear(B)
+	Erase(block[:])
+	return key
+}
+
+func argon2IndexAlpha(rand uint64, lanes, segments, threads, n, slice, lane, index uint32) uint32 {
+	refLane := uint32(rand>>32) % threads
+	if n == 0 && slice == 0 {
+		refLane = lane
+	}
+	m, s := 3*segments, ((slice+1)%argon2SyncPoints)*segments
+	if lane == refLane {
+		m += index
+	}
+	if n == 0 {
+		m, s = slice*segments, 0
+		if slice == 0 || lane == refLane {
+			m += index
+		}
+	}
+	if index == 0 || lane == refLane {
+		m--
+	}
+
+	p := rand & 0xFFFFFFFF
+	p = (p * p) >> 32
+	p = (p * uint64(m)) >> 32
+	return refLane*lanes + uint32((uint64(s)+uint64(m)-(p+1))%uint64(lanes))
+}
+
+// argon2HashLong is the variable-length hash H' of RFC 9106.
+func argon2HashLong(out, in []byte) {
+	var b2 hash.Hash
+	if n := len(out); n < blake2b.Size {
+		b2, _ = blake2b.New(n, nil)
+	} else {
+		b2, _ = blake2b.New512(nil)
+	}
+
+	var buf [blake2b.Size]byte
+	binary.LittleEndian.PutUint32(buf[:4], uint32(len(out)))
+	b2.Write(buf[:4])
+	b2.Write(in)
+
+	if len(out) <= blake2b.Size {
+		b2.Sum(out[:0])
+		return
+	}
+
+	outLen := len(out)
+	b2.Sum(buf[:0])
+	b2.Reset()
+	copy(out, buf[:32])
+	out = out[32:]
+	for len(out) > blake2b.Size {
+		b2.Write(buf[:])
+		b2.Sum(buf[:0])
+		copy(out, buf[:32])
+		out = out[32:]
+		b2.Reset()
+	}
+
+	if outLen%blake2b.Size > 0 {
+		r := (outLen+31)/32 - 2
+		b2, _ = blake2b.New(outLen-32*r, nil)
+	}
+	b2.Write(buf[:])
+	b2.Sum(out[:0])
+}
+
+// argon2Compress is the compression function G: out = P(in1 ^ in2) ^ in1
+// ^ in2, XORed into out when xor is set, as passes after the first need.
+func argon2Compress(out, in1, in2 *argon2Block, xor bool) {
+	var t argon2Block
+	for i := range t {
+		t[i] = in1[i] ^ in2[i]
+	}
+
+	var idx [16]int
+	// rows: 8 runs of P over 16 consecutive words
+	for r := 0; r < 8; r++ {
+		for i := range idx {
+			idx[i] = 16*r + i
+		}
+		argon2Round(&t, &idx)
+	}
+	// columns: 8 runs of P over word pairs 16 apart
+	for c := 0; c < 8; c++ {
+		for i := 0; i < 8; i++ {
+			idx[2*i] = 2*c + 16*i
+			idx[2*i+1] = 2*c + 16*i + 1
+		}
+		argon2Round(&t, &idx)
+	}
+
+	for i := range t {
+		v := in1[i] ^ in2[i] ^ t[i]
+		if xor {
+			out[i] ^= v
+		} else {
+			out[i] = v
+		}
+	}
+}
+
+// argon2Round is the BLAKE2b round P over the sixteen words of t at idx.
+func argon2Round(t *argon2Block, idx *[16]int) {
+	g := func(a, b, c, d int) {
+		va, vb, vc, vd := t[idx[a]], t[idx[b]], t[idx[c]], t[idx[d]]
+		va += vb + 2*uint64(uint32(va))*uint64(uint32(vb))
+		vd = bits.RotateLeft64(vd^va, -32)
+		vc += vd + 2*uint64(uint32(vc))*uint64(uint32(vd))
+		vb = bits.RotateLeft64(vb^vc, -24)
+		va += vb + 2*uint64(uint32(va))*uint64(uint32(vb))
+		vd = bits.RotateLeft64(vd^va, -16)
+		vc += vd + 2*uint64(uint32(vc))*uint64(uint32(vd))
+		vb = bits.RotateLeft64(vb^vc, -63)
+		t[idx[a]], t[idx[b]], t[idx[c]], t[idx[d]] = va, vb, vc, vd
+	}
+	g(0, 4, 8, 12)
+	g(1, 5, 9, 13)
+	g(2, 6, 10, 14)
+	g(3, 7, 11, 15)
+	g(0, 5, 10, 15)
+	g(1, 6, 11, 12)
+	g(2, 7, 8, 13)
+	g(3, 4, 9, 14)
+}
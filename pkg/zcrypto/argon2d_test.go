@@ -0,0 +1,88 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+	"golang.org/x/crypto/argon2"
+)
+
+func TestArgon2(t *testing.T) {
+	// RFC 9106 section 5 test vectors
+	password := bytes.Repeat([]byte{0x01}, 32)
+	salt := bytes.Repeat([]byte{0x02}, 16)
+	secret := bytes.Repeat([]byte{0x03}, 8)
+	data := bytes.Repeat([]byte{0x04}, 12)
+
+	tests := []struct {
+		name    string
+		variant zcrypto.Argon2Variant
+		want    string
+	}{
+		{"argon2d", zcrypto.Argon2d, "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb"},
+		{"argon2id", zcrypto.Argon2id, "0d640df58d78766c08c037a34a8b53c9d01ef0452d75b65eb52520e96b01e659"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := zcrypto.Argon2Params{Time: 3, Memory: 32, Threads: 4, Secret: secret, Data: data}
+			got, err := zcrypto.Argon2Key(tt.variant, password, salt, p, 32)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("got %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArgon2MatchesXCrypto(t *testing.T) {
+	password, salt := []byte("correct horse"), []byte("battery staple!!")
+	for _, mem := range []uint32{8, 64, 1000} {
+		got, err := zcrypto.Argon2ForTest(zcrypto.Argon2id, password, salt, nil, nil, 2, mem, 2, 48)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := argon2.IDKey(password, salt, 2, mem, 2, 48)
+		if !bytes.Equal(got, want) {
+			t.Errorf("memory %d: got %x, want %x", mem, got, want)
+		}
+	}
+}
+
+func TestArgon2InvalidParams(t *testing.T) {
+	if _, err := zcrypto.Argon2ForTest(zcrypto.Argon2d, nil, nil, nil, nil, 0, 64, 1, 32); err == nil {
+		t.Error("zero iterations accepted")
+	}
+	if _, err := zcrypto.Argon2ForTest(zcrypto.Argon2d, nil, nil, nil, nil, 1, 64, 0, 32); err == nil {
+		t.Error("zero threads accepted")
+	}
+}
+
+func TestArgon2KeyBounds(t *testing.T) {
+	tests := []struct {
+		name string
+		p    zcrypto.Argon2Params
+		ok   bool
+	}{
+		{"smallest", zcrypto.Argon2Params{Time: 1, Memory: 8, Threads: 1}, true},
+		{"most passes", zcrypto.Argon2Params{Time: 64, Memory: 8, Threads: 1}, true},
+		{"too many passes", zcrypto.Argon2Params{Time: 65, Memory: 8, Threads: 1}, false},
+		{"too little memory", zcrypto.Argon2Params{Time: 1, Memory: 15, Threads: 2}, false},
+		{"too much memory", zcrypto.Argon2Params{Time: 1, Memory: 1<<20 + 1, Threads: 1}, false},
+		{"no threads", zcrypto.Argon2Params{Time: 1, Memory: 8}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, v := range []zcrypto.Argon2Variant{zcrypto.Argon2d, zcrypto.Argon2id} {
+				_, err := zcrypto.Argon2Key(v, []byte("pw"), []byte("saltsalt"), tt.p, 32)
+				if (err == nil) != tt.ok {
+					t.Fatalf("variant %d: err = %v, want ok %v", v, err, tt.ok)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package zcrypto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// info prefixes for hierarchy steps; the version lets the scheme change
+// without colliding with keys derived under this one
+const (
+	childInfo   = "zcrypto/v1/child\x00"
+	purposeInfo = "zcrypto/v1/purpose\x00"
+
+	// maxKeyPathSegment bounds a single path segment, in bytes.
+	maxKeyPathSegment = 255
+
+	// minParentKeySize is the shortest key DeriveChild will derive from.
+	minParentKeySize = 16
+)
+
+// ErrInvalidKeyPath is returned when a key path is not canonical.
+var ErrInvalidKeyPath = errors.New("invalid key path")
+
+// KeyPurpose names what a derived key is used for. Keys for different
+// purposes are independent, so a MAC key never doubles as an encryption
+// key even when both come from the same node.
+type KeyPurpose string
+
+// Key purposes.
+const (
+	// PurposeEncryption keys are for Encrypt, NewSealer and the other
+	// AEAD helpers.
+	PurposeEncryption KeyPurpose = "encryption"
+
+	// PurposeMAC keys are for MAC and VerifyMAC.
+	PurposeMAC KeyPurpose = "mac"
+
+	// PurposeFilename keys are for MACs of names that must be stable
+	// but not readable, such as file and collection names on disk.
+	PurposeFilename KeyPurpose = "filename"
+)
+
+// DeriveChild derives the key at path below parent, a slash-separated
+// list of segments such as "zvault/collections/notes". Each segment is one
+// HKDF-SHA256 step, so deriving "a/b" from parent equals deriving "b"
+// from the key at "a": a subtree's key can be handed out without the
+// rest. The result is KeySize bytes.
+//
+// Paths must be canonical, as ValidateKeyPath checks: no empty, "." or ".."
+// segments, no leading or trailing slash, no control characters or
+// invalid UTF-8, and Unicode in normalization form C. Segments are compared
+// byte for byte, so "Notes" and "notes" are different keys; requiring NFC
+// means "café" typed on different systems is one key rather than two.
+// Normalize user input with norm.NFC.String, from
+// golang.org/x/text/unicode/norm, before building a path from it.
+func DeriveChild(parent []byte, path string) ([]byte, error) {
+	if len(parent) < minParentKeySize {
+		return nil, fmt.Errorf("derive child: parent key shorter than %d bytes", minParentKeySize)
+	}
+	if err := ValidateKeyPath(path); err != nil {
+		return nil, fmt.Errorf("derive child: %w", err)
+	}
+
+	key := parent
+	for i, segment := range strings.Split(path, "/") {
+		next, err := ExpandKey(key, nil, []byte(childInfo+segment))
+		if i > 0 {
+			// intermediate keys are ours to erase, the parent is not
+			Erase(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("derive child: %w", err)
+		}
+		key = next
+	}
+	return key, nil
+}
+
+// DerivePurpose derives the key for purpose from a node key returned by
+// DeriveChild. Use it rather than the node key itself, so keys for
+// different uses never coincide.
+//
+//	node, err := zcrypto.DeriveChild(master, "zvault/collections/notes")
+//	encKey, err := zcrypto.DerivePurpose(node, zcrypto.PurposeEncryption)
+//	nameKey, err := zcrypto.DerivePurpose(node, zcrypto.PurposeFilename)
+func DerivePurpose(key []byte, purpose KeyPurpose) ([]byte, error) {
+	if len(key) < minParentKeySize {
+		return nil, fmt.Errorf("derive purpose: key shorter than %d bytes", minParentKeySize)
+	}
+	if purpose == "" {
+		return nil, errors.New("derive purpose: empty purpose")
+	}
+	k, err := ExpandKey(key, nil, []byte(purposeInfo+string(purpose)))
+	if err != nil {
+		return nil, fmt.Errorf("derive purpose: %w", err)
+	}
+	return k, nil
+}
+
+// ValidateKeyPath checks that path is a canonical key path for
+// DeriveChild. The error wraps ErrInvalidKeyPath and names the problem.
+func ValidateKeyPath(path string) error {
+	if path == "" {
+		return fmt.Errorf("%w: empty path", ErrInvalidKeyPath)
+	}
+	if !utf8.ValidString(path) {
+		return fmt.Errorf("%w: not valid UTF-8", ErrInvalidKeyPath)
+	}
+	for segment := range strings.SplitSeq(path, "/") {
+		switch {
+		case segment == "":
+			return fmt.Errorf("%w: empty segment in %q", ErrInvalidKeyPath, path)
+		case segment == "." || segment == "..":
+			return fmt.Errorf("%w: relative segment in %q", ErrInvalidKeyPath, path)
+		case len(segment) > maxKeyPathSegment:
+			return fmt.Errorf("%w: segment longer than %d bytes", ErrInvalidKeyPath, maxKeyPathSegment)
+		case strings.IndexFunc(segment, unicode.IsControl) >= 0:
+			return fmt.Errorf("%w: control character in %q", ErrInvalidKeyPath, path)
+		case !norm.NFC.IsNormalString(segment):
+			return fmt.Errorf("%w: %q is not in Unicode NFC", ErrInvalidKeyPath, path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+// deriveMaster is the key the test vectors derive from: bytes 0x00..0x1f.
+func deriveMaster() []byte {
+	master := make([]byte, 32)
+	for i := range master {
+		master[i] = byte(i)
+	}
+	return master
+}
+
+func TestDeriveChildVectors(t *testing.T) {
+	// other implementations must reproduce these: one HKDF-SHA256 step per
+	// segment, no salt, info "zcrypto/v1/child\x00" followed by the segment
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"zvault", "1bcfd70cc0d9566e9e14069c426c666d8b017aa62b0c99c9ca51cd2ae0617c32"},
+		{"zvault/collections", "69de5f7cf42882f8b66089ea9b484c0b2444d1317352b99a287e942695991046"},
+		{"zvault/collections/notes", "e59b7b11c3aa65df339e1eff9f31c619d1fa711aea3e1457695cb071dbb6a2a9"},
+		{"zghost/identities/01", "e8a602fe10a61c399e6d10fe913d92d9e6e4cf9efc7f89dafdd409caf03ee1cf"},
+		// NFC: é is U+00E9, the UTF-8 bytes c3 a9
+		{"zvault/collections/caf\u00e9", "674b5c33d1748ef2730a941cf75f00f84643f0c2966833900aa1fed61fb2e082"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := zcrypto.DeriveChild(deriveMaster(), tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("got %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDerivePurposeVectors(t *testing.T) {
+	// info "zcrypto/v1/purpose\x00" followed by the purpose
+	node, err := zcrypto.DeriveChild(deriveMaster(), "zvault/collections/notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		purpose zcrypto.KeyPurpose
+		want    string
+	}{
+		{zcrypto.PurposeEncryption, "905503bc88772db60c9cc04db8951718bd2759189bb9c54793ae8340891d4fc4"},
+		{zcrypto.PurposeMAC, "cc9265210e9784794289bfc2cbed2f4dadd96d301b8e4d838dc96c7dda07200a"},
+		{zcrypto.PurposeFilename, "d00bfabe51b0057870f9a6530429ab2e3dfc4afdc97e6cf844452afd7c649686"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.purpose), func(t *testing.T) {
+			got, err := zcrypto.DerivePurpose(node, tt.purpose)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("got %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeriveChildHierarchy(t *testing.T) {
+	master := deriveMaster()
+
+	full, err := zcrypto.DeriveChild(master, "zvault/collections/notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := zcrypto.DeriveChild(master, "zvault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stepped, err := zcrypto.DeriveChild(sub, "collections/notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(full, stepped) {
+		t.Error("deriving in steps differs from deriving the full path")
+	}
+	if !bytes.Equal(master, deriveMaster()) {
+		t.Error("DeriveChild modified the parent key")
+	}
+
+	// neither segment boundaries nor case may collide
+	distinct := map[string]bool{}
+	for _, p := range []string{"zvault/notes", "zvaultnotes", "zvault/Notes", "zvault/notes/x", "zvault"} {
+		k, err := zcrypto.DeriveChild(master, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if distinct[string(k)] {
+			t.Errorf("path %q collides with another path", p)
+		}
+		distinct[string(k)] = true
+	}
+
+	// a purpose key is not the node key, nor a child named after the purpose
+	enc, err := zcrypto.DerivePurpose(full, zcrypto.PurposeEncryption)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := zcrypto.DeriveChild(full, "encryption")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(enc, full) || bytes.Equal(enc, child) {
+		t.Error("purpose key collides with a node key")
+	}
+}
+
+func TestDeriveChildInvalid(t *testing.T) {
+	paths := []string{
+		"",
+		"/zvault",
+		"zvault/",
+		"zvault//notes",
+		"zvault/./notes",
+		"zvault/../zghost",
+		"zvault/no\x00tes",
+		"zvault/\nnotes",
+		"zvault/\xff",
+		"zvault/cafe\u0301", // NFD: e followed by a combining acute accent
+		"zvault/" + strings.Repeat("a", 256),
+	}
+	for _, p := range paths {
+		if _, err := zcrypto.DeriveChild(deriveMaster(), p); !errors.Is(err, zcrypto.ErrInvalidKeyPath) {
+			t.Errorf("DeriveChild(%q): err = %v, want ErrInvalidKeyPath", p, err)
+		}
+	}
+
+	if err := zcrypto.ValidateKeyPath("zvault/collections/café notes"); err != nil {
+		t.Errorf("ValidateKeyPath rejected a valid path: %v", err)
+	}
+	if _, err := zcrypto.DeriveChild(make([]byte, 8), "zvault"); err == nil {
+		t.Error("DeriveChild accepted a short parent key")
+	}
+	if _, err := zcrypto.DerivePurpose(deriveMaster(), ""); err == nil {
+		t.Error("DerivePurpose accepted an empty purpose")
+	}
+}
@@ -1,17 +1,40 @@
 // Package zcrypto provides encryption primitives for zarlcorp privacy tools.
 //
-// It composes proven Go stdlib and x/crypto primitives — no custom cryptography.
-// All error paths return errors; the package never panics.
+// It builds on Go stdlib and x/crypto primitives wherever they exist. A few
+// pieces have no upstream Go implementation and are written here, against
+// their specifications and published test vectors: Argon2d, SPAKE2 over
+// edwards25519, Shamir secret sharing over GF(256) and the QR encoder.
+//
+// Functions that return an error report every failure through it,
+// including nil or zero-value keys. The password and passphrase generators
+// panic if crypto/rand fails, and methods without an error result, such as
+// Public or Sign, may panic on a nil key.
 //
 // # Features
 //
-//   - AES-256-GCM symmetric encryption
-//   - Argon2id password-based key derivation
-//   - HKDF-SHA256 key expansion
+//   - AES-256-GCM symmetric encryption, with optional associated data
+//   - XChaCha20-Poly1305 cipher suite for CPUs without AES instructions
+//   - Chunked streaming encryption with constant memory
+//   - Argon2id password-based key derivation, and Argon2d for other formats
+//   - PHC-format password hashing with scrypt and bcrypt import
+//   - HKDF-SHA256 key expansion and path-based key hierarchies
+//   - HMAC-SHA256 keyed hashing, SHA-256 and BLAKE2b content hashes
+//   - Fingerprints as hex, words or emoji for out-of-band comparison
+//   - HOTP/TOTP one-time passwords with otpauth:// provisioning URIs
+//   - QR code rendering for terminals and PNG
 //   - Cryptographic random generation
-//   - Secure memory erasure
-//   - File encryption/decryption helpers
-//   - age-compatible password and key-based encryption
+//   - Random, pattern and pronounceable passwords and diceware passphrases
+//     with entropy reporting
+//   - zxcvbn-style password strength estimation
+//   - Shamir secret sharing with transcribable shares
+//   - Ed25519 signatures, compatible with minisign
+//   - X25519 key agreement and anonymous sealed boxes
+//   - SPAKE2 device pairing from a short code
+//   - Secure memory erasure and locked, guarded memory for keys
+//   - File shredding for plaintext temporaries
+//   - Chunked file encryption/decryption helpers
+//   - age-compatible password and key-based encryption, with identity
+//     management and SSH recipients
 //
 // # AES-256-GCM
 //
@@ -30,6 +53,211 @@
 //	    // handle error
 //	}
 //
+// # Associated Data and Reusable Sealers
+//
+// EncryptWithAD binds a ciphertext to context such as a record id. A Sealer
+// caches the cipher for a key and offers allocation-free Seal and Open with
+// caller-managed nonces for hot paths.
+//
+//	s, err := zcrypto.NewSealer(key)
+//	if err != nil {
+//	    // handle error
+//	}
+//	ct, err := s.Encrypt(plaintext, []byte("record-1"))
+//
+// # Cipher Suites
+//
+// XChaCha20-Poly1305 is available with the same shape as Encrypt and
+// Decrypt. EncryptSuite prefixes the ciphertext with a suite identifier
+// byte so DecryptSuite can open output from either suite.
+//
+//	ct, err := zcrypto.EncryptSuite(zcrypto.XChaCha20Poly1305, key, plaintext, nil)
+//	if err != nil {
+//	    // handle error
+//	}
+//	plaintext, err = zcrypto.DecryptSuite(key, ct, nil)
+//
+// # Password Hashing
+//
+// HashPassword produces a self-describing PHC string. VerifyPassword also
+// accepts scrypt and bcrypt hashes imported from other tools, and
+// NeedsRehash reports when a stored hash should be upgraded.
+//
+//	hash, err := zcrypto.HashPassword([]byte("hunter2"))
+//	if err != nil {
+//	    // handle error
+//	}
+//	if err := zcrypto.VerifyPassword([]byte("hunter2"), hash); err != nil {
+//	    // zcrypto.ErrPasswordMismatch or a malformed hash
+//	}
+//	if zcrypto.NeedsRehash(hash) {
+//	    hash, err = zcrypto.HashPassword([]byte("hunter2"))
+//	}
+//
+// # One-Time Passwords
+//
+// OTPKey generates and ValidateTOTP checks RFC 6238 codes with a skew
+// window. Persist the returned step and pass it back with WithUsedCounter
+// to reject replays. Keys round-trip through otpauth:// URIs, and
+// ParseMigrationURI imports Google Authenticator exports.
+//
+//	k, err := zcrypto.ParseOTPAuthURI("otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP")
+//	if err != nil {
+//	    // handle error
+//	}
+//	step, err := zcrypto.ValidateTOTP(k, code, time.Now(), zcrypto.WithUsedCounter(last))
+//
+// # QR Codes
+//
+// NewQRCode encodes any string, and OTPKey.QRCode a provisioning URI, for
+// display as Unicode half blocks in a terminal or export as PNG.
+//
+//	q, err := k.QRCode()
+//	if err != nil {
+//	    // handle error
+//	}
+//	fmt.Print(q.HalfBlocks(true))
+//	err = q.WritePNG(f, 8)
+//
+// # Passwords and Passphrases
+//
+// GeneratePassphrase draws words from the embedded EFF large wordlist.
+// PasswordEntropy and PassphraseEntropy report the strength in bits of the
+// generators' output for the same arguments.
+//
+//	p := zcrypto.GeneratePassphrase(6, zcrypto.WithCapitalize(), zcrypto.WithDigit())
+//	bits := zcrypto.PassphraseEntropy(6, zcrypto.WithCapitalize(), zcrypto.WithDigit())
+//
+// GeneratePassword follows a site's password policy through per-class
+// minimums, WithSymbols, WithMaxLength and WithoutAmbiguous. WithPattern
+// fills a template such as "Cvccvc-99", and WithPronounceable alternates
+// consonants and vowels.
+//
+//	pw := zcrypto.GeneratePassword(16, zcrypto.WithMaxLength(12), zcrypto.WithSymbols("!#$"))
+//	pin := zcrypto.GeneratePassword(0, zcrypto.WithPattern("9999-9999"))
+//
+// # Password Strength
+//
+// EstimateStrength scores a chosen password against common passwords,
+// words, names, keyboard patterns, repeats, sequences and dates, with
+// feedback suitable for showing to the user.
+//
+//	s := zcrypto.EstimateStrength(password, username)
+//	if s.Score < 3 {
+//	    fmt.Println(s.Warning, s.Suggestions, s.CrackTimeDisplay())
+//	}
+//
+// # Secret Sharing
+//
+// SplitSecret splits a secret into n shares, any k of which recover it with
+// CombineShares. Shares are checksummed base32 meant to be written down, so
+// a recovery key can be escrowed with trusted people instead of a server.
+//
+//	shares, err := zcrypto.SplitSecret(recoveryKey, 5, 3)
+//	// hand out shares; later, with any three of them:
+//	key, err := zcrypto.CombineShares([]string{s1, s4, s5})
+//
+// # Signatures
+//
+// SigningKey signs messages and streams with Ed25519; VerifyKey checks
+// them. SignMinisign writes minisign signature files, so anything signed
+// here can be checked with `minisign -Vm file -P <key>`, and VerifyMinisign
+// accepts signatures made by minisign itself.
+//
+//	k, err := zcrypto.GenerateSigningKey()
+//	sig, err := k.SignMinisign(blocklist, "file:blocklist.txt")
+//	comment, err := k.Public().VerifyMinisign(blocklist, sig)
+//
+// # Key Exchange and Pairing
+//
+// X25519Key agrees keys with another device's X25519PublicKey, and Seal
+// encrypts to a public key without revealing the sender, compatible with
+// libsodium sealed boxes. To meet a new device with no keys at all,
+// Pairing runs SPAKE2 from a short code one device shows and the user
+// types into the other; send the X25519 public keys over the result.
+//
+//	code := zcrypto.GeneratePairingCode()
+//	p, err := zcrypto.NewPairing(zcrypto.PairingInitiator, code)
+//	// send p.Message(), receive the peer's message m
+//	confirm, err := p.Exchange(m)
+//	// send confirm, receive the peer's confirmation c
+//	key, err := p.Confirm(c)
+//
+// # Secret Memory
+//
+// SecretBuffer keeps keys outside the Go heap: on Linux in locked memory
+// between guard pages, excluded from core dumps and optionally read-only.
+// DeriveKeyInto and ExpandKeyInto write keys straight into one.
+//
+//	key, err := zcrypto.NewSecretBuffer(zcrypto.KeySize)
+//	defer key.Close()
+//	salt, err := zcrypto.DeriveKeyInto(key, password, nil)
+//	err = key.ReadOnly()
+//
+// # Key Hierarchies
+//
+// DeriveChild derives a subkey at a slash-separated path, one HKDF step
+// per segment, so every tool reaches the same key for the same path and a
+// subtree's key can be handed out on its own. Paths must be in Unicode NFC,
+// so the same name typed on different systems reaches the same key.
+// DerivePurpose then splits a
+// node into independent keys for encryption, MACs and filename hashing.
+//
+//	node, err := zcrypto.DeriveChild(master, "zvault/collections/notes")
+//	encKey, err := zcrypto.DerivePurpose(node, zcrypto.PurposeEncryption)
+//	nameKey, err := zcrypto.DerivePurpose(node, zcrypto.PurposeFilename)
+//	name := zcrypto.MAC(nameKey, []byte("groceries"))
+//
+// # Keyed Hashes and Fingerprints
+//
+// MAC and VerifyMAC authenticate data, or name sensitive values without
+// revealing them, under a secret key. Sum and SumReader hash content with
+// SHA-256 or BLAKE2b-256. A Fingerprint shows a key or file as short hex,
+// EFF words or emoji, for two people to compare before trusting it.
+//
+//	mac := zcrypto.MAC(key, []byte("user@example.com"))
+//	err := zcrypto.VerifyMAC(key, []byte("user@example.com"), mac)
+//
+//	f := zcrypto.NewFingerprint(recipientKey)
+//	fmt.Println(f, f.Words(6), f.Emoji(7))
+//
+// # Shredding Files
+//
+// Shred overwrites a file with random data, syncing each pass, renames it
+// to random names and removes it. ShredFS does the same through a
+// zfilesystem.ReadWriteFileFS, so a MemFS can stand in for tests. On SSDs
+// and copy-on-write or journaling filesystems old blocks may survive;
+// full-disk encryption remains the real protection.
+//
+//	err := zcrypto.Shred(tmpPath, zcrypto.WithPasses(1))
+//
+// # Argon2 For Other Formats
+//
+// Argon2Key computes Argon2d or Argon2id with RFC 9106's secret and
+// associated data inputs, which x/crypto/argon2 does not offer, for reading
+// formats such as KeePass databases; zimport uses it. It accepts the same
+// costs as VerifyPassword, so parameters from an untrusted file cannot
+// exhaust memory or CPU.
+//
+//	key, err := zcrypto.Argon2Key(zcrypto.Argon2d, password, salt,
+//	    zcrypto.Argon2Params{Time: 10, Memory: 64 * 1024, Threads: 2}, zcrypto.KeySize)
+//
+// # Streaming Encryption
+//
+// NewEncryptWriter and NewDecryptReader encrypt arbitrarily large streams in
+// fixed-size authenticated chunks. Truncation and reordering are detected.
+//
+//	w, err := zcrypto.NewEncryptWriter(key, dst)
+//	if err != nil {
+//	    // handle error
+//	}
+//	if _, err := io.Copy(w, src); err != nil {
+//	    // handle error
+//	}
+//	if err := w.Close(); err != nil {
+//	    // handle error
+//	}
+//
 // # Age Password Encryption
 //
 // Password-based age encryption produces output compatible with the age CLI.
@@ -46,8 +274,8 @@
 //
 // # Age Key Encryption
 //
-// Key-based age encryption uses X25519 public keys and is compatible with
-// age -r and age -i.
+// Key-based age encryption uses X25519 public keys, or ssh-ed25519 and
+// ssh-rsa keys, and is compatible with age -r and age -i.
 //
 //	err := zcrypto.EncryptAgeKey([]string{"age1..."}, src, dst)
 //	if err != nil {
@@ -58,4 +286,23 @@
 //	if err != nil {
 //	    // handle error
 //	}
+//
+// WithArmor makes either kind of encryption write ASCII armor, like
+// age -a, for output that must survive a paste into chat or a note field.
+// Decryption detects armored input by itself.
+//
+//	err := zcrypto.EncryptAge("passphrase", src, dst, zcrypto.WithArmor())
+//
+// # Age Identities
+//
+// GenerateAgeIdentity creates a key and AgeRecipient derives its public
+// half. AgeIdentityFile writes the age-keygen format, which
+// EncryptAgeIdentityFile can protect with a passphrase;
+// ParseAgeIdentityFile reads either, as well as SSH private keys.
+//
+//	id, err := zcrypto.GenerateAgeIdentity()
+//	recipient, err := zcrypto.AgeRecipient(id)
+//	file, err := zcrypto.AgeIdentityFile(id)
+//	protected, err := zcrypto.EncryptAgeIdentityFile(file, passphrase)
+//	ids, err := zcrypto.ParseAgeIdentityFile(protected, passphrase)
 package zcrypto
@@ -1,32 +1,85 @@
 package zcrypto
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 )
 
+const (
+	// fileMagic starts every file written by EncryptFile. Files from before
+	// the header was introduced begin with a random 12-byte nonce instead.
+	// One starting with the magic and an unknown version byte is still read
+	// as legacy, so only a nonce matching the whole 7-byte header, a 1 in
+	// 2^56 chance, is misread.
+	fileMagic = "zcfile"
+
+	// fileVersionStream marks a body in the chunked stream format.
+	fileVersionStream byte = 2
+)
+
 // EncryptFile encrypts the contents of src and writes the result to dst.
-// Uses AES-256-GCM with the given 32-byte key.
+// Uses AES-256-GCM with the given 32-byte key in fixed-size chunks, so
+// memory use is constant regardless of the file size. The output starts
+// with a short versioned header followed by a NewEncryptWriter stream.
 func EncryptFile(key []byte, src io.Reader, dst io.Writer) error {
-	plaintext, err := io.ReadAll(src)
-	if err != nil {
-		return fmt.Errorf("read source: %w", err)
+	if len(key) != KeySize {
+		return fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	if _, err := dst.Write(append([]byte(fileMagic), fileVersionStream)); err != nil {
+		return fmt.Errorf("write header: %w", err)
 	}
 
-	ciphertext, err := Encrypt(key, plaintext)
+	w, err := NewEncryptWriter(key, dst)
 	if err != nil {
 		return fmt.Errorf("encrypt: %w", err)
 	}
 
-	if _, err := dst.Write(ciphertext); err != nil {
-		return fmt.Errorf("write output: %w", err)
+	if _, err := io.Copy(w, src); err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("encrypt: %w", err)
 	}
 
 	return nil
 }
 
-// DecryptFile decrypts a file encrypted by EncryptFile.
+// DecryptFile decrypts a file encrypted by EncryptFile, including files
+// written by earlier versions as a single AES-256-GCM message. Chunked files
+// are decrypted with constant memory: each chunk is written to dst as soon
+// as it is authenticated, so on error dst may already hold a prefix of the
+// plaintext, which should be discarded.
 func DecryptFile(key []byte, src io.Reader, dst io.Writer) error {
+	header := make([]byte, len(fileMagic)+1)
+	n, err := io.ReadFull(src, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("read source: %w", err)
+	}
+	header = header[:n]
+
+	magic, ok := bytes.CutPrefix(header, []byte(fileMagic))
+	if !ok || len(magic) != 1 || magic[0] != fileVersionStream {
+		return decryptLegacyFile(key, io.MultiReader(bytes.NewReader(header), src), dst)
+	}
+
+	r, err := NewDecryptReader(key, src)
+	if err != nil {
+		return fmt.Errorf("decrypt: %w", err)
+	}
+
+	if _, err := io.Copy(dst, r); err != nil {
+		return fmt.Errorf("decrypt: %w", err)
+	}
+
+	return nil
+}
+
+// decryptLegacyFile decrypts a headerless file sealed in one piece.
+func decryptLegacyFile(key []byte, src io.Reader, dst io.Writer) error {
 	ciphertext, err := io.ReadAll(src)
 	if err != nil {
 		return fmt.Errorf("read source: %w", err)
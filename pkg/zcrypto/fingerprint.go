@@ -0,0 +1,132 @@
+package zcrypto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+const (
+	// fingerprintGroups is how many 4-digit hex groups String shows: 80 bits,
+	// beyond what an attacker can grind a lookalike key or file for
+	fingerprintGroups = 5
+
+	// maxFingerprintWords is how many EFF words 256 bits can fill
+	maxFingerprintWords = 19
+)
+
+// Fingerprint is a digest of a key or file for people to compare out of
+// band, read aloud or side by side, before trusting it. It is the SHA-256
+// of the content, so Hex matches sha256sum.
+type Fingerprint [sha256.Size]byte
+
+// NewFingerprint returns the fingerprint of data, such as a public key.
+func NewFingerprint(data []byte) Fingerprint {
+	return sha256.Sum256(data)
+}
+
+// FingerprintReader returns the fingerprint of everything read from r, in
+// constant memory.
+func FingerprintReader(r io.Reader) (Fingerprint, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return Fingerprint{}, fmt.Errorf("read content: %w", err)
+	}
+	var f Fingerprint
+	h.Sum(f[:0])
+	return f, nil
+}
+
+// Hex returns the full fingerprint in lowercase hex.
+func (f Fingerprint) Hex() string {
+	return hex.EncodeToString(f[:])
+}
+
+// String returns a short form for display, such as "3A7F 91C2 0B44 E8D1
+// 5C06".
+func (f Fingerprint) String() string {
+	s := strings.ToUpper(hex.EncodeToString(f[:2*fingerprintGroups]))
+	groups := make([]string, fingerprintGroups)
+	for i := range groups {
+		groups[i] = s[4*i : 4*i+4]
+	}
+	return strings.Join(groups, " ")
+}
+
+// Words returns the fingerprint as n words from the EFF large wordlist,
+// about 12.9 bits each, so six words carry roughly as much as String. n
+// is clamped to 1..19.
+func (f Fingerprint) Words(n int) string {
+	n = min(max(n, 1), maxFingerprintWords)
+
+	words := effWords()
+	x := new(big.Int).SetBytes(f[:])
+	base := big.NewInt(int64(len(words)))
+	idx := new(big.Int)
+
+	out := make([]string, n)
+	for i := range out {
+		x.DivMod(x, base, idx)
+		out[i] = words[idx.Int64()]
+	}
+	return strings.Join(out, " ")
+}
+
+// Emoji returns the fingerprint as n emoji, 6 bits each, from the table
+// Matrix uses for short authentication strings; EmojiNames gives their
+// names for reading aloud. n is clamped to 1..42.
+func (f Fingerprint) Emoji(n int) string {
+	idx := f.emojiIndexes(n)
+	out := make([]string, len(idx))
+	for i, j := range idx {
+		out[i] = fingerprintEmoji[j].emoji
+	}
+	return strings.Join(out, " ")
+}
+
+// EmojiNames returns the English names of the emoji in Emoji(n).
+func (f Fingerprint) EmojiNames(n int) []string {
+	idx := f.emojiIndexes(n)
+	out := make([]string, len(idx))
+	for i, j := range idx {
+		out[i] = fingerprintEmoji[j].name
+	}
+	return out
+}
+
+// emojiIndexes splits the leading bits of f into n 6-bit table indexes.
+func (f Fingerprint) emojiIndexes(n int) []int {
+	n = min(max(n, 1), len(f)*8/6)
+
+	idx := make([]int, n)
+	for i := range idx {
+		bit := 6 * i
+		v := int(f[bit/8])<<8 | int(f[bit/8+1])
+		idx[i] = v >> (10 - bit%8) & 0x3f
+	}
+	return idx
+}
+
+// fingerprintEmoji is the 64-entry emoji table from the Matrix
+// specification, chosen to be distinct across fonts and easy to name.
+var fingerprintEmoji = [64]struct{ emoji, name string }{
+	{"🐶", "Dog"}, {"🐱", "Cat"}, {"🦁", "Lion"}, {"🐎", "Horse"},
+	{"🦄", "Unicorn"}, {"🐷", "Pig"}, {"🐘", "Elephant"}, {"🐰", "Rabbit"},
+	{"🐼", "Panda"}, {"🐓", "Rooster"}, {"🐧", "Penguin"}, {"🐢", "Turtle"},
+	{"🐟", "Fish"}, {"🐙", "Octopus"}, {"🦋", "Butterfly"}, {"🌷", "Flower"},
+	{"🌳", "Tree"}, {"🌵", "Cactus"}, {"🍄", "Mushroom"}, {"🌏", "Globe"},
+	{"🌙", "Moon"}, {"☁️", "Cloud"}, {"🔥", "Fire"}, {"🍌", "Banana"},
+	{"🍎", "Apple"}, {"🍓", "Strawberry"}, {"🌽", "Corn"}, {"🍕", "Pizza"},
+	{"🎂", "Cake"}, {"❤️", "Heart"}, {"😀", "Smiley"}, {"🤖", "Robot"},
+	{"🎩", "Hat"}, {"👓", "Glasses"}, {"🔧", "Spanner"}, {"🎅", "Santa"},
+	{"👍", "Thumbs Up"}, {"☂️", "Umbrella"}, {"⌛", "Hourglass"}, {"⏰", "Clock"},
+	{"🎁", "Gift"}, {"💡", "Light Bulb"}, {"📕", "Book"}, {"✏️", "Pencil"},
+	{"📎", "Paperclip"}, {"✂️", "Scissors"}, {"🔒", "Lock"}, {"🔑", "Key"},
+	{"🔨", "Hammer"}, {"☎️", "Telephone"}, {"🏁", "Flag"}, {"🚂", "Train"},
+	{"🚲", "Bicycle"}, {"✈️", "Aeroplane"}, {"🚀", "Rocket"}, {"🏆", "Trophy"},
+	{"⚽", "Ball"}, {"🎸", "Guitar"}, {"🎺", "Trumpet"}, {"🔔", "Bell"},
+	{"⚓", "Anchor"}, {"🎧", "Headphones"}, {"📁", "Folder"}, {"📌", "Pin"},
+}
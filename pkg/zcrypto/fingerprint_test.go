@@ -0,0 +1,84 @@
+package zcrypto_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func TestFingerprint(t *testing.T) {
+	f := zcrypto.NewFingerprint([]byte("hello"))
+
+	// printf hello | sha256sum
+	if got, want := f.Hex(), "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; got != want {
+		t.Errorf("Hex = %s, want %s", got, want)
+	}
+	if got, want := f.String(), "2CF2 4DBA 5FB0 A30E 26E8"; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+
+	r, err := zcrypto.FingerprintReader(strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != f {
+		t.Errorf("FingerprintReader = %s, want %s", r, f)
+	}
+
+	if _, err := zcrypto.FingerprintReader(iotest.ErrReader(errors.New("disk gone"))); err == nil {
+		t.Error("FingerprintReader with failing reader succeeded")
+	}
+}
+
+func TestFingerprintWords(t *testing.T) {
+	var zero zcrypto.Fingerprint
+	if got := zero.Words(3); got != "abacus abacus abacus" {
+		t.Errorf("zero Words = %q", got)
+	}
+
+	f := zcrypto.NewFingerprint([]byte("hello"))
+	tests := []struct {
+		n, want int
+	}{
+		{6, 6},
+		{0, 1},
+		{-1, 1},
+		{19, 19},
+		{100, 19},
+	}
+	for _, tt := range tests {
+		if got := len(strings.Fields(f.Words(tt.n))); got != tt.want {
+			t.Errorf("Words(%d) has %d words, want %d", tt.n, got, tt.want)
+		}
+	}
+
+	if !strings.HasPrefix(f.Words(8), f.Words(4)+" ") {
+		t.Error("Words(4) is not a prefix of Words(8)")
+	}
+	if f.Words(6) == zcrypto.NewFingerprint([]byte("hellp")).Words(6) {
+		t.Error("different content gave the same words")
+	}
+}
+
+func TestFingerprintEmoji(t *testing.T) {
+	// leading bits 000001 000010 000011 111111
+	f := zcrypto.Fingerprint{0x04, 0x20, 0xff}
+
+	if got, want := f.Emoji(4), "🐱 🦁 🐎 📌"; got != want {
+		t.Errorf("Emoji = %q, want %q", got, want)
+	}
+	names := f.EmojiNames(4)
+	if want := []string{"Cat", "Lion", "Horse", "Pin"}; strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("EmojiNames = %v, want %v", names, want)
+	}
+
+	if got := len(strings.Fields(f.Emoji(100))); got != 42 {
+		t.Errorf("Emoji(100) has %d emoji, want 42", got)
+	}
+	if got := len(f.EmojiNames(0)); got != 1 {
+		t.Errorf("EmojiNames(0) has %d names, want 1", got)
+	}
+}
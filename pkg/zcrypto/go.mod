@@ -4,10 +4,14 @@ go 1.26.0
 
 require (
 	filippo.io/age v1.3.1
+	filippo.io/edwards25519 v1.1.0
+	github.com/zarlcorp/core/pkg/zfilesystem v0.1.0
 	golang.org/x/crypto v0.48.0
+	golang.org/x/sys v0.41.0
+	golang.org/x/text v0.34.0
 )
 
 require (
 	filippo.io/hpke v0.4.0 // indirect
-	golang.org/x/sys v0.41.0 // indirect
+	github.com/zarlcorp/core/pkg/zsync v0.1.0 // indirect
 )
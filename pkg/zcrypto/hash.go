@@ -0,0 +1,103 @@
+package zcrypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// MACSize is the length of a MAC returned by MAC.
+const MACSize = sha256.Size
+
+// ErrInvalidMAC is returned by VerifyMAC when a MAC does not match.
+var ErrInvalidMAC = errors.New("invalid MAC")
+
+// MAC returns the HMAC-SHA256 of data under key. Use a random 32-byte key,
+// or one derived with ExpandKey, and keep it secret: anyone holding it can
+// forge MACs. MACs make safe lookup names for sensitive values such as
+// cache keys, without revealing them.
+func MAC(key, data []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+// NewMAC returns an HMAC-SHA256 hash under key, for MACs over streams.
+func NewMAC(key []byte) hash.Hash {
+	return hmac.New(sha256.New, key)
+}
+
+// VerifyMAC checks mac against data in constant time.
+func VerifyMAC(key, data, mac []byte) error {
+	if !hmac.Equal(MAC(key, data), mac) {
+		return ErrInvalidMAC
+	}
+	return nil
+}
+
+// HashAlgorithm identifies a content hash.
+type HashAlgorithm byte
+
+// Content hash algorithms.
+const (
+	// SHA256 is SHA-256, as printed by sha256sum.
+	SHA256 HashAlgorithm = 1
+
+	// BLAKE2b256 is BLAKE2b with a 256-bit digest, as printed by
+	// `b2sum -l 256`. It is faster than SHA-256 on CPUs without SHA
+	// instructions.
+	BLAKE2b256 HashAlgorithm = 2
+)
+
+// String returns the algorithm's name.
+func (a HashAlgorithm) String() string {
+	switch a {
+	case SHA256:
+		return "SHA-256"
+	case BLAKE2b256:
+		return "BLAKE2b-256"
+	default:
+		return fmt.Sprintf("HashAlgorithm(%d)", byte(a))
+	}
+}
+
+// NewHash returns a new hash computing alg.
+func NewHash(alg HashAlgorithm) (hash.Hash, error) {
+	switch alg {
+	case SHA256:
+		return sha256.New(), nil
+	case BLAKE2b256:
+		h, _ := blake2b.New256(nil)
+		return h, nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm %d", byte(alg))
+	}
+}
+
+// Sum returns the alg digest of data.
+func Sum(alg HashAlgorithm, data []byte) ([]byte, error) {
+	h, err := NewHash(alg)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// SumReader returns the alg digest of everything read from r, in constant
+// memory.
+func SumReader(alg HashAlgorithm, r io.Reader) ([]byte, error) {
+	h, err := NewHash(alg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, fmt.Errorf("read content: %w", err)
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,98 @@
+package zcrypto_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func TestMAC(t *testing.T) {
+	// RFC 4231 test case 2
+	key := []byte("Jefe")
+	data := []byte("what do ya want for nothing?")
+	want := "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+
+	mac := zcrypto.MAC(key, data)
+	if got := hex.EncodeToString(mac); got != want {
+		t.Fatalf("MAC = %s, want %s", got, want)
+	}
+	if len(mac) != zcrypto.MACSize {
+		t.Errorf("len = %d, want %d", len(mac), zcrypto.MACSize)
+	}
+
+	m := zcrypto.NewMAC(key)
+	m.Write(data[:10])
+	m.Write(data[10:])
+	if got := hex.EncodeToString(m.Sum(nil)); got != want {
+		t.Errorf("NewMAC = %s, want %s", got, want)
+	}
+
+	if err := zcrypto.VerifyMAC(key, data, mac); err != nil {
+		t.Errorf("VerifyMAC: %v", err)
+	}
+
+	tampered := append([]byte(nil), mac...)
+	tampered[0] ^= 1
+	tests := []struct {
+		name      string
+		key, data []byte
+		mac       []byte
+	}{
+		{"tampered MAC", key, data, tampered},
+		{"truncated MAC", key, data, mac[:16]},
+		{"wrong key", []byte("Jeff"), data, mac},
+		{"wrong data", key, []byte("what do ya want for nothing!"), mac},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := zcrypto.VerifyMAC(tt.key, tt.data, tt.mac); !errors.Is(err, zcrypto.ErrInvalidMAC) {
+				t.Errorf("err = %v, want ErrInvalidMAC", err)
+			}
+		})
+	}
+}
+
+func TestSum(t *testing.T) {
+	tests := []struct {
+		alg  zcrypto.HashAlgorithm
+		name string
+		want string // printf hello | sha256sum, b2sum -l 256
+	}{
+		{zcrypto.SHA256, "SHA-256", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+		{zcrypto.BLAKE2b256, "BLAKE2b-256", "324dcf027dd4a30a932c441f365a25e86b173defa4b8e58948253471b81b72cf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.alg.String() != tt.name {
+				t.Errorf("String = %q, want %q", tt.alg, tt.name)
+			}
+
+			sum, err := zcrypto.Sum(tt.alg, []byte("hello"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(sum); got != tt.want {
+				t.Errorf("Sum = %s, want %s", got, tt.want)
+			}
+
+			sum, err = zcrypto.SumReader(tt.alg, strings.NewReader("hello"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(sum); got != tt.want {
+				t.Errorf("SumReader = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := zcrypto.Sum(zcrypto.HashAlgorithm(9), nil); err == nil {
+		t.Error("Sum with unknown algorithm succeeded")
+	}
+	if _, err := zcrypto.SumReader(zcrypto.SHA256, iotest.ErrReader(errors.New("disk gone"))); err == nil {
+		t.Error("SumReader with failing reader succeeded")
+	}
+}
@@ -47,3 +47,41 @@ func ExpandKey(secret, salt, info []byte) ([]byte, error) {
 
 	return key, nil
 }
+
+// DeriveKeyInto is like DeriveKey but writes the key into dst, deriving
+// dst.Len() bytes; a KeySize buffer receives the same key DeriveKey
+// returns. Argon2id's own copy of the output is erased.
+func DeriveKeyInto(dst *SecretBuffer, password, salt []byte) (usedSalt []byte, err error) {
+	out, err := dst.writable()
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+
+	if salt == nil {
+		salt = make([]byte, SaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("generate salt: %w", err)
+		}
+	}
+
+	k := argon2.IDKey(password, salt, argon2Time, argon2Memory, argon2Threads, uint32(len(out)))
+	copy(out, k)
+	Erase(k)
+	return salt, nil
+}
+
+// ExpandKeyInto is like ExpandKey but writes the key into dst, expanding
+// dst.Len() bytes; a KeySize buffer receives the same key ExpandKey
+// returns.
+func ExpandKeyInto(dst *SecretBuffer, secret, salt, info []byte) error {
+	out, err := dst.writable()
+	if err != nil {
+		return fmt.Errorf("expand key: %w", err)
+	}
+
+	r := hkdf.New(sha256.New, secret, salt, info)
+	if _, err := io.ReadFull(r, out); err != nil {
+		return fmt.Errorf("expand key: %w", err)
+	}
+	return nil
+}
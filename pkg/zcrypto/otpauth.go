@@ -0,0 +1,335 @@
+package zcrypto
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// URI returns the key as an otpauth:// provisioning URI, the format
+// authenticator apps read from QR codes:
+//
+//	otpauth://totp/Issuer:account?secret=...&issuer=Issuer
+//
+// Algorithm, digits and period are only included when they differ from the
+// defaults, since some apps reject URIs that spell them out.
+func (k OTPKey) URI() (string, error) {
+	raw, err := decodeSecret(k.Secret)
+	if err != nil {
+		return "", fmt.Errorf("decode secret: %w", err)
+	}
+
+	typ := k.Type
+	if typ == "" {
+		typ = TOTP
+	}
+	if typ != TOTP && typ != HOTP {
+		return "", fmt.Errorf("unsupported OTP type %q", k.Type)
+	}
+
+	if _, err := k.hash(); err != nil {
+		return "", err
+	}
+
+	label := url.PathEscape(k.Account)
+	if k.Issuer != "" {
+		label = url.PathEscape(k.Issuer) + ":" + label
+	}
+
+	q := url.Values{}
+	q.Set("secret", otpBase32.EncodeToString(raw))
+	if k.Issuer != "" {
+		q.Set("issuer", k.Issuer)
+	}
+	if alg := OTPAlgorithm(strings.ToUpper(string(k.Algorithm))); alg != "" && alg != OTPSHA1 {
+		q.Set("algorithm", string(alg))
+	}
+	if d := k.digits(); d != otpDefaultDigits {
+		q.Set("digits", strconv.Itoa(d))
+	}
+	switch typ {
+	case TOTP:
+		if p := k.period(); p != totpPeriod {
+			q.Set("period", strconv.Itoa(p))
+		}
+	case HOTP:
+		q.Set("counter", strconv.FormatUint(k.Counter, 10))
+	}
+
+	// spaces as %20, which every authenticator app decodes correctly
+	query := strings.ReplaceAll(q.Encode(), "+", "%20")
+	return "otpauth://" + string(typ) + "/" + label + "?" + query, nil
+}
+
+// ParseOTPAuthURI parses an otpauth://totp/... or otpauth://hotp/...
+// provisioning URI. The issuer parameter takes precedence over an issuer
+// prefix in the label.
+func ParseOTPAuthURI(uri string) (OTPKey, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return OTPKey{}, fmt.Errorf("parse uri: %w", err)
+	}
+	if u.Scheme != "otpauth" {
+		return OTPKey{}, fmt.Errorf("unsupported uri scheme %q", u.Scheme)
+	}
+
+	k := OTPKey{Type: OTPType(strings.ToLower(u.Host))}
+	if k.Type != TOTP && k.Type != HOTP {
+		return OTPKey{}, fmt.Errorf("unsupported OTP type %q", u.Host)
+	}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		k.Issuer = strings.TrimSpace(issuer)
+		k.Account = strings.TrimSpace(account)
+	} else {
+		k.Account = label
+	}
+
+	q := u.Query()
+	if issuer := q.Get("issuer"); issuer != "" {
+		k.Issuer = issuer
+	}
+
+	k.Secret = q.Get("secret")
+	if k.Secret == "" {
+		return OTPKey{}, errors.New("missing secret")
+	}
+	if _, err := decodeSecret(k.Secret); err != nil {
+		return OTPKey{}, fmt.Errorf("decode secret: %w", err)
+	}
+
+	if alg := q.Get("algorithm"); alg != "" {
+		k.Algorithm = OTPAlgorithm(strings.ToUpper(alg))
+		if _, err := k.hash(); err != nil {
+			return OTPKey{}, err
+		}
+	}
+
+	if d := q.Get("digits"); d != "" {
+		if k.Digits, err = strconv.Atoi(d); err != nil || k.Digits < 6 || k.Digits > 10 {
+			return OTPKey{}, fmt.Errorf("invalid digits %q", d)
+		}
+	}
+
+	switch k.Type {
+	case TOTP:
+		if p := q.Get("period"); p != "" {
+			if k.Period, err = strconv.Atoi(p); err != nil || k.Period < 1 {
+				return OTPKey{}, fmt.Errorf("invalid period %q", p)
+			}
+		}
+	case HOTP:
+		c := q.Get("counter")
+		if c == "" {
+			return OTPKey{}, errors.New("missing counter")
+		}
+		if k.Counter, err = strconv.ParseUint(c, 10, 64); err != nil {
+			return OTPKey{}, fmt.Errorf("invalid counter %q", c)
+		}
+	}
+
+	return k, nil
+}
+
+// ParseMigrationURI decodes an otpauth-migration://offline?data=... URI, as
+// exported by Google Authenticator's "Transfer accounts" QR codes, into the
+// keys it contains. Large exports are split across several QR codes; parse
+// each one and concatenate the results.
+func ParseMigrationURI(uri string) ([]OTPKey, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("parse uri: %w", err)
+	}
+	if u.Scheme != "otpauth-migration" || u.Host != "offline" {
+		return nil, errors.New("not an otpauth-migration://offline uri")
+	}
+
+	data := u.Query().Get("data")
+	if data == "" {
+		return nil, errors.New("missing data")
+	}
+
+	// some scanners leave '+' unescaped, which query decoding turns into ' '
+	data = strings.ReplaceAll(data, " ", "+")
+	payload, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		if payload, err = base64.RawStdEncoding.DecodeString(data); err != nil {
+			return nil, fmt.Errorf("decode data: %w", err)
+		}
+	}
+
+	return parseMigrationPayload(payload)
+}
+
+// The migration payload is a protocol buffer:
+//
+//	message MigrationPayload {
+//	  repeated OtpParameters otp_parameters = 1;
+//	  int32 version = 2; int32 batch_size = 3; int32 batch_index = 4; int32 batch_id = 5;
+//	}
+//	message OtpParameters {
+//	  bytes secret = 1; string name = 2; string issuer = 3;
+//	  Algorithm algorithm = 4;  // 1 SHA1, 2 SHA256, 3 SHA512, 4 MD5
+//	  DigitCount digits = 5;    // 1 six, 2 eight
+//	  OtpType type = 6;         // 1 HOTP, 2 TOTP
+//	  int64 counter = 7;
+//	}
+//
+// Only the fields above are read; unknown fields are skipped.
+
+// parseMigrationPayload decodes a MigrationPayload message.
+func parseMigrationPayload(b []byte) ([]OTPKey, error) {
+	var keys []OTPKey
+
+	err := walkProto(b, func(field int, _ uint64, data []byte) error {
+		if field != 1 || data == nil {
+			return nil
+		}
+		k, err := parseMigrationParams(data)
+		if err != nil {
+			return fmt.Errorf("account %d: %w", len(keys)+1, err)
+		}
+		keys = append(keys, k)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// parseMigrationParams decodes one OtpParameters message.
+func parseMigrationParams(b []byte) (OTPKey, error) {
+	var (
+		k      OTPKey
+		secret []byte
+		name   string
+	)
+
+	err := walkProto(b, func(field int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			secret = data
+		case 2:
+			name = string(data)
+		case 3:
+			k.Issuer = string(data)
+		case 4:
+			switch v {
+			case 0, 1:
+				k.Algorithm = OTPSHA1
+			case 2:
+				k.Algorithm = OTPSHA256
+			case 3:
+				k.Algorithm = OTPSHA512
+			default:
+				return fmt.Errorf("unsupported algorithm %d", v)
+			}
+		case 5:
+			switch v {
+			case 0, 1:
+				k.Digits = 6
+			case 2:
+				k.Digits = 8
+			default:
+				return fmt.Errorf("unsupported digit count %d", v)
+			}
+		case 6:
+			switch v {
+			case 1:
+				k.Type = HOTP
+			case 0, 2:
+				k.Type = TOTP
+			default:
+				return fmt.Errorf("unsupported type %d", v)
+			}
+		case 7:
+			k.Counter = v
+		}
+		return nil
+	})
+	if err != nil {
+		return OTPKey{}, err
+	}
+
+	if len(secret) == 0 {
+		return OTPKey{}, errors.New("missing secret")
+	}
+	k.Secret = otpBase32.EncodeToString(secret)
+
+	if k.Type == "" {
+		k.Type = TOTP
+	}
+	if k.Algorithm == "" {
+		k.Algorithm = OTPSHA1
+	}
+	if k.Digits == 0 {
+		k.Digits = otpDefaultDigits
+	}
+
+	// the name is often "Issuer:account"; the issuer field wins if present
+	k.Account = name
+	if issuer, account, ok := strings.Cut(name, ":"); ok {
+		k.Account = strings.TrimSpace(account)
+		if k.Issuer == "" {
+			k.Issuer = strings.TrimSpace(issuer)
+		}
+	}
+
+	return k, nil
+}
+
+// walkProto calls fn for each field of a protocol buffer message. Varint
+// fields are passed as v with nil data; length-delimited fields as data.
+// Fixed-width fields are skipped.
+func walkProto(b []byte, fn func(field int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("malformed payload")
+		}
+		b = b[n:]
+
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case 0: // varint
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errors.New("malformed payload")
+			}
+			b = b[n:]
+			if err := fn(field, v, nil); err != nil {
+				return err
+			}
+		case 1: // 64-bit
+			if len(b) < 8 {
+				return errors.New("malformed payload")
+			}
+			b = b[8:]
+		case 2: // length-delimited
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return errors.New("malformed payload")
+			}
+			data := b[n : n+int(l)]
+			b = b[n+int(l):]
+			if err := fn(field, 0, data[:len(data):len(data)]); err != nil {
+				return err
+			}
+		case 5: // 32-bit
+			if len(b) < 4 {
+				return errors.New("malformed payload")
+			}
+			b = b[4:]
+		default:
+			return errors.New("malformed payload")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package zcrypto_test
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func TestOTPKeyURIRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		key  zcrypto.OTPKey
+		want string
+	}{
+		{
+			name: "defaults",
+			key:  zcrypto.OTPKey{Issuer: "zarlcorp", Account: "alice@example.com", Secret: "jbsw y3dp ehpk 3pxp"},
+			want: "otpauth://totp/zarlcorp:alice@example.com?issuer=zarlcorp&secret=JBSWY3DPEHPK3PXP",
+		},
+		{
+			name: "spaces and options",
+			key: zcrypto.OTPKey{
+				Issuer:    "Big Corp",
+				Account:   "bob smith",
+				Secret:    "JBSWY3DPEHPK3PXP",
+				Algorithm: zcrypto.OTPSHA256,
+				Digits:    8,
+				Period:    60,
+			},
+			want: "otpauth://totp/Big%20Corp:bob%20smith?algorithm=SHA256&digits=8&issuer=Big%20Corp&period=60&secret=JBSWY3DPEHPK3PXP",
+		},
+		{
+			name: "hotp",
+			key:  zcrypto.OTPKey{Type: zcrypto.HOTP, Account: "carol", Secret: "JBSWY3DPEHPK3PXP", Counter: 42},
+			want: "otpauth://hotp/carol?counter=42&secret=JBSWY3DPEHPK3PXP",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri, err := tt.key.URI()
+			if err != nil {
+				t.Fatalf("uri: %v", err)
+			}
+			if uri != tt.want {
+				t.Fatalf("got  %s\nwant %s", uri, tt.want)
+			}
+
+			got, err := zcrypto.ParseOTPAuthURI(uri)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got.Issuer != tt.key.Issuer || got.Account != tt.key.Account || got.Counter != tt.key.Counter {
+				t.Fatalf("parsed %+v from %s", got, uri)
+			}
+
+			// both keys produce the same codes
+			want, _ := tt.key.HOTPCode(7)
+			code, err := got.HOTPCode(7)
+			if err != nil || code != want {
+				t.Fatalf("parsed key code %q, %v, want %q", code, err, want)
+			}
+		})
+	}
+}
+
+func TestParseOTPAuthURI(t *testing.T) {
+	k, err := zcrypto.ParseOTPAuthURI("otpauth://totp/Label%20Issuer:alice?secret=JBSWY3DPEHPK3PXP&issuer=Param%20Issuer&algorithm=sha512&digits=8&period=45")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	want := zcrypto.OTPKey{
+		Type:      zcrypto.TOTP,
+		Issuer:    "Param Issuer",
+		Account:   "alice",
+		Secret:    "JBSWY3DPEHPK3PXP",
+		Algorithm: zcrypto.OTPSHA512,
+		Digits:    8,
+		Period:    45,
+	}
+	if k != want {
+		t.Fatalf("got %+v, want %+v", k, want)
+	}
+}
+
+func TestParseOTPAuthURIInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+	}{
+		{name: "wrong scheme", uri: "https://totp/a?secret=JBSWY3DPEHPK3PXP"},
+		{name: "unknown type", uri: "otpauth://motp/a?secret=JBSWY3DPEHPK3PXP"},
+		{name: "missing secret", uri: "otpauth://totp/a"},
+		{name: "bad secret", uri: "otpauth://totp/a?secret=1111"},
+		{name: "bad algorithm", uri: "otpauth://totp/a?secret=JBSWY3DPEHPK3PXP&algorithm=MD5"},
+		{name: "bad digits", uri: "otpauth://totp/a?secret=JBSWY3DPEHPK3PXP&digits=3"},
+		{name: "bad period", uri: "otpauth://totp/a?secret=JBSWY3DPEHPK3PXP&period=0"},
+		{name: "hotp without counter", uri: "otpauth://hotp/a?secret=JBSWY3DPEHPK3PXP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := zcrypto.ParseOTPAuthURI(tt.uri); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+// protobuf helpers for building migration payloads
+func pbVarint(field int, v uint64) []byte {
+	b := binary.AppendUvarint(nil, uint64(field)<<3)
+	return binary.AppendUvarint(b, v)
+}
+
+func pbBytes(field int, data []byte) []byte {
+	b := binary.AppendUvarint(nil, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+func migrationURI(payload []byte) string {
+	return "otpauth-migration://offline?data=" + url.QueryEscape(base64.StdEncoding.EncodeToString(payload))
+}
+
+func TestParseMigrationURI(t *testing.T) {
+	var totp []byte
+	totp = append(totp, pbBytes(1, []byte("12345678901234567890"))...)
+	totp = append(totp, pbBytes(2, []byte("Example:alice@example.com"))...)
+	totp = append(totp, pbVarint(4, 1)...) // SHA1
+	totp = append(totp, pbVarint(5, 1)...) // six digits
+	totp = append(totp, pbVarint(6, 2)...) // TOTP
+
+	var hotp []byte
+	hotp = append(hotp, pbBytes(1, []byte("hello!"))...)
+	hotp = append(hotp, pbBytes(2, []byte("bob"))...)
+	hotp = append(hotp, pbBytes(3, []byte("Other"))...)
+	hotp = append(hotp, pbVarint(4, 2)...) // SHA256
+	hotp = append(hotp, pbVarint(5, 2)...) // eight digits
+	hotp = append(hotp, pbVarint(6, 1)...) // HOTP
+	hotp = append(hotp, pbVarint(7, 9)...)
+	hotp = append(hotp, pbBytes(99, []byte("unknown field"))...)
+
+	var payload []byte
+	payload = append(payload, pbBytes(1, totp)...)
+	payload = append(payload, pbBytes(1, hotp)...)
+	payload = append(payload, pbVarint(2, 1)...) // version
+	payload = append(payload, pbVarint(3, 1)...) // batch size
+
+	keys, err := zcrypto.ParseMigrationURI(migrationURI(payload))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	want := []zcrypto.OTPKey{
+		{
+			Type:      zcrypto.TOTP,
+			Issuer:    "Example",
+			Account:   "alice@example.com",
+			Secret:    "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+			Algorithm: zcrypto.OTPSHA1,
+			Digits:    6,
+		},
+		{
+			Type:      zcrypto.HOTP,
+			Issuer:    "Other",
+			Account:   "bob",
+			Secret:    "NBSWY3DPEE",
+			Algorithm: zcrypto.OTPSHA256,
+			Digits:    8,
+			Counter:   9,
+		},
+	}
+
+	if len(keys) != len(want) {
+		t.Fatalf("got %d keys, want %d", len(keys), len(want))
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Fatalf("key %d = %+v, want %+v", i, keys[i], want[i])
+		}
+	}
+
+	// the imported secret reproduces the RFC test vector
+	code, err := keys[0].TOTPCodeAt(time.Unix(59, 0))
+	if err != nil || code != "287082" {
+		t.Fatalf("code = %q, %v", code, err)
+	}
+}
+
+func TestParseMigrationURIInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		uri  string
+	}{
+		{name: "wrong scheme", uri: "otpauth://offline?data=AA"},
+		{name: "missing data", uri: "otpauth-migration://offline"},
+		{name: "bad base64", uri: "otpauth-migration://offline?data=!!!"},
+		{name: "truncated", uri: migrationURI(pbBytes(1, []byte{0x0a, 0x10, 0x01}))},
+		{name: "no secret", uri: migrationURI(pbBytes(1, pbBytes(2, []byte("name"))))},
+		{name: "md5", uri: migrationURI(pbBytes(1, append(pbBytes(1, []byte("key")), pbVarint(4, 4)...)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := zcrypto.ParseMigrationURI(tt.uri); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
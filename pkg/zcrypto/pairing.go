@@ -0,0 +1,261 @@
+package zcrypto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/edwards25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// PairingMessageSize is the length of a Pairing message.
+	PairingMessageSize = 32
+
+	// pairingCodeWords makes codes of about 38 bits: an active attacker
+	// gets one guess per pairing attempt, so this is plenty
+	pairingCodeWords = 3
+
+	pairingLabel = "zcrypto pairing v1"
+)
+
+// ErrPairingFailed is returned when the two sides of a Pairing did not use
+// the same code, or someone interfered with the exchange.
+var ErrPairingFailed = errors.New("pairing failed")
+
+// SPAKE2 points M and N for edwards25519 from RFC 9382, section 6; nobody
+// knows their discrete logs
+const (
+	spake2M = "d048032c6ea0b6d697ddc2e86bda85a33adac920f1bf18e1b0c6d166a5cecdaf"
+	spake2N = "d3bfb518f44f3430f29d0c92af503865a1ed3281dc69b35dd868ba85f886c4ab"
+)
+
+// PairingRole says which side of a Pairing a device plays. The two devices
+// must take different roles.
+type PairingRole int
+
+// Pairing roles.
+const (
+	// PairingInitiator is the device that shows the code.
+	PairingInitiator PairingRole = iota + 1
+
+	// PairingResponder is the device the code is typed into.
+	PairingResponder
+)
+
+// PairingOption configures a Pairing.
+type PairingOption func(*pairingConfig)
+
+type pairingConfig struct {
+	context     []byte
+	initiatorID []byte
+	responderID []byte
+}
+
+// WithPairingContext binds the pairing to an application and purpose, such
+// as "zvault sync". Both sides must pass the same context.
+func WithPairingContext(context string) PairingOption {
+	return func(c *pairingConfig) {
+		c.context = []byte(context)
+	}
+}
+
+// WithPairingIdentities binds the pairing to names for the two devices,
+// such as their hostnames. Both sides must pass the same names.
+func WithPairingIdentities(initiator, responder string) PairingOption {
+	return func(c *pairingConfig) {
+		c.initiatorID = []byte(initiator)
+		c.responderID = []byte(responder)
+	}
+}
+
+// Pairing agrees a shared key between two devices that share nothing but
+// a short code, using SPAKE2 (RFC 9382) over edwards25519. Someone
+// watching or tampering with the exchange learns nothing about the key,
+// and can only test one guess of the code per attempt.
+//
+// Each side sends its Message to the other and passes what it receives to
+// Exchange, which returns a confirmation to send back. Confirm checks the
+// peer's confirmation and returns the key. A Pairing is used once.
+type Pairing struct {
+	role   PairingRole
+	cfg    pairingConfig
+	w, x   *edwards25519.Scalar
+	msg    []byte
+	key    []byte
+	expect []byte
+}
+
+// GeneratePairingCode returns a random code of three EFF words, such as
+// "unshaken-cosmos-tulip", for the initiator to show and the responder to
+// type in.
+func GeneratePairingCode() string {
+	return GeneratePassphrase(pairingCodeWords, WithSeparator("-"))
+}
+
+// NewPairing starts a pairing with code. Codes are compared ignoring case
+// and surrounding space.
+func NewPairing(role PairingRole, code string, opts ...PairingOption) (*Pairing, error) {
+	if role != PairingInitiator && role != PairingResponder {
+		return nil, fmt.Errorf("invalid pairing role %d", role)
+	}
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return nil, errors.New("pairing code is empty")
+	}
+
+	var cfg pairingConfig
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	h := sha512.New()
+	writeLengthPrefixed(h, []byte(pairingLabel))
+	writeLengthPrefixed(h, []byte(code))
+	w, _ := edwards25519.NewScalar().SetUniformBytes(h.Sum(nil))
+
+	seed, err := RandBytes(64)
+	if err != nil {
+		return nil, fmt.Errorf("start pairing: %w", err)
+	}
+	defer Erase(seed)
+	x, _ := edwards25519.NewScalar().SetUniformBytes(seed)
+
+	// X = x*G + w*M for the initiator, x*G + w*N for the responder
+	blind, _, err := spake2Points(role)
+	if err != nil {
+		return nil, err
+	}
+	X := new(edwards25519.Point).ScalarBaseMult(x)
+	X.Add(X, new(edwards25519.Point).ScalarMult(w, blind))
+
+	return &Pairing{role: role, cfg: cfg, w: w, x: x, msg: X.Bytes()}, nil
+}
+
+// Message returns the message to send to the other device.
+func (p *Pairing) Message() []byte {
+	return bytes.Clone(p.msg)
+}
+
+// Exchange processes the other device's message and returns a
+// confirmation to send back to it.
+func (p *Pairing) Exchange(peer []byte) ([]byte, error) {
+	if p == nil || p.w == nil {
+		return nil, errors.New("pairing not started with NewPairing")
+	}
+	if p.x == nil {
+		return nil, errors.New("pairing already exchanged")
+	}
+	if len(peer) != PairingMessageSize || bytes.Equal(peer, p.msg) {
+		return nil, errors.New("malformed pairing message")
+	}
+	Y, err := new(edwards25519.Point).SetBytes(peer)
+	if err != nil {
+		return nil, errors.New("malformed pairing message")
+	}
+
+	// remove the peer's blinding, then K = h*x*(Y - w*N) with cofactor h
+	// so small-order components cannot leak bits of x
+	_, unblind, err := spake2Points(p.role)
+	if err != nil {
+		return nil, err
+	}
+	Y.Subtract(Y, new(edwards25519.Point).ScalarMult(p.w, unblind))
+	K := new(edwards25519.Point).ScalarMult(p.x, Y.MultByCofactor(Y))
+	p.x = nil
+	if K.Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return nil, ErrPairingFailed
+	}
+
+	pA, pB := p.msg, peer
+	if p.role == PairingResponder {
+		pA, pB = peer, p.msg
+	}
+	var tt bytes.Buffer
+	writeLengthPrefixed(&tt, p.cfg.initiatorID)
+	writeLengthPrefixed(&tt, p.cfg.responderID)
+	writeLengthPrefixed(&tt, pA)
+	writeLengthPrefixed(&tt, pB)
+	writeLengthPrefixed(&tt, K.Bytes())
+	writeLengthPrefixed(&tt, p.w.Bytes())
+	defer Erase(tt.Bytes())
+
+	sum := sha512.Sum512(tt.Bytes())
+	defer Erase(sum[:])
+	ke, ka := sum[:KeySize], sum[KeySize:]
+
+	kc := make([]byte, 2*sha256.Size)
+	defer Erase(kc)
+	info := append([]byte("ConfirmationKeys"), p.cfg.context...)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ka, nil, info), kc); err != nil {
+		return nil, fmt.Errorf("derive confirmation keys: %w", err)
+	}
+	cA, cB := MAC(kc[:sha256.Size], tt.Bytes()), MAC(kc[sha256.Size:], tt.Bytes())
+
+	p.key = bytes.Clone(ke)
+	if p.role == PairingInitiator {
+		p.expect = cB
+		return cA, nil
+	}
+	p.expect = cA
+	return cB, nil
+}
+
+// Confirm checks the other device's confirmation and returns the shared
+// KeySize key. It returns ErrPairingFailed if the devices used different
+// codes or the exchange was tampered with; start over with a new code.
+func (p *Pairing) Confirm(peer []byte) ([]byte, error) {
+	if p == nil || p.key == nil {
+		return nil, errors.New("pairing not exchanged")
+	}
+	key := p.key
+	p.key = nil
+	if !hmac.Equal(peer, p.expect) {
+		Erase(key)
+		return nil, ErrPairingFailed
+	}
+	return key, nil
+}
+
+// writeLengthPrefixed writes b preceded by its length as a little-endian
+// uint64, as RFC 9382 builds its transcript.
+func writeLengthPrefixed(w io.Writer, b []byte) {
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], uint64(len(b)))
+	w.Write(n[:])
+	w.Write(b)
+}
+
+// spake2Points returns the point that blinds role's message and the one
+// that blinds its peer's.
+func spake2Points(role PairingRole) (own, peer *edwards25519.Point, err error) {
+	m, err := hex.DecodeString(spake2M)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode spake2 point: %w", err)
+	}
+	n, err := hex.DecodeString(spake2N)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode spake2 point: %w", err)
+	}
+	M, err := new(edwards25519.Point).SetBytes(m)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode spake2 point: %w", err)
+	}
+	N, err := new(edwards25519.Point).SetBytes(n)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode spake2 point: %w", err)
+	}
+
+	if role == PairingResponder {
+		return N, M, nil
+	}
+	return M, N, nil
+}
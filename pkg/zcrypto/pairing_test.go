@@ -0,0 +1,230 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+// pair runs both sides of a pairing and returns each side's key or error.
+func pair(t *testing.T, a, b *zcrypto.Pairing) (keyA, keyB []byte, errA, errB error) {
+	t.Helper()
+
+	confirmA, err := a.Exchange(b.Message())
+	if err != nil {
+		return nil, nil, err, err
+	}
+	confirmB, err := b.Exchange(a.Message())
+	if err != nil {
+		return nil, nil, err, err
+	}
+
+	keyA, errA = a.Confirm(confirmB)
+	keyB, errB = b.Confirm(confirmA)
+	return keyA, keyB, errA, errB
+}
+
+func newPairing(t *testing.T, role zcrypto.PairingRole, code string, opts ...zcrypto.PairingOption) *zcrypto.Pairing {
+	t.Helper()
+	p, err := zcrypto.NewPairing(role, code, opts...)
+	if err != nil {
+		t.Fatalf("NewPairing: %v", err)
+	}
+	return p
+}
+
+func TestPairing(t *testing.T) {
+	code := zcrypto.GeneratePairingCode()
+	if n := len(strings.Split(code, "-")); n != 3 {
+		t.Fatalf("code %q has %d words, want 3", code, n)
+	}
+
+	opts := []zcrypto.PairingOption{
+		zcrypto.WithPairingContext("zvault sync"),
+		zcrypto.WithPairingIdentities("desktop", "laptop"),
+	}
+	a := newPairing(t, zcrypto.PairingInitiator, code, opts...)
+	b := newPairing(t, zcrypto.PairingResponder, " "+strings.ToUpper(code)+"\n", opts...)
+
+	if len(a.Message()) != zcrypto.PairingMessageSize {
+		t.Errorf("message is %d bytes, want %d", len(a.Message()), zcrypto.PairingMessageSize)
+	}
+
+	keyA, keyB, errA, errB := pair(t, a, b)
+	if errA != nil || errB != nil {
+		t.Fatalf("Confirm: %v, %v", errA, errB)
+	}
+	if !bytes.Equal(keyA, keyB) || len(keyA) != zcrypto.KeySize {
+		t.Fatalf("keys differ: %x, %x", keyA, keyB)
+	}
+
+	// the key works with the rest of the package
+	ct, err := zcrypto.Encrypt(keyA, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zcrypto.Decrypt(keyB, ct); err != nil {
+		t.Errorf("Decrypt: %v", err)
+	}
+
+	// a second run with the same code agrees a different key
+	keyA2, _, errA, errB := pair(t,
+		newPairing(t, zcrypto.PairingInitiator, code, opts...),
+		newPairing(t, zcrypto.PairingResponder, code, opts...))
+	if errA != nil || errB != nil {
+		t.Fatalf("second pairing: %v, %v", errA, errB)
+	}
+	if bytes.Equal(keyA, keyA2) {
+		t.Error("two pairings agreed the same key")
+	}
+}
+
+func TestPairingMismatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		codeB string
+		optsA []zcrypto.PairingOption
+		optsB []zcrypto.PairingOption
+	}{
+		{name: "wrong code", codeB: "cosmos-tulip-unshaken"},
+		{
+			name:  "different context",
+			codeB: "unshaken-cosmos-tulip",
+			optsA: []zcrypto.PairingOption{zcrypto.WithPairingContext("zvault sync")},
+			optsB: []zcrypto.PairingOption{zcrypto.WithPairingContext("zghost sync")},
+		},
+		{
+			name:  "different identities",
+			codeB: "unshaken-cosmos-tulip",
+			optsA: []zcrypto.PairingOption{zcrypto.WithPairingIdentities("desktop", "laptop")},
+			optsB: []zcrypto.PairingOption{zcrypto.WithPairingIdentities("desktop", "phone")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newPairing(t, zcrypto.PairingInitiator, "unshaken-cosmos-tulip", tt.optsA...)
+			b := newPairing(t, zcrypto.PairingResponder, tt.codeB, tt.optsB...)
+
+			keyA, keyB, errA, errB := pair(t, a, b)
+			if !errors.Is(errA, zcrypto.ErrPairingFailed) || !errors.Is(errB, zcrypto.ErrPairingFailed) {
+				t.Fatalf("errors = %v, %v, want ErrPairingFailed", errA, errB)
+			}
+			if keyA != nil || keyB != nil {
+				t.Error("failed pairing returned a key")
+			}
+		})
+	}
+}
+
+func TestPairingTampered(t *testing.T) {
+	code := "unshaken-cosmos-tulip"
+
+	t.Run("message", func(t *testing.T) {
+		a := newPairing(t, zcrypto.PairingInitiator, code)
+		b := newPairing(t, zcrypto.PairingResponder, code)
+
+		// a man in the middle without the code replaces b's message
+		mallory := newPairing(t, zcrypto.PairingResponder, "guessed-wrong-code")
+		confirmA, err := a.Exchange(mallory.Message())
+		if err != nil {
+			t.Fatal(err)
+		}
+		confirmB, err := b.Exchange(a.Message())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := a.Confirm(confirmB); !errors.Is(err, zcrypto.ErrPairingFailed) {
+			t.Errorf("initiator: err = %v, want ErrPairingFailed", err)
+		}
+		if _, err := b.Confirm(confirmA); !errors.Is(err, zcrypto.ErrPairingFailed) {
+			t.Errorf("responder: err = %v, want ErrPairingFailed", err)
+		}
+	})
+
+	t.Run("confirmation", func(t *testing.T) {
+		a := newPairing(t, zcrypto.PairingInitiator, code)
+		b := newPairing(t, zcrypto.PairingResponder, code)
+		if _, err := a.Exchange(b.Message()); err != nil {
+			t.Fatal(err)
+		}
+		confirmB, err := b.Exchange(a.Message())
+		if err != nil {
+			t.Fatal(err)
+		}
+		confirmB[0] ^= 1
+		if _, err := a.Confirm(confirmB); !errors.Is(err, zcrypto.ErrPairingFailed) {
+			t.Errorf("err = %v, want ErrPairingFailed", err)
+		}
+	})
+
+	t.Run("same role", func(t *testing.T) {
+		a := newPairing(t, zcrypto.PairingInitiator, code)
+		b := newPairing(t, zcrypto.PairingInitiator, code)
+		_, _, errA, errB := pair(t, a, b)
+		if !errors.Is(errA, zcrypto.ErrPairingFailed) || !errors.Is(errB, zcrypto.ErrPairingFailed) {
+			t.Errorf("errors = %v, %v, want ErrPairingFailed", errA, errB)
+		}
+	})
+}
+
+func TestPairingMisuse(t *testing.T) {
+	if _, err := zcrypto.NewPairing(zcrypto.PairingInitiator, "  "); err == nil {
+		t.Error("NewPairing accepted an empty code")
+	}
+	if _, err := zcrypto.NewPairing(zcrypto.PairingRole(0), "code"); err == nil {
+		t.Error("NewPairing accepted an invalid role")
+	}
+
+	a := newPairing(t, zcrypto.PairingInitiator, "code")
+	b := newPairing(t, zcrypto.PairingResponder, "code")
+
+	if _, err := a.Confirm(make([]byte, 32)); err == nil {
+		t.Error("Confirm before Exchange succeeded")
+	}
+	if _, err := a.Exchange(a.Message()); err == nil {
+		t.Error("Exchange accepted a reflected message")
+	}
+	if _, err := a.Exchange(b.Message()[:31]); err == nil {
+		t.Error("Exchange accepted a short message")
+	}
+	notOnCurve := make([]byte, 32)
+	notOnCurve[0] = 2
+	if _, err := a.Exchange(notOnCurve); err == nil {
+		t.Error("Exchange accepted a point not on the curve")
+	}
+
+	confirmA, err := a.Exchange(b.Message())
+	if err != nil {
+		t.Fatalf("Exchange after rejected messages: %v", err)
+	}
+	if _, err := a.Exchange(b.Message()); err == nil {
+		t.Error("second Exchange succeeded")
+	}
+
+	confirmB, err := b.Exchange(a.Message())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Confirm(confirmA); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if _, err := b.Confirm(confirmA); err == nil {
+		t.Error("second Confirm succeeded")
+	}
+	if _, err := a.Confirm(confirmB); err != nil {
+		t.Errorf("Confirm: %v", err)
+	}
+
+	// a nil or zero Pairing returns errors rather than panicking
+	for _, p := range []*zcrypto.Pairing{nil, {}} {
+		if _, err := p.Exchange(b.Message()); err == nil {
+			t.Error("Exchange on an unstarted pairing succeeded")
+		}
+		if _, err := p.Confirm(confirmB); err == nil {
+			t.Error("Confirm on an unstarted pairing succeeded")
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package zcrypto
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	hashSaltSize = 16
+	hashKeySize  = 32
+
+	// bounds on parameters read from a hash string, so a crafted or
+	// corrupted hash cannot make verification exhaust memory or CPU;
+	// HashPassword refuses to produce hashes outside them
+	minArgon2Memory = 8           // KiB per thread, as Argon2 requires
+	maxArgon2Memory = 1024 * 1024 // 1 GiB in KiB
+	maxArgon2Time   = 64
+	maxScryptLogN   = 22
+	maxScryptMemory = 1 << 30 // bytes; scrypt needs 128·r·N
+	maxScryptRP     = 1 << 10
+	maxBcryptCost   = 18
+	minHashBytes    = 8
+	maxHashBytes    = 128
+)
+
+// ErrPasswordMismatch is returned by VerifyPassword when the password does
+// not match the hash.
+var ErrPasswordMismatch = errors.New("password does not match")
+
+// phc is the base64 flavour used in PHC strings: standard alphabet, no
+// padding.
+var phc = base64.RawStdEncoding
+
+// HashOption configures password hashing.
+type HashOption func(*hashConfig)
+
+type hashConfig struct {
+	time    uint32
+	memory  uint32 // KiB
+	threads uint8
+}
+
+func defaultHashConfig() hashConfig {
+	return hashConfig{
+		time:    argon2Time,
+		memory:  argon2Memory,
+		threads: argon2Threads,
+	}
+}
+
+// WithArgon2Params sets the Argon2id cost: iterations, memory in KiB, and
+// parallelism. The default matches DeriveKey: 1 iteration, 64 MiB, and 4
+// threads. Values of zero keep the default. HashPassword returns an error
+// for costs VerifyPassword would refuse: more than 64 iterations, more than
+// 1 GiB, or less than 8 KiB per thread.
+func WithArgon2Params(iterations, memoryKiB uint32, threads uint8) HashOption {
+	return func(c *hashConfig) {
+		if iterations > 0 {
+			c.time = iterations
+		}
+		if memoryKiB > 0 {
+			c.memory = memoryKiB
+		}
+		if threads > 0 {
+			c.threads = threads
+		}
+	}
+}
+
+// HashPassword hashes password with Argon2id and a random salt and returns
+// a self-describing PHC string that records the parameters used:
+//
+//	$argon2id$v=19$m=65536,t=1,p=4$<salt>$<hash>
+//
+// Store the string as is and check it with VerifyPassword.
+func HashPassword(password []byte, opts ...HashOption) (string, error) {
+	cfg := defaultHashConfig()
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if err := cfg.check(); err != nil {
+		return "", err
+	}
+
+	salt := make([]byte, hashSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate salt: %w", err)
+	}
+
+	key := argon2.IDKey(password, salt, cfg.time, cfg.memory, cfg.threads, hashKeySize)
+	defer Erase(key)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, cfg.memory, cfg.time, cfg.threads,
+		phc.EncodeToString(salt), phc.EncodeToString(key)), nil
+}
+
+// VerifyPassword checks password against a hash in constant time. It returns
+// nil on a match, ErrPasswordMismatch on a mismatch, and another error if
+// the hash is malformed or uses an unsupported scheme.
+//
+// Besides the Argon2id strings produced by HashPassword it accepts hashes
+// imported from other tools:
+//
+//	$scrypt$ln=<log2 N>,r=<r>,p=<p>$<salt>$<hash>   (PHC scrypt)
+//	$2a$, $2b$, $2y$                               (bcrypt)
+//
+// Hashes that would need more than 1 GiB of memory to verify are rejected
+// as malformed, so a crafted hash cannot exhaust the machine.
+//
+// Use NeedsRehash after a successful verify to upgrade imported or
+// outdated hashes to the current parameters.
+func VerifyPassword(password []byte, hash string) error {
+	id, _, _ := strings.Cut(strings.TrimPrefix(hash, "$"), "$")
+
+	switch id {
+	case "argon2id":
+		return verifyArgon2id(password, hash)
+	case "scrypt":
+		return verifyScrypt(password, hash)
+	case "2a", "2b", "2y":
+		if cost, err := bcrypt.Cost([]byte(hash)); err == nil && cost > maxBcryptCost {
+			return errors.New("bcrypt cost out of range")
+		}
+		err := bcrypt.CompareHashAndPassword([]byte(hash), password)
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrPasswordMismatch
+		}
+		if err != nil {
+			return fmt.Errorf("bcrypt: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported hash scheme %q", id)
+	}
+}
+
+// NeedsRehash reports whether hash should be replaced by a fresh
+// HashPassword result: it is not Argon2id, its parameters differ from those
+// the options select, or it cannot be parsed.
+func NeedsRehash(hash string, opts ...HashOption) bool {
+	cfg := defaultHashConfig()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	h, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return h.cfg != cfg || len(h.key) != hashKeySize
+}
+
+// check returns an error if the parameters are outside the bounds
+// VerifyPassword accepts.
+func (c hashConfig) check() error {
+	if c.time < 1 || c.time > maxArgon2Time || c.threads < 1 ||
+		c.memory > maxArgon2Memory || c.memory < minArgon2Memory*uint32(c.threads) {
+		return errors.New("argon2 parameters out of range")
+	}
+	return nil
+}
+
+// argon2Hash is a parsed Argon2id PHC string.
+type argon2Hash struct {
+	cfg  hashConfig
+	salt []byte
+	key  []byte
+}
+
+func verifyArgon2id(password []byte, hash string) error {
+	h, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+
+	key := argon2.IDKey(password, h.salt, h.cfg.time, h.cfg.memory, h.cfg.threads, uint32(len(h.key)))
+	defer Erase(key)
+
+	if subtle.ConstantTimeCompare(key, h.key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// parseArgon2id parses $argon2id$v=19$m=..,t=..,p=..$salt$hash.
+func parseArgon2id(hash string) (argon2Hash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return argon2Hash{}, errors.New("malformed argon2id hash")
+	}
+
+	if parts[2] != fmt.Sprintf("v=%d", argon2.Version) {
+		return argon2Hash{}, fmt.Errorf("unsupported argon2id version %q", parts[2])
+	}
+
+	params, err := parseParams(parts[3], "m", "t", "p")
+	if err != nil {
+		return argon2Hash{}, fmt.Errorf("argon2id: %w", err)
+	}
+	m, t, p := params[0], params[1], params[2]
+	if p > 255 {
+		return argon2Hash{}, errors.New("argon2id parameters out of range")
+	}
+	h := argon2Hash{cfg: hashConfig{time: uint32(t), memory: uint32(m), threads: uint8(p)}}
+	if err := h.cfg.check(); err != nil {
+		return argon2Hash{}, err
+	}
+
+	if h.salt, h.key, err = decodeSaltHash(parts[4], parts[5]); err != nil {
+		return argon2Hash{}, fmt.Errorf("argon2id: %w", err)
+	}
+
+	return h, nil
+}
+
+func verifyScrypt(password []byte, hash string) error {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != "scrypt" {
+		return errors.New("malformed scrypt hash")
+	}
+
+	params, err := parseParams(parts[2], "ln", "r", "p")
+	if err != nil {
+		return fmt.Errorf("scrypt: %w", err)
+	}
+	ln, r, p := params[0], params[1], params[2]
+	if ln < 1 || ln > maxScryptLogN || r < 1 || p < 1 || r*p > maxScryptRP || 128*r<<ln > maxScryptMemory {
+		return errors.New("scrypt parameters out of range")
+	}
+
+	salt, want, err := decodeSaltHash(parts[3], parts[4])
+	if err != nil {
+		return fmt.Errorf("scrypt: %w", err)
+	}
+
+	key, err := scrypt.Key(password, salt, 1<<ln, int(r), int(p), len(want))
+	if err != nil {
+		return fmt.Errorf("scrypt: %w", err)
+	}
+	defer Erase(key)
+
+	if subtle.ConstantTimeCompare(key, want) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// parseParams parses a comma-separated k=v list with exactly the given
+// keys in order.
+func parseParams(s string, keys ...string) ([]uint64, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != len(keys) {
+		return nil, fmt.Errorf("malformed parameters %q", s)
+	}
+
+	vals := make([]uint64, len(keys))
+	for i, f := range fields {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok || k != keys[i] {
+			return nil, fmt.Errorf("malformed parameters %q", s)
+		}
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", k, err)
+		}
+		vals[i] = n
+	}
+
+	return vals, nil
+}
+
+// decodeSaltHash decodes the base64 salt and hash fields of a PHC string.
+func decodeSaltHash(s, h string) (salt, hash []byte, err error) {
+	salt, err = phc.DecodeString(s)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode salt: %w", err)
+	}
+	hash, err = phc.DecodeString(h)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode hash: %w", err)
+	}
+
+	if len(salt) < minHashBytes || len(salt) > maxHashBytes || len(hash) < minHashBytes || len(hash) > maxHashBytes {
+		return nil, nil, errors.New("salt or hash length out of range")
+	}
+
+	return salt, hash, nil
+}
@@ -0,0 +1,197 @@
+package zcrypto_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+// cheap parameters keep the tests fast
+var fastHash = zcrypto.WithArgon2Params(1, 64, 1)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := zcrypto.HashPassword([]byte("correct horse"), fastHash)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("unexpected hash format %q", hash)
+	}
+
+	if err := zcrypto.VerifyPassword([]byte("correct horse"), hash); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	err = zcrypto.VerifyPassword([]byte("wrong horse"), hash)
+	if !errors.Is(err, zcrypto.ErrPasswordMismatch) {
+		t.Fatalf("expected ErrPasswordMismatch, got %v", err)
+	}
+
+	// a fresh salt every time
+	again, err := zcrypto.HashPassword([]byte("correct horse"), fastHash)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if again == hash {
+		t.Fatal("two hashes of the same password are identical")
+	}
+}
+
+func TestHashPasswordDefaults(t *testing.T) {
+	hash, err := zcrypto.HashPassword([]byte("pw"))
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=1,p=4$") {
+		t.Fatalf("unexpected default parameters in %q", hash)
+	}
+	if zcrypto.NeedsRehash(hash) {
+		t.Fatal("fresh default hash needs rehash")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash, err := zcrypto.HashPassword([]byte("pw"), fastHash)
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		opts []zcrypto.HashOption
+		want bool
+	}{
+		{name: "same parameters", hash: hash, opts: []zcrypto.HashOption{fastHash}, want: false},
+		{name: "upgraded memory", hash: hash, opts: []zcrypto.HashOption{zcrypto.WithArgon2Params(1, 128, 1)}, want: true},
+		{name: "upgraded time", hash: hash, opts: []zcrypto.HashOption{zcrypto.WithArgon2Params(2, 64, 1)}, want: true},
+		{name: "defaults", hash: hash, want: true},
+		{name: "bcrypt", hash: "$2a$04$abcdefghijklmnopqrstuu", want: true},
+		{name: "garbage", hash: "not a hash", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := zcrypto.NeedsRehash(tt.hash, tt.opts...); got != tt.want {
+				t.Fatalf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyPasswordScrypt(t *testing.T) {
+	// generated with Python's hashlib.scrypt(n=1024, r=8, p=1, dklen=32)
+	const hash = "$scrypt$ln=10,r=8,p=1$c2FsdHNhbHRzYWx0c2FsdA$A9lBa6RTbfBovWqamVIqXKovIl4Vk6OZyVojLJmYmSI"
+
+	if err := zcrypto.VerifyPassword([]byte("correct horse"), hash); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	err := zcrypto.VerifyPassword([]byte("wrong horse"), hash)
+	if !errors.Is(err, zcrypto.ErrPasswordMismatch) {
+		t.Fatalf("expected ErrPasswordMismatch, got %v", err)
+	}
+}
+
+func TestVerifyPasswordBcrypt(t *testing.T) {
+	h, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	hash := string(h)
+
+	// other implementations write the same hash with different minor versions
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		t.Run(prefix, func(t *testing.T) {
+			hash := prefix + hash[4:]
+
+			if err := zcrypto.VerifyPassword([]byte("correct horse"), hash); err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+
+			err := zcrypto.VerifyPassword([]byte("wrong horse"), hash)
+			if !errors.Is(err, zcrypto.ErrPasswordMismatch) {
+				t.Fatalf("expected ErrPasswordMismatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyPasswordMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "empty", hash: ""},
+		{name: "unknown scheme", hash: "$md5$abc"},
+		{name: "argon2i", hash: "$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{name: "wrong version", hash: "$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{name: "missing field", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ"},
+		{name: "params out of order", hash: "$argon2id$v=19$t=1,m=64,p=1$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{name: "huge memory", hash: "$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{name: "zero threads", hash: "$argon2id$v=19$m=64,t=1,p=0$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{name: "bad base64", hash: "$argon2id$v=19$m=64,t=1,p=1$!!!$aGFzaGhhc2g"},
+		{name: "short salt", hash: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$aGFzaGhhc2g"},
+		{name: "huge scrypt", hash: "$scrypt$ln=40,r=8,p=1$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{name: "scrypt memory", hash: "$scrypt$ln=22,r=1024,p=1$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{name: "scrypt memory at max ln", hash: "$scrypt$ln=22,r=4,p=1$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{name: "scrypt cpu", hash: "$scrypt$ln=1,r=1,p=2048$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{name: "argon2 memory", hash: "$argon2id$v=19$m=4194304,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2g"},
+		{name: "huge bcrypt cost", hash: "$2a$31$abcdefghijklmnopqrstuuabcdefghijklmnopqrstuvwxyz01234"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := zcrypto.VerifyPassword([]byte("pw"), tt.hash)
+			if err == nil || errors.Is(err, zcrypto.ErrPasswordMismatch) {
+				t.Fatalf("expected malformed hash error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHashPasswordParamBounds(t *testing.T) {
+	tests := []struct {
+		name               string
+		iterations, memory uint32
+		threads            uint8
+		ok                 bool
+	}{
+		{name: "min memory", iterations: 1, memory: 8, threads: 1, ok: true},
+		{name: "min memory per thread", iterations: 1, memory: 32, threads: 4, ok: true},
+		{name: "max threads", iterations: 1, memory: 8 * 255, threads: 255, ok: true},
+		{name: "max iterations", iterations: 64, memory: 8, threads: 1, ok: true},
+		{name: "max memory", iterations: 1, memory: 1024 * 1024, threads: 1, ok: true},
+		{name: "memory below min", iterations: 1, memory: 7, threads: 1},
+		{name: "memory below min per thread", iterations: 1, memory: 16, threads: 4},
+		{name: "iterations above max", iterations: 65, memory: 64, threads: 1},
+		{name: "memory above max", iterations: 1, memory: 1024*1024 + 1, threads: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.ok && tt.memory > 64*1024 && testing.Short() {
+				t.Skip("hashes with 1 GiB of memory")
+			}
+
+			hash, err := zcrypto.HashPassword([]byte("pw"), zcrypto.WithArgon2Params(tt.iterations, tt.memory, tt.threads))
+			if !tt.ok {
+				if err == nil {
+					t.Fatalf("HashPassword accepted parameters VerifyPassword refuses: %s", hash)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("hash: %v", err)
+			}
+			if err := zcrypto.VerifyPassword([]byte("pw"), hash); err != nil {
+				t.Fatalf("verify %s: %v", hash, err)
+			}
+		})
+	}
+}
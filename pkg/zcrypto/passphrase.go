@@ -0,0 +1,140 @@
+package zcrypto
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"sync"
+)
+
+// effLargeWordlist is the EFF large wordlist for dice-generated passphrases,
+// one "<dice rolls>\t<word>" entry per line. It is published by the
+// Electronic Frontier Foundation under CC BY 3.0 US.
+//
+// https://www.eff.org/deeplinks/2016/07/new-wordlists-random-passphrases
+//
+//go:embed wordlists/eff_large_wordlist.txt
+var effLargeWordlist string
+
+// effWords returns the 7776 words of the EFF large wordlist.
+var effWords = sync.OnceValue(func() []string {
+	lines := strings.Split(strings.TrimSpace(effLargeWordlist), "\n")
+	words := make([]string, len(lines))
+	for i, line := range lines {
+		_, words[i], _ = strings.Cut(line, "\t")
+	}
+	return words
+})
+
+// PassphraseOption configures passphrase generation.
+type PassphraseOption func(*passphraseConfig)
+
+type passphraseConfig struct {
+	separator  string
+	separators string // random separator characters, overrides separator
+	capitalize bool
+	digit      bool
+}
+
+func defaultPassphraseConfig() passphraseConfig {
+	return passphraseConfig{
+		separator: "-",
+	}
+}
+
+// WithSeparator sets the string placed between words. The default is "-".
+func WithSeparator(sep string) PassphraseOption {
+	return func(c *passphraseConfig) {
+		c.separator = sep
+		c.separators = ""
+	}
+}
+
+// WithRandomSeparators places a random character from chars between each
+// pair of words, adding entropy at the cost of memorability. Repeated
+// characters are picked no more often than the rest.
+func WithRandomSeparators(chars string) PassphraseOption {
+	return func(c *passphraseConfig) {
+		c.separators = chars
+	}
+}
+
+// WithCapitalize capitalizes the first letter of every word, for sites that
+// require an uppercase letter. It adds no entropy.
+func WithCapitalize() PassphraseOption {
+	return func(c *passphraseConfig) {
+		c.capitalize = true
+	}
+}
+
+// WithDigit appends a random digit to a randomly chosen word, for sites
+// that require a number.
+func WithDigit() PassphraseOption {
+	return func(c *passphraseConfig) {
+		c.digit = true
+	}
+}
+
+// GeneratePassphrase produces a diceware passphrase of the given number of
+// words drawn uniformly from the embedded EFF large wordlist, each worth
+// about 12.9 bits. Six or more words are recommended for secrets that guard
+// other secrets. Minimum is 1 word; shorter values are clamped.
+//
+// Panics if crypto/rand fails (unrecoverable).
+func GeneratePassphrase(words int, opts ...PassphraseOption) string {
+	cfg := defaultPassphraseConfig()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	words = max(words, 1)
+	list := effWords()
+
+	parts := make([]string, words)
+	for i := range parts {
+		parts[i] = list[randIntn(len(list))]
+		if cfg.capitalize {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+
+	if cfg.digit {
+		i := randIntn(words)
+		parts[i] += string(pickRune(digitChars))
+	}
+
+	if cfg.separators == "" {
+		return strings.Join(parts, cfg.separator)
+	}
+
+	separators := distinct(cfg.separators)
+	var b strings.Builder
+	for i, p := range parts {
+		if i > 0 {
+			b.WriteRune(pickRune(separators))
+		}
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// PassphraseEntropy returns the entropy in bits of a passphrase generated by
+// GeneratePassphrase with the same arguments.
+func PassphraseEntropy(words int, opts ...PassphraseOption) float64 {
+	cfg := defaultPassphraseConfig()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	words = max(words, 1)
+	bits := float64(words) * math.Log2(float64(len(effWords())))
+
+	if cfg.digit {
+		bits += math.Log2(float64(words * len(digitChars)))
+	}
+	if cfg.separators != "" {
+		bits += float64(words-1) * charsetEntropy(cfg.separators)
+	}
+
+	return bits
+}
@@ -0,0 +1,153 @@
+package zcrypto_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func TestGeneratePassphrase(t *testing.T) {
+	tests := []struct {
+		name  string
+		words int
+		want  int
+	}{
+		{"one", 1, 1},
+		{"six", 6, 6},
+		{"twelve", 12, 12},
+		{"zero", 0, 1},
+		{"negative", -3, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := zcrypto.GeneratePassphrase(tt.words, zcrypto.WithSeparator(" "))
+			words := strings.Split(p, " ")
+			if len(words) != tt.want {
+				t.Fatalf("%q has %d words, want %d", p, len(words), tt.want)
+			}
+			for _, w := range words {
+				if len(w) < 3 || strings.ToLower(w) != w {
+					t.Fatalf("unexpected word %q in %q", w, p)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratePassphraseDefaultSeparator(t *testing.T) {
+	p := zcrypto.GeneratePassphrase(4)
+	// a few EFF words contain a hyphen themselves
+	if n := strings.Count(p, "-"); n < 3 {
+		t.Fatalf("%q has %d separators, want at least 3", p, n)
+	}
+}
+
+func TestGeneratePassphraseOptions(t *testing.T) {
+	for range 50 {
+		p := zcrypto.GeneratePassphrase(5, zcrypto.WithSeparator("."), zcrypto.WithCapitalize(), zcrypto.WithDigit())
+
+		words := strings.Split(p, ".")
+		if len(words) != 5 {
+			t.Fatalf("%q has %d words, want 5", p, len(words))
+		}
+
+		digits := 0
+		for _, w := range words {
+			if !unicode.IsUpper(rune(w[0])) {
+				t.Fatalf("word %q in %q is not capitalized", w, p)
+			}
+			for _, r := range w[1:] {
+				if unicode.IsDigit(r) {
+					digits++
+				}
+			}
+		}
+		if digits != 1 {
+			t.Fatalf("%q has %d digits, want 1", p, digits)
+		}
+	}
+}
+
+func TestGeneratePassphraseRandomSeparators(t *testing.T) {
+	p := zcrypto.GeneratePassphrase(8, zcrypto.WithRandomSeparators("0123456789"))
+
+	seps := strings.FieldsFunc(p, func(r rune) bool { return !unicode.IsDigit(r) })
+	if len(seps) != 7 {
+		t.Fatalf("%q has %d separators, want 7", p, len(seps))
+	}
+}
+
+func TestGeneratePassphraseRandomness(t *testing.T) {
+	seen := make(map[string]bool)
+	for range 100 {
+		p := zcrypto.GeneratePassphrase(4)
+		if seen[p] {
+			t.Fatalf("duplicate passphrase %q", p)
+		}
+		seen[p] = true
+	}
+}
+
+func TestPassphraseEntropy(t *testing.T) {
+	word := math.Log2(7776)
+
+	tests := []struct {
+		name  string
+		words int
+		opts  []zcrypto.PassphraseOption
+		want  float64
+	}{
+		{"six words", 6, nil, 6 * word},
+		{"capitalize adds nothing", 6, []zcrypto.PassphraseOption{zcrypto.WithCapitalize()}, 6 * word},
+		{"digit", 4, []zcrypto.PassphraseOption{zcrypto.WithDigit()}, 4*word + math.Log2(40)},
+		{"random separators", 3, []zcrypto.PassphraseOption{zcrypto.WithRandomSeparators("0123")}, 3*word + 2*2},
+		// repeats are picked no more often than the rest
+		{"duplicated separators", 2, []zcrypto.PassphraseOption{zcrypto.WithRandomSeparators("-- ")}, 2*word + 1},
+		{"clamped", 0, nil, word},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := zcrypto.PassphraseEntropy(tt.words, tt.opts...)
+			if math.Abs(got-tt.want) > 0.001 {
+				t.Fatalf("entropy = %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		opts   []zcrypto.PasswordOption
+		want   float64
+	}{
+		// every character is a guaranteed pick, so all are equally likely
+		{"minimum", 4, nil, math.Log2(26 * 26 * 10 * 26 * 24)},
+		{"charset", 10, []zcrypto.PasswordOption{zcrypto.WithCharset("01")}, 10},
+		// clamped to 4, one character from all 62; the likeliest passwords
+		// have two from one class, which the shuffle reaches two ways
+		{"without symbols", 2, []zcrypto.PasswordOption{zcrypto.WithoutSymbols()}, math.Log2(24 * 26 * 26 * 10 * 62 / 2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := zcrypto.PasswordEntropy(tt.length, tt.opts...)
+			if math.Abs(got-tt.want) > 0.001 {
+				t.Fatalf("entropy = %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+
+	// long passwords approach length × log2(88) from below
+	got := zcrypto.PasswordEntropy(200)
+	upper := 200 * math.Log2(88)
+	if got >= upper || got < upper-1 {
+		t.Fatalf("entropy = %.2f, want just under %.2f", got, upper)
+	}
+}
@@ -2,7 +2,10 @@ package zcrypto
 
 import (
 	"crypto/rand"
+	"math"
 	"math/big"
+	"strings"
+	"unicode/utf8"
 )
 
 // password character classes
@@ -11,42 +14,121 @@ const (
 	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	digitChars  = "0123456789"
 	symbolChars = "!@#$%^&*()-_=+[]{}|;:,.<>?"
+
+	// ambiguousChars look alike in many fonts
+	ambiguousChars = "0O1lI|"
 )
 
+// minPasswordLength is the shortest password GeneratePassword produces
+// outside of WithCharset.
+const minPasswordLength = 4
+
 // PasswordOption configures password generation.
 type PasswordOption func(*passwordConfig)
 
 type passwordConfig struct {
-	charset string
-	symbols bool
+	charset       string
+	symbols       bool
+	symbolSet     string
+	pattern       string
+	pronounceable bool
+	noAmbiguous   bool
+	minLower      int
+	minUpper      int
+	minDigits     int
+	minSymbols    int
+	maxLength     int
 }
 
 func defaultPasswordConfig() passwordConfig {
 	return passwordConfig{
-		symbols: true,
+		symbols:    true,
+		symbolSet:  symbolChars,
+		minLower:   1,
+		minUpper:   1,
+		minDigits:  1,
+		minSymbols: 1,
 	}
 }
 
-// WithoutSymbols excludes symbol characters from generated passwords.
+// WithoutSymbols excludes symbol characters from generated passwords,
+// overriding WithSymbols and WithMinSymbols.
 func WithoutSymbols() PasswordOption {
 	return func(c *passwordConfig) {
 		c.symbols = false
 	}
 }
 
+// WithSymbols restricts symbols to chars, for sites that accept only some.
+func WithSymbols(chars string) PasswordOption {
+	return func(c *passwordConfig) {
+		c.symbolSet = chars
+	}
+}
+
 // WithCharset overrides the full character set used for password generation.
 // When set, character class guarantees (lower, upper, digit, symbol) are
-// disabled — the caller controls everything.
+// disabled — the caller controls everything. Characters may be any Unicode;
+// repeats are picked no more often than the rest.
 func WithCharset(chars string) PasswordOption {
 	return func(c *passwordConfig) {
 		c.charset = chars
 	}
 }
 
+// WithoutAmbiguous excludes characters that are easily confused when read
+// or transcribed: 0, O, 1, l, I and |.
+func WithoutAmbiguous() PasswordOption {
+	return func(c *passwordConfig) {
+		c.noAmbiguous = true
+	}
+}
+
+// WithMinLower requires at least n lowercase letters. The default is 1.
+func WithMinLower(n int) PasswordOption {
+	return func(c *passwordConfig) {
+		c.minLower = max(n, 0)
+	}
+}
+
+// WithMinUpper requires at least n uppercase letters. The default is 1.
+func WithMinUpper(n int) PasswordOption {
+	return func(c *passwordConfig) {
+		c.minUpper = max(n, 0)
+	}
+}
+
+// WithMinDigits requires at least n digits. The default is 1.
+func WithMinDigits(n int) PasswordOption {
+	return func(c *passwordConfig) {
+		c.minDigits = max(n, 0)
+	}
+}
+
+// WithMinSymbols requires at least n symbols. The default is 1.
+func WithMinSymbols(n int) PasswordOption {
+	return func(c *passwordConfig) {
+		c.minSymbols = max(n, 0)
+	}
+}
+
+// WithMaxLength caps the password length, for sites that reject long
+// passwords. The minimum length and the per-class minimums take precedence
+// if they conflict with it.
+func WithMaxLength(n int) PasswordOption {
+	return func(c *passwordConfig) {
+		c.maxLength = n
+	}
+}
+
 // GeneratePassword produces a cryptographically random password of the given
 // length. By default it guarantees at least one character from each class
 // (lower, upper, digit, symbol) and uses Fisher-Yates shuffle for uniform
-// distribution. Minimum length is 4; shorter values are clamped.
+// distribution. Minimum length is 4, or the sum of the per-class minimums if
+// larger; shorter values are clamped.
+//
+// WithPattern and WithPronounceable switch to template and pronounceable
+// generation; see their docs for how they interpret the other options.
 //
 // Panics if crypto/rand fails (unrecoverable).
 func GeneratePassword(length int, opts ...PasswordOption) string {
@@ -60,35 +142,38 @@ func GeneratePassword(length int, opts ...PasswordOption) string {
 		if length < 1 {
 			length = 1
 		}
-		return generateFromCharset(length, cfg.charset)
+		return generateFromCharset(length, distinct(cfg.charset))
 	}
 
-	if length < 4 {
-		length = 4
+	if cfg.pattern != "" {
+		return generateFromPattern(cfg)
 	}
 
-	var classes []string
-	classes = append(classes, lowerChars, upperChars, digitChars)
-	if cfg.symbols {
-		classes = append(classes, symbolChars)
+	length = cfg.length(length)
+	if cfg.pronounceable {
+		return generatePronounceable(length, cfg)
 	}
 
+	classes := cfg.classes()
+
 	// build the full charset
 	var charset string
 	for _, c := range classes {
-		charset += c
+		charset += c.chars
 	}
 
-	buf := make([]byte, length)
+	buf := make([]rune, 0, length)
 
-	// guarantee one from each class
-	for i, class := range classes {
-		buf[i] = pickByte(class)
+	// guarantee the minimum from each class
+	for _, class := range classes {
+		for range class.min {
+			buf = append(buf, pickRune(class.chars))
+		}
 	}
 
 	// fill remainder from full charset
-	for i := len(classes); i < length; i++ {
-		buf[i] = pickByte(charset)
+	for len(buf) < length {
+		buf = append(buf, pickRune(charset))
 	}
 
 	// Fisher-Yates shuffle
@@ -97,34 +182,187 @@ func GeneratePassword(length int, opts ...PasswordOption) string {
 	return string(buf)
 }
 
+// PasswordEntropy returns the min-entropy in bits of a password generated
+// by GeneratePassword with the same arguments: -log2 of the chance of
+// guessing its most likely output in one try, a lower bound on the
+// Shannon entropy. The per-class minimums make some passwords likelier
+// than others, so with them this is slightly less than length ×
+// log2(charset), and less than log2 of the number of passwords meeting the
+// policy.
+func PasswordEntropy(length int, opts ...PasswordOption) float64 {
+	cfg := defaultPasswordConfig()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	if cfg.charset != "" {
+		return float64(max(length, 1)) * charsetEntropy(cfg.charset)
+	}
+
+	if cfg.pattern != "" {
+		return patternEntropy(cfg)
+	}
+
+	length = cfg.length(length)
+	if cfg.pronounceable {
+		return pronounceableEntropy(length, cfg)
+	}
+
+	return classMinEntropy(length, cfg.classes())
+}
+
+// passwordClass is a character class and how many of it a password needs.
+type passwordClass struct {
+	chars string
+	min   int
+}
+
+// classes returns the enabled character classes with ambiguous
+// characters removed.
+func (c passwordConfig) classes() []passwordClass {
+	classes := []passwordClass{
+		{c.filter(lowerChars), c.minLower},
+		{c.filter(upperChars), c.minUpper},
+		{c.filter(digitChars), c.minDigits},
+	}
+	if sym := c.filter(c.symbolSet); c.symbols && sym != "" {
+		classes = append(classes, passwordClass{sym, c.minSymbols})
+	}
+	return classes
+}
+
+// length applies the maximum length, then the minimums, to a requested
+// length.
+func (c passwordConfig) length(n int) int {
+	if c.maxLength > 0 {
+		n = min(n, c.maxLength)
+	}
+
+	floor := minPasswordLength
+	var sum int
+	for _, class := range c.classes() {
+		sum += class.min
+	}
+	return max(n, floor, sum)
+}
+
+// filter removes repeated characters from chars, and ambiguous ones if
+// configured to.
+func (c passwordConfig) filter(chars string) string {
+	if !c.noAmbiguous {
+		return distinct(chars)
+	}
+	return distinct(strings.Map(func(r rune) rune {
+		if strings.ContainsRune(ambiguousChars, r) {
+			return -1
+		}
+		return r
+	}, chars))
+}
+
+// distinct returns chars with repeated characters removed, keeping the
+// first of each.
+func distinct(chars string) string {
+	seen := make(map[rune]bool)
+	return strings.Map(func(r rune) rune {
+		if seen[r] {
+			return -1
+		}
+		seen[r] = true
+		return r
+	}, chars)
+}
+
+// charsetEntropy returns the entropy in bits of one character picked
+// uniformly from the distinct characters of chars, as pickRune does once
+// they are deduplicated.
+func charsetEntropy(chars string) float64 {
+	return math.Log2(float64(utf8.RuneCountInString(distinct(chars))))
+}
+
+// classMinEntropy returns the min-entropy in bits of GeneratePassword's
+// class-based output: -log2 of the probability of its most likely
+// password. Each class's minimum is picked from that class, the rest from
+// the whole charset, and the result shuffled, so a password is likelier
+// the more of its characters could have been one of the guaranteed picks;
+// the most likely spread those characters as evenly as the minimums allow.
+// The classes are assumed not to share characters.
+func classMinEntropy(length int, classes []passwordClass) float64 {
+	// a password with n[c] characters from class c comes out with
+	// probability fill! × Π n[c]!/(n[c]-min[c])! / (length! × Π |c|^min[c] × N^fill)
+	n := make([]int, len(classes))
+	fill := length
+	var size int
+	for i, c := range classes {
+		n[i] = c.min
+		fill -= c.min
+		size += utf8.RuneCountInString(c.chars)
+	}
+
+	// the falling factorials are concave in n[c], so handing out the fill
+	// one character at a time to the class that gains most is optimal
+	for range fill {
+		best, gain := -1, 0.0
+		for i, c := range classes {
+			if g := float64(n[i]+1) / float64(n[i]+1-c.min); c.min > 0 && g > gain {
+				best, gain = i, g
+			}
+		}
+		if best < 0 {
+			break
+		}
+		n[best]++
+	}
+
+	bits := log2Factorial(length) - log2Factorial(fill) + float64(fill)*math.Log2(float64(size))
+	for i, c := range classes {
+		bits += float64(c.min) * math.Log2(float64(utf8.RuneCountInString(c.chars)))
+		bits -= log2Factorial(n[i]) - log2Factorial(n[i]-c.min)
+	}
+	return bits
+}
+
+// log2Factorial returns log2(n!).
+func log2Factorial(n int) float64 {
+	lg, _ := math.Lgamma(float64(n) + 1)
+	return lg / math.Ln2
+}
+
 // generateFromCharset fills a buffer from an arbitrary charset and shuffles.
 func generateFromCharset(length int, charset string) string {
-	buf := make([]byte, length)
+	buf := make([]rune, length)
 	for i := range buf {
-		buf[i] = pickByte(charset)
+		buf[i] = pickRune(charset)
 	}
 	shuffle(buf)
 	return string(buf)
 }
 
 // shuffle performs Fisher-Yates shuffle using crypto/rand.
-func shuffle(buf []byte) {
+func shuffle(buf []rune) {
 	for i := len(buf) - 1; i > 0; i-- {
 		j := randIntn(i + 1)
 		buf[i], buf[j] = buf[j], buf[i]
 	}
 }
 
-// pickByte returns a random byte from a string.
-func pickByte(s string) byte {
-	return s[randIntn(len(s))]
+// pickRune returns a random character from a string. Callers deduplicate
+// s first so that every character is equally likely.
+func pickRune(s string) rune {
+	chars := []rune(s)
+	return chars[randIntn(len(chars))]
 }
 
 // randIntn returns a cryptographically random int in [0, n).
 func randIntn(n int) int {
-	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	return int(randBigIntn(big.NewInt(int64(n))).Int64())
+}
+
+// randBigIntn returns a cryptographically random integer in [0, n).
+func randBigIntn(n *big.Int) *big.Int {
+	v, err := rand.Int(rand.Reader, n)
 	if err != nil {
 		panic("crypto/rand: " + err.Error())
 	}
-	return int(v.Int64())
+	return v
 }
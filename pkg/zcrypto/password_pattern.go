@@ -0,0 +1,247 @@
+package zcrypto
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// letters for pronounceable passwords and pattern placeholders
+const (
+	consonantChars = "bcdfghjklmnpqrstvwxyz"
+	vowelChars     = "aeiou"
+)
+
+// WithPattern generates the password from a template instead of a length,
+// for sites with fixed formats. Each placeholder is replaced by a random
+// character from its class:
+//
+//	c  lowercase consonant    C  uppercase consonant
+//	v  lowercase vowel        V  uppercase vowel
+//	a  lowercase letter       A  uppercase letter
+//	9  digit                  s  symbol
+//	*  any character
+//
+// Any other character is copied as is; a backslash copies the next
+// character literally, so `\9` is a literal 9. For example "Cvccvc-99"
+// produces passwords like "Tobnar-47". The length argument, the length and
+// minimum count options and WithoutSymbols are ignored, so * and s include
+// symbols unless WithSymbols("") removes them; WithSymbols and
+// WithoutAmbiguous narrow the placeholder classes.
+//
+// A placeholder whose class they narrow to nothing, such as s with
+// WithSymbols(""), cannot be filled and is copied as the literal letter,
+// so that part of the password is fixed text; PasswordEntropy counts it
+// as zero bits. Check patterns built from user input with
+// ValidatePasswordPattern first.
+func WithPattern(pattern string) PasswordOption {
+	return func(c *passwordConfig) {
+		c.pattern = pattern
+	}
+}
+
+// WithPronounceable generates passwords of alternating consonants and
+// vowels, which are easier to read out and type, like "Dibopa-4". The
+// minimum uppercase count capitalizes that many random letters, and the
+// minimum digit and symbol counts are appended as a short suffix. Each
+// letter carries less entropy than a random character, so use a longer
+// length for the same strength; PasswordEntropy reports it.
+func WithPronounceable() PasswordOption {
+	return func(c *passwordConfig) {
+		c.pronounceable = true
+	}
+}
+
+// ValidatePasswordPattern reports whether GeneratePassword can fill every
+// placeholder of the pattern set by WithPattern among opts. It returns an
+// error naming the first placeholder whose class the other options leave
+// empty, or a trailing backslash with nothing to escape, and nil when opts
+// set no pattern.
+func ValidatePasswordPattern(opts ...PasswordOption) error {
+	cfg := defaultPasswordConfig()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	escaped := false
+	for i, r := range cfg.pattern {
+		if !escaped && r == '\\' {
+			escaped = true
+			continue
+		}
+		if class, ok := cfg.patternClass(r); ok && !escaped && class == "" {
+			return fmt.Errorf("pattern placeholder %q at byte %d has no characters to draw from", r, i)
+		}
+		escaped = false
+	}
+	if escaped {
+		return errors.New("pattern ends with an unpaired backslash")
+	}
+	return nil
+}
+
+// patternClass returns the characters a placeholder stands for.
+func (c passwordConfig) patternClass(r rune) (string, bool) {
+	switch r {
+	case 'c':
+		return c.filter(consonantChars), true
+	case 'C':
+		return c.filter(strings.ToUpper(consonantChars)), true
+	case 'v':
+		return c.filter(vowelChars), true
+	case 'V':
+		return c.filter(strings.ToUpper(vowelChars)), true
+	case 'a':
+		return c.filter(lowerChars), true
+	case 'A':
+		return c.filter(upperChars), true
+	case '9':
+		return c.filter(digitChars), true
+	case 's':
+		return c.filter(c.symbolSet), true
+	case '*':
+		return c.filter(lowerChars + upperChars + digitChars + c.symbolSet), true
+	default:
+		return "", false
+	}
+}
+
+// walkPattern calls fn with the class of each placeholder, or with the
+// literal for other characters.
+func (c passwordConfig) walkPattern(fn func(class string, literal rune)) {
+	escaped := false
+	for _, r := range c.pattern {
+		if !escaped && r == '\\' {
+			escaped = true
+			continue
+		}
+		if class, ok := c.patternClass(r); ok && !escaped && class != "" {
+			fn(class, 0)
+		} else {
+			fn("", r)
+		}
+		escaped = false
+	}
+}
+
+func generateFromPattern(cfg passwordConfig) string {
+	var b strings.Builder
+	cfg.walkPattern(func(class string, literal rune) {
+		if class != "" {
+			b.WriteRune(pickRune(class))
+		} else {
+			b.WriteRune(literal)
+		}
+	})
+	return b.String()
+}
+
+func patternEntropy(cfg passwordConfig) float64 {
+	var bits float64
+	cfg.walkPattern(func(class string, _ rune) {
+		if class != "" {
+			bits += charsetEntropy(class)
+		}
+	})
+	return bits
+}
+
+// pronounceableLetters returns the consonants and vowels to draw from;
+// with WithoutAmbiguous, letters that are ambiguous in either case are
+// dropped, since any letter may be capitalized.
+func (c passwordConfig) pronounceableLetters() (string, string) {
+	keep := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if c.filter(string(r)) == "" || c.filter(strings.ToUpper(string(r))) == "" {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	return keep(consonantChars), keep(vowelChars)
+}
+
+// pronounceableSuffix returns the digit and symbol counts and classes for
+// the suffix.
+func (c passwordConfig) pronounceableSuffix() (digits, symbols int, digitSet, symbolSet string) {
+	digits, digitSet = c.minDigits, c.filter(digitChars)
+	if sym := c.filter(c.symbolSet); c.symbols && sym != "" {
+		symbols, symbolSet = c.minSymbols, sym
+	}
+	return digits, symbols, digitSet, symbolSet
+}
+
+func generatePronounceable(length int, cfg passwordConfig) string {
+	cons, vows := cfg.pronounceableLetters()
+	digits, symbols, digitSet, symbolSet := cfg.pronounceableSuffix()
+	letters := length - digits - symbols
+
+	// start with a vowel in proportion to the strings that start gives,
+	// so that every string of letters is equally likely
+	nc, nv := big.NewInt(int64(len(cons))), big.NewInt(int64(len(vows)))
+	long, short := big.NewInt(int64((letters+1)/2)), big.NewInt(int64(letters/2))
+	consFirst := new(big.Int).Mul(new(big.Int).Exp(nc, long, nil), new(big.Int).Exp(nv, short, nil))
+	vowFirst := new(big.Int).Mul(new(big.Int).Exp(nv, long, nil), new(big.Int).Exp(nc, short, nil))
+	vowel := randBigIntn(new(big.Int).Add(consFirst, vowFirst)).Cmp(consFirst) >= 0
+
+	buf := make([]rune, 0, length)
+	for range letters {
+		if vowel {
+			buf = append(buf, pickRune(vows))
+		} else {
+			buf = append(buf, pickRune(cons))
+		}
+		vowel = !vowel
+	}
+
+	// capitalize distinct random letters: the first minUpper of a shuffle
+	idx := make([]int, letters)
+	for i := range idx {
+		idx[i] = i
+	}
+	for i := 0; i < min(cfg.minUpper, letters); i++ {
+		j := i + randIntn(letters-i)
+		idx[i], idx[j] = idx[j], idx[i]
+		buf[idx[i]] -= 'a' - 'A'
+	}
+
+	suffix := make([]rune, 0, digits+symbols)
+	for range digits {
+		suffix = append(suffix, pickRune(digitSet))
+	}
+	for range symbols {
+		suffix = append(suffix, pickRune(symbolSet))
+	}
+	shuffle(suffix)
+
+	return string(append(buf, suffix...))
+}
+
+func pronounceableEntropy(length int, cfg passwordConfig) float64 {
+	cons, vows := cfg.pronounceableLetters()
+	digits, symbols, digitSet, symbolSet := cfg.pronounceableSuffix()
+	letters := length - digits - symbols
+
+	var bits float64
+	if letters > 0 {
+		// starting with a consonant or a vowel gives disjoint sets of
+		// strings, and the start is picked in proportion to their sizes,
+		// so every string is equally likely; add the sizes
+		c, v := math.Log2(float64(len(cons))), math.Log2(float64(len(vows)))
+		long, short := float64((letters+1)/2), float64(letters/2)
+		a, b := long*c+short*v, long*v+short*c
+		bits = max(a, b) + math.Log2(1+math.Exp2(min(a, b)-max(a, b)))
+		bits += math.Log2(binomial(letters, min(cfg.minUpper, letters)))
+	}
+
+	bits += math.Log2(binomial(digits+symbols, digits))
+	if digits > 0 {
+		bits += float64(digits) * charsetEntropy(digitSet)
+	}
+	if symbols > 0 {
+		bits += float64(symbols) * charsetEntropy(symbolSet)
+	}
+	return bits
+}
@@ -1,8 +1,10 @@
 package zcrypto
 
 import (
+	"math"
 	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestGeneratePassword(t *testing.T) {
@@ -100,6 +102,70 @@ func TestGeneratePasswordWithCharset(t *testing.T) {
 	}
 }
 
+func TestGeneratePasswordUnicode(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []PasswordOption
+		chars string
+	}{
+		{"charset", []PasswordOption{WithCharset("äöü€")}, "äöü€"},
+		{"symbols", []PasswordOption{WithSymbols("§±"), WithMinSymbols(6)}, lowerChars + upperChars + digitChars + "§±"},
+		{"pattern", []PasswordOption{WithPattern("ss*"), WithSymbols("§±")}, lowerChars + upperChars + digitChars + "§±"},
+		{"pronounceable", []PasswordOption{WithPronounceable(), WithSymbols("§±"), WithMinSymbols(3)}, lowerChars + upperChars + digitChars + "§±"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 50 {
+				pw := GeneratePassword(8, tt.opts...)
+				if !utf8.ValidString(pw) {
+					t.Fatalf("%q is not valid UTF-8", pw)
+				}
+				for _, r := range pw {
+					if !strings.ContainsRune(tt.chars, r) {
+						t.Fatalf("%q contains %q, not in %q", pw, r, tt.chars)
+					}
+				}
+			}
+		})
+	}
+
+	if n := utf8.RuneCountInString(GeneratePassword(8, WithCharset("äöü€"))); n != 8 {
+		t.Fatalf("length = %d characters, want 8", n)
+	}
+}
+
+func TestGeneratePasswordRepeatedCharset(t *testing.T) {
+	// "aab" is two characters, each picked half the time
+	var a int
+	const n = 4000
+	for _, r := range GeneratePassword(n, WithCharset("aab")) {
+		if r == 'a' {
+			a++
+		}
+	}
+	if a < n*45/100 || a > n*55/100 {
+		t.Fatalf("picked a %d of %d times, want about half", a, n)
+	}
+}
+
+func TestGeneratePasswordPronounceableStart(t *testing.T) {
+	// 21³×5² strings start with a consonant and 5³×21² with a vowel;
+	// each start is picked in proportion
+	opts := []PasswordOption{WithPronounceable(), WithMinUpper(0), WithMinDigits(0), WithMinSymbols(0)}
+	var vowel int
+	const n = 2000
+	for range n {
+		pw := GeneratePassword(5, opts...)
+		if strings.ContainsRune(vowelChars, rune(pw[0])) {
+			vowel++
+		}
+	}
+	if want := n * 55125 / 286650; vowel < want*3/4 || vowel > want*5/4 {
+		t.Fatalf("%d of %d start with a vowel, want about %d", vowel, n, want)
+	}
+}
+
 func TestGeneratePasswordWithCharsetMinLength(t *testing.T) {
 	// custom charset bypasses the 4-char minimum, clamps to 1
 	pw := GeneratePassword(0, WithCharset("x"))
@@ -141,3 +207,307 @@ func TestGeneratePasswordCharacterDistribution(t *testing.T) {
 		}
 	}
 }
+
+func TestGeneratePasswordMinCounts(t *testing.T) {
+	count := func(s, chars string) int {
+		n := 0
+		for _, r := range s {
+			if strings.ContainsRune(chars, r) {
+				n++
+			}
+		}
+		return n
+	}
+
+	tests := []struct {
+		name    string
+		length  int
+		opts    []PasswordOption
+		wantLen int
+		lower   int
+		upper   int
+		digits  int
+		symbols int
+	}{
+		{"defaults", 12, nil, 12, 1, 1, 1, 1},
+		{"more digits and symbols", 12, []PasswordOption{WithMinDigits(4), WithMinSymbols(3)}, 12, 1, 1, 4, 3},
+		{"minimums raise length", 4, []PasswordOption{WithMinUpper(3), WithMinDigits(3)}, 8, 1, 3, 3, 1},
+		{"no upper required", 8, []PasswordOption{WithMinUpper(0)}, 8, 1, 0, 1, 1},
+		{"max length", 64, []PasswordOption{WithMaxLength(16)}, 16, 1, 1, 1, 1},
+		{"minimums beat max length", 10, []PasswordOption{WithMaxLength(6), WithMinDigits(6)}, 9, 1, 1, 6, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 20 {
+				pw := GeneratePassword(tt.length, tt.opts...)
+				if len(pw) != tt.wantLen {
+					t.Fatalf("%q has length %d, want %d", pw, len(pw), tt.wantLen)
+				}
+				if count(pw, lowerChars) < tt.lower || count(pw, upperChars) < tt.upper ||
+					count(pw, digitChars) < tt.digits || count(pw, symbolChars) < tt.symbols {
+					t.Fatalf("%q misses a minimum", pw)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratePasswordWithSymbols(t *testing.T) {
+	for range 50 {
+		pw := GeneratePassword(20, WithSymbols("!#"), WithMinSymbols(3))
+		for _, r := range pw {
+			if strings.ContainsRune(symbolChars, r) && r != '!' && r != '#' {
+				t.Fatalf("password %q contains disallowed symbol %q", pw, r)
+			}
+		}
+		if strings.Count(pw, "!")+strings.Count(pw, "#") < 3 {
+			t.Fatalf("password %q has fewer than 3 symbols", pw)
+		}
+	}
+}
+
+func TestGeneratePasswordWithoutAmbiguous(t *testing.T) {
+	modes := map[string][]PasswordOption{
+		"default":       nil,
+		"pattern":       {WithPattern("**********AAAAA99999")},
+		"pronounceable": {WithPronounceable(), WithMinUpper(4)},
+	}
+
+	for name, opts := range modes {
+		t.Run(name, func(t *testing.T) {
+			opts := append(opts, WithoutAmbiguous())
+			for range 200 {
+				pw := GeneratePassword(20, opts...)
+				if strings.ContainsAny(pw, ambiguousChars) {
+					t.Fatalf("password %q contains an ambiguous character", pw)
+				}
+			}
+		})
+	}
+}
+
+func TestGeneratePasswordWithPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		classes []string
+	}{
+		{"Cvccvc-99", []string{
+			strings.ToUpper(consonantChars), vowelChars, consonantChars, consonantChars,
+			vowelChars, consonantChars, "-", digitChars, digitChars,
+		}},
+		{"aA9s*", []string{lowerChars, upperChars, digitChars, symbolChars, lowerChars + upperChars + digitChars + symbolChars}},
+		{`\9\\V`, []string{"9", `\`, strings.ToUpper(vowelChars)}},
+		{"pin: 9999", []string{"p", "i", "n", ":", " ", digitChars, digitChars, digitChars, digitChars}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			for range 50 {
+				// the length argument is ignored
+				pw := GeneratePassword(100, WithPattern(tt.pattern))
+				if len(pw) != len(tt.classes) {
+					t.Fatalf("%q has length %d, want %d", pw, len(pw), len(tt.classes))
+				}
+				for i, class := range tt.classes {
+					if !strings.ContainsRune(class, rune(pw[i])) {
+						t.Fatalf("%q: position %d %q not in %q", pw, i, pw[i], class)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValidatePasswordPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []PasswordOption
+		wantErr bool
+	}{
+		{"no pattern", nil, false},
+		{"fillable", []PasswordOption{WithPattern(`Cvc-99\s`)}, false},
+		{"escaped empty class", []PasswordOption{WithPattern(`a\s`), WithSymbols("")}, false},
+		{"no symbols", []PasswordOption{WithPattern("a9s"), WithSymbols("")}, true},
+		{"only ambiguous symbols", []PasswordOption{WithPattern("s"), WithSymbols("|"), WithoutAmbiguous()}, true},
+		{"trailing backslash", []PasswordOption{WithPattern(`a\`)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidatePasswordPattern(tt.opts...); (err != nil) != tt.wantErr {
+				t.Fatalf("validate = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// the unfillable placeholder is copied and adds nothing
+	opts := []PasswordOption{WithPattern("99s"), WithSymbols("")}
+	if pw := GeneratePassword(0, opts...); pw[2] != 's' {
+		t.Fatalf("%q: empty class not copied literally", pw)
+	}
+	if got, want := PasswordEntropy(0, opts...), math.Log2(100); math.Abs(got-want) > 0.001 {
+		t.Fatalf("entropy = %.4f, want %.4f", got, want)
+	}
+}
+
+func TestGeneratePasswordPronounceable(t *testing.T) {
+	for range 100 {
+		pw := GeneratePassword(12, WithPronounceable(), WithMinUpper(2), WithMinDigits(2), WithMinSymbols(1))
+		if len(pw) != 12 {
+			t.Fatalf("%q has length %d, want 12", pw, len(pw))
+		}
+
+		letters, suffix := pw[:9], pw[9:]
+		upper := 0
+		for i := range letters {
+			c := letters[i]
+			if c >= 'A' && c <= 'Z' {
+				upper++
+				c += 'a' - 'A'
+			}
+			if i > 0 {
+				prev := strings.ToLower(letters[i-1 : i])
+				if strings.ContainsRune(vowelChars, rune(c)) == strings.ContainsAny(prev, vowelChars) {
+					t.Fatalf("%q does not alternate consonants and vowels", pw)
+				}
+			}
+		}
+		if upper != 2 {
+			t.Fatalf("%q has %d uppercase letters, want 2", pw, upper)
+		}
+		var digits, symbols int
+		for _, r := range suffix {
+			switch {
+			case strings.ContainsRune(digitChars, r):
+				digits++
+			case strings.ContainsRune(symbolChars, r):
+				symbols++
+			}
+		}
+		if digits != 2 || symbols != 1 {
+			t.Fatalf("%q suffix %q is not 2 digits and a symbol", pw, suffix)
+		}
+	}
+}
+
+func TestPasswordEntropyOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		length int
+		opts   []PasswordOption
+		want   float64
+	}{
+		// only composition: 2 digits, 1 lower, 1 upper, in 4!/2! orders
+		{"minimum counts", 4, []PasswordOption{WithMinDigits(2), WithoutSymbols()}, math.Log2(12 * 100 * 26 * 26)},
+		{"pattern", 0, []PasswordOption{WithPattern("Cvc-99")}, math.Log2(21 * 5 * 21 * 100)},
+		{"pattern without ambiguous", 0, []PasswordOption{WithPattern("9A"), WithoutAmbiguous()}, math.Log2(8 * 24)},
+		// WithoutSymbols does not apply to patterns
+		{"pattern any without symbols", 0, []PasswordOption{WithPattern("*"), WithoutSymbols()}, math.Log2(26 + 26 + 10 + 26)},
+		{"repeated charset", 4, []PasswordOption{WithCharset("aab")}, 4},
+		{"repeated symbols", 0, []PasswordOption{WithPattern("ss"), WithSymbols("!!?")}, 2},
+		// 21³×5² consonant-first and 5³×21² vowel-first strings
+		{"pronounceable odd", 5, []PasswordOption{WithPronounceable(), WithMinUpper(0), WithMinDigits(0), WithMinSymbols(0)}, math.Log2(21*21*21*5*5 + 5*5*5*21*21)},
+		// consonant-vowel-consonant-vowel or the reverse
+		{"pronounceable", 4, []PasswordOption{WithPronounceable(), WithMinUpper(0), WithMinDigits(0), WithMinSymbols(0)}, math.Log2(2 * 21 * 21 * 5 * 5)},
+		{"pronounceable suffix", 5, []PasswordOption{WithPronounceable(), WithMinUpper(1), WithMinSymbols(0), WithSymbols("")},
+			math.Log2((21*5*21*5 + 5*21*5*21) * 4 * 10)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PasswordEntropy(tt.length, tt.opts...); math.Abs(got-tt.want) > 0.001 {
+				t.Fatalf("entropy = %.4f, want %.4f", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratePasswordWithoutSymbolsIgnoresMinimum(t *testing.T) {
+	for range 50 {
+		pw := GeneratePassword(6, WithMinSymbols(5), WithoutSymbols())
+		if len(pw) != 6 {
+			t.Fatalf("%q has length %d, want 6", pw, len(pw))
+		}
+		if strings.ContainsAny(pw, symbolChars) {
+			t.Fatalf("%q contains a symbol", pw)
+		}
+	}
+}
+
+func TestClassMinEntropyMatchesGenerator(t *testing.T) {
+	tests := []struct {
+		name    string
+		length  int
+		classes []passwordClass
+	}{
+		{"all guaranteed", 2, []passwordClass{{"ab", 1}, {"xyz", 1}}},
+		{"one fill", 3, []passwordClass{{"ab", 1}, {"xyz", 1}}},
+		{"uneven minimums", 4, []passwordClass{{"ab", 2}, {"xyz", 1}, {"0", 0}}},
+		{"no minimums", 3, []passwordClass{{"ab", 0}, {"xyz", 0}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// enumerate every pick sequence and every shuffle the way
+			// GeneratePassword makes them
+			var slots []string
+			var charset string
+			for _, c := range tt.classes {
+				charset += c.chars
+				for range c.min {
+					slots = append(slots, c.chars)
+				}
+			}
+			for len(slots) < tt.length {
+				slots = append(slots, charset)
+			}
+
+			probs := make(map[string]float64)
+			var pick func(buf []byte, p float64)
+			pick = func(buf []byte, p float64) {
+				if len(buf) == len(slots) {
+					perms := permutations(len(buf))
+					for _, perm := range perms {
+						out := make([]byte, len(buf))
+						for i, j := range perm {
+							out[i] = buf[j]
+						}
+						probs[string(out)] += p / float64(len(perms))
+					}
+					return
+				}
+				chars := slots[len(buf)]
+				for i := range len(chars) {
+					pick(append(buf, chars[i]), p/float64(len(chars)))
+				}
+			}
+			pick(nil, 1)
+
+			var most float64
+			for _, p := range probs {
+				most = max(most, p)
+			}
+
+			want := -math.Log2(most)
+			if got := classMinEntropy(tt.length, tt.classes); math.Abs(got-want) > 1e-9 {
+				t.Fatalf("min-entropy = %.6f, want %.6f", got, want)
+			}
+		})
+	}
+}
+
+// permutations returns every ordering of 0..n-1.
+func permutations(n int) [][]int {
+	if n == 0 {
+		return [][]int{{}}
+	}
+	var out [][]int
+	for _, p := range permutations(n - 1) {
+		for i := 0; i <= len(p); i++ {
+			q := append(append(append([]int{}, p[:i]...), n-1), p[i:]...)
+			out = append(out, q)
+		}
+	}
+	return out
+}
@@ -0,0 +1,569 @@
+package zcrypto
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// qrQuietZone is the light border, in modules, the QR spec requires around
+// a symbol for reliable scanning.
+const qrQuietZone = 4
+
+// QRLevel is a QR code error correction level. Higher levels survive more
+// damage at the cost of a larger symbol.
+type QRLevel byte
+
+// QR code error correction levels, with the share of the symbol each can
+// recover.
+const (
+	QRLow      QRLevel = iota // ~7%
+	QRMedium                  // ~15%
+	QRQuartile                // ~25%
+	QRHigh                    // ~30%
+)
+
+// qrECCPerBlock and qrBlocks give, per level and version, the error
+// correction codewords in each block and the number of blocks (ISO/IEC
+// 18004 table 9). Index 0 is unused.
+var (
+	qrECCPerBlock = [4][41]int{
+		{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+		{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+		{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	}
+	qrBlocks = [4][41]int{
+		{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+		{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+		{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+		{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+	}
+)
+
+// QRCode is an encoded QR code symbol. Render it with HalfBlocks for a
+// terminal or WritePNG for export.
+type QRCode struct {
+	size     int
+	modules  []bool // dark modules, row-major
+	reserved []bool // function patterns, excluded from masking
+}
+
+// NewQRCode encodes content in byte mode at the given error correction
+// level, choosing the smallest version (21x21 to 177x177 modules) that fits.
+func NewQRCode(content string, level QRLevel) (*QRCode, error) {
+	if level > QRHigh {
+		return nil, fmt.Errorf("invalid QR level %d", level)
+	}
+
+	data := []byte(content)
+	version := 0
+	for v := 1; v <= 40; v++ {
+		if qrHeaderBits(v)+8*len(data) <= 8*qrDataCodewords(v, level) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("content too long for a QR code: %d bytes", len(data))
+	}
+
+	q := &QRCode{size: 17 + 4*version}
+	q.modules = make([]bool, q.size*q.size)
+	q.reserved = make([]bool, q.size*q.size)
+
+	q.drawFunctionPatterns(version)
+	q.drawCodewords(qrInterleave(qrEncodeData(data, version, level), version, level))
+
+	// keep the mask with the lowest penalty, as the spec requires
+	best, bestPenalty := 0, -1
+	for mask := range 8 {
+		q.applyMask(mask)
+		q.drawFormatBits(level, mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		q.applyMask(mask) // xor again to undo
+	}
+	q.applyMask(best)
+	q.drawFormatBits(level, best)
+
+	return q, nil
+}
+
+// QRCode encodes the key's provisioning URI as a QR code for authenticator
+// apps to scan, at medium error correction.
+func (k OTPKey) QRCode() (*QRCode, error) {
+	uri, err := k.URI()
+	if err != nil {
+		return nil, err
+	}
+	return NewQRCode(uri, QRMedium)
+}
+
+// Size returns the width and height of the symbol in modules, excluding the
+// quiet zone.
+func (q *QRCode) Size() int { return q.size }
+
+// Dark reports whether the module at column x, row y is dark. Coordinates
+// outside the symbol are light.
+func (q *QRCode) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= q.size || y >= q.size {
+		return false
+	}
+	return q.modules[y*q.size+x]
+}
+
+// HalfBlocks renders the symbol and its quiet zone as Unicode half-block
+// characters, two module rows per line, so it appears square in most
+// terminal fonts. Dark modules are drawn as ink, which suits a style with a
+// dark foreground on a light background. Set invert on plain dark-background
+// terminals so light modules are drawn instead.
+func (q *QRCode) HalfBlocks(invert bool) string {
+	ink := func(x, y int) bool { return q.Dark(x, y) != invert }
+
+	var b strings.Builder
+	for y := -qrQuietZone; y < q.size+qrQuietZone; y += 2 {
+		for x := -qrQuietZone; x < q.size+qrQuietZone; x++ {
+			top, bottom := ink(x, y), ink(x, y+1)
+			switch {
+			case top && bottom:
+				b.WriteRune('█')
+			case top:
+				b.WriteRune('▀')
+			case bottom:
+				b.WriteRune('▄')
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// Image renders the symbol and its quiet zone as a black and white image
+// with each module scale pixels square.
+func (q *QRCode) Image(scale int) image.Image {
+	scale = max(scale, 1)
+	n := (q.size + 2*qrQuietZone) * scale
+
+	img := image.NewPaletted(image.Rect(0, 0, n, n), color.Palette{color.White, color.Black})
+	for py := range n {
+		for px := range n {
+			if q.Dark(px/scale-qrQuietZone, py/scale-qrQuietZone) {
+				img.SetColorIndex(px, py, 1)
+			}
+		}
+	}
+	return img
+}
+
+// WritePNG writes the symbol to w as a PNG with each module scale pixels
+// square.
+func (q *QRCode) WritePNG(w io.Writer, scale int) error {
+	if err := png.Encode(w, q.Image(scale)); err != nil {
+		return fmt.Errorf("encode png: %w", err)
+	}
+	return nil
+}
+
+func (q *QRCode) set(x, y int, dark bool) {
+	q.modules[y*q.size+x] = dark
+	q.reserved[y*q.size+x] = true
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns and
+// the version information, and reserves the format information area.
+func (q *QRCode) drawFunctionPatterns(version int) {
+	for i := range q.size {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+
+	q.drawFinder(3, 3)
+	q.drawFinder(q.size-4, 3)
+	q.drawFinder(3, q.size-4)
+
+	pos := qrAlignmentPositions(version)
+	for i, x := range pos {
+		for j, y := range pos {
+			// skip the three corners occupied by finders
+			if (i == 0 && j == 0) || (i == 0 && j == len(pos)-1) || (i == len(pos)-1 && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// placeholder so the area is reserved while data is drawn
+	q.drawFormatBits(QRMedium, 0)
+
+	if version >= 7 {
+		bits := version<<12 | qrBCH(version, 0x1f25, 12)
+		for i := range 18 {
+			dark := bits>>i&1 == 1
+			a, b := q.size-11+i%3, i/3
+			q.set(a, b, dark)
+			q.set(b, a, dark)
+		}
+	}
+}
+
+// drawFinder draws a finder pattern and its separator centred on x, y.
+func (q *QRCode) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= q.size || yy >= q.size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			q.set(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the format information for level and
+// mask.
+func (q *QRCode) drawFormatBits(level QRLevel, mask int) {
+	// the spec orders levels M, L, H, Q in the format bits
+	data := int([4]byte{1, 0, 3, 2}[level])<<3 | mask
+	bits := (data<<10 | qrBCH(data, 0x537, 10)) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	// around the top-left finder
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+
+	// split between the other two finders
+	for i := range 8 {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true) // always dark
+}
+
+// drawCodewords places the codewords in the zigzag order the spec defines:
+// two-module columns from the right, alternating upwards and downwards,
+// skipping function patterns and the vertical timing column.
+func (q *QRCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := range q.size {
+			for j := range 2 {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if q.reserved[y*q.size+x] || i >= len(codewords)*8 {
+					continue
+				}
+				q.modules[y*q.size+x] = codewords[i>>3]>>(7-i&7)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask xors the data modules with the given mask pattern.
+func (q *QRCode) applyMask(mask int) {
+	for y := range q.size {
+		for x := range q.size {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.reserved[y*q.size+x] {
+				q.modules[y*q.size+x] = !q.modules[y*q.size+x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol with the four mask evaluation rules of the
+// spec; lower is better.
+func (q *QRCode) penalty() int {
+	const (
+		n1 = 3
+		n2 = 3
+		n3 = 40
+		n4 = 10
+	)
+
+	score := 0
+	line := make([]bool, q.size)
+	for _, vertical := range []bool{false, true} {
+		for a := range q.size {
+			for b := range q.size {
+				if vertical {
+					line[b] = q.Dark(a, b)
+				} else {
+					line[b] = q.Dark(b, a)
+				}
+			}
+
+			// runs of five or more modules of one colour
+			run := 1
+			for i := 1; i <= q.size; i++ {
+				if i < q.size && line[i] == line[i-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += n1 + run - 5
+				}
+				run = 1
+			}
+
+			// finder-like 1:1:3:1:1 patterns with four light modules on
+			// either side, counting the quiet zone as light
+			at := func(i int) bool { return i >= 0 && i < q.size && line[i] }
+			for i := -4; i < q.size; i++ {
+				if at(i) && !at(i+1) && at(i+2) && at(i+3) && at(i+4) && !at(i+5) && at(i+6) {
+					if !at(i-1) && !at(i-2) && !at(i-3) && !at(i-4) {
+						score += n3
+					}
+					if !at(i+7) && !at(i+8) && !at(i+9) && !at(i+10) {
+						score += n3
+					}
+				}
+			}
+		}
+	}
+
+	// 2x2 blocks of one colour, and the overall dark proportion
+	dark := 0
+	for y := range q.size {
+		for x := range q.size {
+			c := q.Dark(x, y)
+			if c {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size && c == q.Dark(x+1, y) && c == q.Dark(x, y+1) && c == q.Dark(x+1, y+1) {
+				score += n2
+			}
+		}
+	}
+	total := q.size * q.size
+	k := (abs(dark*20-total*10) + total - 1) / total
+	score += (k - 1) * n4
+
+	return score
+}
+
+// qrEncodeData builds the data codewords for a byte mode segment, padded
+// to the version's capacity.
+func qrEncodeData(data []byte, version int, level QRLevel) []byte {
+	capacity := qrDataCodewords(version, level)
+	out := make([]byte, 0, capacity)
+
+	var acc uint64
+	var n int
+	put := func(v uint64, bits int) {
+		acc = acc<<bits | v
+		n += bits
+		for n >= 8 {
+			out = append(out, byte(acc>>(n-8)))
+			n -= 8
+		}
+	}
+
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	put(0b0100, 4) // byte mode
+	put(uint64(len(data)), countBits)
+	for _, b := range data {
+		put(uint64(b), 8)
+	}
+
+	// terminator of up to four zero bits, then pad to a byte boundary
+	put(0, min(4, capacity*8-len(out)*8-n))
+	if n > 0 {
+		put(0, 8-n)
+	}
+
+	for pad := byte(0xec); len(out) < capacity; pad ^= 0xec ^ 0x11 {
+		out = append(out, pad)
+	}
+	return out
+}
+
+// qrInterleave splits data into blocks, appends Reed-Solomon error
+// correction to each, and interleaves the result.
+func qrInterleave(data []byte, version int, level QRLevel) []byte {
+	numBlocks := qrBlocks[level][version]
+	eccLen := qrECCPerBlock[level][version]
+	raw := qrRawModules(version) / 8
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw / numBlocks
+
+	divisor := rsDivisor(eccLen)
+	blocks := make([][]byte, numBlocks)
+	for i, k := 0, 0; i < numBlocks; i++ {
+		n := shortLen - eccLen
+		if i >= numShort {
+			n++
+		}
+		b := make([]byte, 0, shortLen+1)
+		b = append(b, data[k:k+n]...)
+		if i < numShort {
+			// pad short blocks so every block has the same layout; the
+			// padding is skipped when interleaving
+			b = append(b, 0)
+		}
+		blocks[i] = append(b, rsRemainder(data[k:k+n], divisor)...)
+		k += n
+	}
+
+	out := make([]byte, 0, raw)
+	for i := range shortLen + 1 {
+		for j, b := range blocks {
+			if i != shortLen-eccLen || j >= numShort {
+				out = append(out, b[i])
+			}
+		}
+	}
+	return out
+}
+
+// qrHeaderBits is the length of the byte mode header for version.
+func qrHeaderBits(version int) int {
+	if version >= 10 {
+		return 4 + 16
+	}
+	return 4 + 8
+}
+
+// qrDataCodewords is the number of data codewords a version holds at level.
+func qrDataCodewords(version int, level QRLevel) int {
+	return qrRawModules(version)/8 - qrECCPerBlock[level][version]*qrBlocks[level][version]
+}
+
+// qrRawModules counts the modules available for codewords in a version,
+// including the remainder bits.
+func qrRawModules(version int) int {
+	n := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		n -= (25*align-10)*align - 55
+		if version >= 7 {
+			n -= 36
+		}
+	}
+	return n
+}
+
+// qrAlignmentPositions returns the row and column centres of the alignment
+// patterns for version.
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+
+	num := version/7 + 2
+	step := (version*8 + num*3 + 5) / (num*4 - 4) * 2
+	pos := make([]int, num)
+	pos[0] = 6
+	for i, p := num-1, 17+4*version-7; i >= 1; i, p = i-1, p-step {
+		pos[i] = p
+	}
+	return pos
+}
+
+// qrBCH returns the remainder of data, shifted left by bits, divided by
+// the generator polynomial.
+func qrBCH(data, generator, bits int) int {
+	rem := data
+	for range bits {
+		rem = rem<<1 ^ (rem>>(bits-1))*generator
+	}
+	return rem
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of the given
+// degree, highest coefficient first with the leading 1 omitted.
+func rsDivisor(degree int) []byte {
+	out := make([]byte, degree)
+	out[degree-1] = 1
+
+	root := byte(1)
+	for range degree {
+		for j := range out {
+			out[j] = gfMul(out[j], root)
+			if j+1 < len(out) {
+				out[j] ^= out[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return out
+}
+
+// rsRemainder returns the error correction codewords for data.
+func rsRemainder(data, divisor []byte) []byte {
+	out := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ out[0]
+		copy(out, out[1:])
+		out[len(out)-1] = 0
+		for i, d := range divisor {
+			out[i] ^= gfMul(d, factor)
+		}
+	}
+	return out
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11d
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,403 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func TestQRCodeVersionCapacity(t *testing.T) {
+	// byte mode capacities from ISO/IEC 18004 table 7
+	tests := []struct {
+		version  int
+		level    zcrypto.QRLevel
+		capacity int
+	}{
+		{1, zcrypto.QRLow, 17},
+		{1, zcrypto.QRMedium, 14},
+		{1, zcrypto.QRQuartile, 11},
+		{1, zcrypto.QRHigh, 7},
+		{7, zcrypto.QRLow, 154},
+		{7, zcrypto.QRMedium, 122},
+		{7, zcrypto.QRQuartile, 86},
+		{7, zcrypto.QRHigh, 64},
+		{10, zcrypto.QRLow, 271},
+		{10, zcrypto.QRMedium, 213},
+		{10, zcrypto.QRQuartile, 151},
+		{10, zcrypto.QRHigh, 119},
+		{40, zcrypto.QRLow, 2953},
+		{40, zcrypto.QRMedium, 2331},
+		{40, zcrypto.QRQuartile, 1663},
+		{40, zcrypto.QRHigh, 1273},
+	}
+
+	for _, tt := range tests {
+		q, err := zcrypto.NewQRCode(strings.Repeat("a", tt.capacity), tt.level)
+		if err != nil {
+			t.Fatalf("version %d level %d: %v", tt.version, tt.level, err)
+		}
+		if want := 17 + 4*tt.version; q.Size() != want {
+			t.Errorf("version %d level %d: size %d, want %d", tt.version, tt.level, q.Size(), want)
+		}
+
+		q, err = zcrypto.NewQRCode(strings.Repeat("a", tt.capacity+1), tt.level)
+		if tt.version == 40 {
+			if err == nil {
+				t.Errorf("level %d: expected error beyond version 40", tt.level)
+			}
+			continue
+		}
+		if err != nil || q.Size() <= 17+4*tt.version {
+			t.Errorf("version %d level %d: one byte over did not grow the symbol", tt.version, tt.level)
+		}
+	}
+}
+
+func TestQRCodeRoundTrip(t *testing.T) {
+	uri := "otpauth://totp/zarlcorp:alice@example.com?issuer=zarlcorp&secret=JBSWY3DPEHPK3PXP"
+	tests := []struct {
+		name    string
+		content string
+		level   zcrypto.QRLevel
+	}{
+		{name: "empty", content: "", level: zcrypto.QRLow},
+		{name: "short", content: "hello", level: zcrypto.QRHigh},
+		{name: "otpauth", content: uri, level: zcrypto.QRMedium},
+		{name: "version info", content: strings.Repeat("x", 200), level: zcrypto.QRQuartile},
+		{name: "mixed blocks", content: strings.Repeat("0123456789", 60), level: zcrypto.QRMedium},
+		{name: "utf8", content: "zarlcorp ✓ ключ", level: zcrypto.QRLow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := zcrypto.NewQRCode(tt.content, tt.level)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+
+			level, got := decodeQR(t, q)
+			if level != tt.level {
+				t.Errorf("level = %d, want %d", level, tt.level)
+			}
+			if got != tt.content {
+				t.Errorf("decoded %q, want %q", got, tt.content)
+			}
+		})
+	}
+}
+
+func TestQRCodeVersionInfo(t *testing.T) {
+	q, err := zcrypto.NewQRCode(strings.Repeat("x", 110), zcrypto.QRMedium)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if q.Size() != 45 {
+		t.Fatalf("size = %d, want version 7", q.Size())
+	}
+
+	// version 7 information from ISO/IEC 18004 annex D
+	const want = 0x07c94
+	var got, mirror int
+	for i := range 18 {
+		a, b := q.Size()-11+i%3, i/3
+		if q.Dark(a, b) {
+			got |= 1 << i
+		}
+		if q.Dark(b, a) {
+			mirror |= 1 << i
+		}
+	}
+	if got != want || mirror != want {
+		t.Fatalf("version info %#x and %#x, want %#x", got, mirror, want)
+	}
+}
+
+func TestQRCodeInvalid(t *testing.T) {
+	if _, err := zcrypto.NewQRCode("x", zcrypto.QRLevel(4)); err == nil {
+		t.Fatal("expected error for invalid level")
+	}
+}
+
+func TestQRCodeHalfBlocks(t *testing.T) {
+	q, err := zcrypto.NewQRCode("hello", zcrypto.QRMedium)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	for _, invert := range []bool{false, true} {
+		lines := strings.Split(strings.TrimSuffix(q.HalfBlocks(invert), "\n"), "\n")
+
+		width := q.Size() + 8
+		if want := (width + 1) / 2; len(lines) != want {
+			t.Fatalf("invert %v: %d lines, want %d", invert, len(lines), want)
+		}
+
+		for y, line := range lines {
+			runes := []rune(line)
+			if len(runes) != width {
+				t.Fatalf("invert %v: line %d has %d runes, want %d", invert, y, len(runes), width)
+			}
+			for x, r := range runes {
+				mx, my := x-4, 2*y-4
+				top := q.Dark(mx, my) != invert
+				bottom := q.Dark(mx, my+1) != invert
+				if my+1 >= q.Size()+4 {
+					bottom = invert
+				}
+				if r != halfBlock(top, bottom) {
+					t.Fatalf("invert %v: rune %q at %d,%d", invert, r, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestQRCodeWritePNG(t *testing.T) {
+	q, err := zcrypto.NewQRCode("hello", zcrypto.QRMedium)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := q.WritePNG(&buf, 3); err != nil {
+		t.Fatalf("write png: %v", err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+
+	n := (q.Size() + 8) * 3
+	if b := img.Bounds(); b.Dx() != n || b.Dy() != n {
+		t.Fatalf("bounds %v, want %dx%d", b, n, n)
+	}
+
+	for y := range q.Size() {
+		for x := range q.Size() {
+			r, _, _, _ := img.At((x+4)*3+1, (y+4)*3+1).RGBA()
+			if dark := r == 0; dark != q.Dark(x, y) {
+				t.Fatalf("pixel for module %d,%d dark=%v", x, y, dark)
+			}
+		}
+	}
+}
+
+func TestOTPKeyQRCode(t *testing.T) {
+	k := zcrypto.OTPKey{Issuer: "zarlcorp", Account: "alice", Secret: "JBSWY3DPEHPK3PXP"}
+
+	q, err := k.QRCode()
+	if err != nil {
+		t.Fatalf("qr code: %v", err)
+	}
+
+	uri, _ := k.URI()
+	if _, got := decodeQR(t, q); got != uri {
+		t.Fatalf("decoded %q, want %q", got, uri)
+	}
+}
+
+func halfBlock(top, bottom bool) rune {
+	switch {
+	case top && bottom:
+		return '█'
+	case top:
+		return '▀'
+	case bottom:
+		return '▄'
+	default:
+		return ' '
+	}
+}
+
+// decodeQR is a minimal byte mode decoder. It reads the format information,
+// removes the mask, collects the codewords in placement order, checks every
+// block's Reed-Solomon syndromes, and parses the data segment.
+func decodeQR(t *testing.T, q *zcrypto.QRCode) (zcrypto.QRLevel, string) {
+	t.Helper()
+
+	size := q.Size()
+	version := (size - 17) / 4
+
+	// first copy of the format information, around the top-left finder
+	var format int
+	bit := func(i int, x, y int) {
+		if q.Dark(x, y) {
+			format |= 1 << i
+		}
+	}
+	for i := 0; i <= 5; i++ {
+		bit(i, 8, i)
+	}
+	bit(6, 8, 7)
+	bit(7, 8, 8)
+	bit(8, 7, 8)
+	for i := 9; i < 15; i++ {
+		bit(i, 14-i, 8)
+	}
+	format ^= 0x5412
+	if gfPolyMod(format, 0x537) != 0 {
+		t.Fatalf("format information %015b fails its BCH check", format)
+	}
+	level := zcrypto.QRLevel([4]byte{1, 0, 3, 2}[format>>13])
+	mask := format >> 10 & 7
+
+	masked := func(x, y int) bool {
+		switch mask {
+		case 0:
+			return (x+y)%2 == 0
+		case 1:
+			return y%2 == 0
+		case 2:
+			return x%3 == 0
+		case 3:
+			return (x+y)%3 == 0
+		case 4:
+			return (x/3+y/2)%2 == 0
+		case 5:
+			return x*y%2+x*y%3 == 0
+		case 6:
+			return (x*y%2+x*y%3)%2 == 0
+		default:
+			return ((x+y)%2+x*y%3)%2 == 0
+		}
+	}
+
+	// zigzag from the bottom-right, two columns at a time
+	var bits []bool
+	upward := true
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for i := range size {
+			y := i
+			if upward {
+				y = size - 1 - i
+			}
+			for _, x := range []int{right, right - 1} {
+				if !q.ReservedForTest(x, y) {
+					bits = append(bits, q.Dark(x, y) != masked(x, y))
+				}
+			}
+		}
+		upward = !upward
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i := range codewords {
+		for j := range 8 {
+			if bits[i*8+j] {
+				codewords[i] |= 0x80 >> j
+			}
+		}
+	}
+
+	// de-interleave; the later blocks are one data codeword longer
+	numBlocks, ecc := zcrypto.QRBlocksForTest(version, level)
+	numLong := len(codewords) % numBlocks
+	shortData := len(codewords)/numBlocks - ecc
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range shortData + 1 {
+		for j := range blocks {
+			if i < shortData || j >= numBlocks-numLong {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	for range ecc {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], codewords[k])
+			k++
+		}
+	}
+
+	var data []byte
+	for j, b := range blocks {
+		if !rsValid(b, ecc) {
+			t.Fatalf("block %d fails its Reed-Solomon check", j)
+		}
+		data = append(data, b[:len(b)-ecc]...)
+	}
+
+	// byte mode header: 4 bit mode, 8 or 16 bit length
+	r := bitReader{data: data}
+	if m := r.read(4); m != 0b0100 {
+		t.Fatalf("mode = %04b, want byte mode", m)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	n := r.read(countBits)
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = byte(r.read(8))
+	}
+	return level, string(out)
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) int {
+	v := 0
+	for range n {
+		v = v<<1 | int(r.data[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return v
+}
+
+// gfPolyMod returns the remainder of the binary polynomial v divided by g.
+func gfPolyMod(v, g int) int {
+	gl := 0
+	for g>>gl > 1 {
+		gl++
+	}
+	for l := 31; l >= gl; l-- {
+		if v>>l&1 == 1 {
+			v ^= g << (l - gl)
+		}
+	}
+	return v
+}
+
+// rsValid reports whether a codeword block has all-zero syndromes for the
+// QR generator polynomial with roots 2^0 .. 2^(ecc-1).
+func rsValid(block []byte, ecc int) bool {
+	mul := func(a, b byte) byte {
+		var p byte
+		for b > 0 {
+			if b&1 == 1 {
+				p ^= a
+			}
+			hi := a & 0x80
+			a <<= 1
+			if hi != 0 {
+				a ^= 0x1d
+			}
+			b >>= 1
+		}
+		return p
+	}
+
+	root := byte(1)
+	for range ecc {
+		var s byte
+		for _, c := range block {
+			s = mul(s, root) ^ c
+		}
+		if s != 0 {
+			return false
+		}
+		root = mul(root, 2)
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func TestEncryptWithAD(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+	plaintext := []byte("secret")
+
+	ct, err := zcrypto.EncryptWithAD(key, plaintext, []byte("record-1"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	got, err := zcrypto.DecryptWithAD(key, ct, []byte("record-1"))
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, want %q", got, plaintext)
+	}
+
+	tests := []struct {
+		name string
+		ad   []byte
+	}{
+		{name: "different ad", ad: []byte("record-2")},
+		{name: "missing ad", ad: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := zcrypto.DecryptWithAD(key, ct, tt.ad); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestEncryptWithADNilMatchesEncrypt(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+
+	ct, err := zcrypto.EncryptWithAD(key, []byte("hello"), nil)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	got, err := zcrypto.Decrypt(key, ct)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("got %q, want %q", got, "hello")
+	}
+}
+
+func TestNewSealerInvalidKey(t *testing.T) {
+	if _, err := zcrypto.NewSealer(make([]byte, 16)); err == nil {
+		t.Fatal("expected error for short key")
+	}
+}
+
+func TestSealerEncryptDecrypt(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+	s, err := zcrypto.NewSealer(key)
+	if err != nil {
+		t.Fatalf("new sealer: %v", err)
+	}
+
+	ct, err := s.Encrypt([]byte("hello"), []byte("ad"))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	// same wire format as the package-level functions
+	got, err := zcrypto.DecryptWithAD(key, ct, []byte("ad"))
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("got %q, want %q", got, "hello")
+	}
+
+	if _, err := s.Decrypt(ct[:5], []byte("ad")); err == nil {
+		t.Fatal("expected error for short ciphertext")
+	}
+}
+
+func TestSealerSealOpenInPlace(t *testing.T) {
+	s, err := zcrypto.NewSealer(make([]byte, zcrypto.KeySize))
+	if err != nil {
+		t.Fatalf("new sealer: %v", err)
+	}
+
+	nonce := make([]byte, zcrypto.NonceSize)
+	plaintext := []byte("in place")
+
+	buf := make([]byte, len(plaintext), len(plaintext)+zcrypto.TagSize)
+	copy(buf, plaintext)
+
+	ct, err := s.Seal(buf[:0], nonce, buf, nil)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if &ct[0] != &buf[0] || len(ct) != len(plaintext)+zcrypto.TagSize {
+		t.Fatal("seal did not reuse the buffer")
+	}
+
+	got, err := s.Open(ct[:0], nonce, ct, nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, want %q", got, plaintext)
+	}
+
+	if _, err := s.Seal(nil, nonce[:8], plaintext, nil); err == nil {
+		t.Fatal("expected error for short nonce")
+	}
+	if _, err := s.Open(nil, nonce, ct[:4], nil); err == nil {
+		t.Fatal("expected error for truncated ciphertext")
+	}
+}
+
+func TestSealerSealAllocs(t *testing.T) {
+	s, err := zcrypto.NewSealer(make([]byte, zcrypto.KeySize))
+	if err != nil {
+		t.Fatalf("new sealer: %v", err)
+	}
+
+	nonce := make([]byte, zcrypto.NonceSize)
+	buf := make([]byte, 1024, 1024+zcrypto.TagSize)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		ct, err := s.Seal(buf[:0], nonce, buf, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Open(ct[:0], nonce, ct, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("seal and open allocated %v times", allocs)
+	}
+}
@@ -0,0 +1,118 @@
+package zcrypto
+
+import (
+	"errors"
+	"runtime"
+)
+
+// ErrSecretBufferClosed is returned when a closed SecretBuffer is used.
+var ErrSecretBufferClosed = errors.New("secret buffer closed")
+
+// SecretBuffer holds a secret outside the Go heap, where the garbage
+// collector cannot copy it. On Linux the memory is mapped separately, locked
+// so it is never swapped to disk, excluded from core dumps and fenced by
+// inaccessible guard pages; the secret ends against the trailing guard page
+// so an overrun faults. Elsewhere it is ordinary memory that is erased on
+// Close.
+//
+// Close zeroes and releases the memory. A buffer that is never closed is
+// released when garbage collected, but Close is what guarantees the erase.
+// A SecretBuffer is not safe for concurrent use.
+type SecretBuffer struct {
+	mem      []byte // the whole allocation, including guard pages
+	data     []byte
+	readOnly bool
+	cleanup  runtime.Cleanup
+}
+
+// NewSecretBuffer allocates a zeroed SecretBuffer of size bytes. Locked
+// memory is limited per process (see ulimit -l), so keep buffers to the
+// keys themselves.
+func NewSecretBuffer(size int) (*SecretBuffer, error) {
+	if size < 1 {
+		return nil, errors.New("secret buffer size must be positive")
+	}
+
+	mem, data, err := allocSecret(size)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &SecretBuffer{mem: mem, data: data}
+	b.cleanup = runtime.AddCleanup(b, func(mem []byte) { _ = freeSecret(mem) }, mem)
+	return b, nil
+}
+
+// NewSecretBufferFrom moves secret into a new SecretBuffer and erases the
+// original.
+func NewSecretBufferFrom(secret []byte) (*SecretBuffer, error) {
+	b, err := NewSecretBuffer(len(secret))
+	if err != nil {
+		return nil, err
+	}
+	copy(b.data, secret)
+	Erase(secret)
+	return b, nil
+}
+
+// Bytes returns the secret. The slice is valid until Close; writing to it
+// while the buffer is read-only crashes the program on Linux.
+func (b *SecretBuffer) Bytes() []byte {
+	return b.data
+}
+
+// Len returns the size of the secret, or 0 once closed.
+func (b *SecretBuffer) Len() int {
+	return len(b.data)
+}
+
+// ReadOnly makes the secret read-only, so a stray write faults instead of
+// corrupting it. Only enforced on Linux.
+func (b *SecretBuffer) ReadOnly() error {
+	return b.protect(true)
+}
+
+// ReadWrite makes the secret writable again.
+func (b *SecretBuffer) ReadWrite() error {
+	return b.protect(false)
+}
+
+// IsReadOnly reports whether the buffer was made read-only.
+func (b *SecretBuffer) IsReadOnly() bool {
+	return b.readOnly
+}
+
+func (b *SecretBuffer) protect(readOnly bool) error {
+	if b.mem == nil {
+		return ErrSecretBufferClosed
+	}
+	if err := protectSecret(b.mem, readOnly); err != nil {
+		return err
+	}
+	b.readOnly = readOnly
+	return nil
+}
+
+// Close zeroes the secret and releases its memory. Calling Close again
+// does nothing.
+func (b *SecretBuffer) Close() error {
+	if b.mem == nil {
+		return nil
+	}
+
+	b.cleanup.Stop()
+	err := freeSecret(b.mem)
+	b.mem, b.data = nil, nil
+	return err
+}
+
+// writable returns the secret for a function that fills it.
+func (b *SecretBuffer) writable() ([]byte, error) {
+	switch {
+	case b.mem == nil:
+		return nil, ErrSecretBufferClosed
+	case b.readOnly:
+		return nil, errors.New("secret buffer is read-only")
+	}
+	return b.data, nil
+}
@@ -0,0 +1,77 @@
+package zcrypto
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// allocSecret maps size bytes between two guard pages, locked and kept out
+// of core dumps, and returns the mapping and the secret's place in it.
+func allocSecret(size int) (mem, data []byte, err error) {
+	page := os.Getpagesize()
+	inner := (size + page - 1) / page * page
+
+	mem, err = unix.Mmap(-1, 0, inner+2*page, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return nil, nil, fmt.Errorf("map secret memory: %w", err)
+	}
+
+	region := mem[page : page+inner]
+	if err := setupSecret(mem, region, page); err != nil {
+		_ = unix.Munmap(mem)
+		return nil, nil, err
+	}
+
+	// end the secret against the trailing guard page, so overruns fault
+	return mem, region[inner-size : inner : inner], nil
+}
+
+func setupSecret(mem, region []byte, page int) error {
+	if err := unix.Mprotect(mem[:page], unix.PROT_NONE); err != nil {
+		return fmt.Errorf("protect guard page: %w", err)
+	}
+	if err := unix.Mprotect(mem[len(mem)-page:], unix.PROT_NONE); err != nil {
+		return fmt.Errorf("protect guard page: %w", err)
+	}
+	if err := unix.Mlock(region); err != nil {
+		return fmt.Errorf("lock secret memory: %w", err)
+	}
+	if err := unix.Madvise(region, unix.MADV_DONTDUMP); err != nil {
+		return fmt.Errorf("exclude secret memory from core dumps: %w", err)
+	}
+	return nil
+}
+
+// secretRegion returns the part of a mapping between the guard pages.
+func secretRegion(mem []byte) []byte {
+	page := os.Getpagesize()
+	return mem[page : len(mem)-page]
+}
+
+func protectSecret(mem []byte, readOnly bool) error {
+	prot := unix.PROT_READ | unix.PROT_WRITE
+	if readOnly {
+		prot = unix.PROT_READ
+	}
+	if err := unix.Mprotect(secretRegion(mem), prot); err != nil {
+		return fmt.Errorf("protect secret memory: %w", err)
+	}
+	return nil
+}
+
+func freeSecret(mem []byte) error {
+	region := secretRegion(mem)
+	if err := unix.Mprotect(region, unix.PROT_READ|unix.PROT_WRITE); err != nil {
+		return fmt.Errorf("unprotect secret memory: %w", err)
+	}
+	Erase(region)
+	if err := unix.Munlock(region); err != nil {
+		return fmt.Errorf("unlock secret memory: %w", err)
+	}
+	if err := unix.Munmap(mem); err != nil {
+		return fmt.Errorf("unmap secret memory: %w", err)
+	}
+	return nil
+}
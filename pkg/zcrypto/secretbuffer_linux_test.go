@@ -0,0 +1,113 @@
+package zcrypto_test
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+// smapsEntry returns the /proc/self/smaps fields of the mapping holding addr.
+func smapsEntry(t *testing.T, addr uintptr) map[string]string {
+	t.Helper()
+
+	f, err := os.Open("/proc/self/smaps")
+	if err != nil {
+		t.Skipf("smaps unavailable: %v", err)
+	}
+	defer f.Close()
+
+	var fields map[string]string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		key, value, ok := strings.Cut(line, ":")
+		if ok && !strings.Contains(key, " ") {
+			if fields != nil {
+				fields[key] = strings.TrimSpace(value)
+			}
+			continue
+		}
+
+		// a mapping header: "start-end perms offset dev inode path"
+		if fields != nil {
+			return fields
+		}
+		lo, hi, _ := strings.Cut(strings.Fields(line)[0], "-")
+		start, _ := strconv.ParseUint(lo, 16, 64)
+		end, _ := strconv.ParseUint(hi, 16, 64)
+		if uint64(addr) >= start && uint64(addr) < end {
+			fields = make(map[string]string)
+		}
+	}
+	if fields == nil {
+		t.Fatalf("no mapping holds %#x", addr)
+	}
+	return fields
+}
+
+func TestSecretBufferLockedLinux(t *testing.T) {
+	b, err := zcrypto.NewSecretBuffer(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	m := smapsEntry(t, uintptr(unsafe.Pointer(&b.Bytes()[0])))
+	if m["Locked"] == "0 kB" {
+		t.Fatalf("secret memory is not locked: Locked: %s", m["Locked"])
+	}
+	if !strings.Contains(" "+m["VmFlags"]+" ", " dd ") {
+		t.Fatalf("secret memory is not excluded from core dumps: VmFlags: %s", m["VmFlags"])
+	}
+}
+
+// faults are fatal, so they are provoked in a child process
+const secretFaultEnv = "ZCRYPTO_SECRET_FAULT"
+
+func TestSecretBufferFaultsLinux(t *testing.T) {
+	if mode := os.Getenv(secretFaultEnv); mode != "" {
+		secretFault(mode)
+		return
+	}
+
+	for _, mode := range []string{"overrun", "read-only"} {
+		t.Run(mode, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestSecretBufferFaultsLinux$")
+			cmd.Env = append(os.Environ(), secretFaultEnv+"="+mode)
+			out, err := cmd.CombinedOutput()
+			if err == nil {
+				t.Fatalf("child survived:\n%s", out)
+			}
+			if !strings.Contains(string(out), "unexpected fault address") {
+				t.Fatalf("child did not fault:\n%s", out)
+			}
+		})
+	}
+}
+
+func secretFault(mode string) {
+	b, err := zcrypto.NewSecretBuffer(10)
+	if err != nil {
+		panic(err)
+	}
+	data := b.Bytes()
+
+	switch mode {
+	case "overrun":
+		// one past the end lands on the guard page
+		p := (*byte)(unsafe.Add(unsafe.Pointer(&data[len(data)-1]), 1))
+		fmt.Println(*p)
+	case "read-only":
+		if err := b.ReadOnly(); err != nil {
+			panic(err)
+		}
+		data[0] = 1
+	}
+}
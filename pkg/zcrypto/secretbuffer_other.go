@@ -0,0 +1,18 @@
+//go:build !linux
+
+package zcrypto
+
+// allocSecret falls back to ordinary memory, which Close still erases.
+func allocSecret(size int) (mem, data []byte, err error) {
+	mem = make([]byte, size)
+	return mem, mem, nil
+}
+
+func protectSecret(mem []byte, readOnly bool) error {
+	return nil
+}
+
+func freeSecret(mem []byte) error {
+	Erase(mem)
+	return nil
+}
@@ -0,0 +1,161 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func TestSecretBuffer(t *testing.T) {
+	for _, size := range []int{1, 32, 4096, 5000} {
+		b, err := zcrypto.NewSecretBuffer(size)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+
+		data := b.Bytes()
+		if len(data) != size || b.Len() != size {
+			t.Fatalf("size %d: got %d bytes", size, len(data))
+		}
+		if !bytes.Equal(data, make([]byte, size)) {
+			t.Fatalf("size %d: not zeroed", size)
+		}
+
+		for i := range data {
+			data[i] = byte(i)
+		}
+		if data[size-1] != byte(size-1) {
+			t.Fatalf("size %d: write lost", size)
+		}
+
+		if err := b.Close(); err != nil {
+			t.Fatalf("size %d: close: %v", size, err)
+		}
+		if b.Bytes() != nil || b.Len() != 0 {
+			t.Fatalf("size %d: buffer still usable after Close", size)
+		}
+		if err := b.Close(); err != nil {
+			t.Fatalf("size %d: second close: %v", size, err)
+		}
+	}
+
+	if _, err := zcrypto.NewSecretBuffer(0); err == nil {
+		t.Fatal("expected error for empty buffer")
+	}
+}
+
+func TestSecretBufferFrom(t *testing.T) {
+	secret := []byte("master key material")
+	want := bytes.Clone(secret)
+
+	b, err := zcrypto.NewSecretBufferFrom(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Fatalf("got %q, want %q", b.Bytes(), want)
+	}
+	if !bytes.Equal(secret, make([]byte, len(secret))) {
+		t.Fatal("original was not erased")
+	}
+}
+
+func TestSecretBufferReadOnly(t *testing.T) {
+	b, err := zcrypto.NewSecretBuffer(zcrypto.KeySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	b.Bytes()[0] = 42
+	if err := b.ReadOnly(); err != nil {
+		t.Fatal(err)
+	}
+	if !b.IsReadOnly() || b.Bytes()[0] != 42 {
+		t.Fatal("read-only buffer is not readable")
+	}
+
+	// filling a read-only buffer is refused rather than faulting
+	if _, err := zcrypto.DeriveKeyInto(b, []byte("pw"), nil); err == nil {
+		t.Fatal("expected error deriving into read-only buffer")
+	}
+
+	if err := b.ReadWrite(); err != nil {
+		t.Fatal(err)
+	}
+	b.Bytes()[0] = 43
+	if b.IsReadOnly() || b.Bytes()[0] != 43 {
+		t.Fatal("buffer is not writable again")
+	}
+
+	// Close works on a read-only buffer
+	if err := b.ReadOnly(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ReadWrite(); !errors.Is(err, zcrypto.ErrSecretBufferClosed) {
+		t.Fatalf("got %v, want ErrSecretBufferClosed", err)
+	}
+}
+
+func TestDeriveKeyInto(t *testing.T) {
+	password := []byte("correct horse battery staple")
+	want, salt, err := zcrypto.DeriveKey(password, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := zcrypto.NewSecretBuffer(zcrypto.KeySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	usedSalt, err := zcrypto.DeriveKeyInto(b, password, salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(usedSalt, salt) || !bytes.Equal(b.Bytes(), want) {
+		t.Fatal("DeriveKeyInto differs from DeriveKey")
+	}
+
+	// a nil salt is generated
+	usedSalt, err = zcrypto.DeriveKeyInto(b, password, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usedSalt) != zcrypto.SaltSize {
+		t.Fatalf("salt is %d bytes, want %d", len(usedSalt), zcrypto.SaltSize)
+	}
+}
+
+func TestExpandKeyInto(t *testing.T) {
+	secret := []byte("input key material")
+	want, err := zcrypto.ExpandKey(secret, nil, []byte("file-encryption"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := zcrypto.NewSecretBuffer(zcrypto.KeySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zcrypto.ExpandKeyInto(b, secret, nil, []byte("file-encryption")); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Fatal("ExpandKeyInto differs from ExpandKey")
+	}
+
+	b.Close()
+	if err := zcrypto.ExpandKeyInto(b, secret, nil, nil); !errors.Is(err, zcrypto.ErrSecretBufferClosed) {
+		t.Fatalf("got %v, want ErrSecretBufferClosed", err)
+	}
+}
@@ -0,0 +1,233 @@
+package zcrypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	shareVersion   = 1
+	shareSetIDSize = 2
+	shareSumSize   = 4
+	shareGroupSize = 5
+
+	// share layout: version, set id, threshold, x, y..., checksum
+	shareHeaderSize = 1 + shareSetIDSize + 1 + 1
+	maxShares       = 255
+)
+
+// shareEncoding is Crockford's base32: no I, L, O or U, so shares read
+// aloud or copied by hand survive the common confusions.
+var shareEncoding = base32.NewEncoding("0123456789ABCDEFGHJKMNPQRSTVWXYZ").WithPadding(base32.NoPadding)
+
+// ErrInvalidShare is returned by CombineShares when a share is malformed or
+// fails its checksum, usually because of a transcription mistake.
+var ErrInvalidShare = errors.New("invalid share")
+
+// ErrTooFewShares is returned by CombineShares when fewer shares than the
+// threshold are given.
+var ErrTooFewShares = errors.New("too few shares")
+
+// share is a decoded share: one point on each of the secret's polynomials.
+type share struct {
+	setID     [shareSetIDSize]byte
+	threshold int
+	x         byte
+	y         []byte
+}
+
+// SplitSecret splits secret into n shares, any k of which recover it with
+// CombineShares; fewer than k reveal nothing about it. Each byte of the
+// secret is the constant term of a random polynomial of degree k-1 over
+// GF(256), the field QR error correction uses, and share i holds the
+// polynomials evaluated at i.
+//
+// Shares are strings of Crockford base32 in dash-separated groups of five,
+// carrying the threshold and a checksum, so they can be written on paper
+// and typed back in. 2 ≤ k ≤ n ≤ 255.
+func SplitSecret(secret []byte, n, k int) ([]string, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("empty secret")
+	}
+	if k < 2 || k > n || n > maxShares {
+		return nil, fmt.Errorf("invalid threshold %d of %d shares", k, n)
+	}
+
+	var setID [shareSetIDSize]byte
+	if _, err := rand.Read(setID[:]); err != nil {
+		return nil, fmt.Errorf("generate share set id: %w", err)
+	}
+
+	// coeffs[j] holds the degree j+1 coefficient of every byte's polynomial
+	coeffs := make([]byte, (k-1)*len(secret))
+	defer Erase(coeffs)
+	if _, err := rand.Read(coeffs); err != nil {
+		return nil, fmt.Errorf("generate polynomial: %w", err)
+	}
+
+	shares := make([]string, n)
+	y := make([]byte, len(secret))
+	defer Erase(y)
+	for i := range shares {
+		x := byte(i + 1)
+		for b := range secret {
+			// Horner's method, highest degree first
+			var v byte
+			for j := k - 2; j >= 0; j-- {
+				v = gfMul(v, x) ^ coeffs[j*len(secret)+b]
+			}
+			y[b] = gfMul(v, x) ^ secret[b]
+		}
+		shares[i] = encodeShare(share{setID: setID, threshold: k, x: x, y: y})
+	}
+
+	return shares, nil
+}
+
+// CombineShares recovers a secret from at least the threshold number of
+// shares produced by one call to SplitSecret. Dashes, spaces and case are
+// ignored, as are the look-alikes O for 0 and I or L for 1.
+//
+// A share that fails its checksum returns ErrInvalidShare; shares from
+// different splits are rejected rather than combined into garbage.
+func CombineShares(shares []string) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, ErrTooFewShares
+	}
+
+	var points []share
+	for i, s := range shares {
+		sh, err := decodeShare(s)
+		if err != nil {
+			return nil, fmt.Errorf("share %d: %w", i+1, err)
+		}
+
+		if len(points) > 0 {
+			first := points[0]
+			if sh.setID != first.setID || sh.threshold != first.threshold || len(sh.y) != len(first.y) {
+				return nil, fmt.Errorf("share %d: from a different split", i+1)
+			}
+		}
+
+		dup := false
+		for _, p := range points {
+			if p.x != sh.x {
+				continue
+			}
+			if !bytes.Equal(p.y, sh.y) {
+				return nil, fmt.Errorf("share %d: conflicts with another share", i+1)
+			}
+			dup = true
+		}
+		if !dup {
+			points = append(points, sh)
+		}
+	}
+
+	k := points[0].threshold
+	if len(points) < k {
+		return nil, fmt.Errorf("%w: have %d, need %d", ErrTooFewShares, len(points), k)
+	}
+	points = points[:k]
+
+	// Lagrange interpolation at x = 0
+	secret := make([]byte, len(points[0].y))
+	for i, p := range points {
+		// basis = Π x_j / (x_j - x_i) over j ≠ i; subtraction is xor
+		num, den := byte(1), byte(1)
+		for j, q := range points {
+			if j == i {
+				continue
+			}
+			num = gfMul(num, q.x)
+			den = gfMul(den, q.x^p.x)
+		}
+		basis := gfMul(num, gfInv(den))
+		for b := range secret {
+			secret[b] ^= gfMul(p.y[b], basis)
+		}
+	}
+
+	return secret, nil
+}
+
+// encodeShare renders a share as checksummed, grouped base32.
+func encodeShare(s share) string {
+	raw := make([]byte, 0, shareHeaderSize+len(s.y)+shareSumSize)
+	raw = append(raw, shareVersion)
+	raw = append(raw, s.setID[:]...)
+	raw = append(raw, byte(s.threshold), s.x)
+	raw = append(raw, s.y...)
+	sum := sha256.Sum256(raw)
+	raw = append(raw, sum[:shareSumSize]...)
+	defer Erase(raw)
+
+	enc := shareEncoding.EncodeToString(raw)
+	var b strings.Builder
+	for i := 0; i < len(enc); i += shareGroupSize {
+		if i > 0 {
+			b.WriteByte('-')
+		}
+		b.WriteString(enc[i:min(i+shareGroupSize, len(enc))])
+	}
+	return b.String()
+}
+
+// decodeShare parses and verifies a share string.
+func decodeShare(s string) (share, error) {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ' ', '\t', '\n', '\r':
+			return -1
+		case 'O', 'o':
+			return '0'
+		case 'I', 'i', 'L', 'l':
+			return '1'
+		}
+		return r
+	}, strings.ToUpper(s))
+
+	// the decoder ignores the unused bits of the last character, which the
+	// checksum cannot see, so a typo there only shows up on re-encoding
+	raw, err := shareEncoding.DecodeString(s)
+	if err != nil || len(raw) <= shareHeaderSize+shareSumSize || shareEncoding.EncodeToString(raw) != s {
+		return share{}, ErrInvalidShare
+	}
+
+	body, sum := raw[:len(raw)-shareSumSize], raw[len(raw)-shareSumSize:]
+	want := sha256.Sum256(body)
+	if !bytes.Equal(sum, want[:shareSumSize]) {
+		return share{}, fmt.Errorf("%w: checksum mismatch", ErrInvalidShare)
+	}
+
+	if body[0] != shareVersion {
+		return share{}, fmt.Errorf("%w: unsupported version %d", ErrInvalidShare, body[0])
+	}
+
+	var sh share
+	copy(sh.setID[:], body[1:])
+	sh.threshold = int(body[1+shareSetIDSize])
+	sh.x = body[2+shareSetIDSize]
+	sh.y = body[shareHeaderSize:]
+	if sh.threshold < 2 || sh.x == 0 {
+		return share{}, fmt.Errorf("%w: malformed header", ErrInvalidShare)
+	}
+	return sh, nil
+}
+
+// gfInv returns the multiplicative inverse of a non-zero a as a^254.
+func gfInv(a byte) byte {
+	// a^254 = a^(2+4+8+16+32+64+128)
+	r := byte(1)
+	sq := a
+	for range 7 {
+		sq = gfMul(sq, sq)
+		r = gfMul(r, sq)
+	}
+	return r
+}
@@ -0,0 +1,205 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func TestGF256(t *testing.T) {
+	// the QR code field, modulo x^8 + x^4 + x^3 + x^2 + 1
+	if got := zcrypto.GFMulForTest(0x80, 2); got != 0x1d {
+		t.Fatalf("0x80 * 2 = %#x, want 0x1d", got)
+	}
+
+	for a := 1; a < 256; a++ {
+		if got := zcrypto.GFMulForTest(byte(a), zcrypto.GFInvForTest(byte(a))); got != 1 {
+			t.Fatalf("%#x * inverse = %#x, want 1", a, got)
+		}
+	}
+}
+
+func TestSplitCombineSecret(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+
+	tests := []struct{ n, k int }{
+		{2, 2},
+		{3, 2},
+		{5, 3},
+		{6, 6},
+	}
+
+	for _, tt := range tests {
+		shares, err := zcrypto.SplitSecret(secret, tt.n, tt.k)
+		if err != nil {
+			t.Fatalf("split %d of %d: %v", tt.k, tt.n, err)
+		}
+		if len(shares) != tt.n {
+			t.Fatalf("got %d shares, want %d", len(shares), tt.n)
+		}
+
+		// every subset of exactly k shares recovers the secret
+		for mask := range 1 << tt.n {
+			var subset []string
+			for i := range tt.n {
+				if mask&(1<<i) != 0 {
+					subset = append(subset, shares[i])
+				}
+			}
+			if len(subset) != tt.k {
+				continue
+			}
+
+			got, err := zcrypto.CombineShares(subset)
+			if err != nil {
+				t.Fatalf("combine %d of %d, mask %b: %v", tt.k, tt.n, mask, err)
+			}
+			if !bytes.Equal(got, secret) {
+				t.Fatalf("combine %d of %d, mask %b: got %q", tt.k, tt.n, mask, got)
+			}
+		}
+
+		// more than k shares work too
+		got, err := zcrypto.CombineShares(shares)
+		if err != nil || !bytes.Equal(got, secret) {
+			t.Fatalf("combine all %d: got %q, %v", tt.n, got, err)
+		}
+	}
+}
+
+func TestSplitSecretRandomized(t *testing.T) {
+	secret := []byte{0x00, 0xff, 0x42}
+
+	a, err := zcrypto.SplitSecret(secret, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := zcrypto.SplitSecret(secret, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a[0] == b[0] {
+		t.Fatal("two splits of the same secret produced the same share")
+	}
+}
+
+func TestSplitSecretFormat(t *testing.T) {
+	shares, err := zcrypto.SplitSecret(bytes.Repeat([]byte{0xaa}, 32), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	format := regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{5}(-[0-9A-HJKMNP-TV-Z]{1,5})+$`)
+	for _, s := range shares {
+		if !format.MatchString(s) {
+			t.Fatalf("share %q is not grouped Crockford base32", s)
+		}
+	}
+}
+
+func TestSplitSecretInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret []byte
+		n, k   int
+	}{
+		{"empty secret", nil, 3, 2},
+		{"threshold one", []byte("s"), 3, 1},
+		{"threshold above shares", []byte("s"), 2, 3},
+		{"too many shares", []byte("s"), 256, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := zcrypto.SplitSecret(tt.secret, tt.n, tt.k); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestCombineSharesTranscription(t *testing.T) {
+	secret := []byte("vault recovery key")
+	shares, err := zcrypto.SplitSecret(secret, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a sloppy copy: lowercase, spaces for dashes, O and I for 0 and 1
+	sloppy := strings.NewReplacer("-", " ", "0", "O", "1", "I").Replace(strings.ToLower(shares[0]))
+	got, err := zcrypto.CombineShares([]string{sloppy, strings.ReplaceAll(shares[2], "-", "")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Fatalf("got %q, want %q", got, secret)
+	}
+}
+
+func TestCombineSharesTypo(t *testing.T) {
+	shares, err := zcrypto.SplitSecret([]byte("vault recovery key"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range len(shares[1]) {
+		if shares[1][i] == '-' {
+			continue
+		}
+		typo := []byte(shares[1])
+		if typo[i] == 'X' {
+			typo[i] = 'Y'
+		} else {
+			typo[i] = 'X'
+		}
+
+		_, err := zcrypto.CombineShares([]string{shares[0], string(typo)})
+		if !errors.Is(err, zcrypto.ErrInvalidShare) {
+			t.Fatalf("typo at %d: got %v, want ErrInvalidShare", i, err)
+		}
+	}
+}
+
+func TestCombineSharesErrors(t *testing.T) {
+	a, err := zcrypto.SplitSecret([]byte("first secret"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := zcrypto.SplitSecret([]byte("other secret"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := zcrypto.SplitSecret([]byte("first secret"), 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		shares  []string
+		wantErr error
+	}{
+		{"none", nil, zcrypto.ErrTooFewShares},
+		{"below threshold", c[:2], zcrypto.ErrTooFewShares},
+		{"duplicate share", []string{a[0], a[0]}, zcrypto.ErrTooFewShares},
+		{"garbage", []string{"not a share", a[0]}, zcrypto.ErrInvalidShare},
+		{"different splits", []string{a[0], b[1]}, nil},
+		{"different thresholds", []string{a[0], c[1], c[2]}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := zcrypto.CombineShares(tt.shares)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
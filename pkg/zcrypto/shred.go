@@ -0,0 +1,181 @@
+package zcrypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/zarlcorp/core/pkg/zfilesystem"
+)
+
+const (
+	defaultShredPasses = 3
+	shredChunkSize     = 32 * 1024
+	shredNameAttempts  = 16
+)
+
+// renamer is implemented by filesystems that can rename files, such as
+// zfilesystem.MemFS and zfilesystem.OSFileSystem. It is declared here so
+// ShredFS works with any release of zfilesystem.
+type renamer interface {
+	Rename(oldpath, newpath string) error
+}
+
+// ShredOption configures Shred and ShredFS.
+type ShredOption func(*shredConfig)
+
+type shredConfig struct {
+	passes int
+}
+
+// WithPasses sets how many times the file is overwritten with random data.
+// The default is 3; values below 1 are treated as 1.
+func WithPasses(n int) ShredOption {
+	return func(c *shredConfig) {
+		c.passes = max(n, 1)
+	}
+}
+
+// Shred destroys the file at name: it overwrites the contents with random
+// data, syncing each pass to disk, renames the file to random names to
+// scrub its name from the directory, and then removes it.
+//
+// Overwriting is best effort. SSDs and flash remap writes for wear
+// levelling, and copy-on-write or journaling filesystems (btrfs, ZFS, APFS,
+// ext4 with data=journal) write new blocks instead of reusing old ones, so
+// the original data may survive on the device. Backups and snapshots are
+// out of reach entirely. Keep plaintext off disk where possible, or rely
+// on full-disk encryption, and treat Shred as a second line of defence.
+func Shred(name string, opts ...ShredOption) error {
+	return ShredFS(zfilesystem.NewOSFileSystem(filepath.Dir(name)), filepath.Base(name), opts...)
+}
+
+// ShredFS is Shred for a file in fsys. Files are synced if they have a Sync
+// method, as *os.File does, and renamed if fsys has a Rename method; on a
+// MemFS it exercises the same steps in tests.
+func ShredFS(fsys zfilesystem.ReadWriteFileFS, name string, opts ...ShredOption) error {
+	cfg := shredConfig{passes: defaultShredPasses}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	size, mode, err := shredStat(fsys, name)
+	if err != nil {
+		return err
+	}
+
+	for range cfg.passes {
+		if err := shredPass(fsys, name, size, mode); err != nil {
+			return err
+		}
+
+		if rfs, ok := fsys.(renamer); ok {
+			next, err := shredName(fsys, name)
+			if err != nil {
+				return err
+			}
+			if next == "" {
+				continue
+			}
+			if err := rfs.Rename(name, next); err != nil {
+				return fmt.Errorf("rename %s: %w", name, err)
+			}
+			name = next
+		}
+	}
+
+	if err := fsys.Remove(name); err != nil {
+		return fmt.Errorf("remove %s: %w", name, err)
+	}
+	return nil
+}
+
+// shredStat returns the size and permissions of the regular file name.
+func shredStat(fsys zfilesystem.ReadWriteFileFS, name string) (int64, os.FileMode, error) {
+	f, err := fsys.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return 0, 0, fmt.Errorf("open %s: %w", name, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("stat %s: %w", name, err)
+	}
+	if !info.Mode().IsRegular() {
+		return 0, 0, fmt.Errorf("shred %s: not a regular file", name)
+	}
+	return info.Size(), info.Mode().Perm(), nil
+}
+
+// shredPass overwrites size bytes of name with random data in place and
+// syncs them to disk.
+func shredPass(fsys zfilesystem.ReadWriteFileFS, name string, size int64, mode os.FileMode) error {
+	f, err := fsys.OpenFile(name, os.O_WRONLY, mode)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", name, err)
+	}
+
+	buf := make([]byte, min(size, shredChunkSize))
+	for left := size; left > 0; {
+		chunk := buf[:min(left, int64(len(buf)))]
+		if _, err := rand.Read(chunk); err != nil {
+			f.Close()
+			return fmt.Errorf("read random bytes: %w", err)
+		}
+		if _, err := f.Write(chunk); err != nil {
+			f.Close()
+			return fmt.Errorf("overwrite %s: %w", name, err)
+		}
+		left -= int64(len(chunk))
+	}
+
+	if s, ok := f.(interface{ Sync() error }); ok {
+		if err := s.Sync(); err != nil {
+			f.Close()
+			return fmt.Errorf("sync %s: %w", name, err)
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("overwrite %s: %w", name, err)
+	}
+	return nil
+}
+
+// shredName returns an unused random name of the same length as name's
+// base, in the same directory, so the rename does not leak the original
+// length. It returns "" if no free name turns up, which is likely only for
+// one- or two-character names.
+func shredName(fsys zfilesystem.ReadWriteFileFS, name string) (string, error) {
+	dir, base := path.Split(filepath.ToSlash(name))
+	if base == "" {
+		return "", errors.New("shred: empty file name")
+	}
+
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	for range shredNameAttempts {
+		b, err := RandBytes(len(base))
+		if err != nil {
+			return "", err
+		}
+		for i := range b {
+			b[i] = alphabet[int(b[i])%len(alphabet)]
+		}
+
+		// rename replaces its target, so never pick a name that is taken
+		next := filepath.FromSlash(dir + string(b))
+		f, err := fsys.OpenFile(next, os.O_RDONLY, 0)
+		if err == nil {
+			f.Close()
+			continue
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return next, nil
+		}
+	}
+	return "", nil
+}
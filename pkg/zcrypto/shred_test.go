@@ -0,0 +1,175 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+	"github.com/zarlcorp/core/pkg/zfilesystem"
+)
+
+// shredSpy records what ShredFS does to the filesystem it wraps.
+type shredSpy struct {
+	*zfilesystem.MemFS
+	writes  [][]byte
+	renames []string
+}
+
+func (s *shredSpy) OpenFile(name string, flag int, perm fs.FileMode) (zfilesystem.File, error) {
+	f, err := s.MemFS.OpenFile(name, flag, perm)
+	if err != nil || flag&os.O_WRONLY == 0 {
+		return f, err
+	}
+	s.writes = append(s.writes, nil)
+	return &shredSpyFile{File: f, spy: s}, nil
+}
+
+// Rename moves the file by hand rather than with MemFS.Rename, so the
+// tests build against zfilesystem releases that predate it.
+func (s *shredSpy) Rename(oldpath, newpath string) error {
+	s.renames = append(s.renames, newpath)
+	data, err := s.ReadFile(oldpath)
+	if err != nil {
+		return err
+	}
+	if err := s.WriteFile(newpath, data, 0o600); err != nil {
+		return err
+	}
+	return s.Remove(oldpath)
+}
+
+type shredSpyFile struct {
+	zfilesystem.File
+	spy *shredSpy
+}
+
+func (f *shredSpyFile) Write(p []byte) (int, error) {
+	last := len(f.spy.writes) - 1
+	f.spy.writes[last] = append(f.spy.writes[last], p...)
+	return f.File.Write(p)
+}
+
+func TestShredFS(t *testing.T) {
+	secret := bytes.Repeat([]byte("plaintext "), 10000)
+
+	spy := &shredSpy{MemFS: zfilesystem.NewMemFS()}
+	if err := spy.WriteFile("tmp/export.csv", secret, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := spy.WriteFile("tmp/keep.txt", []byte("keep"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zcrypto.ShredFS(spy, "tmp/export.csv", zcrypto.WithPasses(4)); err != nil {
+		t.Fatalf("ShredFS: %v", err)
+	}
+
+	if len(spy.writes) != 4 {
+		t.Fatalf("passes = %d, want 4", len(spy.writes))
+	}
+	for i, w := range spy.writes {
+		if len(w) != len(secret) {
+			t.Errorf("pass %d wrote %d bytes, want %d", i, len(w), len(secret))
+		}
+		if bytes.Contains(w, []byte("plaintext")) {
+			t.Errorf("pass %d wrote the plaintext back", i)
+		}
+	}
+	if bytes.Equal(spy.writes[0], spy.writes[1]) {
+		t.Error("passes wrote the same data")
+	}
+
+	if len(spy.renames) != 4 {
+		t.Fatalf("renames = %v, want 4", spy.renames)
+	}
+	for _, name := range spy.renames {
+		if filepath.Dir(name) != "tmp" || len(filepath.Base(name)) != len("export.csv") {
+			t.Errorf("renamed to %q, want a name of the same length in tmp", name)
+		}
+	}
+
+	var left []string
+	err := spy.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			left = append(left, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 1 || left[0] != filepath.Join("tmp", "keep.txt") {
+		t.Errorf("files left = %v, want only tmp/keep.txt", left)
+	}
+}
+
+func TestShredFSOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []zcrypto.ShredOption
+		passes int
+	}{
+		{"default", nil, 3},
+		{"one pass", []zcrypto.ShredOption{zcrypto.WithPasses(1)}, 1},
+		{"zero clamps to one", []zcrypto.ShredOption{zcrypto.WithPasses(0)}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spy := &shredSpy{MemFS: zfilesystem.NewMemFS()}
+			if err := spy.WriteFile("f", []byte("secret"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if err := zcrypto.ShredFS(spy, "f", tt.opts...); err != nil {
+				t.Fatalf("ShredFS: %v", err)
+			}
+			if len(spy.writes) != tt.passes {
+				t.Errorf("passes = %d, want %d", len(spy.writes), tt.passes)
+			}
+		})
+	}
+}
+
+func TestShredFSErrors(t *testing.T) {
+	mfs := zfilesystem.NewMemFS()
+	if err := zcrypto.ShredFS(mfs, "missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: err = %v, want os.ErrNotExist", err)
+	}
+
+	osfs := zfilesystem.NewOSFileSystem(t.TempDir())
+	if err := osfs.MkdirAll("dir", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := zcrypto.ShredFS(osfs, "dir"); err == nil {
+		t.Error("shredding a directory succeeded")
+	}
+}
+
+func TestShred(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "vault.tmp")
+	if err := os.WriteFile(name, []byte("master password"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "empty"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := zcrypto.Shred(name); err != nil {
+		t.Fatalf("Shred: %v", err)
+	}
+	if err := zcrypto.Shred(filepath.Join(dir, "empty")); err != nil {
+		t.Fatalf("Shred empty file: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("directory not empty after Shred: %v", entries)
+	}
+}
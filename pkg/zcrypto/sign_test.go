@@ -0,0 +1,299 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+// minisign vectors: the legacy one is from the aead.dev/minisign test data,
+// the prehashed one was produced with that library
+const (
+	legacyPub = `untrusted comment: minisign public key C373193807678450
+RWRQhGcHOBlzw4CoKyugkk4ioDfoxlXxC9LBx+VNhJ3w9w+cAxgvPsuo
+`
+	legacySig = `untrusted comment: signature from minisign secret key
+RWRQhGcHOBlzwxrJCyuC+rJfHSfyRKRxkuwa3JJ0bWEs7RHjL1OUmqnTr+V1B9JzFuJIH/ybR2Eus9oEZKt9RbitpF/L4D3+5wg=
+trusted comment: timestamp:1614549543	file:message.txt
+P/722+ynQ+tIy0qadFHwLx5MsyNz/jDKJkDWQj4dDD2OKnVte8m/M14mwPE/1NMwzShPMSBhMXqZGdbe+UZjDg==
+`
+	hashedPub = `untrusted comment: minisign public key: FFB7C848DE45158B
+RWSLFUXeSMi3/5yAU6mpH1kccpsZqjobAAtu7PF+h9GV7io8p0OEeh0e
+`
+	hashedSig = `untrusted comment: signature from minisign secret key
+RUSLFUXeSMi3/9qAykpcPh6jS0FuI9mtBJcg/XXIIW32Tii9Fuoda8olWbqMRnkdALvGSRQYnbCVFfyig6zsU/1tne5pCnjY4gM=
+trusted comment: timestamp:1700000000	file:message.txt
+sVadvS7cBSQ8+mFoBlls++AE/jyw/KqhRXtDfseqb65pfrTmGYoFEJ3oLkIuUUpmW0yLc3h5HflgibWhwrIABg==
+`
+	minisignMessage = "Hello World!\n"
+)
+
+func TestSignVerify(t *testing.T) {
+	k, err := zcrypto.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := []byte("config bundle")
+	sig := k.Sign(msg)
+	if len(sig) != zcrypto.SignatureSize {
+		t.Fatalf("signature is %d bytes, want %d", len(sig), zcrypto.SignatureSize)
+	}
+	if err := k.Public().Verify(msg, sig); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	if err := k.Public().Verify([]byte("config bundle!"), sig); !errors.Is(err, zcrypto.ErrInvalidSignature) {
+		t.Fatalf("tampered message: got %v, want ErrInvalidSignature", err)
+	}
+
+	other, err := zcrypto.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Public().Verify(msg, sig); !errors.Is(err, zcrypto.ErrInvalidSignature) {
+		t.Fatalf("wrong key: got %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestSignReader(t *testing.T) {
+	k, err := zcrypto.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := bytes.Repeat([]byte("0.0.0.0 ads.example\n"), 10000)
+	sig, err := k.SignReader(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Public().VerifyReader(bytes.NewReader(msg), sig); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	msg[len(msg)-1] = ' '
+	if err := k.Public().VerifyReader(bytes.NewReader(msg), sig); !errors.Is(err, zcrypto.ErrInvalidSignature) {
+		t.Fatalf("tampered stream: got %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestSigningKeySeed(t *testing.T) {
+	k, err := zcrypto.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := zcrypto.NewSigningKey(k.Seed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Public().String() != k.Public().String() {
+		t.Fatal("restored key has a different public key")
+	}
+	if err := k.Public().Verify([]byte("m"), restored.Sign([]byte("m"))); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	if _, err := zcrypto.NewSigningKey(make([]byte, 31)); err == nil {
+		t.Fatal("expected error for short seed")
+	}
+}
+
+func TestVerifyKeyEncoding(t *testing.T) {
+	k, err := zcrypto.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := k.Public()
+
+	for _, s := range []string{pub.String(), string(pub.PublicKeyFile())} {
+		parsed, err := zcrypto.ParseVerifyKey(s)
+		if err != nil {
+			t.Fatalf("parse %q: %v", s, err)
+		}
+		if parsed.ID() != pub.ID() || !bytes.Equal(parsed.Bytes(), pub.Bytes()) {
+			t.Fatalf("parse %q: got a different key", s)
+		}
+	}
+
+	fromBytes, err := zcrypto.NewVerifyKey(pub.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fromBytes.String() != pub.String() {
+		t.Fatal("NewVerifyKey derived a different key ID")
+	}
+
+	for _, bad := range []string{"", "not base64!", "RWQ=", strings.Replace(pub.String(), "RW", "RU", 1)} {
+		if _, err := zcrypto.ParseVerifyKey(bad); err == nil {
+			t.Fatalf("parse %q: expected error", bad)
+		}
+	}
+}
+
+func TestVerifyKeyDerivedID(t *testing.T) {
+	// aead.dev/minisign derives key IDs the same way
+	pub, err := zcrypto.ParseVerifyKey(hashedPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	derived, err := zcrypto.NewVerifyKey(pub.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if derived.ID() != "FFB7C848DE45158B" {
+		t.Fatalf("ID = %s, want FFB7C848DE45158B", derived.ID())
+	}
+}
+
+func TestVerifyMinisignVectors(t *testing.T) {
+	tests := []struct {
+		name    string
+		pub     string
+		sig     string
+		comment string
+	}{
+		{"legacy", legacyPub, legacySig, "timestamp:1614549543\tfile:message.txt"},
+		{"prehashed", hashedPub, hashedSig, "timestamp:1700000000\tfile:message.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub, err := zcrypto.ParseVerifyKey(tt.pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			comment, err := pub.VerifyMinisign(strings.NewReader(minisignMessage), []byte(tt.sig))
+			if err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+			if comment != tt.comment {
+				t.Fatalf("trusted comment = %q, want %q", comment, tt.comment)
+			}
+
+			// CRLF line endings, as after a trip through Windows
+			crlf := strings.ReplaceAll(tt.sig, "\n", "\r\n")
+			if _, err := pub.VerifyMinisign(strings.NewReader(minisignMessage), []byte(crlf)); err != nil {
+				t.Fatalf("verify CRLF: %v", err)
+			}
+
+			_, err = pub.VerifyMinisign(strings.NewReader("Hello World?\n"), []byte(tt.sig))
+			if !errors.Is(err, zcrypto.ErrInvalidSignature) {
+				t.Fatalf("tampered message: got %v, want ErrInvalidSignature", err)
+			}
+
+			forged := strings.Replace(tt.sig, "file:message.txt", "file:other.txt", 1)
+			_, err = pub.VerifyMinisign(strings.NewReader(minisignMessage), []byte(forged))
+			if !errors.Is(err, zcrypto.ErrInvalidSignature) {
+				t.Fatalf("forged trusted comment: got %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestSignMinisign(t *testing.T) {
+	k, err := zcrypto.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := "blocklist v42\n"
+	sig, err := k.SignMinisign(strings.NewReader(msg), "file:blocklist.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(sig)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[1], "RU") || lines[2] != "trusted comment: file:blocklist.txt" {
+		t.Fatalf("unexpected signature file:\n%s", sig)
+	}
+
+	comment, err := k.Public().VerifyMinisign(strings.NewReader(msg), sig)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if comment != "file:blocklist.txt" {
+		t.Fatalf("trusted comment = %q", comment)
+	}
+
+	// a default trusted comment carries a timestamp
+	sig, err = k.SignMinisign(strings.NewReader(msg), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comment, err := k.Public().VerifyMinisign(strings.NewReader(msg), sig); err != nil || !strings.HasPrefix(comment, "timestamp:") {
+		t.Fatalf("default comment = %q, %v", comment, err)
+	}
+
+	other, err := zcrypto.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Public().VerifyMinisign(strings.NewReader(msg), sig); !errors.Is(err, zcrypto.ErrInvalidSignature) {
+		t.Fatalf("wrong key: got %v, want ErrInvalidSignature", err)
+	}
+
+	if _, err := k.SignMinisign(strings.NewReader(msg), "two\nlines"); err == nil {
+		t.Fatal("expected error for multi-line trusted comment")
+	}
+}
+
+func TestVerifyMinisignMalformed(t *testing.T) {
+	pub, err := zcrypto.ParseVerifyKey(hashedPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(hashedSig, "\n")
+	tests := map[string]string{
+		"empty":               "",
+		"missing global":      strings.Join(lines[:3], "\n"),
+		"no untrusted prefix": strings.Replace(hashedSig, "untrusted comment: ", "", 1),
+		"bad base64":          strings.Replace(hashedSig, lines[1], "!!!!", 1),
+		"short signature":     strings.Replace(hashedSig, lines[1], "RUSLFUXe", 1),
+	}
+
+	for name, sig := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := pub.VerifyMinisign(strings.NewReader(minisignMessage), []byte(sig)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestSignNilKeys(t *testing.T) {
+	k, err := zcrypto.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := k.Sign([]byte("message"))
+	file, err := k.SignMinisign(strings.NewReader("message"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bad := range []*zcrypto.SigningKey{nil, {}} {
+		if _, err := bad.SignReader(strings.NewReader("message")); err == nil {
+			t.Error("SignReader with a nil key succeeded")
+		}
+		if _, err := bad.SignMinisign(strings.NewReader("message"), ""); err == nil {
+			t.Error("SignMinisign with a nil key succeeded")
+		}
+	}
+	for _, bad := range []*zcrypto.VerifyKey{nil, {}} {
+		if err := bad.Verify([]byte("message"), sig); err == nil {
+			t.Error("Verify with a nil key succeeded")
+		}
+		if err := bad.VerifyReader(strings.NewReader("message"), sig); err == nil {
+			t.Error("VerifyReader with a nil key succeeded")
+		}
+		if _, err := bad.VerifyMinisign(strings.NewReader("message"), file); err == nil {
+			t.Error("VerifyMinisign with a nil key succeeded")
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package zcrypto
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// ChunkSize is the plaintext length of each segment in an encrypted stream.
+	ChunkSize = 64 * 1024
+
+	streamInfo = "zcrypto stream v1"
+)
+
+// errStreamTruncated is returned when a stream ends without a final chunk.
+var errStreamTruncated = errors.New("stream truncated")
+
+// The stream format follows the STREAM construction: a random salt header,
+// then a sequence of AES-256-GCM sealed chunks. Each chunk is sealed under a
+// per-stream key derived from the caller's key and the salt, with a nonce of
+// an 11-byte big-endian chunk counter followed by a byte that is 1 for the
+// final chunk and 0 otherwise. Reordering, dropping or appending chunks, or
+// truncating the stream at a chunk boundary, all fail authentication.
+//
+//	salt (16) || seal(chunk 0) || seal(chunk 1) || ... || seal(final chunk)
+//
+// Every chunk except the last holds exactly ChunkSize bytes of plaintext.
+// The final chunk is empty only when the whole stream is empty.
+
+// newStreamAEAD derives the per-stream key from key and salt and returns
+// the AEAD used to seal its chunks.
+func newStreamAEAD(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	sk, err := ExpandKey(key, salt, []byte(streamInfo))
+	if err != nil {
+		return nil, fmt.Errorf("derive stream key: %w", err)
+	}
+	defer Erase(sk)
+
+	return newGCM(sk)
+}
+
+// streamNonce fills nonce with the chunk counter and final flag.
+func streamNonce(nonce *[NonceSize]byte, counter uint64, last bool) {
+	clear(nonce[:])
+	for i := range 8 {
+		nonce[NonceSize-2-i] = byte(counter >> (8 * i))
+	}
+	if last {
+		nonce[NonceSize-1] = 1
+	}
+}
+
+// encryptWriter seals plaintext into chunks as it is written.
+type encryptWriter struct {
+	aead    cipher.AEAD
+	dst     io.Writer
+	buf     []byte // pending plaintext, with room for the tag
+	counter uint64
+	nonce   [NonceSize]byte
+	err     error
+}
+
+// NewEncryptWriter returns a writer that encrypts everything written to it
+// into dst using AES-256-GCM in fixed-size chunks, so memory use is
+// constant regardless of the stream length. Key must be exactly 32 bytes.
+// The caller must Close the writer to seal the final chunk; a stream that
+// is not closed cannot be decrypted.
+func NewEncryptWriter(key []byte, dst io.Writer) (io.WriteCloser, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+
+	aead, err := newStreamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dst.Write(salt); err != nil {
+		return nil, fmt.Errorf("write header: %w", err)
+	}
+
+	return &encryptWriter{
+		aead: aead,
+		dst:  dst,
+		buf:  make([]byte, 0, ChunkSize+TagSize),
+	}, nil
+}
+
+// Write buffers p and seals every full chunk that is followed by more data.
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	total := len(p)
+	for len(p) > 0 {
+		// a full chunk is only known not to be final once more data arrives
+		if len(w.buf) == ChunkSize {
+			if err := w.flush(false); err != nil {
+				return total - len(p), err
+			}
+		}
+
+		n := min(len(p), ChunkSize-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+	}
+
+	return total, nil
+}
+
+// Close seals and writes the final chunk. It does not close dst.
+func (w *encryptWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if err := w.flush(true); err != nil {
+		return err
+	}
+
+	w.err = errors.New("write to closed stream")
+	return nil
+}
+
+// flush seals the buffered plaintext in place and writes it to dst.
+func (w *encryptWriter) flush(last bool) error {
+	if w.counter == 1<<64-1 {
+		w.err = errors.New("stream too long")
+		return w.err
+	}
+
+	streamNonce(&w.nonce, w.counter, last)
+	ct := w.aead.Seal(w.buf[:0], w.nonce[:], w.buf, nil)
+
+	if _, err := w.dst.Write(ct); err != nil {
+		w.err = fmt.Errorf("write chunk: %w", err)
+		return w.err
+	}
+
+	Erase(w.buf[:cap(w.buf)])
+	w.buf = w.buf[:0]
+	w.counter++
+	return nil
+}
+
+// decryptReader opens chunks produced by encryptWriter as they are read.
+type decryptReader struct {
+	aead    cipher.AEAD
+	src     *bufio.Reader
+	buf     []byte // sealed chunk, opened in place
+	plain   []byte // unread plaintext within buf
+	counter uint64
+	nonce   [NonceSize]byte
+	done    bool
+	err     error
+}
+
+// NewDecryptReader returns a reader that decrypts a stream produced by
+// NewEncryptWriter. Plaintext is only returned once its chunk has been
+// authenticated. A stream that was truncated, reordered or tampered with
+// yields an error instead of io.EOF. Key must be exactly 32 bytes.
+func NewDecryptReader(key []byte, src io.Reader) (io.Reader, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(src, salt); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errors.New("stream too short")
+		}
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	aead, err := newStreamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{
+		aead: aead,
+		src:  bufio.NewReaderSize(src, ChunkSize+TagSize),
+		buf:  make([]byte, ChunkSize+TagSize),
+	}, nil
+}
+
+// Read returns authenticated plaintext, opening the next chunk as needed.
+func (r *decryptReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.next()
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// next reads and opens one chunk.
+func (r *decryptReader) next() error {
+	n, err := io.ReadFull(r.src, r.buf)
+
+	var last bool
+	switch {
+	case errors.Is(err, io.EOF):
+		return errStreamTruncated
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		last = true
+	case err != nil:
+		return fmt.Errorf("read chunk: %w", err)
+	default:
+		// a full chunk is final only if nothing follows it
+		if _, err := r.src.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return fmt.Errorf("read chunk: %w", err)
+		}
+	}
+
+	if n < TagSize {
+		return errStreamTruncated
+	}
+
+	streamNonce(&r.nonce, r.counter, last)
+	plain, err := r.aead.Open(r.buf[:0], r.nonce[:], r.buf[:n], nil)
+	if err != nil {
+		return fmt.Errorf("decrypt chunk %d: %w", r.counter, err)
+	}
+
+	if last && len(plain) == 0 && r.counter > 0 {
+		return errors.New("empty final chunk")
+	}
+
+	r.plain = plain
+	r.done = last
+	r.counter++
+	return nil
+}
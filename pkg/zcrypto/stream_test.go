@@ -0,0 +1,186 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func encryptStream(t *testing.T, key, plaintext []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := zcrypto.NewEncryptWriter(key, &buf)
+	if err != nil {
+		t.Fatalf("new encrypt writer: %v", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decryptStream(key, ciphertext []byte) ([]byte, error) {
+	r, err := zcrypto.NewDecryptReader(key, bytes.NewReader(ciphertext))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "empty", size: 0},
+		{name: "one byte", size: 1},
+		{name: "under one chunk", size: zcrypto.ChunkSize - 1},
+		{name: "exactly one chunk", size: zcrypto.ChunkSize},
+		{name: "one chunk plus one", size: zcrypto.ChunkSize + 1},
+		{name: "several chunks", size: 3*zcrypto.ChunkSize + 517},
+		{name: "exact multiple", size: 4 * zcrypto.ChunkSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext, err := zcrypto.RandBytes(tt.size)
+			if err != nil {
+				t.Fatalf("rand: %v", err)
+			}
+
+			ct := encryptStream(t, key, plaintext)
+
+			got, err := decryptStream(key, ct)
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("round trip mismatch: got %d bytes, want %d", len(got), len(plaintext))
+			}
+		})
+	}
+}
+
+func TestStreamSmallWrites(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+	plaintext := bytes.Repeat([]byte("0123456789"), zcrypto.ChunkSize/5)
+
+	var buf bytes.Buffer
+	w, err := zcrypto.NewEncryptWriter(key, &buf)
+	if err != nil {
+		t.Fatalf("new encrypt writer: %v", err)
+	}
+	for i := 0; i < len(plaintext); i += 7 {
+		if _, err := w.Write(plaintext[i:min(i+7, len(plaintext))]); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// chunking must not depend on how the caller split its writes
+	if want := len(encryptStream(t, key, plaintext)); buf.Len() != want {
+		t.Fatalf("ciphertext length = %d, want %d", buf.Len(), want)
+	}
+
+	got, err := decryptStream(key, buf.Bytes())
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatal("round trip mismatch")
+	}
+}
+
+func TestStreamTampering(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+	plaintext := bytes.Repeat([]byte{0xab}, 2*zcrypto.ChunkSize+100)
+	ct := encryptStream(t, key, plaintext)
+
+	// header is the salt; each sealed full chunk adds a 16-byte tag
+	const header = zcrypto.SaltSize
+	const sealed = zcrypto.ChunkSize + 16
+
+	tests := []struct {
+		name   string
+		mangle func([]byte) []byte
+	}{
+		{
+			name:   "flipped bit",
+			mangle: func(b []byte) []byte { b[header+10] ^= 1; return b },
+		},
+		{
+			name:   "truncated at chunk boundary",
+			mangle: func(b []byte) []byte { return b[:header+2*sealed] },
+		},
+		{
+			name:   "truncated mid chunk",
+			mangle: func(b []byte) []byte { return b[:header+sealed+100] },
+		},
+		{
+			name:   "header only",
+			mangle: func(b []byte) []byte { return b[:header] },
+		},
+		{
+			name: "chunks swapped",
+			mangle: func(b []byte) []byte {
+				out := append([]byte{}, b[:header]...)
+				out = append(out, b[header+sealed:header+2*sealed]...)
+				out = append(out, b[header:header+sealed]...)
+				return append(out, b[header+2*sealed:]...)
+			},
+		},
+		{
+			name:   "trailing data",
+			mangle: func(b []byte) []byte { return append(b, 0) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mangled := tt.mangle(bytes.Clone(ct))
+			if _, err := decryptStream(key, mangled); err == nil {
+				t.Fatal("expected error decrypting mangled stream")
+			}
+		})
+	}
+}
+
+func TestStreamWrongKey(t *testing.T) {
+	key1 := make([]byte, zcrypto.KeySize)
+	key2 := make([]byte, zcrypto.KeySize)
+	key2[0] = 1
+
+	ct := encryptStream(t, key1, []byte("secret"))
+	if _, err := decryptStream(key2, ct); err == nil {
+		t.Fatal("expected error decrypting with wrong key")
+	}
+}
+
+func TestStreamInvalidKey(t *testing.T) {
+	if _, err := zcrypto.NewEncryptWriter(make([]byte, 16), io.Discard); err == nil {
+		t.Fatal("expected error for short key")
+	}
+}
+
+func TestStreamWriteAfterClose(t *testing.T) {
+	w, err := zcrypto.NewEncryptWriter(make([]byte, zcrypto.KeySize), io.Discard)
+	if err != nil {
+		t.Fatalf("new encrypt writer: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Fatal("expected error writing to closed stream")
+	}
+}
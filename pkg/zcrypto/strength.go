@@ -0,0 +1,542 @@
+package zcrypto
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// strengthMaxRunes bounds the work done per password; longer passwords
+	// are scored on their prefix, which can only underestimate them.
+	strengthMaxRunes = 100
+
+	// offlineSlowHashRate is the guesses per second assumed for CrackTime:
+	// an offline attack on a slow hash such as Argon2id.
+	offlineSlowHashRate = 1e4
+
+	bruteforceCardinality           = 10
+	minGuessesBeforeGrowingSequence = 10000
+	minSubmatchGuessesSingleChar    = 10
+	minSubmatchGuessesMultiChar     = 50
+	minYearSpace                    = 20
+)
+
+// PasswordStrength is the result of EstimateStrength.
+type PasswordStrength struct {
+	// Score rates the password from 0 (too guessable) to 4 (very
+	// unguessable). Passwords that protect other secrets should score 3 or
+	// more.
+	Score int
+
+	// Guesses estimates how many guesses an attacker who knows common
+	// passwords, words and patterns needs to find the password.
+	Guesses float64
+
+	// CrackTime estimates how long an offline attack against a slow hash
+	// like Argon2id, at ten thousand guesses per second, takes to find the
+	// password. It saturates at the largest time.Duration.
+	CrackTime time.Duration
+
+	// Warning explains what makes the password weak, if anything.
+	Warning string
+
+	// Suggestions lists ways to strengthen the password.
+	Suggestions []string
+}
+
+// EstimateStrength estimates how hard password is to guess, in the style
+// of zxcvbn. It finds the cheapest way to build the password from common
+// passwords, English words and names (including reversed and l33t forms),
+// keyboard patterns, repeats, sequences and dates, and scores that. Pass
+// user-specific words such as the account name or email as userInputs so
+// passwords built from them are penalised too.
+//
+// Only the first 100 characters are analysed.
+func EstimateStrength(password string, userInputs ...string) PasswordStrength {
+	pw := []rune(password)
+	if len(pw) > strengthMaxRunes {
+		pw = pw[:strengthMaxRunes]
+	}
+
+	dicts := strengthDicts()
+	if len(userInputs) > 0 {
+		dicts = make(map[string]rankedDict, len(dicts)+1)
+		for k, v := range strengthDicts() {
+			dicts[k] = v
+		}
+		dicts[dictUserInputs] = newRankedDict(userInputs)
+	}
+
+	m := strengthMatcher{dicts: dicts, year: time.Now().Year()}
+	est := m.mostGuessable(pw)
+
+	s := PasswordStrength{
+		Score:   guessesScore(est.guesses),
+		Guesses: est.guesses,
+	}
+
+	secs := est.guesses / offlineSlowHashRate
+	if secs >= math.MaxInt64/float64(time.Second) {
+		s.CrackTime = time.Duration(math.MaxInt64)
+	} else {
+		s.CrackTime = time.Duration(secs * float64(time.Second))
+	}
+
+	s.Warning, s.Suggestions = strengthFeedback(s.Score, est.sequence)
+	return s
+}
+
+// CrackTimeDisplay returns CrackTime in words, such as "3 hours" or
+// "centuries".
+func (s PasswordStrength) CrackTimeDisplay() string {
+	const (
+		minute  = 60
+		hour    = 60 * minute
+		day     = 24 * hour
+		month   = 31 * day
+		year    = 12 * month
+		century = 100 * year
+	)
+
+	secs := s.Guesses / offlineSlowHashRate
+	units := []struct {
+		name string
+		size float64
+		next float64
+	}{
+		{"second", 1, minute},
+		{"minute", minute, hour},
+		{"hour", hour, day},
+		{"day", day, month},
+		{"month", month, year},
+		{"year", year, century},
+	}
+
+	if secs < 1 {
+		return "less than a second"
+	}
+	for _, u := range units {
+		if secs < u.next {
+			n := int(math.Round(secs / u.size))
+			if n == 1 {
+				return "1 " + u.name
+			}
+			return fmt.Sprintf("%d %ss", n, u.name)
+		}
+	}
+	return "centuries"
+}
+
+// guessesScore maps guesses to a 0-4 score.
+func guessesScore(guesses float64) int {
+	const delta = 5
+	switch {
+	case guesses < 1e3+delta:
+		return 0 // risky password
+	case guesses < 1e6+delta:
+		return 1 // protects against throttled online attacks
+	case guesses < 1e8+delta:
+		return 2 // protects against unthrottled online attacks
+	case guesses < 1e10+delta:
+		return 3 // moderate protection against offline slow-hash attacks
+	default:
+		return 4 // strong protection against offline slow-hash attacks
+	}
+}
+
+// guessEstimate is the cheapest way found to build a password.
+type guessEstimate struct {
+	guesses  float64
+	sequence []strengthMatch
+}
+
+func (m strengthMatcher) mostGuessable(pw []rune) guessEstimate {
+	return m.mostGuessableSequence(pw, m.omnimatch(pw))
+}
+
+// mostGuessableSequence finds the sequence of non-overlapping matches,
+// with bruteforce filling the gaps, that minimises
+//
+//	l! × Π guesses(match) + 10000^(l-1)
+//
+// for a sequence of length l. The factorial accounts for the attacker not
+// knowing the order of the patterns; the additive term favours sequences
+// of fewer, longer matches. This is zxcvbn's dynamic programming search.
+func (m strengthMatcher) mostGuessableSequence(pw []rune, matches []strengthMatch) guessEstimate {
+	n := len(pw)
+	if n == 0 {
+		return guessEstimate{guesses: 1}
+	}
+
+	byEnd := make([][]strengthMatch, n)
+	for _, mt := range matches {
+		mt.guesses = m.estimateGuesses(mt, n)
+		byEnd[mt.j] = append(byEnd[mt.j], mt)
+	}
+	for _, ms := range byEnd {
+		slices.SortStableFunc(ms, func(a, b strengthMatch) int { return a.i - b.i })
+	}
+
+	// optimal[k][l] is the best sequence of l matches covering pw[:k+1]
+	type step struct {
+		match   strengthMatch
+		pi      float64 // product of guesses
+		guesses float64 // overall guesses, as above
+	}
+	optimal := make([]map[int]step, n)
+	for k := range optimal {
+		optimal[k] = make(map[int]step)
+	}
+	lengths := func(k int) []int {
+		return slices.Sorted(func(yield func(int) bool) {
+			for l := range optimal[k] {
+				if !yield(l) {
+					return
+				}
+			}
+		})
+	}
+
+	update := func(mt strengthMatch, l int) {
+		k := mt.j
+		pi := mt.guesses
+		if l > 1 {
+			pi *= optimal[mt.i-1][l-1].pi
+		}
+		g := factorial(l)*pi + math.Pow(minGuessesBeforeGrowingSequence, float64(l-1))
+
+		// a shorter or equal sequence that is no worse wins
+		for cl, s := range optimal[k] {
+			if cl <= l && s.guesses <= g {
+				return
+			}
+		}
+		optimal[k][l] = step{match: mt, pi: pi, guesses: g}
+	}
+
+	bruteforce := func(i, j int) strengthMatch {
+		mt := strengthMatch{kind: matchBruteforce, i: i, j: j, token: string(pw[i : j+1])}
+		mt.guesses = m.estimateGuesses(mt, n)
+		return mt
+	}
+
+	for k := range n {
+		for _, mt := range byEnd[k] {
+			if mt.i == 0 {
+				update(mt, 1)
+				continue
+			}
+			for _, l := range lengths(mt.i - 1) {
+				update(mt, l+1)
+			}
+		}
+
+		// a bruteforce run ending at k, either alone or after a
+		// non-bruteforce match, since two adjacent runs should merge
+		update(bruteforce(0, k), 1)
+		for i := 1; i <= k; i++ {
+			for _, l := range lengths(i - 1) {
+				if optimal[i-1][l].match.kind != matchBruteforce {
+					update(bruteforce(i, k), l+1)
+				}
+			}
+		}
+	}
+
+	bestL, best := 0, math.Inf(1)
+	for _, l := range lengths(n - 1) {
+		if g := optimal[n-1][l].guesses; g < best {
+			bestL, best = l, g
+		}
+	}
+
+	seq := make([]strengthMatch, bestL)
+	for k, l := n-1, bestL; l > 0; l-- {
+		s := optimal[k][l]
+		seq[l-1] = s.match
+		k = s.match.i - 1
+	}
+
+	return guessEstimate{guesses: best, sequence: seq}
+}
+
+// estimateGuesses returns the guesses needed to find mt's token by its
+// pattern, given a password of n runes.
+func (m strengthMatcher) estimateGuesses(mt strengthMatch, n int) float64 {
+	length := mt.j - mt.i + 1
+
+	// a match that is only part of the password needs some minimum, so a
+	// long string of cheap matches is not scored below bruteforce
+	minGuesses := 1.0
+	if length < n {
+		minGuesses = minSubmatchGuessesMultiChar
+		if length == 1 {
+			minGuesses = minSubmatchGuessesSingleChar
+		}
+	}
+
+	var g float64
+	switch mt.kind {
+	case matchBruteforce:
+		g = math.Pow(bruteforceCardinality, float64(length))
+		if math.IsInf(g, 1) {
+			g = math.MaxFloat64
+		}
+		if length == 1 {
+			minGuesses = minSubmatchGuessesSingleChar + 1
+		} else {
+			minGuesses = minSubmatchGuessesMultiChar + 1
+		}
+	case matchDictionary:
+		g = float64(mt.rank) * uppercaseVariations(mt.token) * l33tVariations(mt)
+		if mt.reversed {
+			g *= 2
+		}
+	case matchSpatial:
+		g = spatialGuesses(mt)
+	case matchRepeat:
+		g = mt.baseGuesses * float64(mt.repeats)
+	case matchSequence:
+		g = sequenceGuesses(mt)
+	case matchYear:
+		g = float64(max(abs(mt.year-m.year), minYearSpace))
+	case matchDate:
+		g = float64(max(abs(mt.year-m.year), minYearSpace)) * 365
+		if mt.separator {
+			g *= 4
+		}
+	}
+
+	return max(g, minGuesses)
+}
+
+// uppercaseVariations counts the ways the token's capitalisation could
+// have been chosen; common patterns like a leading capital count as 2.
+func uppercaseVariations(token string) float64 {
+	var upper, lower int
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+
+	rs := []rune(token)
+	first, last := unicode.IsUpper(rs[0]), unicode.IsUpper(rs[len(rs)-1])
+	if lower == 0 || (upper == 1 && (first || last)) {
+		return 2
+	}
+
+	var v float64
+	for i := 1; i <= min(upper, lower); i++ {
+		v += binomial(upper+lower, i)
+	}
+	return v
+}
+
+// l33tVariations counts the ways the token's substitutions could have
+// been chosen.
+func l33tVariations(mt strengthMatch) float64 {
+	if len(mt.l33t) == 0 {
+		return 1
+	}
+
+	token := strings.ToLower(mt.token)
+	v := 1.0
+	for subbed, letter := range mt.l33t {
+		s := strings.Count(token, string(subbed))
+		u := strings.Count(token, string(letter))
+		if s == 0 || u == 0 {
+			v *= 2
+			continue
+		}
+		var p float64
+		for i := 1; i <= min(u, s); i++ {
+			p += binomial(u+s, i)
+		}
+		v *= p
+	}
+	return v
+}
+
+// spatialGuesses counts keyboard patterns of the token's length with up
+// to its number of turns, times its shifted-key variations.
+func spatialGuesses(mt strengthMatch) float64 {
+	var starts, degree float64
+	for _, g := range keyboardGraphs() {
+		if g.name == mt.graph {
+			starts, degree = g.starts, g.avgDegree
+		}
+	}
+
+	length := len([]rune(mt.token))
+	var guesses float64
+	for i := 2; i <= length; i++ {
+		for j := 1; j <= min(mt.turns, i-1); j++ {
+			guesses += binomial(i-1, j-1) * starts * math.Pow(degree, float64(j))
+		}
+	}
+
+	if s := mt.shifted; s > 0 {
+		u := length - s
+		if u == 0 {
+			guesses *= 2
+		} else {
+			var v float64
+			for i := 1; i <= min(s, u); i++ {
+				v += binomial(s+u, i)
+			}
+			guesses *= v
+		}
+	}
+
+	return guesses
+}
+
+// sequenceGuesses rates obvious starting points like "a" or "1" lower.
+func sequenceGuesses(mt strengthMatch) float64 {
+	var base float64
+	switch first := []rune(mt.token)[0]; {
+	case strings.ContainsRune("aAzZ019", first):
+		base = 4
+	case unicode.IsDigit(first):
+		base = 10
+	default:
+		base = 26
+	}
+	if !mt.ascending {
+		base *= 2
+	}
+	return base * float64(len([]rune(mt.token)))
+}
+
+func binomial(n, k int) float64 {
+	if k > n {
+		return 0
+	}
+	r := 1.0
+	for d := 1; d <= k; d++ {
+		r = r * float64(n-k+d) / float64(d)
+	}
+	return r
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for i := 2; i <= n; i++ {
+		f *= float64(i)
+	}
+	return f
+}
+
+// strengthFeedback explains a weak score using the longest match.
+func strengthFeedback(score int, seq []strengthMatch) (string, []string) {
+	if len(seq) == 0 {
+		return "", []string{
+			"Use a few words, avoid common phrases",
+			"No need for symbols, digits, or uppercase letters",
+		}
+	}
+	if score > 2 {
+		return "", nil
+	}
+
+	longest := seq[0]
+	for _, mt := range seq[1:] {
+		if len(mt.token) > len(longest.token) {
+			longest = mt
+		}
+	}
+
+	warning, suggestions := matchFeedback(longest, len(seq) == 1)
+	return warning, append([]string{"Add another word or two. Uncommon words are better."}, suggestions...)
+}
+
+func matchFeedback(mt strengthMatch, sole bool) (string, []string) {
+	switch mt.kind {
+	case matchDictionary:
+		return dictionaryFeedback(mt, sole)
+
+	case matchSpatial:
+		warning := "Short keyboard patterns are easy to guess"
+		if mt.turns == 1 {
+			warning = "Straight rows of keys are easy to guess"
+		}
+		return warning, []string{"Use a longer keyboard pattern with more turns"}
+
+	case matchRepeat:
+		warning := `Repeats like "abcabcabc" are only slightly harder to guess than "abc"`
+		if len([]rune(mt.baseToken)) == 1 {
+			warning = `Repeats like "aaa" are easy to guess`
+		}
+		return warning, []string{"Avoid repeated words and characters"}
+
+	case matchSequence:
+		return "Sequences like abc or 6543 are easy to guess", []string{"Avoid sequences"}
+
+	case matchYear:
+		return "Recent years are easy to guess", []string{
+			"Avoid recent years",
+			"Avoid years that are associated with you",
+		}
+
+	case matchDate:
+		return "Dates are often easy to guess", []string{"Avoid dates and years that are associated with you"}
+	}
+
+	return "", nil
+}
+
+func dictionaryFeedback(mt strengthMatch, sole bool) (string, []string) {
+	var warning string
+	switch mt.dict {
+	case dictPasswords:
+		switch {
+		case sole && mt.l33t == nil && !mt.reversed && mt.rank <= 10:
+			warning = "This is a top-10 common password"
+		case sole && mt.l33t == nil && !mt.reversed && mt.rank <= 100:
+			warning = "This is a top-100 common password"
+		case sole && mt.l33t == nil && !mt.reversed:
+			warning = "This is a very common password"
+		case sole:
+			warning = "This is similar to a commonly used password"
+		}
+	case dictEnglish:
+		if sole {
+			warning = "A word by itself is easy to guess"
+		}
+	case dictNames:
+		warning = "Common names and surnames are easy to guess"
+		if sole {
+			warning = "Names and surnames by themselves are easy to guess"
+		}
+	case dictUserInputs:
+		warning = "Passwords based on your account details are easy to guess"
+	}
+
+	var suggestions []string
+	rs := []rune(mt.token)
+	switch {
+	case strings.ToUpper(mt.token) == mt.token && strings.ToLower(mt.token) != mt.token:
+		suggestions = append(suggestions, "All-uppercase is almost as easy to guess as all-lowercase")
+	case unicode.IsUpper(rs[0]):
+		suggestions = append(suggestions, "Capitalization doesn't help very much")
+	}
+	if mt.reversed && len(rs) >= 4 {
+		suggestions = append(suggestions, "Reversed words aren't much harder to guess")
+	}
+	if mt.l33t != nil {
+		suggestions = append(suggestions, "Predictable substitutions like '@' instead of 'a' don't help very much")
+	}
+
+	return warning, suggestions
+}
@@ -0,0 +1,715 @@
+package zcrypto
+
+import (
+	_ "embed"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// frequency lists for the dictionary matcher, most common first
+var (
+	//go:embed wordlists/passwords.txt
+	passwordsList string
+	//go:embed wordlists/english.txt
+	englishList string
+	//go:embed wordlists/female_names.txt
+	femaleNamesList string
+	//go:embed wordlists/male_names.txt
+	maleNamesList string
+	//go:embed wordlists/surnames.txt
+	surnamesList string
+)
+
+// match kinds
+const (
+	matchDictionary = "dictionary"
+	matchSpatial    = "spatial"
+	matchRepeat     = "repeat"
+	matchSequence   = "sequence"
+	matchDate       = "date"
+	matchYear       = "year"
+	matchBruteforce = "bruteforce"
+)
+
+// dictionary names, used for feedback
+const (
+	dictPasswords  = "passwords"
+	dictEnglish    = "english"
+	dictNames      = "names"
+	dictUserInputs = "user_inputs"
+)
+
+// strengthMatch is a substring of the password, runes i through j
+// inclusive, that fits a guessable pattern.
+type strengthMatch struct {
+	kind  string
+	i, j  int
+	token string
+
+	// dictionary
+	dict     string
+	rank     int
+	l33t     map[rune]rune // substituted rune to the letter it stands for
+	reversed bool
+
+	// spatial
+	graph   string
+	turns   int
+	shifted int
+
+	// repeat
+	baseToken   string
+	baseGuesses float64
+	repeats     int
+
+	// sequence
+	space     int
+	ascending bool
+
+	// date and year
+	year      int
+	separator bool
+
+	guesses float64
+}
+
+// rankedDict maps lowercase words to their 1-based frequency rank.
+type rankedDict map[string]int
+
+func newRankedDict(words []string) rankedDict {
+	d := make(rankedDict, len(words))
+	for i, w := range words {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if _, ok := d[w]; !ok && w != "" {
+			d[w] = i + 1
+		}
+	}
+	return d
+}
+
+// strengthDicts returns the embedded ranked dictionaries by name.
+var strengthDicts = sync.OnceValue(func() map[string]rankedDict {
+	lines := func(s string) []string { return strings.Split(strings.TrimSpace(s), "\n") }
+
+	// names share a dictionary, interleaved so each list keeps its ranks
+	female, male, surnames := lines(femaleNamesList), lines(maleNamesList), lines(surnamesList)
+	names := make(rankedDict, len(female)+len(male)+len(surnames))
+	for _, list := range [][]string{female, male, surnames} {
+		for i, w := range list {
+			if r, ok := names[w]; !ok || i+1 < r {
+				names[w] = i + 1
+			}
+		}
+	}
+
+	return map[string]rankedDict{
+		dictPasswords: newRankedDict(lines(passwordsList)),
+		dictEnglish:   newRankedDict(lines(englishList)),
+		dictNames:     names,
+	}
+})
+
+// l33tTable lists the substitutions the dictionary matcher undoes.
+var l33tTable = map[rune]string{
+	'a': "4@",
+	'b': "8",
+	'c': "({[<",
+	'e': "3",
+	'g': "69",
+	'i': "1!|",
+	'l': "1|7",
+	'o': "0",
+	's': "$5",
+	't': "+7",
+	'x': "%",
+	'z': "2",
+}
+
+// maxWordRunes is the longest substring looked up in a dictionary.
+const maxWordRunes = 32
+
+// maxL33tSubs caps the substitution combinations tried for one password.
+const maxL33tSubs = 64
+
+// strengthMatcher finds every pattern match in a password.
+type strengthMatcher struct {
+	dicts map[string]rankedDict
+	year  int // reference year for dates
+}
+
+func (m strengthMatcher) omnimatch(pw []rune) []strengthMatch {
+	var out []strengthMatch
+	out = append(out, m.dictionaryMatches(pw)...)
+	out = append(out, m.reverseDictionaryMatches(pw)...)
+	out = append(out, m.l33tMatches(pw)...)
+	out = append(out, spatialMatches(pw)...)
+	out = append(out, m.repeatMatches(pw)...)
+	out = append(out, sequenceMatches(pw)...)
+	out = append(out, yearMatches(pw)...)
+	out = append(out, m.dateMatches(pw)...)
+	return out
+}
+
+func (m strengthMatcher) dictionaryMatches(pw []rune) []strengthMatch {
+	lower := []rune(strings.ToLower(string(pw)))
+	if len(lower) != len(pw) {
+		lower = pw
+	}
+
+	var out []strengthMatch
+	for name, dict := range m.dicts {
+		for i := range lower {
+			for j := i; j < len(lower) && j-i < maxWordRunes; j++ {
+				word := string(lower[i : j+1])
+				if rank, ok := dict[word]; ok {
+					out = append(out, strengthMatch{
+						kind:  matchDictionary,
+						i:     i,
+						j:     j,
+						token: string(pw[i : j+1]),
+						dict:  name,
+						rank:  rank,
+					})
+				}
+			}
+		}
+	}
+	return out
+}
+
+func (m strengthMatcher) reverseDictionaryMatches(pw []rune) []strengthMatch {
+	n := len(pw)
+	rev := make([]rune, n)
+	for i, r := range pw {
+		rev[n-1-i] = r
+	}
+
+	matches := m.dictionaryMatches(rev)
+	for k := range matches {
+		mt := &matches[k]
+		mt.i, mt.j = n-1-mt.j, n-1-mt.i
+		mt.token = string(pw[mt.i : mt.j+1])
+		mt.reversed = true
+	}
+	return matches
+}
+
+func (m strengthMatcher) l33tMatches(pw []rune) []strengthMatch {
+	// which substitutions could apply, and the letters each could stand for
+	present := make(map[rune][]rune)
+	for _, r := range pw {
+		if _, seen := present[r]; seen {
+			continue
+		}
+		for letter, subs := range l33tTable {
+			if strings.ContainsRune(subs, r) {
+				present[r] = append(present[r], letter)
+			}
+		}
+	}
+	if len(present) == 0 {
+		return nil
+	}
+
+	// enumerate one letter choice per substituted rune, in a fixed order so
+	// the cap drops the same combinations every time
+	runes := slices.Sorted(maps.Keys(present))
+	subs := []map[rune]rune{{}}
+	for _, r := range runes {
+		letters := present[r]
+		slices.Sort(letters)
+		var next []map[rune]rune
+		for _, s := range subs {
+			for _, l := range letters {
+				c := make(map[rune]rune, len(s)+1)
+				for k, v := range s {
+					c[k] = v
+				}
+				c[r] = l
+				next = append(next, c)
+			}
+		}
+		if len(next) > maxL33tSubs {
+			break
+		}
+		subs = next
+	}
+
+	var out []strengthMatch
+	seen := make(map[[3]int]bool)
+	for _, sub := range subs {
+		unsubbed := make([]rune, len(pw))
+		for i, r := range pw {
+			if l, ok := sub[r]; ok {
+				unsubbed[i] = l
+			} else {
+				unsubbed[i] = r
+			}
+		}
+
+		for _, mt := range m.dictionaryMatches(unsubbed) {
+			token := pw[mt.i : mt.j+1]
+
+			// keep only the substitutions used in this token
+			used := make(map[rune]rune)
+			for _, r := range token {
+				if l, ok := sub[r]; ok {
+					used[r] = l
+				}
+			}
+			// single characters are common enough to be noise
+			if len(used) == 0 || len(token) <= 1 {
+				continue
+			}
+
+			key := [3]int{mt.i, mt.j, mt.rank}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			mt.token = string(token)
+			mt.l33t = used
+			out = append(out, mt)
+		}
+	}
+	return out
+}
+
+// keyboard layouts in the format zxcvbn uses to derive adjacency: each key
+// lists its unshifted and shifted characters, and rows are offset to match
+// the physical stagger
+const (
+	qwertyLayout = `
+` + "`~" + ` 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) -_ =+
+    qQ wW eE rR tT yY uU iI oO pP [{ ]} \|
+     aA sS dD fF gG hH jJ kK lL ;: '"
+      zZ xX cC vV bB nN mM ,< .> /?
+`
+
+	keypadLayout = `
+  / * -
+7 8 9 +
+4 5 6
+1 2 3
+  0 .
+`
+)
+
+// keyboardGraph maps each character to its neighbouring keys, in a fixed
+// direction order; an empty string marks a missing neighbour.
+type keyboardGraph struct {
+	name      string
+	adjacent  map[rune][]string
+	starts    float64 // number of keys
+	avgDegree float64
+}
+
+var keyboardGraphs = sync.OnceValue(func() []keyboardGraph {
+	return []keyboardGraph{
+		buildKeyboardGraph("qwerty", qwertyLayout, true),
+		buildKeyboardGraph("keypad", keypadLayout, false),
+	}
+})
+
+// buildKeyboardGraph derives adjacency from a layout. Slanted layouts have
+// six neighbours per key, aligned ones eight.
+func buildKeyboardGraph(name, layout string, slanted bool) keyboardGraph {
+	type pos struct{ x, y int }
+
+	tokens := strings.Fields(layout)
+	unit := len(tokens[0]) + 1
+
+	keys := make(map[pos]string)
+	for y, line := range strings.Split(layout, "\n") {
+		slant := 0
+		if slanted {
+			slant = y - 1
+		}
+		for _, tok := range strings.Fields(line) {
+			x := (strings.Index(line, tok) - slant) / unit
+			keys[pos{x, y}] = tok
+		}
+	}
+
+	neighbours := func(p pos) []pos {
+		x, y := p.x, p.y
+		if slanted {
+			return []pos{{x - 1, y}, {x, y - 1}, {x + 1, y - 1}, {x + 1, y}, {x, y + 1}, {x - 1, y + 1}}
+		}
+		return []pos{{x - 1, y}, {x - 1, y - 1}, {x, y - 1}, {x + 1, y - 1}, {x + 1, y}, {x + 1, y + 1}, {x, y + 1}, {x - 1, y + 1}}
+	}
+
+	g := keyboardGraph{name: name, adjacent: make(map[rune][]string)}
+	var degrees int
+	for p, tok := range keys {
+		var adj []string
+		for _, n := range neighbours(p) {
+			adj = append(adj, keys[n])
+			if keys[n] != "" {
+				degrees++
+			}
+		}
+		for _, r := range tok {
+			g.adjacent[r] = adj
+		}
+	}
+	g.starts = float64(len(keys))
+	g.avgDegree = float64(degrees) / float64(len(keys))
+	return g
+}
+
+// spatialMatches finds runs of three or more adjacent keys.
+func spatialMatches(pw []rune) []strengthMatch {
+	var out []strengthMatch
+	for _, g := range keyboardGraphs() {
+		out = append(out, g.matches(pw)...)
+	}
+	return out
+}
+
+func (g keyboardGraph) matches(pw []rune) []strengthMatch {
+	var out []strengthMatch
+
+	for i := 0; i < len(pw)-1; {
+		j := i + 1
+		lastDir, turns, shifted := -1, 0, 0
+		if g.name == "qwerty" && isShifted(pw[i]) {
+			shifted = 1
+		}
+
+		for {
+			found := false
+			if j < len(pw) {
+				for dir, adj := range g.adjacent[pw[j-1]] {
+					idx := strings.IndexRune(adj, pw[j])
+					if adj == "" || idx < 0 {
+						continue
+					}
+					found = true
+					if idx == 1 {
+						shifted++
+					}
+					if dir != lastDir {
+						turns++
+						lastDir = dir
+					}
+					break
+				}
+			}
+
+			if found {
+				j++
+				continue
+			}
+
+			if j-i > 2 {
+				out = append(out, strengthMatch{
+					kind:    matchSpatial,
+					i:       i,
+					j:       j - 1,
+					token:   string(pw[i:j]),
+					graph:   g.name,
+					turns:   turns,
+					shifted: shifted,
+				})
+			}
+			i = j
+			break
+		}
+	}
+	return out
+}
+
+func isShifted(r rune) bool {
+	return unicode.IsUpper(r) || strings.ContainsRune(`~!@#$%^&*()_+{}|:"<>?`, r)
+}
+
+// repeatMatches finds repeated characters or blocks, like "aaa" or
+// "abcabc", scoring the base with a nested estimate.
+func (m strengthMatcher) repeatMatches(pw []rune) []strengthMatch {
+	var out []strengthMatch
+
+	for start := 0; start < len(pw); {
+		i, greedy, lazy := findRepeat(pw, start)
+		if i < 0 {
+			break
+		}
+
+		// prefer the longer span; the greedy span's base is its shortest
+		// repeating unit
+		span, base := lazy.span, lazy.base
+		if greedy.span > lazy.span {
+			span = greedy.span
+			base = shortestPeriod(pw[i : i+span])
+		}
+
+		baseRunes := pw[i : i+base]
+		est := m.mostGuessable(baseRunes)
+		out = append(out, strengthMatch{
+			kind:        matchRepeat,
+			i:           i,
+			j:           i + span - 1,
+			token:       string(pw[i : i+span]),
+			baseToken:   string(baseRunes),
+			baseGuesses: est.guesses,
+			repeats:     span / base,
+		})
+		start = i + span
+	}
+	return out
+}
+
+type repeatRun struct{ base, span int }
+
+// findRepeat returns the leftmost position at or after start where some
+// block repeats, with the longest-base and shortest-base repetitions there.
+func findRepeat(pw []rune, start int) (int, repeatRun, repeatRun) {
+	for i := start; i < len(pw); i++ {
+		var greedy, lazy repeatRun
+		for l := 1; i+2*l <= len(pw); l++ {
+			if reps := countRepeats(pw[i:], l); reps >= 2 {
+				if lazy.base == 0 {
+					lazy = repeatRun{l, reps * l}
+				}
+				greedy = repeatRun{l, reps * l}
+			}
+		}
+		if lazy.base != 0 {
+			return i, greedy, lazy
+		}
+	}
+	return -1, repeatRun{}, repeatRun{}
+}
+
+// countRepeats counts how many times the first l runes of s repeat back to
+// back at its start.
+func countRepeats(s []rune, l int) int {
+	reps := 1
+	for k := l; k+l <= len(s) && string(s[k:k+l]) == string(s[:l]); k += l {
+		reps++
+	}
+	return reps
+}
+
+// shortestPeriod returns the length of the shortest block that tiles s.
+func shortestPeriod(s []rune) int {
+	for l := 1; l <= len(s)/2; l++ {
+		if len(s)%l == 0 && countRepeats(s, l) == len(s)/l {
+			return l
+		}
+	}
+	return len(s)
+}
+
+// maxSequenceDelta is the largest step between runes that still counts as
+// a sequence, so "acegi" and "97531" match.
+const maxSequenceDelta = 5
+
+// sequenceMatches finds runs with a constant step, like "abcd" or "9753".
+func sequenceMatches(pw []rune) []strengthMatch {
+	if len(pw) < 2 {
+		return nil
+	}
+
+	var out []strengthMatch
+	add := func(i, j, delta int) {
+		if j-i <= 1 && abs(delta) != 1 {
+			return
+		}
+		if delta == 0 || abs(delta) > maxSequenceDelta {
+			return
+		}
+
+		token := string(pw[i : j+1])
+		space := 26
+		if strings.Trim(token, digitChars) == "" {
+			space = 10
+		}
+		out = append(out, strengthMatch{
+			kind:      matchSequence,
+			i:         i,
+			j:         j,
+			token:     token,
+			space:     space,
+			ascending: delta > 0,
+		})
+	}
+
+	i, last := 0, int(pw[1]-pw[0])
+	for k := 1; k < len(pw); k++ {
+		delta := int(pw[k] - pw[k-1])
+		if delta == last {
+			continue
+		}
+		add(i, k-1, last)
+		i, last = k-1, delta
+	}
+	add(i, len(pw)-1, last)
+
+	return out
+}
+
+// yearMatches finds four-digit years from 1900 to 2099.
+func yearMatches(pw []rune) []strengthMatch {
+	var out []strengthMatch
+	for i := 0; i+4 <= len(pw); i++ {
+		token := string(pw[i : i+4])
+		if (strings.HasPrefix(token, "19") || strings.HasPrefix(token, "20")) && strings.Trim(token, digitChars) == "" {
+			year, _ := strconv.Atoi(token)
+			out = append(out, strengthMatch{kind: matchYear, i: i, j: i + 3, token: token, year: year})
+		}
+	}
+	return out
+}
+
+// date bounds and the digit splits tried for dates without separators
+const (
+	dateMinYear = 1000
+	dateMaxYear = 2050
+)
+
+var dateSplits = map[int][][2]int{
+	4: {{1, 2}, {2, 3}},         // 1 1 91, 11 1 1
+	5: {{1, 3}, {2, 3}},         // 1 11 91, 11 1 91
+	6: {{1, 2}, {2, 4}, {4, 5}}, // 1 1 1991, 11 11 91, 1991 1 1
+	7: {{1, 3}, {2, 3}, {4, 5}, {4, 6}},
+	8: {{2, 4}, {4, 6}}, // 11 11 1991, 1991 11 11
+}
+
+// dateMatches finds day, month and year in either order, with or without
+// separators, like "13.5.1989" or "890513".
+func (m strengthMatcher) dateMatches(pw []rune) []strengthMatch {
+	var out []strengthMatch
+
+	// without separators: 4 to 8 digits
+	for i := 0; i+4 <= len(pw); i++ {
+		for j := i + 3; j < i+8 && j < len(pw); j++ {
+			token := string(pw[i : j+1])
+			if strings.Trim(token, digitChars) != "" {
+				break
+			}
+
+			best, found := 0, false
+			for _, s := range dateSplits[len(token)] {
+				a, _ := strconv.Atoi(token[:s[0]])
+				b, _ := strconv.Atoi(token[s[0]:s[1]])
+				c, _ := strconv.Atoi(token[s[1]:])
+				if y, ok := dateYear(a, b, c); ok && (!found || abs(y-m.year) < abs(best-m.year)) {
+					best, found = y, true
+				}
+			}
+			if found {
+				out = append(out, strengthMatch{kind: matchDate, i: i, j: j, token: token, year: best})
+			}
+		}
+	}
+
+	// with separators: 6 to 10 runes, like 1-1-91 or 1991/11/11
+	for i := 0; i+6 <= len(pw); i++ {
+		for j := i + 5; j < i+10 && j < len(pw); j++ {
+			if y, ok := m.separatedDate(string(pw[i : j+1])); ok {
+				out = append(out, strengthMatch{kind: matchDate, i: i, j: j, token: string(pw[i : j+1]), year: y, separator: true})
+			}
+		}
+	}
+
+	// drop dates inside longer dates, like "1/1/91" within "11/1/91"
+	var kept []strengthMatch
+	for _, a := range out {
+		inside := false
+		for _, b := range out {
+			if b.i <= a.i && b.j >= a.j && b.j-b.i > a.j-a.i {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			kept = append(kept, a)
+		}
+	}
+	return kept
+}
+
+// separatedDate parses token as three numbers split by the same separator.
+func (m strengthMatcher) separatedDate(token string) (int, bool) {
+	sepIdx := strings.IndexAny(token, ` /\_.-`)
+	if sepIdx < 1 {
+		return 0, false
+	}
+
+	parts := strings.Split(token, token[sepIdx:sepIdx+1])
+	if len(parts) != 3 || len(parts[0]) > 4 || len(parts[1]) < 1 || len(parts[1]) > 2 || len(parts[2]) < 1 || len(parts[2]) > 4 {
+		return 0, false
+	}
+
+	var ints [3]int
+	for k, p := range parts {
+		if strings.Trim(p, digitChars) != "" {
+			return 0, false
+		}
+		ints[k], _ = strconv.Atoi(p)
+	}
+
+	return dateYear(ints[0], ints[1], ints[2])
+}
+
+// dateYear interprets three numbers as a day, month and year in any common
+// order and returns the year, expanding two-digit years.
+func dateYear(a, b, c int) (int, bool) {
+	if b > 31 || b <= 0 {
+		return 0, false
+	}
+
+	over12, over31, under1 := 0, 0, 0
+	for _, n := range [3]int{a, b, c} {
+		if (n > 99 && n < dateMinYear) || n > dateMaxYear {
+			return 0, false
+		}
+		if n > 31 {
+			over31++
+		}
+		if n > 12 {
+			over12++
+		}
+		if n <= 0 {
+			under1++
+		}
+	}
+	if over31 >= 2 || over12 == 3 || under1 >= 2 {
+		return 0, false
+	}
+
+	type split struct{ year, x, y int }
+	splits := [2]split{{c, a, b}, {a, b, c}}
+
+	// a four-digit year decides the split
+	for _, s := range splits {
+		if s.year >= dateMinYear && s.year <= dateMaxYear {
+			return s.year, isDayMonth(s.x, s.y)
+		}
+	}
+
+	for _, s := range splits {
+		if isDayMonth(s.x, s.y) {
+			switch {
+			case s.year > 99:
+				return s.year, true
+			case s.year > 50:
+				return s.year + 1900, true
+			default:
+				return s.year + 2000, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func isDayMonth(a, b int) bool {
+	dm := func(d, m int) bool { return d >= 1 && d <= 31 && m >= 1 && m <= 12 }
+	return dm(a, b) || dm(b, a)
+}
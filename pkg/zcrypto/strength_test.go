@@ -0,0 +1,150 @@
+package zcrypto_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func TestEstimateStrength(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		maxScore int
+		minScore int
+		warning  string
+	}{
+		{name: "top password", password: "password", maxScore: 0, warning: "This is a top-10 common password"},
+		{name: "l33t password", password: "P@ssw0rd", maxScore: 0, warning: "This is similar to a commonly used password"},
+		{name: "reversed password", password: "drowssap", maxScore: 0, warning: "This is similar to a commonly used password"},
+		{name: "keyboard row", password: "zxcvbnm,./", maxScore: 1, warning: "Straight rows of keys are easy to guess"},
+		{name: "keyboard turns", password: "qwsazxcde", maxScore: 2, warning: "Short keyboard patterns are easy to guess"},
+		{name: "repeated char", password: "zzzzzzzzzz", maxScore: 0, warning: `Repeats like "aaa" are easy to guess`},
+		{name: "repeated block", password: "kitkatkitkatkitkat", maxScore: 1, warning: `Repeats like "abcabcabc" are only slightly harder to guess than "abc"`},
+		{name: "sequence", password: "lmnopqrs", maxScore: 0, warning: "Sequences like abc or 6543 are easy to guess"},
+		{name: "date", password: "13.05.1989", maxScore: 1, warning: "Dates are often easy to guess"},
+		{name: "date without separators", password: "05131989", maxScore: 1, warning: "Dates are often easy to guess"},
+		{name: "word", password: "situation", maxScore: 1, warning: "A word by itself is easy to guess"},
+		{name: "name", password: "jessica", maxScore: 0, warning: "Names and surnames by themselves are easy to guess"},
+		{name: "passphrase", password: "correct horse battery staple", minScore: 4},
+		{name: "random", password: "kJ8#xq2!Lm9@vR", minScore: 4},
+		{name: "empty", password: "", maxScore: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := zcrypto.EstimateStrength(tt.password)
+
+			if tt.maxScore == 0 && tt.minScore == 0 && s.Score != 0 {
+				t.Errorf("score = %d, want 0", s.Score)
+			}
+			if tt.maxScore > 0 && s.Score > tt.maxScore {
+				t.Errorf("score = %d, want at most %d", s.Score, tt.maxScore)
+			}
+			if s.Score < tt.minScore {
+				t.Errorf("score = %d, want at least %d", s.Score, tt.minScore)
+			}
+			if s.Warning != tt.warning {
+				t.Errorf("warning = %q, want %q", s.Warning, tt.warning)
+			}
+			if s.Score <= 2 && len(s.Suggestions) == 0 {
+				t.Error("weak password has no suggestions")
+			}
+			if s.Score > 2 && (s.Warning != "" || len(s.Suggestions) != 0) {
+				t.Errorf("strong password has feedback %q %v", s.Warning, s.Suggestions)
+			}
+		})
+	}
+}
+
+func TestEstimateStrengthSuggestions(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"Monkey", "Capitalization doesn't help very much"},
+		{"MONKEY", "All-uppercase is almost as easy to guess as all-lowercase"},
+		{"yeknom", "Reversed words aren't much harder to guess"},
+		{"m0nk3y", "Predictable substitutions like '@' instead of 'a' don't help very much"},
+	}
+
+	for _, tt := range tests {
+		s := zcrypto.EstimateStrength(tt.password)
+		found := false
+		for _, got := range s.Suggestions {
+			found = found || got == tt.want
+		}
+		if !found {
+			t.Errorf("%q: suggestions %q missing %q", tt.password, s.Suggestions, tt.want)
+		}
+	}
+}
+
+func TestEstimateStrengthUserInputs(t *testing.T) {
+	const pw = "zarlcorpwidgetco"
+
+	without := zcrypto.EstimateStrength(pw)
+	with := zcrypto.EstimateStrength(pw, "zarlcorp", "WidgetCo")
+
+	if with.Guesses >= without.Guesses {
+		t.Fatalf("user inputs did not lower guesses: %g >= %g", with.Guesses, without.Guesses)
+	}
+	if with.Warning != "Passwords based on your account details are easy to guess" {
+		t.Fatalf("warning = %q", with.Warning)
+	}
+}
+
+func TestEstimateStrengthMonotonic(t *testing.T) {
+	// adding random characters never makes a password easier to guess
+	prev := 0.0
+	pw := ""
+	for _, c := range "Xq7#pL2!vN9@" {
+		pw += string(c)
+		s := zcrypto.EstimateStrength(pw)
+		if s.Guesses < prev {
+			t.Fatalf("%q: guesses dropped from %g to %g", pw, prev, s.Guesses)
+		}
+		prev = s.Guesses
+	}
+}
+
+func TestEstimateStrengthLongAndUnicode(t *testing.T) {
+	long := strings.Repeat("Xq7#pL2!vN9@", 50)
+	if s := zcrypto.EstimateStrength(long); s.Score != 4 {
+		t.Fatalf("long password score = %d", s.Score)
+	}
+
+	if s := zcrypto.EstimateStrength("пароль密码🔑"); s.Guesses <= 1 {
+		t.Fatalf("unicode password guesses = %g", s.Guesses)
+	}
+}
+
+func TestPasswordStrengthCrackTime(t *testing.T) {
+	tests := []struct {
+		guesses float64
+		want    string
+	}{
+		{5e3, "less than a second"},
+		{1e4, "1 second"},
+		{9e5, "2 minutes"},
+		{3.6e8, "10 hours"},
+		{1e20, "centuries"},
+	}
+
+	for _, tt := range tests {
+		s := zcrypto.PasswordStrength{Guesses: tt.guesses}
+		if got := s.CrackTimeDisplay(); got != tt.want {
+			t.Errorf("%g guesses: display %q, want %q", tt.guesses, got, tt.want)
+		}
+	}
+
+	if s := zcrypto.EstimateStrength("password"); s.CrackTime >= time.Second {
+		t.Fatalf("crack time for a top password = %v", s.CrackTime)
+	}
+	if s := zcrypto.EstimateStrength(zcrypto.GeneratePassword(60)); s.CrackTime != time.Duration(math.MaxInt64) {
+		t.Fatalf("crack time did not saturate: %v", s.CrackTime)
+	}
+}
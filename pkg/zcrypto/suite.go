@@ -0,0 +1,138 @@
+package zcrypto
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// XNonceSize is the nonce length for XChaCha20-Poly1305.
+const XNonceSize = chacha20poly1305.NonceSizeX
+
+// Suite identifies an authenticated cipher. Its value is the identifier
+// byte written by EncryptSuite.
+type Suite byte
+
+// Cipher suites.
+const (
+	// AES256GCM is AES-256-GCM with 96-bit random nonces. It is fastest on
+	// CPUs with AES instructions, but a key should not seal more than about
+	// 2^32 messages before random nonces risk colliding.
+	AES256GCM Suite = 1
+
+	// XChaCha20Poly1305 uses 192-bit random nonces, so a key can seal
+	// practically unlimited messages, and runs in constant time at good
+	// speed on CPUs without AES instructions, such as small ARM boards.
+	XChaCha20Poly1305 Suite = 2
+)
+
+// String returns the suite's name.
+func (s Suite) String() string {
+	switch s {
+	case AES256GCM:
+		return "AES-256-GCM"
+	case XChaCha20Poly1305:
+		return "XChaCha20-Poly1305"
+	default:
+		return fmt.Sprintf("Suite(%d)", byte(s))
+	}
+}
+
+// NewSuiteSealer returns a Sealer using suite for key, which must be
+// exactly 32 bytes for both suites.
+func NewSuiteSealer(suite Suite, key []byte) (*Sealer, error) {
+	var (
+		aead cipher.AEAD
+		err  error
+	)
+
+	switch suite {
+	case AES256GCM:
+		aead, err = newGCM(key)
+	case XChaCha20Poly1305:
+		aead, err = newXChaCha(key)
+	default:
+		return nil, fmt.Errorf("unsupported cipher suite %d", byte(suite))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealer{suite: suite, aead: aead}, nil
+}
+
+// EncryptXChaCha encrypts plaintext using XChaCha20-Poly1305 with the given
+// key. Key must be exactly 32 bytes. Returns ciphertext with the 24-byte
+// nonce prepended.
+func EncryptXChaCha(key, plaintext []byte) ([]byte, error) {
+	s, err := NewSuiteSealer(XChaCha20Poly1305, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.Encrypt(plaintext, nil)
+}
+
+// DecryptXChaCha decrypts ciphertext produced by EncryptXChaCha.
+func DecryptXChaCha(key, ciphertext []byte) ([]byte, error) {
+	s, err := NewSuiteSealer(XChaCha20Poly1305, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.Decrypt(ciphertext, nil)
+}
+
+// EncryptSuite encrypts plaintext with the given suite and authenticates ad,
+// which may be nil. The output starts with the suite identifier byte,
+// followed by the nonce and ciphertext, so DecryptSuite can open it without
+// knowing the suite in advance. The identifier byte is also authenticated.
+func EncryptSuite(suite Suite, key, plaintext, ad []byte) ([]byte, error) {
+	s, err := NewSuiteSealer(suite, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ct, err := s.Encrypt(plaintext, suiteAD(suite, ad))
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte{byte(suite)}, ct...), nil
+}
+
+// DecryptSuite decrypts ciphertext produced by EncryptSuite with any suite
+// and the same associated data.
+func DecryptSuite(key, ciphertext, ad []byte) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	suite := Suite(ciphertext[0])
+	s, err := NewSuiteSealer(suite, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Decrypt(ciphertext[1:], suiteAD(suite, ad))
+}
+
+// suiteAD prefixes ad with the suite identifier so the identifier cannot be
+// changed without failing authentication.
+func suiteAD(suite Suite, ad []byte) []byte {
+	return append([]byte{byte(suite)}, ad...)
+}
+
+// newXChaCha returns an XChaCha20-Poly1305 AEAD for key.
+func newXChaCha(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, fmt.Errorf("create XChaCha20-Poly1305: %w", err)
+	}
+
+	return aead, nil
+}
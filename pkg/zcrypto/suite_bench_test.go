@@ -0,0 +1,67 @@
+package zcrypto_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+var benchSizes = []int{64, 1024, 64 * 1024}
+
+func benchmarkSeal(b *testing.B, suite zcrypto.Suite) {
+	s, err := zcrypto.NewSuiteSealer(suite, make([]byte, zcrypto.KeySize))
+	if err != nil {
+		b.Fatal(err)
+	}
+	nonce := make([]byte, s.NonceSize())
+
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			buf := make([]byte, size, size+zcrypto.TagSize)
+
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for range b.N {
+				if _, err := s.Seal(buf[:0], nonce, buf, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func benchmarkEncrypt(b *testing.B, suite zcrypto.Suite) {
+	key := make([]byte, zcrypto.KeySize)
+
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("%dB", size), func(b *testing.B) {
+			plaintext := make([]byte, size)
+
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for range b.N {
+				if _, err := zcrypto.EncryptSuite(suite, key, plaintext, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSeal_AES256GCM(b *testing.B) {
+	benchmarkSeal(b, zcrypto.AES256GCM)
+}
+
+func BenchmarkSeal_XChaCha20Poly1305(b *testing.B) {
+	benchmarkSeal(b, zcrypto.XChaCha20Poly1305)
+}
+
+// Encrypt benchmarks include cipher setup and a random nonce per message.
+func BenchmarkEncrypt_AES256GCM(b *testing.B) {
+	benchmarkEncrypt(b, zcrypto.AES256GCM)
+}
+
+func BenchmarkEncrypt_XChaCha20Poly1305(b *testing.B) {
+	benchmarkEncrypt(b, zcrypto.XChaCha20Poly1305)
+}
@@ -0,0 +1,144 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func TestEncryptXChaCha(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+	plaintext := []byte("hello, xchacha")
+
+	ct, err := zcrypto.EncryptXChaCha(key, plaintext)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if want := zcrypto.XNonceSize + len(plaintext) + zcrypto.TagSize; len(ct) != want {
+		t.Fatalf("ciphertext length = %d, want %d", len(ct), want)
+	}
+
+	got, err := zcrypto.DecryptXChaCha(key, ct)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("got %q, want %q", got, plaintext)
+	}
+
+	ct[len(ct)-1] ^= 1
+	if _, err := zcrypto.DecryptXChaCha(key, ct); err == nil {
+		t.Fatal("expected error for tampered ciphertext")
+	}
+
+	if _, err := zcrypto.EncryptXChaCha(key[:16], plaintext); err == nil {
+		t.Fatal("expected error for short key")
+	}
+}
+
+func TestEncryptSuite(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+	plaintext := []byte("either suite")
+	ad := []byte("context")
+
+	for _, suite := range []zcrypto.Suite{zcrypto.AES256GCM, zcrypto.XChaCha20Poly1305} {
+		t.Run(suite.String(), func(t *testing.T) {
+			ct, err := zcrypto.EncryptSuite(suite, key, plaintext, ad)
+			if err != nil {
+				t.Fatalf("encrypt: %v", err)
+			}
+			if zcrypto.Suite(ct[0]) != suite {
+				t.Fatalf("suite byte = %d, want %d", ct[0], suite)
+			}
+
+			got, err := zcrypto.DecryptSuite(key, ct, ad)
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Fatalf("got %q, want %q", got, plaintext)
+			}
+
+			if _, err := zcrypto.DecryptSuite(key, ct, nil); err == nil {
+				t.Fatal("expected error for wrong associated data")
+			}
+		})
+	}
+}
+
+func TestDecryptSuiteInvalid(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+
+	ct, err := zcrypto.EncryptSuite(zcrypto.XChaCha20Poly1305, key, []byte("secret"), nil)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	// relabelling the ciphertext as the other suite fails authentication
+	relabelled := bytes.Clone(ct)
+	relabelled[0] = byte(zcrypto.AES256GCM)
+
+	unknown := bytes.Clone(ct)
+	unknown[0] = 0x7f
+
+	tests := []struct {
+		name string
+		ct   []byte
+	}{
+		{name: "empty", ct: nil},
+		{name: "suite byte only", ct: ct[:1]},
+		{name: "relabelled suite", ct: relabelled},
+		{name: "unknown suite", ct: unknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := zcrypto.DecryptSuite(key, tt.ct, nil); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestNewSuiteSealer(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+
+	tests := []struct {
+		suite     zcrypto.Suite
+		nonceSize int
+		wantErr   bool
+	}{
+		{suite: zcrypto.AES256GCM, nonceSize: zcrypto.NonceSize},
+		{suite: zcrypto.XChaCha20Poly1305, nonceSize: zcrypto.XNonceSize},
+		{suite: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.suite.String(), func(t *testing.T) {
+			s, err := zcrypto.NewSuiteSealer(tt.suite, key)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("new sealer: %v", err)
+			}
+			if s.Suite() != tt.suite || s.NonceSize() != tt.nonceSize {
+				t.Fatalf("suite %v nonce size %d", s.Suite(), s.NonceSize())
+			}
+
+			nonce := make([]byte, tt.nonceSize)
+			ct, err := s.Seal(nil, nonce, []byte("msg"), nil)
+			if err != nil {
+				t.Fatalf("seal: %v", err)
+			}
+			got, err := s.Open(nil, nonce, ct, nil)
+			if err != nil || string(got) != "msg" {
+				t.Fatalf("open = %q, %v", got, err)
+			}
+		})
+	}
+}
@@ -3,9 +3,15 @@ package zcrypto
 import (
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base32"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash"
+	"math"
 	"strings"
 	"time"
 )
@@ -13,8 +19,59 @@ import (
 const (
 	// totpPeriod is the time step in seconds (RFC 6238 default).
 	totpPeriod = 30
+
+	otpDefaultDigits = 6
+	otpSecretSize    = 20 // 160 bits, as recommended by RFC 4226
+	otpDefaultSkew   = 1
+
+	// maxOTPSkew bounds WithSkew; each step of skew is another code an
+	// attacker's guess may match
+	maxOTPSkew = 10
 )
 
+// otpBase32 encodes secrets the way authenticator apps expect them.
+var otpBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ErrInvalidOTP is returned when a one-time password does not match.
+var ErrInvalidOTP = errors.New("invalid one-time password")
+
+// ErrOTPReused is returned when a one-time password matches a time step
+// that was already used.
+var ErrOTPReused = errors.New("one-time password already used")
+
+// OTPType distinguishes counter-based from time-based one-time passwords.
+type OTPType string
+
+// One-time password types, as used in otpauth URIs.
+const (
+	TOTP OTPType = "totp"
+	HOTP OTPType = "hotp"
+)
+
+// OTPAlgorithm is the HMAC hash used to compute one-time passwords.
+type OTPAlgorithm string
+
+// One-time password algorithms, as used in otpauth URIs.
+const (
+	OTPSHA1   OTPAlgorithm = "SHA1"
+	OTPSHA256 OTPAlgorithm = "SHA256"
+	OTPSHA512 OTPAlgorithm = "SHA512"
+)
+
+// OTPKey describes a HOTP (RFC 4226) or TOTP (RFC 6238) credential. Zero
+// values select the common defaults: TOTP, SHA1, 6 digits and a 30-second
+// period.
+type OTPKey struct {
+	Type      OTPType
+	Issuer    string
+	Account   string
+	Secret    string // base32, case-insensitive, padding optional
+	Algorithm OTPAlgorithm
+	Digits    int
+	Period    int    // seconds per time step, TOTP only
+	Counter   uint64 // next counter value, HOTP only
+}
+
 // TOTPCode generates the current 6-digit TOTP code for the given base32-encoded secret.
 // The secret is case-insensitive and padding is optional.
 func TOTPCode(secret string) (string, error) {
@@ -24,25 +81,219 @@ func TOTPCode(secret string) (string, error) {
 // TOTPCodeAt generates a 6-digit TOTP code for the given base32-encoded secret at time t.
 // Implements RFC 6238 with HMAC-SHA1, 30-second period, and 6-digit output.
 func TOTPCodeAt(secret string, t time.Time) (string, error) {
-	key, err := decodeSecret(secret)
+	return OTPKey{Secret: secret}.TOTPCodeAt(t)
+}
+
+// GenerateOTPSecret returns a random 160-bit secret encoded as unpadded
+// base32, ready for an OTPKey or an otpauth URI.
+func GenerateOTPSecret() (string, error) {
+	b, err := RandBytes(otpSecretSize)
+	if err != nil {
+		return "", err
+	}
+	return otpBase32.EncodeToString(b), nil
+}
+
+// TOTPCodeAt returns the TOTP code for the time step containing t.
+func (k OTPKey) TOTPCodeAt(t time.Time) (string, error) {
+	return k.HOTPCode(k.TimeStep(t))
+}
+
+// TimeStep returns the TOTP counter for the time step containing t.
+func (k OTPKey) TimeStep(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(k.period())
+}
+
+// HOTPCode returns the code for the given counter value (RFC 4226).
+func (k OTPKey) HOTPCode(counter uint64) (string, error) {
+	key, err := decodeSecret(k.Secret)
 	if err != nil {
 		return "", fmt.Errorf("decode secret: %w", err)
 	}
+	defer Erase(key)
+
+	return k.code(key, counter)
+}
+
+// code computes the truncated HMAC for counter with a decoded key.
+func (k OTPKey) code(key []byte, counter uint64) (string, error) {
+	newHash, err := k.hash()
+	if err != nil {
+		return "", err
+	}
 
-	counter := uint64(t.Unix()) / totpPeriod
+	digits := k.digits()
+	if digits < 6 || digits > 10 {
+		return "", fmt.Errorf("digits must be 6 to 10, got %d", digits)
+	}
 
 	var buf [8]byte
 	binary.BigEndian.PutUint64(buf[:], counter)
 
-	mac := hmac.New(sha1.New, key)
+	mac := hmac.New(newHash, key)
 	mac.Write(buf[:])
 	h := mac.Sum(nil)
 
 	offset := h[len(h)-1] & 0x0f
-	code := binary.BigEndian.Uint32(h[offset:offset+4]) & 0x7fffffff
-	code %= 1_000_000
+	code := uint64(binary.BigEndian.Uint32(h[offset:offset+4]) & 0x7fffffff)
+
+	mod := uint64(1)
+	for range digits {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, code%mod), nil
+}
+
+// OTPOption configures one-time password validation.
+type OTPOption func(*otpConfig)
+
+type otpConfig struct {
+	skew     int
+	used     uint64
+	haveUsed bool
+}
+
+func defaultOTPConfig() otpConfig {
+	return otpConfig{skew: otpDefaultSkew}
+}
+
+// WithSkew sets how many steps away from the expected one a code may be.
+// For TOTP it allows that many time steps either side of the current one,
+// absorbing clock drift; for HOTP it is how far ahead of the expected
+// counter to look, absorbing codes generated but never submitted. The
+// default is 1; values are clamped to between 0 and 10.
+func WithSkew(steps int) OTPOption {
+	return func(c *otpConfig) {
+		c.skew = min(max(steps, 0), maxOTPSkew)
+	}
+}
+
+// WithUsedCounter rejects with ErrOTPReused any TOTP code whose time step is
+// not after counter, the value ValidateTOTP returned for the last accepted
+// code. This stops a code that was observed being replayed within its
+// validity window.
+func WithUsedCounter(counter uint64) OTPOption {
+	return func(c *otpConfig) {
+		c.used = counter
+		c.haveUsed = true
+	}
+}
+
+// ValidateTOTP checks code against the TOTP key at time t, allowing the
+// skew set by WithSkew. It returns the time step the code matched; persist
+// it and pass it to WithUsedCounter next time to prevent replays. Returns
+// ErrInvalidOTP if the code does not match and ErrOTPReused if it matches a
+// step that was already used.
+func ValidateTOTP(k OTPKey, code string, t time.Time, opts ...OTPOption) (uint64, error) {
+	cfg := defaultOTPConfig()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	step := k.TimeStep(t)
+	first := step - min(step, uint64(cfg.skew))
+
+	matched, err := k.match(code, first, addSaturating(step, uint64(cfg.skew)))
+	if err != nil {
+		return 0, err
+	}
+
+	if cfg.haveUsed && matched <= cfg.used {
+		return 0, ErrOTPReused
+	}
+
+	return matched, nil
+}
+
+// ValidateHOTP checks code against the HOTP key's counter, looking ahead as
+// far as WithSkew allows. It returns the counter to store for the next
+// validation, one past the value that matched. Returns ErrInvalidOTP if the
+// code does not match.
+func ValidateHOTP(k OTPKey, code string, opts ...OTPOption) (uint64, error) {
+	cfg := defaultOTPConfig()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	matched, err := k.match(code, k.Counter, addSaturating(k.Counter, uint64(cfg.skew)))
+	if err != nil {
+		return 0, err
+	}
+	if matched == math.MaxUint64 {
+		return 0, errors.New("hotp counter exhausted")
+	}
 
-	return fmt.Sprintf("%06d", code), nil
+	return matched + 1, nil
+}
+
+// addSaturating returns a+b, or the largest uint64 if that overflows.
+func addSaturating(a, b uint64) uint64 {
+	if a > math.MaxUint64-b {
+		return math.MaxUint64
+	}
+	return a + b
+}
+
+// match returns the first counter in [from, to] whose code equals code.
+// Every candidate is compared so the time taken does not reveal which one
+// matched.
+func (k OTPKey) match(code string, from, to uint64) (uint64, error) {
+	key, err := decodeSecret(k.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("decode secret: %w", err)
+	}
+	defer Erase(key)
+
+	var (
+		matched uint64
+		found   bool
+	)
+	for c := from; ; c++ {
+		want, err := k.code(key, c)
+		if err != nil {
+			return 0, err
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 && !found {
+			matched, found = c, true
+		}
+		if c == to {
+			break
+		}
+	}
+
+	if !found {
+		return 0, ErrInvalidOTP
+	}
+	return matched, nil
+}
+
+// hash returns the constructor for the key's algorithm.
+func (k OTPKey) hash() (func() hash.Hash, error) {
+	switch OTPAlgorithm(strings.ToUpper(string(k.Algorithm))) {
+	case "", OTPSHA1:
+		return sha1.New, nil
+	case OTPSHA256:
+		return sha256.New, nil
+	case OTPSHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported OTP algorithm %q", k.Algorithm)
+	}
+}
+
+func (k OTPKey) digits() int {
+	if k.Digits == 0 {
+		return otpDefaultDigits
+	}
+	return k.Digits
+}
+
+func (k OTPKey) period() int {
+	if k.Period <= 0 {
+		return totpPeriod
+	}
+	return k.Period
 }
 
 // decodeSecret decodes a base32 secret, handling case and optional padding.
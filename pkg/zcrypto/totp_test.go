@@ -1,6 +1,8 @@
 package zcrypto_test
 
 import (
+	"errors"
+	"math"
 	"testing"
 	"time"
 
@@ -238,3 +240,224 @@ func TestTOTPCodeCallsTOTPCodeAt(t *testing.T) {
 		t.Logf("TOTPCode and TOTPCodeAt differ (likely window boundary crossing) — not a failure")
 	}
 }
+
+func TestHOTPCodeRFC4226(t *testing.T) {
+	// RFC 4226 appendix D
+	want := []string{
+		"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489",
+	}
+
+	k := zcrypto.OTPKey{Type: zcrypto.HOTP, Secret: rfc6238Secret}
+	for counter, w := range want {
+		got, err := k.HOTPCode(uint64(counter))
+		if err != nil {
+			t.Fatalf("counter %d: %v", counter, err)
+		}
+		if got != w {
+			t.Fatalf("counter %d: got %q, want %q", counter, got, w)
+		}
+	}
+}
+
+func TestOTPKeyTOTPCodeAtRFC6238(t *testing.T) {
+	// RFC 6238 appendix B, 8 digits, with a seed of the hash's length
+	sha256Key := zcrypto.OTPKey{
+		Secret:    "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA",
+		Algorithm: zcrypto.OTPSHA256,
+		Digits:    8,
+	}
+	sha512Key := zcrypto.OTPKey{
+		Secret:    "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA",
+		Algorithm: zcrypto.OTPSHA512,
+		Digits:    8,
+	}
+
+	tests := []struct {
+		time   int64
+		sha256 string
+		sha512 string
+	}{
+		{time: 59, sha256: "46119246", sha512: "90693936"},
+		{time: 1111111109, sha256: "68084774", sha512: "25091201"},
+		{time: 1111111111, sha256: "67062674", sha512: "99943326"},
+		{time: 1234567890, sha256: "91819424", sha512: "93441116"},
+		{time: 2000000000, sha256: "90698825", sha512: "38618901"},
+		{time: 20000000000, sha256: "77737706", sha512: "47863826"},
+	}
+
+	for _, tt := range tests {
+		ts := time.Unix(tt.time, 0)
+
+		got, err := sha256Key.TOTPCodeAt(ts)
+		if err != nil || got != tt.sha256 {
+			t.Fatalf("SHA256 t=%d: got %q, %v, want %q", tt.time, got, err, tt.sha256)
+		}
+
+		got, err = sha512Key.TOTPCodeAt(ts)
+		if err != nil || got != tt.sha512 {
+			t.Fatalf("SHA512 t=%d: got %q, %v, want %q", tt.time, got, err, tt.sha512)
+		}
+	}
+}
+
+func TestOTPKeyPeriod(t *testing.T) {
+	k := zcrypto.OTPKey{Secret: rfc6238Secret, Period: 60}
+
+	if got := k.TimeStep(time.Unix(119, 0)); got != 1 {
+		t.Fatalf("time step = %d, want 1", got)
+	}
+
+	// a 60s period at t=119 uses the same counter as 30s at t=59
+	got, err := k.TOTPCodeAt(time.Unix(119, 0))
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	if got != "287082" {
+		t.Fatalf("got %q, want %q", got, "287082")
+	}
+}
+
+func TestOTPKeyInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		key  zcrypto.OTPKey
+	}{
+		{name: "bad secret", key: zcrypto.OTPKey{Secret: "!!!"}},
+		{name: "bad algorithm", key: zcrypto.OTPKey{Secret: rfc6238Secret, Algorithm: "MD5"}},
+		{name: "too few digits", key: zcrypto.OTPKey{Secret: rfc6238Secret, Digits: 4}},
+		{name: "too many digits", key: zcrypto.OTPKey{Secret: rfc6238Secret, Digits: 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.key.HOTPCode(0); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	k := zcrypto.OTPKey{Secret: rfc6238Secret}
+	now := time.Unix(1111111111, 0)
+	step := k.TimeStep(now)
+
+	codeAt := func(offset int) string {
+		c, err := k.HOTPCode(uint64(int64(step) + int64(offset)))
+		if err != nil {
+			t.Fatalf("code: %v", err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		code    string
+		opts    []zcrypto.OTPOption
+		want    uint64
+		wantErr error
+	}{
+		{name: "current", code: codeAt(0), want: step},
+		{name: "previous step", code: codeAt(-1), want: step - 1},
+		{name: "next step", code: codeAt(1), want: step + 1},
+		{name: "outside skew", code: codeAt(2), wantErr: zcrypto.ErrInvalidOTP},
+		{name: "wider skew", code: codeAt(2), opts: []zcrypto.OTPOption{zcrypto.WithSkew(2)}, want: step + 2},
+		{name: "no skew", code: codeAt(-1), opts: []zcrypto.OTPOption{zcrypto.WithSkew(0)}, wantErr: zcrypto.ErrInvalidOTP},
+		{name: "wrong code", code: "000000", wantErr: zcrypto.ErrInvalidOTP},
+		{name: "replayed", code: codeAt(0), opts: []zcrypto.OTPOption{zcrypto.WithUsedCounter(step)}, wantErr: zcrypto.ErrOTPReused},
+		{name: "older than used", code: codeAt(-1), opts: []zcrypto.OTPOption{zcrypto.WithUsedCounter(step)}, wantErr: zcrypto.ErrOTPReused},
+		{name: "newer than used", code: codeAt(0), opts: []zcrypto.OTPOption{zcrypto.WithUsedCounter(step - 1)}, want: step},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := zcrypto.ValidateTOTP(k, tt.code, now, tt.opts...)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("matched step %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateHOTP(t *testing.T) {
+	k := zcrypto.OTPKey{Type: zcrypto.HOTP, Secret: rfc6238Secret, Counter: 3}
+
+	// counter 3 is "969429", counter 4 is "338314", counter 5 is "254676"
+	next, err := zcrypto.ValidateHOTP(k, "969429")
+	if err != nil || next != 4 {
+		t.Fatalf("validate = %d, %v, want 4", next, err)
+	}
+
+	next, err = zcrypto.ValidateHOTP(k, "338314")
+	if err != nil || next != 5 {
+		t.Fatalf("look-ahead validate = %d, %v, want 5", next, err)
+	}
+
+	if _, err := zcrypto.ValidateHOTP(k, "254676"); !errors.Is(err, zcrypto.ErrInvalidOTP) {
+		t.Fatalf("expected ErrInvalidOTP beyond window, got %v", err)
+	}
+
+	// earlier counters are never accepted again
+	if _, err := zcrypto.ValidateHOTP(k, "359152"); !errors.Is(err, zcrypto.ErrInvalidOTP) {
+		t.Fatalf("expected ErrInvalidOTP for old counter, got %v", err)
+	}
+}
+
+func TestValidateOTPSkewBounds(t *testing.T) {
+	k := zcrypto.OTPKey{Type: zcrypto.HOTP, Secret: rfc6238Secret, Counter: 0}
+
+	// skew is clamped, so a code far ahead of the counter is never reached
+	far, err := k.HOTPCode(1000)
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	if _, err := zcrypto.ValidateHOTP(k, far, zcrypto.WithSkew(1<<30)); !errors.Is(err, zcrypto.ErrInvalidOTP) {
+		t.Fatalf("expected ErrInvalidOTP beyond the clamped skew, got %v", err)
+	}
+
+	// the look-ahead window does not wrap around to counter 0
+	k.Counter = math.MaxUint64 - 2
+	zero, err := k.HOTPCode(0)
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	if _, err := zcrypto.ValidateHOTP(k, zero, zcrypto.WithSkew(10)); !errors.Is(err, zcrypto.ErrInvalidOTP) {
+		t.Fatalf("expected ErrInvalidOTP for a wrapped counter, got %v", err)
+	}
+	last, err := k.HOTPCode(math.MaxUint64 - 1)
+	if err != nil {
+		t.Fatalf("code: %v", err)
+	}
+	if next, err := zcrypto.ValidateHOTP(k, last, zcrypto.WithSkew(10)); err != nil || next != math.MaxUint64 {
+		t.Fatalf("validate near the end = %d, %v", next, err)
+	}
+}
+
+func TestGenerateOTPSecret(t *testing.T) {
+	s1, err := zcrypto.GenerateOTPSecret()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	s2, err := zcrypto.GenerateOTPSecret()
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	if len(s1) != 32 || s1 == s2 {
+		t.Fatalf("unexpected secrets %q and %q", s1, s2)
+	}
+
+	if _, err := zcrypto.TOTPCodeAt(s1, time.Now()); err != nil {
+		t.Fatalf("generated secret unusable: %v", err)
+	}
+}
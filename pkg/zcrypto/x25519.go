@@ -0,0 +1,165 @@
+package zcrypto
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+const (
+	// X25519KeySize is the length of X25519 private and public keys.
+	X25519KeySize = 32
+
+	// SealedBoxOverhead is how much longer a sealed box is than its message.
+	SealedBoxOverhead = box.AnonymousOverhead
+)
+
+// errNilKey is returned by methods called on, or given, a nil or zero key.
+var errNilKey = errors.New("nil or uninitialised key")
+
+// X25519Key is an X25519 private key, for agreeing keys with another
+// device's X25519PublicKey and opening boxes sealed to it.
+type X25519Key struct {
+	key *ecdh.PrivateKey
+	pub *X25519PublicKey
+}
+
+// X25519PublicKey is an X25519 public key. Anyone holding it can seal
+// boxes only the matching X25519Key opens.
+type X25519PublicKey struct {
+	key *ecdh.PublicKey
+}
+
+// GenerateX25519Key creates a random X25519 key.
+func GenerateX25519Key() (*X25519Key, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate x25519 key: %w", err)
+	}
+	return &X25519Key{key: key, pub: &X25519PublicKey{key: key.PublicKey()}}, nil
+}
+
+// NewX25519Key wraps a 32-byte private key, as returned by Bytes. Store it
+// encrypted to keep a key.
+func NewX25519Key(priv []byte) (*X25519Key, error) {
+	if len(priv) != X25519KeySize {
+		return nil, fmt.Errorf("private key must be %d bytes, got %d", X25519KeySize, len(priv))
+	}
+	key, err := ecdh.X25519().NewPrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("parse x25519 key: %w", err)
+	}
+	return &X25519Key{key: key, pub: &X25519PublicKey{key: key.PublicKey()}}, nil
+}
+
+// Bytes returns the raw 32-byte private key.
+func (k *X25519Key) Bytes() []byte {
+	return k.key.Bytes()
+}
+
+// Public returns the public half of k.
+func (k *X25519Key) Public() *X25519PublicKey {
+	return k.pub
+}
+
+// SharedKey agrees a 32-byte key with peer: both sides get the same key
+// from their own private key and the other's public key. info separates
+// keys for different purposes, as with ExpandKey. The key is only as
+// trustworthy as peer, so check its Fingerprint out of band or receive it
+// over a Pairing first.
+func (k *X25519Key) SharedKey(peer *X25519PublicKey, info []byte) ([]byte, error) {
+	if !k.valid() || !peer.valid() {
+		return nil, errNilKey
+	}
+
+	secret, err := k.key.ECDH(peer.key)
+	if err != nil {
+		return nil, fmt.Errorf("agree key: %w", err)
+	}
+	defer Erase(secret)
+
+	// bind both public keys, in an order both sides agree on
+	a, b := k.pub.key.Bytes(), peer.key.Bytes()
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	return ExpandKey(secret, append(a, b...), info)
+}
+
+// OpenSealed decrypts a box sealed to k's public key. It cannot tell who
+// sealed it.
+func (k *X25519Key) OpenSealed(sealed []byte) ([]byte, error) {
+	if !k.valid() {
+		return nil, errNilKey
+	}
+	if len(sealed) < SealedBoxOverhead {
+		return nil, errors.New("sealed box too short")
+	}
+
+	var priv, pub [X25519KeySize]byte
+	defer Erase(priv[:])
+	copy(priv[:], k.key.Bytes())
+	copy(pub[:], k.pub.key.Bytes())
+
+	msg, ok := box.OpenAnonymous(nil, sealed, &pub, &priv)
+	if !ok {
+		return nil, errors.New("open sealed box: authentication failed")
+	}
+	return msg, nil
+}
+
+// NewX25519PublicKey wraps a raw 32-byte public key.
+func NewX25519PublicKey(pub []byte) (*X25519PublicKey, error) {
+	if len(pub) != X25519KeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", X25519KeySize, len(pub))
+	}
+	key, err := ecdh.X25519().NewPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("parse x25519 public key: %w", err)
+	}
+	return &X25519PublicKey{key: key}, nil
+}
+
+// Bytes returns the raw 32-byte public key.
+func (p *X25519PublicKey) Bytes() []byte {
+	return p.key.Bytes()
+}
+
+// Fingerprint returns the key's fingerprint, for users to compare before
+// trusting it.
+func (p *X25519PublicKey) Fingerprint() Fingerprint {
+	return NewFingerprint(p.key.Bytes())
+}
+
+// Seal encrypts message so only the holder of the matching X25519Key can
+// read it. The sender stays anonymous: each box uses a fresh ephemeral
+// key, so the result is SealedBoxOverhead bytes longer than message.
+// Boxes are compatible with libsodium's crypto_box_seal.
+func (p *X25519PublicKey) Seal(message []byte) ([]byte, error) {
+	if !p.valid() {
+		return nil, errNilKey
+	}
+
+	var pub [X25519KeySize]byte
+	copy(pub[:], p.key.Bytes())
+
+	sealed, err := box.SealAnonymous(nil, message, &pub, rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("seal box: %w", err)
+	}
+	return sealed, nil
+}
+
+// valid reports whether k was made by GenerateX25519Key or NewX25519Key.
+func (k *X25519Key) valid() bool {
+	return k != nil && k.key != nil && k.pub.valid()
+}
+
+// valid reports whether p holds a key.
+func (p *X25519PublicKey) valid() bool {
+	return p != nil && p.key != nil
+}
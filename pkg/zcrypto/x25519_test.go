@@ -0,0 +1,163 @@
+package zcrypto_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+func TestX25519Key(t *testing.T) {
+	k, err := zcrypto.GenerateX25519Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := zcrypto.NewX25519Key(k.Bytes())
+	if err != nil {
+		t.Fatalf("NewX25519Key: %v", err)
+	}
+	if !bytes.Equal(restored.Public().Bytes(), k.Public().Bytes()) {
+		t.Error("restored key has a different public key")
+	}
+
+	pub, err := zcrypto.NewX25519PublicKey(k.Public().Bytes())
+	if err != nil {
+		t.Fatalf("NewX25519PublicKey: %v", err)
+	}
+	if pub.Fingerprint() != k.Public().Fingerprint() {
+		t.Error("fingerprint changed through Bytes")
+	}
+
+	if _, err := zcrypto.NewX25519Key(make([]byte, 31)); err == nil {
+		t.Error("NewX25519Key accepted a short key")
+	}
+	if _, err := zcrypto.NewX25519PublicKey(make([]byte, 33)); err == nil {
+		t.Error("NewX25519PublicKey accepted a long key")
+	}
+}
+
+func TestX25519SharedKey(t *testing.T) {
+	alice, err := zcrypto.GenerateX25519Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := zcrypto.GenerateX25519Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ab, err := alice.SharedKey(bob.Public(), []byte("sync"))
+	if err != nil {
+		t.Fatalf("SharedKey: %v", err)
+	}
+	ba, err := bob.SharedKey(alice.Public(), []byte("sync"))
+	if err != nil {
+		t.Fatalf("SharedKey: %v", err)
+	}
+	if !bytes.Equal(ab, ba) || len(ab) != zcrypto.KeySize {
+		t.Fatalf("shared keys differ: %x, %x", ab, ba)
+	}
+
+	other, err := alice.SharedKey(bob.Public(), []byte("backup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(ab, other) {
+		t.Error("different info gave the same key")
+	}
+
+	// an all-zero public key is a low-order point
+	zero, err := zcrypto.NewX25519PublicKey(make([]byte, zcrypto.X25519KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := alice.SharedKey(zero, nil); err == nil {
+		t.Error("SharedKey with a low-order key succeeded")
+	}
+}
+
+func TestSealedBox(t *testing.T) {
+	k, err := zcrypto.GenerateX25519Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("vault key for the laptop")
+
+	sealed, err := k.Public().Seal(msg)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if len(sealed) != len(msg)+zcrypto.SealedBoxOverhead {
+		t.Errorf("len = %d, want %d", len(sealed), len(msg)+zcrypto.SealedBoxOverhead)
+	}
+
+	again, err := k.Public().Seal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(sealed, again) {
+		t.Error("sealing twice gave the same box")
+	}
+
+	got, err := k.OpenSealed(sealed)
+	if err != nil {
+		t.Fatalf("OpenSealed: %v", err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("OpenSealed = %q, want %q", got, msg)
+	}
+
+	other, err := zcrypto.GenerateX25519Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name   string
+		key    *zcrypto.X25519Key
+		sealed []byte
+	}{
+		{"wrong key", other, sealed},
+		{"tampered", k, tampered},
+		{"truncated", k, sealed[:zcrypto.SealedBoxOverhead-1]},
+		{"empty", k, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.key.OpenSealed(tt.sealed); err == nil {
+				t.Error("OpenSealed succeeded")
+			}
+		})
+	}
+}
+
+func TestX25519NilKeys(t *testing.T) {
+	k, err := zcrypto.GenerateX25519Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := k.Public().Seal([]byte("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bad := range []*zcrypto.X25519Key{nil, {}} {
+		if _, err := bad.SharedKey(k.Public(), nil); err == nil {
+			t.Error("SharedKey with a nil private key succeeded")
+		}
+		if _, err := bad.OpenSealed(sealed); err == nil {
+			t.Error("OpenSealed with a nil key succeeded")
+		}
+	}
+	for _, bad := range []*zcrypto.X25519PublicKey{nil, {}} {
+		if _, err := k.SharedKey(bad, nil); err == nil {
+			t.Error("SharedKey with a nil peer succeeded")
+		}
+		if _, err := bad.Seal([]byte("hi")); err == nil {
+			t.Error("Seal to a nil key succeeded")
+		}
+	}
+}
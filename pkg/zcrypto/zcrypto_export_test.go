@@ -0,0 +1,23 @@
+package zcrypto
+
+// ReservedForTest reports whether the module at x, y belongs to a function
+// pattern rather than data.
+func (q *QRCode) ReservedForTest(x, y int) bool { return q.reserved[y*q.size+x] }
+
+// QRBlocksForTest returns the block count and error correction codewords
+// per block for a version and level.
+func QRBlocksForTest(version int, level QRLevel) (blocks, ecc int) {
+	return qrBlocks[level][version], qrECCPerBlock[level][version]
+}
+
+// GFMulForTest multiplies in the GF(256) field shared by QR codes and secret sharing.
+func GFMulForTest(a, b byte) byte { return gfMul(a, b) }
+
+// GFInvForTest inverts in the GF(256) field used by secret sharing.
+func GFInvForTest(a byte) byte { return gfInv(a) }
+
+// Argon2ForTest runs the package's own Argon2 without Argon2Key's bounds
+// or its shortcut to x/crypto/argon2.
+func Argon2ForTest(v Argon2Variant, password, salt, secret, data []byte, time, memory uint32, threads uint8, keyLen uint32) ([]byte, error) {
+	return argon2Key(v, password, salt, secret, data, time, memory, threads, keyLen)
+}
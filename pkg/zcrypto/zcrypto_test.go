@@ -2,6 +2,9 @@ package zcrypto_test
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
 	"testing"
 
 	"github.com/zarlcorp/core/pkg/zcrypto"
@@ -387,3 +390,105 @@ func TestEncryptFileEmptyContent(t *testing.T) {
 		t.Fatalf("expected empty output, got %d bytes", len(decrypted.Bytes()))
 	}
 }
+
+func TestEncryptFileLarge(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+
+	original := make([]byte, 3*zcrypto.ChunkSize+17)
+	for i := range original {
+		original[i] = byte(i * 7)
+	}
+
+	var encrypted bytes.Buffer
+	if err := zcrypto.EncryptFile(key, bytes.NewReader(original), &encrypted); err != nil {
+		t.Fatalf("encrypt file: %v", err)
+	}
+
+	var decrypted bytes.Buffer
+	if err := zcrypto.DecryptFile(key, bytes.NewReader(encrypted.Bytes()), &decrypted); err != nil {
+		t.Fatalf("decrypt file: %v", err)
+	}
+
+	if !bytes.Equal(decrypted.Bytes(), original) {
+		t.Fatal("round trip mismatch")
+	}
+}
+
+func TestDecryptFileLegacyFormat(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+	original := []byte("written before files were chunked")
+
+	// older versions sealed the whole file with Encrypt
+	legacy, err := zcrypto.Encrypt(key, original)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	var decrypted bytes.Buffer
+	if err := zcrypto.DecryptFile(key, bytes.NewReader(legacy), &decrypted); err != nil {
+		t.Fatalf("decrypt file: %v", err)
+	}
+
+	if !bytes.Equal(decrypted.Bytes(), original) {
+		t.Fatalf("got %q, want %q", decrypted.Bytes(), original)
+	}
+}
+
+func TestDecryptFileLegacyNonceLikeHeader(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+	original := []byte("sealed under an unlucky nonce")
+
+	// a legacy file whose random nonce happens to start with the magic
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := []byte("zcfile\x09abcde")
+	legacy := gcm.Seal(bytes.Clone(nonce), nonce, original, nil)
+
+	var decrypted bytes.Buffer
+	if err := zcrypto.DecryptFile(key, bytes.NewReader(legacy), &decrypted); err != nil {
+		t.Fatalf("decrypt file: %v", err)
+	}
+	if !bytes.Equal(decrypted.Bytes(), original) {
+		t.Fatalf("got %q, want %q", decrypted.Bytes(), original)
+	}
+}
+
+func TestDecryptFileInvalid(t *testing.T) {
+	key := make([]byte, zcrypto.KeySize)
+
+	var encrypted bytes.Buffer
+	original := bytes.Repeat([]byte("x"), 2*zcrypto.ChunkSize)
+	if err := zcrypto.EncryptFile(key, bytes.NewReader(original), &encrypted); err != nil {
+		t.Fatalf("encrypt file: %v", err)
+	}
+	ct := encrypted.Bytes()
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "header only", data: ct[:7]},
+		{name: "truncated at chunk boundary", data: ct[:len(ct)-zcrypto.ChunkSize-16]},
+		{name: "unknown version", data: append([]byte("zcfile\x09"), ct[7:]...)},
+		{name: "flipped bit", data: func() []byte {
+			b := bytes.Clone(ct)
+			b[len(b)-1] ^= 1
+			return b
+		}()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := zcrypto.DecryptFile(key, bytes.NewReader(tt.data), io.Discard); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -47,6 +47,14 @@ type RemoveFS interface {
 	Remove(filename string) error
 }
 
+// RenameFS defines the interface for renaming files. It is optional:
+// ReadWriteFileFS does not include it, and both MemFS and OSFileSystem
+// implement it.
+type RenameFS interface {
+	// Rename renames oldpath to newpath, replacing newpath if it exists.
+	Rename(oldpath, newpath string) error
+}
+
 // MkdirFS defines the interface for creating directories.
 type MkdirFS interface {
 	// MkdirAll creates a directory named path, along with any necessary parents.
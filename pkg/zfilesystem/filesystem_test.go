@@ -7,6 +7,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/zarlcorp/core/pkg/zfilesystem"
@@ -192,6 +193,46 @@ func testReadWriteFileFS(t *testing.T, fs zfilesystem.ReadWriteFileFS) {
 		}
 	})
 
+	t.Run("Rename", func(t *testing.T) {
+		rfs, ok := fs.(zfilesystem.RenameFS)
+		if !ok {
+			t.Skip("filesystem does not implement RenameFS")
+		}
+
+		if err := fs.MkdirAll("rename", 0o755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := fs.WriteFile("rename/old.txt", []byte("moved"), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		if err := fs.WriteFile("rename/new.txt", []byte("replaced"), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		defer fs.Remove("rename/new.txt")
+
+		if err := rfs.Rename("rename/old.txt", "rename/new.txt"); err != nil {
+			t.Fatalf("Rename() error = %v", err)
+		}
+
+		data, err := fs.ReadFile("rename/new.txt")
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if string(data) != "moved" {
+			t.Errorf("ReadFile() after Rename = %q, want %q", string(data), "moved")
+		}
+		if _, err := fs.ReadFile("rename/old.txt"); err == nil {
+			t.Error("ReadFile() of old path should fail after Rename()")
+		}
+
+		if err := rfs.Rename("rename/missing.txt", "rename/other.txt"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Rename() of missing file error = %v, want os.ErrNotExist", err)
+		}
+		if err := rfs.Rename("rename/new.txt", "../escape.txt"); err == nil {
+			t.Error("Rename() outside the filesystem should fail")
+		}
+	})
+
 	t.Run("WalkDir operations", func(t *testing.T) {
 		files := map[string][]byte{
 			"walk1.txt": []byte("content1"),
@@ -621,3 +662,38 @@ func testReadWriteFileFS(t *testing.T, fs zfilesystem.ReadWriteFileFS) {
 		}
 	})
 }
+
+func TestMemFSRenameConcurrent(t *testing.T) {
+	fs := zfilesystem.NewMemFS()
+	if err := fs.WriteFile("a", []byte("data"), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	// renames racing in both directions must never lose the file
+	var wg sync.WaitGroup
+	for i := range 8 {
+		from, to := "a", "b"
+		if i%2 == 1 {
+			from, to = to, from
+		}
+		wg.Go(func() {
+			for range 1000 {
+				if err := fs.Rename(from, to); err != nil && !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("Rename() error = %v", err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	var found int
+	for _, name := range []string{"a", "b"} {
+		if _, err := fs.ReadFile(name); err == nil {
+			found++
+		}
+	}
+	if found != 1 {
+		t.Fatalf("found %d copies of the file after concurrent renames, want 1", found)
+	}
+}
@@ -8,18 +8,26 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/zarlcorp/core/pkg/zsync"
 )
 
-var _ ReadWriteFileFS = (*MemFS)(nil)
+var (
+	_ ReadWriteFileFS = (*MemFS)(nil)
+	_ RenameFS        = (*MemFS)(nil)
+)
 
 // MemFS provides an in-memory filesystem implementation.
 // It implements ReadWriteFileFS and is safe for concurrent use.
 type MemFS struct {
 	files *zsync.ZMap[string, *memFile]
 	dirs  *zsync.ZSet[string]
+
+	// mu is held shared by single-file operations and exclusively by
+	// Rename, so no one sees a rename half done
+	mu sync.RWMutex
 }
 
 type memFile struct {
@@ -55,7 +63,9 @@ func (mfs *MemFS) ReadFile(filename string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	mfs.mu.RLock()
 	file, ok := mfs.files.Get(p)
+	mfs.mu.RUnlock()
 	if !ok {
 		return nil, os.ErrNotExist
 	}
@@ -69,6 +79,8 @@ func (mfs *MemFS) WriteFile(filename string, data []byte, perm fs.FileMode) erro
 	if err != nil {
 		return err
 	}
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
 	mfs.files.Set(p, &memFile{
 		data:    bytes.Clone(data),
 		modTime: time.Now(),
@@ -97,12 +109,42 @@ func (mfs *MemFS) Remove(filename string) error {
 	if err != nil {
 		return err
 	}
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
 	if !mfs.files.Delete(p) {
 		return os.ErrNotExist
 	}
 	return nil
 }
 
+// Rename moves a file in memory, replacing any file at newpath. Other
+// goroutines see the file at oldpath or at newpath, never both or neither.
+func (mfs *MemFS) Rename(oldpath, newpath string) error {
+	from, err := cleanPath(oldpath)
+	if err != nil {
+		return err
+	}
+	to, err := cleanPath(newpath)
+	if err != nil {
+		return err
+	}
+
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+
+	file, ok := mfs.files.Get(from)
+	if !ok {
+		return os.ErrNotExist
+	}
+	if from == to {
+		return nil
+	}
+	mfs.files.Set(to, file)
+	mfs.files.Delete(from)
+	mfs.addParentDirs(to)
+	return nil
+}
+
 // MkdirAll creates a directory and all necessary parents.
 func (mfs *MemFS) MkdirAll(path string, perm fs.FileMode) error {
 	p, err := cleanPath(path)
@@ -137,7 +179,9 @@ func (mfs *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error
 	}
 
 	// For read operations, check if file exists
+	mfs.mu.RLock()
 	file, ok := mfs.files.Get(p)
+	mfs.mu.RUnlock()
 	if !ok {
 		return nil, os.ErrNotExist
 	}
@@ -383,6 +427,8 @@ func (fh *memFileHandle) Close() error {
 
 	// If in write mode, save the buffer contents to the filesystem
 	if fh.writeMode {
+		fh.mfs.mu.RLock()
+		defer fh.mfs.mu.RUnlock()
 		fh.mfs.files.Set(fh.filename, &memFile{
 			data:    fh.buffer.Bytes(),
 			modTime: time.Now(),
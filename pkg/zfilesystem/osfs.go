@@ -8,7 +8,10 @@ import (
 	"strings"
 )
 
-var _ ReadWriteFileFS = (*OSFileSystem)(nil)
+var (
+	_ ReadWriteFileFS = (*OSFileSystem)(nil)
+	_ RenameFS        = (*OSFileSystem)(nil)
+)
 
 // OSFileSystem implements ReadWriteFileFS using the standard os package.
 type OSFileSystem struct {
@@ -71,6 +74,19 @@ func (o *OSFileSystem) Remove(filename string) error {
 	return os.Remove(p)
 }
 
+// Rename renames a file in the OS filesystem, replacing any file at newpath.
+func (o *OSFileSystem) Rename(oldpath, newpath string) error {
+	from, err := o.resolvePath(oldpath)
+	if err != nil {
+		return err
+	}
+	to, err := o.resolvePath(newpath)
+	if err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
 // MkdirAll creates a directory and all necessary parents.
 func (o *OSFileSystem) MkdirAll(path string, perm fs.FileMode) error {
 	p, err := o.resolvePath(path)
@@ -0,0 +1,14 @@
+// Package zidentity generates burner identities for zarlcorp privacy tools.
+//
+// A Generator draws names, postal addresses, phone numbers, birthdates and
+// usernames from embedded datasets for en-US, en-GB, de-DE, fr-FR and
+// es-ES, formatted as each country writes them. Phone numbers come only
+// from ranges reserved for fiction; Spain has none, so es-ES identities
+// have no phone number. Values are drawn with crypto/rand; WithSeed makes
+// the output repeatable for tests.
+//
+//	g, err := zidentity.New(zidentity.WithLocale("fr-FR"))
+//	id := g.Identity(time.Now())
+//	fmt.Println(id.Name, id.Username, id.Phone)
+//	fmt.Println(id.Address)
+package zidentity
@@ -0,0 +1,3 @@
+module github.com/zarlcorp/core/pkg/zidentity
+
+go 1.26.0
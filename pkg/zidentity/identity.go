@@ -0,0 +1,399 @@
+package zidentity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	mrand "math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLocale is the locale an Generator uses unless
+// WithLocale picks another.
+const DefaultLocale = "en-US"
+
+// Ages Identity draws birthdates from.
+const (
+	identityMinAge = 18
+	identityMaxAge = 65
+
+	// maxIdentityAge bounds Birthdate's maxAge.
+	maxIdentityAge = 130
+)
+
+// Name is a generated person's name.
+type Name struct {
+	Given  string
+	Family string
+}
+
+// String returns the name in the order it is written in the generator's
+// locales: given name first.
+func (n Name) String() string {
+	return strings.TrimSpace(n.Given + " " + n.Family)
+}
+
+// Address is a generated postal address. The city, region and
+// postal code belong together, though in en-GB only the first half of the
+// postcode is real; the street is a common name in the country and the
+// house number is random.
+type Address struct {
+	Street     string // house number and street, in local order
+	City       string
+	Region     string // state, county, region or province
+	PostalCode string
+	Country    string // ISO 3166-1 alpha-2 code
+}
+
+// String formats the address as it would be written on an envelope in
+// its country, one line per line of the result.
+func (a Address) String() string {
+	var lines []string
+	switch a.Country {
+	case "US":
+		lines = []string{a.Street, a.City + ", " + a.Region + " " + a.PostalCode}
+	case "GB":
+		lines = []string{a.Street, a.City, a.PostalCode}
+	case "ES":
+		lines = []string{a.Street, a.PostalCode + " " + a.City}
+		if a.Region != "" && a.Region != a.City {
+			lines = append(lines, a.Region)
+		}
+	default:
+		lines = []string{a.Street, a.PostalCode + " " + a.City}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Identity is a complete burner profile.
+type Identity struct {
+	Name      Name
+	Username  string
+	Birthdate time.Time
+	Address   Address
+	Phone     string // empty in es-ES; see Generator.Phone
+}
+
+// Option configures an Generator.
+type Option func(*config)
+
+type config struct {
+	locale string
+	seed   []byte
+}
+
+// WithLocale selects the locale names, addresses and phone
+// numbers are drawn from; see Locales.
+func WithLocale(locale string) Option {
+	return func(c *config) {
+		c.locale = locale
+	}
+}
+
+// WithSeed makes the generator deterministic: generators with the
+// same seed and locale produce the same sequence of values. Use it for
+// tests and fixtures only; the output is as predictable as the seed.
+func WithSeed(seed []byte) Option {
+	return func(c *config) {
+		c.seed = slices.Clone(seed)
+	}
+}
+
+// Generator generates random identity data for burner profiles:
+// names, postal addresses, phone numbers, birthdates and usernames, drawn
+// from embedded per-locale datasets with crypto/rand. It is safe for
+// concurrent use.
+type Generator struct {
+	locale *identityLocale
+	data   *identityDataset
+
+	mu  sync.Mutex
+	rng *mrand.Rand
+}
+
+// New returns a generator for DefaultLocale, or
+// the locale chosen with WithLocale.
+func New(opts ...Option) (*Generator, error) {
+	cfg := config{locale: DefaultLocale}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	locale, ok := identityLocales[cfg.locale]
+	if !ok {
+		return nil, fmt.Errorf("unsupported locale %q", cfg.locale)
+	}
+	data, err := locale.data()
+	if err != nil {
+		return nil, fmt.Errorf("load identity data: %w", err)
+	}
+
+	g := &Generator{locale: locale, data: data}
+	if cfg.seed != nil {
+		g.rng = mrand.New(mrand.NewChaCha8(sha256.Sum256(cfg.seed)))
+	} else {
+		g.rng = mrand.New(cryptoSource{})
+	}
+	return g, nil
+}
+
+// Locales returns the supported locales, sorted.
+func Locales() []string {
+	return slices.Sorted(maps.Keys(identityLocales))
+}
+
+// Locale returns the generator's locale.
+func (g *Generator) Locale() string {
+	return g.locale.name
+}
+
+// Name returns a random name. In es-ES the family name is two surnames.
+func (g *Generator) Name() Name {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.name()
+}
+
+func (g *Generator) name() Name {
+	n := Name{
+		Given:  g.pick(g.data.given),
+		Family: g.pick(g.data.family),
+	}
+	if g.locale.twoSurnames {
+		second := g.pick(g.data.family)
+		for second == n.Family {
+			second = g.pick(g.data.family)
+		}
+		n.Family += " " + second
+	}
+	return n
+}
+
+// Address returns a random postal address.
+func (g *Generator) Address() Address {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.address()
+}
+
+func (g *Generator) address() Address {
+	city := g.data.cities[g.rng.IntN(len(g.data.cities))]
+	street := g.pick(g.data.streets)
+	return Address{
+		Street:     g.locale.street(g.locale.houseNumber(g.rng), street),
+		City:       city.name,
+		Region:     city.region,
+		PostalCode: g.locale.postcode(g.rng, city),
+		Country:    g.locale.country,
+	}
+}
+
+// ErrNoPhoneRange is returned by Phone in locales whose regulator reserves
+// no numbers for fiction.
+var ErrNoPhoneRange = errors.New("locale has no phone numbers reserved for fiction")
+
+// Phone returns a random phone number in national format, from a range
+// the regulator reserves for fiction so it never reaches a real person.
+// Spain reserves none, so in es-ES Phone returns ErrNoPhoneRange.
+func (g *Generator) Phone() (string, error) {
+	if g.locale.phone == nil {
+		return "", ErrNoPhoneRange
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.phone(), nil
+}
+
+func (g *Generator) phone() string {
+	if g.locale.phone == nil {
+		return ""
+	}
+	city := g.data.cities[g.rng.IntN(len(g.data.cities))]
+	return g.locale.phone(g.rng, city)
+}
+
+// Birthdate returns a random date of birth, at midnight UTC, for someone
+// between minAge and maxAge years old, inclusive, on now's date.
+func (g *Generator) Birthdate(now time.Time, minAge, maxAge int) (time.Time, error) {
+	if minAge < 0 || maxAge < minAge || maxAge > maxIdentityAge {
+		return time.Time{}, fmt.Errorf("invalid age range %d-%d", minAge, maxAge)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.birthdate(now, minAge, maxAge), nil
+}
+
+func (g *Generator) birthdate(now time.Time, minAge, maxAge int) time.Time {
+	// the youngest turned minAge today, the oldest turns maxAge+1 tomorrow
+	latest := yearsBefore(now, minAge)
+	earliest := yearsBefore(now, maxAge+1).AddDate(0, 0, 1)
+	days := int(latest.Sub(earliest)/(24*time.Hour)) + 1
+	return earliest.AddDate(0, 0, g.rng.IntN(days))
+}
+
+// yearsBefore returns the date, at midnight UTC, years before t's date.
+// February 29 becomes February 28 in common years, not March 1, so that a
+// birthday on it has passed.
+func yearsBefore(t time.Time, years int) time.Time {
+	d := time.Date(t.Year()-years, t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if d.Month() != t.Month() {
+		d = d.AddDate(0, 0, -d.Day())
+	}
+	return d
+}
+
+// Username returns a random handle of lowercase ASCII letters, digits,
+// dots and underscores, built from name the way people build their own.
+// Given the zero Name, it is built from a random name of the locale.
+func (g *Generator) Username(name Name) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.username(name)
+}
+
+func (g *Generator) username(name Name) string {
+	given := asciiHandle(name.Given)
+	first, _, _ := strings.Cut(strings.TrimSpace(name.Family), " ")
+	family := asciiHandle(first)
+	digits := func() string { return fmt.Sprintf("%02d", g.rng.IntN(100)) }
+
+	if given == "" || family == "" {
+		return g.username(g.name())
+	}
+
+	switch g.rng.IntN(5) {
+	case 0:
+		return given + "." + family + digits()
+	case 1:
+		return given[:1] + family + digits()
+	case 2:
+		return given + "_" + family[:1] + digits()
+	case 3:
+		return family + "." + given[:1]
+	default:
+		return given + family + fmt.Sprint(1950+g.rng.IntN(60))
+	}
+}
+
+// Identity returns a complete profile of someone aged 18 to 65 on now's
+// date, with a username built from their name.
+func (g *Generator) Identity(now time.Time) Identity {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	name := g.name()
+	return Identity{
+		Name:      name,
+		Username:  g.username(name),
+		Birthdate: g.birthdate(now, identityMinAge, identityMaxAge),
+		Address:   g.address(),
+		Phone:     g.phone(),
+	}
+}
+
+// pick returns a random element of list.
+func (g *Generator) pick(list []string) string {
+	return list[g.rng.IntN(len(list))]
+}
+
+// cryptoSource is a math/rand/v2 Source reading crypto/rand, so the
+// generator's uniform helpers can be used with a secure source.
+type cryptoSource struct{}
+
+func (cryptoSource) Uint64() uint64 {
+	var b [8]byte
+	// crypto/rand.Read never returns an error
+	rand.Read(b[:])
+	return binary.LittleEndian.Uint64(b[:])
+}
+
+// asciiHandle lowercases s and reduces it to ASCII letters, transliterating
+// the accented letters the datasets use and dropping everything else.
+func asciiHandle(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if r >= 'a' && r <= 'z' {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteString(handleTransliterations[r])
+	}
+	return b.String()
+}
+
+// ASCII spellings of accented letters; umlauts follow German usage
+var handleTransliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'å': "a", 'ä': "ae", 'æ': "ae",
+	'ç': "c",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i",
+	'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ø': "o", 'ö': "oe", 'œ': "oe",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "ue",
+	'ý': "y", 'ÿ': "y",
+	'ß': "ss",
+}
+
+// identityDataset is a locale's parsed data file.
+type identityDataset struct {
+	given   []string
+	family  []string
+	streets []string
+	cities  []identityCity
+}
+
+type identityCity struct {
+	name   string
+	region string
+	code   string // postal code, or its outward half in en-GB
+	area   string // telephone area code, in en-US
+}
+
+// parseIdentityData parses a data file: "[section]" headings followed by
+// one entry per line, with "#" comments. City lines are "|"-separated.
+func parseIdentityData(text string) (*identityDataset, error) {
+	d := &identityDataset{}
+	var section string
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			continue
+		}
+		switch section {
+		case "given":
+			d.given = append(d.given, line)
+		case "family":
+			d.family = append(d.family, line)
+		case "streets":
+			d.streets = append(d.streets, line)
+		case "cities":
+			f := strings.Split(line, "|")
+			if len(f) < 3 {
+				return nil, fmt.Errorf("line %d: malformed city", i+1)
+			}
+			c := identityCity{name: f[0], region: f[1], code: f[2]}
+			if len(f) > 3 {
+				c.area = f[3]
+			}
+			d.cities = append(d.cities, c)
+		default:
+			return nil, fmt.Errorf("line %d: entry outside a known section", i+1)
+		}
+	}
+	if len(d.given) == 0 || len(d.family) < 2 || len(d.streets) == 0 || len(d.cities) == 0 {
+		return nil, errors.New("missing section")
+	}
+	return d, nil
+}
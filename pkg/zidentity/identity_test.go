@@ -0,0 +1,216 @@
+package zidentity_test
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zidentity"
+)
+
+func newGenerator(t *testing.T, opts ...zidentity.Option) *zidentity.Generator {
+	t.Helper()
+	g, err := zidentity.New(opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return g
+}
+
+func TestLocales(t *testing.T) {
+	formats := map[string]struct {
+		postcode, phone *regexp.Regexp
+		country         string
+	}{
+		"en-US": {regexp.MustCompile(`^\d{5}$`), regexp.MustCompile(`^\(\d{3}\) 555-01\d\d$`), "US"},
+		"en-GB": {regexp.MustCompile(`^[A-Z]{1,2}\d{1,2} \d[ABD-HJLNP-UW-Z]{2}$`), regexp.MustCompile(`^07700 900\d{3}$`), "GB"},
+		"de-DE": {regexp.MustCompile(`^\d{5}$`), regexp.MustCompile(`^(030 23125|069 90009|040 66969|0221 4710|089 99998)\d{3}$`), "DE"},
+		"fr-FR": {regexp.MustCompile(`^\d{5}$`), regexp.MustCompile(`^06 39 98 \d\d \d\d$`), "FR"},
+		"es-ES": {regexp.MustCompile(`^\d{5}$`), nil, "ES"},
+	}
+	locales := zidentity.Locales()
+	if !slices.Contains(locales, zidentity.DefaultLocale) || len(locales) != len(formats) {
+		t.Fatalf("Locales() = %v", locales)
+	}
+
+	for _, locale := range locales {
+		t.Run(locale, func(t *testing.T) {
+			want, ok := formats[locale]
+			if !ok {
+				t.Fatalf("no formats for %s", locale)
+			}
+			g := newGenerator(t, zidentity.WithLocale(locale))
+			if g.Locale() != locale {
+				t.Errorf("Locale() = %q", g.Locale())
+			}
+
+			for range 200 {
+				a := g.Address()
+				if a.Street == "" || a.City == "" || a.Country != want.country {
+					t.Fatalf("incomplete address %+v", a)
+				}
+				if !strings.ContainsAny(a.Street, "0123456789") {
+					t.Errorf("street %q has no house number", a.Street)
+				}
+				if !want.postcode.MatchString(a.PostalCode) {
+					t.Errorf("postal code %q", a.PostalCode)
+				}
+				p, err := g.Phone()
+				switch {
+				case want.phone == nil:
+					if !errors.Is(err, zidentity.ErrNoPhoneRange) {
+						t.Errorf("Phone() = %q, %v, want ErrNoPhoneRange", p, err)
+					}
+				case err != nil:
+					t.Errorf("Phone: %v", err)
+				case !want.phone.MatchString(p):
+					t.Errorf("phone %q", p)
+				}
+				if n := g.Name(); n.Given == "" || n.Family == "" {
+					t.Errorf("incomplete name %+v", n)
+				}
+			}
+		})
+	}
+}
+
+func TestNameAndAddressFormat(t *testing.T) {
+	g := newGenerator(t, zidentity.WithLocale("es-ES"))
+	for range 50 {
+		if n := g.Name(); len(strings.Fields(n.Family)) < 2 {
+			t.Errorf("es-ES family name %q is not two surnames", n.Family)
+		}
+	}
+
+	tests := []struct {
+		addr zidentity.Address
+		want string
+	}{
+		{
+			zidentity.Address{Street: "42 Maple Ave", City: "Boise", Region: "ID", PostalCode: "83702", Country: "US"},
+			"42 Maple Ave\nBoise, ID 83702",
+		},
+		{
+			zidentity.Address{Street: "7 High Street", City: "York", Region: "North Yorkshire", PostalCode: "YO1 7HH", Country: "GB"},
+			"7 High Street\nYork\nYO1 7HH",
+		},
+		{
+			zidentity.Address{Street: "Hauptstraße 12", City: "Berlin", Region: "Berlin", PostalCode: "10115", Country: "DE"},
+			"Hauptstraße 12\n10115 Berlin",
+		},
+		{
+			zidentity.Address{Street: "Calle Mayor, 3", City: "Vigo", Region: "Pontevedra", PostalCode: "36201", Country: "ES"},
+			"Calle Mayor, 3\n36201 Vigo\nPontevedra",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.addr.String(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+
+	if got := (zidentity.Name{Given: "Ana", Family: "García López"}).String(); got != "Ana García López" {
+		t.Errorf("name String() = %q", got)
+	}
+}
+
+func TestBirthdate(t *testing.T) {
+	g := newGenerator(t)
+	now := time.Date(2024, 2, 29, 15, 0, 0, 0, time.UTC)
+
+	age := func(born time.Time) int {
+		years := now.Year() - born.Year()
+		if now.Month() < born.Month() || now.Month() == born.Month() && now.Day() < born.Day() {
+			years--
+		}
+		return years
+	}
+
+	seen := map[int]bool{}
+	for range 2000 {
+		born, err := g.Birthdate(now, 18, 21)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a := age(born); a < 18 || a > 21 {
+			t.Fatalf("born %s is %d on %s", born.Format(time.DateOnly), a, now.Format(time.DateOnly))
+		}
+		if born.Hour() != 0 || born.Location() != time.UTC {
+			t.Errorf("birthdate %v is not midnight UTC", born)
+		}
+		seen[age(born)] = true
+	}
+	if len(seen) != 4 {
+		t.Errorf("ages drawn: %v, want 18 to 21", seen)
+	}
+
+	for _, r := range [][2]int{{-1, 10}, {30, 20}, {18, 200}} {
+		if _, err := g.Birthdate(now, r[0], r[1]); err == nil {
+			t.Errorf("Birthdate accepted ages %d-%d", r[0], r[1])
+		}
+	}
+}
+
+func TestUsername(t *testing.T) {
+	g := newGenerator(t)
+	valid := regexp.MustCompile(`^[a-z0-9._]+$`)
+
+	for range 100 {
+		u := g.Username(zidentity.Name{Given: "Jürgen", Family: "Müller"})
+		if !valid.MatchString(u) || !strings.Contains(u, "juergen") && !strings.Contains(u, "mueller") {
+			t.Errorf("username %q for Jürgen Müller", u)
+		}
+		u = g.Username(zidentity.Name{Given: "Íñigo", Family: "Núñez Ortega"})
+		if !valid.MatchString(u) || !strings.Contains(u, "inigo") && !strings.Contains(u, "nunez") {
+			t.Errorf("username %q for Íñigo Núñez Ortega", u)
+		}
+		if u := g.Username(zidentity.Name{}); !valid.MatchString(u) || len(u) < 4 {
+			t.Errorf("username %q for no name", u)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	generate := func(seed string, locale string) []zidentity.Identity {
+		g := newGenerator(t, zidentity.WithSeed([]byte(seed)), zidentity.WithLocale(locale))
+		var ids []zidentity.Identity
+		for range 5 {
+			ids = append(ids, g.Identity(now))
+		}
+		return ids
+	}
+
+	a := generate("fixture", "fr-FR")
+	if b := generate("fixture", "fr-FR"); !slices.Equal(a, b) {
+		t.Errorf("same seed gave different identities:\n%+v\n%+v", a, b)
+	}
+	if c := generate("other", "fr-FR"); slices.Equal(a, c) {
+		t.Error("different seeds gave the same identities")
+	}
+
+	for _, id := range a {
+		if id.Name.Given == "" || id.Username == "" || id.Phone == "" || id.Address.City == "" {
+			t.Errorf("incomplete identity %+v", id)
+		}
+		if years := now.Sub(id.Birthdate).Hours() / 24 / 365.25; years < 18 || years > 66 {
+			t.Errorf("identity born %s", id.Birthdate.Format(time.DateOnly))
+		}
+	}
+
+	// unseeded generators do not repeat each other
+	x := newGenerator(t).Identity(now)
+	y := newGenerator(t).Identity(now)
+	if x == y {
+		t.Error("two unseeded generators produced the same identity")
+	}
+}
+
+func TestUnknownLocale(t *testing.T) {
+	if _, err := zidentity.New(zidentity.WithLocale("xx-XX")); err == nil {
+		t.Error("accepted an unknown locale")
+	}
+}
@@ -0,0 +1,129 @@
+package zidentity
+
+import (
+	"embed"
+	"fmt"
+	mrand "math/rand/v2"
+	"sync"
+)
+
+//go:embed data/*.txt
+var identityFiles embed.FS
+
+// identityLocale holds what differs between locales beyond the data file:
+// how addresses are written and how phone numbers are numbered. phone is
+// nil where the country reserves no numbers for fiction, since any other
+// number may belong to someone.
+type identityLocale struct {
+	name        string
+	country     string
+	twoSurnames bool
+
+	houseNumber func(r *mrand.Rand) string
+	street      func(number, street string) string
+	postcode    func(r *mrand.Rand, c identityCity) string
+	phone       func(r *mrand.Rand, c identityCity) string
+
+	data func() (*identityDataset, error)
+}
+
+var identityLocales = map[string]*identityLocale{
+	"en-US": {
+		name:        "en-US",
+		data:        identityData("en-US"),
+		country:     "US",
+		houseNumber: houseNumber(1, 9999),
+		street:      numberFirst,
+		postcode:    exactPostcode,
+		// 555-0100 to 555-0199 is reserved for fiction in every area code
+		phone: func(r *mrand.Rand, c identityCity) string {
+			return fmt.Sprintf("(%s) 555-01%02d", c.area, r.IntN(100))
+		},
+	},
+	"en-GB": {
+		name:        "en-GB",
+		data:        identityData("en-GB"),
+		country:     "GB",
+		houseNumber: houseNumber(1, 200),
+		street:      numberFirst,
+		// the inward code is a digit and two letters, never C, I, K, M,
+		// O or V
+		postcode: func(r *mrand.Rand, c identityCity) string {
+			const letters = "ABDEFGHJLNPQRSTUWXYZ"
+			return fmt.Sprintf("%s %d%c%c", c.code, r.IntN(10),
+				letters[r.IntN(len(letters))], letters[r.IntN(len(letters))])
+		},
+		// Ofcom reserves 07700 900000 to 900999 for drama
+		phone: func(r *mrand.Rand, _ identityCity) string {
+			return fmt.Sprintf("07700 900%03d", r.IntN(1000))
+		},
+	},
+	"de-DE": {
+		name:        "de-DE",
+		data:        identityData("de-DE"),
+		country:     "DE",
+		houseNumber: houseNumber(1, 120),
+		street:      numberLast,
+		postcode:    exactPostcode,
+		// the Bundesnetzagentur reserves these thousand-number blocks in
+		// Berlin, Frankfurt, Hamburg, Cologne and Munich for film and TV
+		phone: func(r *mrand.Rand, _ identityCity) string {
+			blocks := [...]string{"030 23125", "069 90009", "040 66969", "0221 4710", "089 99998"}
+			return fmt.Sprintf("%s%03d", blocks[r.IntN(len(blocks))], r.IntN(1000))
+		},
+	},
+	"fr-FR": {
+		name:        "fr-FR",
+		data:        identityData("fr-FR"),
+		country:     "FR",
+		houseNumber: houseNumber(1, 150),
+		street:      numberFirst,
+		postcode:    exactPostcode,
+		// ARCEP reserves 06 39 98 00 00 to 06 39 98 99 99 for fiction
+		phone: func(r *mrand.Rand, _ identityCity) string {
+			return fmt.Sprintf("06 39 98 %02d %02d", r.IntN(100), r.IntN(100))
+		},
+	},
+	"es-ES": {
+		name:        "es-ES",
+		data:        identityData("es-ES"),
+		country:     "ES",
+		twoSurnames: true,
+		houseNumber: houseNumber(1, 120),
+		street: func(number, street string) string {
+			return street + ", " + number
+		},
+		postcode: exactPostcode,
+	},
+}
+
+// identityData returns a loader that parses a locale's data file once.
+func identityData(locale string) func() (*identityDataset, error) {
+	return sync.OnceValues(func() (*identityDataset, error) {
+		text, err := identityFiles.ReadFile("data/" + locale + ".txt")
+		if err != nil {
+			return nil, err
+		}
+		d, err := parseIdentityData(string(text))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", locale, err)
+		}
+		return d, nil
+	})
+}
+
+// houseNumber returns a generator of house numbers in [lo, hi], skewed
+// towards small numbers as real streets are.
+func houseNumber(lo, hi int) func(r *mrand.Rand) string {
+	return func(r *mrand.Rand) string {
+		// the minimum of two draws makes low numbers more likely
+		n := min(r.IntN(hi-lo+1), r.IntN(hi-lo+1))
+		return fmt.Sprint(lo + n)
+	}
+}
+
+func numberFirst(number, street string) string { return number + " " + street }
+
+func numberLast(number, street string) string { return street + " " + number }
+
+func exactPostcode(_ *mrand.Rand, c identityCity) string { return c.code }
@@ -0,0 +1,345 @@
+package zimport
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Bitwarden KDF types and the limits its clients enforce, so a hostile
+// export cannot make us spend unbounded time or memory
+const (
+	bitwardenPBKDF2   = 0
+	bitwardenArgon2id = 1
+
+	bitwardenMaxIterations       = 2_000_000
+	bitwardenMaxArgon2Iterations = 10
+	bitwardenMaxMemoryMiB        = 1024
+	bitwardenMaxParallelism      = 16
+)
+
+// errBitwardenMAC marks an EncString whose MAC does not verify.
+var errBitwardenMAC = errors.New("bitwarden: MAC mismatch")
+
+type bitwardenExport struct {
+	Encrypted         bool   `json:"encrypted"`
+	PasswordProtected bool   `json:"passwordProtected"`
+	Salt              string `json:"salt"`
+	KDFType           int    `json:"kdfType"`
+	KDFIterations     int    `json:"kdfIterations"`
+	KDFMemory         int    `json:"kdfMemory"`
+	KDFParallelism    int    `json:"kdfParallelism"`
+	EncKeyValidation  string `json:"encKeyValidation_DO_NOT_EDIT"`
+	Data              string `json:"data"`
+
+	Folders     []bitwardenFolder `json:"folders"`
+	Collections []bitwardenFolder `json:"collections"`
+	Items       []bitwardenItem   `json:"items"`
+}
+
+type bitwardenFolder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type bitwardenItem struct {
+	Type          int       `json:"type"`
+	Name          string    `json:"name"`
+	Notes         string    `json:"notes"`
+	Favorite      bool      `json:"favorite"`
+	FolderID      string    `json:"folderId"`
+	CollectionIDs []string  `json:"collectionIds"`
+	CreationDate  time.Time `json:"creationDate"`
+	RevisionDate  time.Time `json:"revisionDate"`
+
+	Fields []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		Type  int    `json:"type"`
+	} `json:"fields"`
+
+	Login *struct {
+		URIs []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTP     string `json:"totp"`
+	} `json:"login"`
+
+	Card *struct {
+		CardholderName string `json:"cardholderName"`
+		Brand          string `json:"brand"`
+		Number         string `json:"number"`
+		ExpMonth       string `json:"expMonth"`
+		ExpYear        string `json:"expYear"`
+		Code           string `json:"code"`
+	} `json:"card"`
+
+	Identity *struct {
+		Title          string `json:"title"`
+		FirstName      string `json:"firstName"`
+		MiddleName     string `json:"middleName"`
+		LastName       string `json:"lastName"`
+		Address1       string `json:"address1"`
+		Address2       string `json:"address2"`
+		Address3       string `json:"address3"`
+		City           string `json:"city"`
+		State          string `json:"state"`
+		PostalCode     string `json:"postalCode"`
+		Country        string `json:"country"`
+		Company        string `json:"company"`
+		Email          string `json:"email"`
+		Phone          string `json:"phone"`
+		SSN            string `json:"ssn"`
+		Username       string `json:"username"`
+		PassportNumber string `json:"passportNumber"`
+		LicenseNumber  string `json:"licenseNumber"`
+	} `json:"identity"`
+
+	SSHKey *struct {
+		PrivateKey     string `json:"privateKey"`
+		PublicKey      string `json:"publicKey"`
+		KeyFingerprint string `json:"keyFingerprint"`
+	} `json:"sshKey"`
+}
+
+// Bitwarden reads a Bitwarden JSON export. Password-protected
+// exports are decrypted with password; unencrypted ones ignore it.
+// Exports encrypted with the account key cannot be read outside
+// Bitwarden and return an error asking for a password-protected export.
+func Bitwarden(data, password []byte) ([]Record, error) {
+	var exp bitwardenExport
+	if err := json.Unmarshal(data, &exp); err != nil {
+		return nil, fmt.Errorf("parse bitwarden export: %w", err)
+	}
+
+	if exp.Encrypted {
+		if !exp.PasswordProtected {
+			return nil, errors.New("bitwarden export is encrypted with the account key; export it again with a password")
+		}
+		if len(password) == 0 {
+			return nil, ErrPasswordRequired
+		}
+
+		plain, err := decryptBitwardenExport(&exp, password)
+		if err != nil {
+			return nil, err
+		}
+		defer zcrypto.Erase(plain)
+
+		exp = bitwardenExport{}
+		if err := json.Unmarshal(plain, &exp); err != nil {
+			return nil, fmt.Errorf("parse bitwarden export: %w", err)
+		}
+		if exp.Encrypted {
+			return nil, errors.New("bitwarden export is encrypted twice")
+		}
+	}
+
+	folders := make(map[string]string, len(exp.Folders)+len(exp.Collections))
+	for _, f := range exp.Folders {
+		folders[f.ID] = f.Name
+	}
+	for _, c := range exp.Collections {
+		folders[c.ID] = c.Name
+	}
+
+	records := make([]Record, 0, len(exp.Items))
+	for _, item := range exp.Items {
+		records = append(records, bitwardenRecord(&item, folders))
+	}
+	return records, nil
+}
+
+// decryptBitwardenExport derives the key for a password-protected export,
+// checks it against the validation string and returns the inner export.
+func decryptBitwardenExport(exp *bitwardenExport, password []byte) ([]byte, error) {
+	var key []byte
+	switch exp.KDFType {
+	case bitwardenPBKDF2:
+		if exp.KDFIterations < 1 || exp.KDFIterations > bitwardenMaxIterations {
+			return nil, fmt.Errorf("bitwarden kdf iterations %d out of range", exp.KDFIterations)
+		}
+		var err error
+		key, err = pbkdf2.Key(sha256.New, string(password), []byte(exp.Salt), exp.KDFIterations, zcrypto.KeySize)
+		if err != nil {
+			return nil, fmt.Errorf("derive key: %w", err)
+		}
+	case bitwardenArgon2id:
+		if exp.KDFIterations < 1 || exp.KDFIterations > bitwardenMaxArgon2Iterations ||
+			exp.KDFMemory < 1 || exp.KDFMemory > bitwardenMaxMemoryMiB ||
+			exp.KDFParallelism < 1 || exp.KDFParallelism > bitwardenMaxParallelism {
+			return nil, errors.New("bitwarden argon2 parameters out of range")
+		}
+		salt := sha256.Sum256([]byte(exp.Salt))
+		var err error
+		key, err = zcrypto.Argon2Key(zcrypto.Argon2id, password, salt[:], zcrypto.Argon2Params{
+			Time:    uint32(exp.KDFIterations),
+			Memory:  uint32(exp.KDFMemory) * 1024,
+			Threads: uint8(exp.KDFParallelism),
+		}, zcrypto.KeySize)
+		if err != nil {
+			return nil, fmt.Errorf("derive key: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported bitwarden kdf type %d", exp.KDFType)
+	}
+	defer zcrypto.Erase(key)
+
+	// Bitwarden stretches the key into separate encryption and MAC keys
+	encKey := make([]byte, zcrypto.KeySize)
+	macKey := make([]byte, zcrypto.KeySize)
+	defer zcrypto.Erase(encKey)
+	defer zcrypto.Erase(macKey)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, key, []byte("enc")), encKey); err != nil {
+		return nil, fmt.Errorf("expand key: %w", err)
+	}
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, key, []byte("mac")), macKey); err != nil {
+		return nil, fmt.Errorf("expand key: %w", err)
+	}
+
+	if _, err := decryptEncString(exp.EncKeyValidation, encKey, macKey); err != nil {
+		if errors.Is(err, errBitwardenMAC) {
+			return nil, ErrWrongPassword
+		}
+		return nil, fmt.Errorf("check password: %w", err)
+	}
+
+	plain, err := decryptEncString(exp.Data, encKey, macKey)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt bitwarden export: %w", err)
+	}
+	return plain, nil
+}
+
+// decryptEncString decrypts a Bitwarden EncString of type 2,
+// "2.iv|ciphertext|mac" in base64: AES-256-CBC with HMAC-SHA256 over the
+// IV and ciphertext.
+func decryptEncString(s string, encKey, macKey []byte) ([]byte, error) {
+	body, ok := strings.CutPrefix(s, "2.")
+	if !ok {
+		return nil, errors.New("unsupported bitwarden encryption type")
+	}
+	parts := strings.Split(body, "|")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed bitwarden encrypted string")
+	}
+
+	var raw [3][]byte
+	for i, p := range parts {
+		b, err := base64.StdEncoding.DecodeString(p)
+		if err != nil {
+			return nil, errors.New("malformed bitwarden encrypted string")
+		}
+		raw[i] = b
+	}
+	iv, ct, mac := raw[0], raw[1], raw[2]
+
+	m := hmac.New(sha256.New, macKey)
+	m.Write(iv)
+	m.Write(ct)
+	if !hmac.Equal(m.Sum(nil), mac) {
+		return nil, errBitwardenMAC
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	return cbcDecrypt(block, iv, ct)
+}
+
+// bitwardenRecord converts a Bitwarden item: 1 login, 2 secure note,
+// 3 card, 4 identity, 5 SSH key.
+func bitwardenRecord(item *bitwardenItem, folders map[string]string) Record {
+	rec := Record{
+		Kind:     Note,
+		Title:    item.Name,
+		Notes:    item.Notes,
+		Folder:   folders[item.FolderID],
+		Favorite: item.Favorite,
+		Created:  item.CreationDate,
+		Modified: item.RevisionDate,
+	}
+	if rec.Folder == "" {
+		for _, id := range item.CollectionIDs {
+			if name := folders[id]; name != "" {
+				rec.Folder = name
+				break
+			}
+		}
+	}
+
+	switch {
+	case item.Type == 1 && item.Login != nil:
+		rec.Kind = Login
+		rec.Username = item.Login.Username
+		rec.Password = item.Login.Password
+		rec.TOTP = item.Login.TOTP
+		for _, u := range item.Login.URIs {
+			rec.addURL(u.URI)
+		}
+	case item.Type == 3 && item.Card != nil:
+		c := item.Card
+		rec.Kind = Card
+		rec.addField("Cardholder name", c.CardholderName, false)
+		rec.addField("Brand", c.Brand, false)
+		rec.addField("Number", c.Number, true)
+		if c.ExpMonth != "" || c.ExpYear != "" {
+			rec.addField("Expires", strings.Trim(c.ExpMonth+"/"+c.ExpYear, "/"), false)
+		}
+		rec.addField("Security code", c.Code, true)
+	case item.Type == 4 && item.Identity != nil:
+		id := item.Identity
+		rec.Kind = Identity
+		rec.Username = id.Username
+		name := strings.Join(strings.Fields(strings.Join([]string{id.Title, id.FirstName, id.MiddleName, id.LastName}, " ")), " ")
+		rec.addField("Name", name, false)
+		rec.addField("Company", id.Company, false)
+		rec.addField("Email", id.Email, false)
+		rec.addField("Phone", id.Phone, false)
+		address := []string{id.Address1, id.Address2, id.Address3, strings.TrimSpace(id.City + " " + id.State + " " + id.PostalCode), id.Country}
+		var lines []string
+		for _, l := range address {
+			if l = strings.TrimSpace(l); l != "" {
+				lines = append(lines, l)
+			}
+		}
+		rec.addField("Address", strings.Join(lines, "\n"), false)
+		rec.addField("Social security number", id.SSN, true)
+		rec.addField("Passport number", id.PassportNumber, true)
+		rec.addField("License number", id.LicenseNumber, true)
+	case item.Type == 5 && item.SSHKey != nil:
+		rec.Kind = SSHKey
+		rec.addField("Private key", item.SSHKey.PrivateKey, true)
+		rec.addField("Public key", item.SSHKey.PublicKey, false)
+		rec.addField("Fingerprint", item.SSHKey.KeyFingerprint, false)
+	}
+
+	for _, f := range item.Fields {
+		// type 3 links to another field and has no value of its own
+		switch f.Type {
+		case 0, 2:
+			rec.addField(f.Name, f.Value, false)
+		case 1:
+			rec.addField(f.Name, f.Value, true)
+		}
+	}
+
+	if rec.Title == "" {
+		rec.Title = defaultTitle(&rec)
+	}
+	return rec
+}
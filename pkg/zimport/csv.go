@@ -0,0 +1,187 @@
+package zimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumns maps lowercased header names used by common exports (Chrome,
+// Firefox, Safari, Bitwarden, LastPass, KeePassXC, Dashlane) to
+// Record fields.
+var csvColumns = map[string]string{
+	"title": "title", "name": "title", "account": "title",
+
+	"username": "username", "user name": "username", "user": "username",
+	"login": "username", "login_username": "username", "login name": "username",
+
+	"password": "password", "login_password": "password", "pass": "password",
+
+	"url": "url", "uri": "url", "website": "url", "web site": "url",
+	"login_uri": "url", "login url": "url",
+
+	"notes": "notes", "note": "notes", "extra": "notes", "comments": "notes",
+	"comment": "notes",
+
+	"totp": "totp", "otp": "totp", "login_totp": "totp", "otpauth": "totp",
+	"one-time password": "totp", "otpurl": "totp",
+
+	"folder": "folder", "group": "folder", "grouping": "folder",
+
+	"tags": "tags",
+
+	"favorite": "favorite", "fav": "favorite",
+
+	"created": "created", "timecreated": "created", "creation time": "created",
+
+	"last modified": "modified", "modified": "modified",
+	"timepasswordchanged": "modified", "last modification time": "modified",
+
+	"type": "type",
+
+	// bookkeeping that means nothing outside the exporting app
+	"guid": "-", "uuid": "-", "httprealm": "-", "formactionorigin": "-",
+	"timelastused": "-", "reprompt": "-", "icon": "-",
+}
+
+// CSV reads a CSV export with a header row, recognising the column
+// names most password managers and browsers use. Columns it does not
+// recognise are kept as fields. CSV exports are never encrypted, so delete
+// the file once imported, with Shred.
+func CSV(r io.Reader) ([]Record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("csv export is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+
+	columns := make([]string, len(header))
+	found := map[string]bool{}
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		header[i] = name
+		if col, ok := csvColumns[strings.ToLower(name)]; ok && (col == "-" || !found[col]) {
+			columns[i] = col
+			found[col] = true
+		}
+	}
+	if !found["username"] && !found["password"] {
+		return nil, errors.New("csv header has no username or password column")
+	}
+
+	var records []Record
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read csv: %w", err)
+		}
+
+		var (
+			rec  Record
+			note bool
+		)
+		for i, v := range row {
+			if i >= len(header) {
+				break
+			}
+			switch columns[i] {
+			case "title":
+				rec.Title = v
+			case "username":
+				rec.Username = v
+			case "password":
+				rec.Password = v
+			case "url":
+				rec.addURL(v)
+			case "notes":
+				rec.Notes = v
+			case "totp":
+				rec.TOTP = v
+			case "folder":
+				rec.Folder = strings.Trim(strings.ReplaceAll(v, "\\", "/"), "/")
+			case "tags":
+				rec.Tags = splitTags(v)
+			case "favorite":
+				rec.Favorite = v == "1" || strings.EqualFold(v, "true") || strings.EqualFold(v, "yes")
+			case "created":
+				rec.Created = parseCSVTime(v)
+			case "modified":
+				rec.Modified = parseCSVTime(v)
+			case "type":
+				note = strings.Contains(strings.ToLower(v), "note")
+			case "-":
+			default:
+				rec.addField(header[i], v, false)
+			}
+		}
+
+		rec.Kind = Login
+		if note || rec.Username == "" && rec.Password == "" && len(rec.URLs) == 0 {
+			if rec.Notes == "" && len(rec.Fields) == 0 {
+				continue
+			}
+			rec.Kind = Note
+		}
+		if rec.Title == "" {
+			rec.Title = defaultTitle(&rec)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// parseCSVTime parses RFC 3339 times and Unix times in seconds or, as
+// Firefox writes them, milliseconds. It returns the zero time otherwise.
+func parseCSVTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if n > 1e11 {
+			return time.UnixMilli(n).UTC()
+		}
+		return time.Unix(n, 0).UTC()
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	if t, err := time.Parse("2006-01-02T15:04:05", s); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// splitTags splits a tag list on commas or semicolons.
+func splitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' }) {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// defaultTitle names an untitled record after its first URL's host or its
+// username.
+func defaultTitle(rec *Record) string {
+	if len(rec.URLs) > 0 {
+		u := rec.URLs[0]
+		if _, rest, ok := strings.Cut(u, "://"); ok {
+			u = rest
+		}
+		u, _, _ = strings.Cut(u, "/")
+		return u
+	}
+	return rec.Username
+}
@@ -0,0 +1,102 @@
+package zimport_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zimport"
+)
+
+func TestCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want []zimport.Record
+	}{
+		{
+			name: "chrome",
+			csv: "\ufeffname,url,username,password,note\n" +
+				"github.com,https://github.com/login,alice,hunter2,\n" +
+				",https://example.com/a,bob,pw,old account\n",
+			want: []zimport.Record{
+				{Kind: zimport.Login, Title: "github.com", Username: "alice", Password: "hunter2", URLs: []string{"https://github.com/login"}},
+				{Kind: zimport.Login, Title: "example.com", Username: "bob", Password: "pw", URLs: []string{"https://example.com/a"}, Notes: "old account"},
+			},
+		},
+		{
+			name: "firefox",
+			csv: `"url","username","password","httpRealm","formActionOrigin","guid","timeCreated","timeLastUsed","timePasswordChanged"` + "\n" +
+				`"https://mail.example.com","carol","s3cret",,"https://mail.example.com","{abc}","1700000000000","1700000000000","1700000001000"` + "\n",
+			want: []zimport.Record{{
+				Kind:     zimport.Login,
+				Title:    "mail.example.com",
+				Username: "carol",
+				Password: "s3cret",
+				URLs:     []string{"https://mail.example.com"},
+				Created:  time.UnixMilli(1700000000000).UTC(),
+				Modified: time.UnixMilli(1700000001000).UTC(),
+			}},
+		},
+		{
+			name: "bitwarden",
+			csv: "folder,favorite,type,name,notes,fields,reprompt,login_uri,login_username,login_password,login_totp\n" +
+				"Work,1,login,VPN,,,0,https://vpn.example.com,dave,pw,otpauth://totp/vpn?secret=JBSWY3DPEHPK3PXP\n" +
+				"Work\\Keys,,note,Door code,1234,,0,,,,\n" +
+				",,note,Empty,,,0,,,,\n",
+			want: []zimport.Record{
+				{
+					Kind:     zimport.Login,
+					Title:    "VPN",
+					Username: "dave",
+					Password: "pw",
+					URLs:     []string{"https://vpn.example.com"},
+					TOTP:     "otpauth://totp/vpn?secret=JBSWY3DPEHPK3PXP",
+					Folder:   "Work",
+					Favorite: true,
+				},
+				{Kind: zimport.Note, Title: "Door code", Notes: "1234", Folder: "Work/Keys"},
+			},
+		},
+		{
+			name: "unknown columns and tags",
+			csv: "Title,User Name,Password,Security Question,Tags\n" +
+				"Bank,erin,pw,first pet,finance; important\n",
+			want: []zimport.Record{{
+				Kind:     zimport.Login,
+				Title:    "Bank",
+				Username: "erin",
+				Password: "pw",
+				Tags:     []string{"finance", "important"},
+				Fields:   []zimport.Field{{Name: "Security Question", Value: "first pet"}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := zimport.CSV(strings.NewReader(tt.csv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVInvalid(t *testing.T) {
+	for name, csv := range map[string]string{
+		"empty":     "",
+		"no fields": "name,url\nexample,https://example.com\n",
+		"bad quote": "username,password\n\"alice,pw\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := zimport.CSV(strings.NewReader(csv)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+// Package zimport reads exports from other password managers for zarlcorp
+// privacy tools.
+//
+// Bitwarden, KeePass, OnePassword and CSV parse exports into Record values,
+// whatever format they came from. Password-protected Bitwarden exports and
+// KeePass KDBX 4 databases are decrypted on the way with zcrypto's
+// primitives, and the key derivation costs they ask for are held to the
+// bounds zcrypto applies to password hashes. Both return
+// ErrPasswordRequired when no password is given and ErrWrongPassword when
+// it does not open the export. Unencrypted exports should be shredded with
+// zcrypto.Shred once imported.
+//
+//	records, err := zimport.KeePass(db, password, nil)
+//	if errors.Is(err, zimport.ErrWrongPassword) {
+//	    // ask again
+//	}
+package zimport
@@ -0,0 +1,656 @@
+package zimport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20"
+	"golang.org/x/crypto/twofish"
+)
+
+// KDBX file signatures and the outer and inner header fields we read
+const (
+	kdbxSignature1 = 0x9AA2D903
+	kdbxSignature2 = 0xB54BFB67
+
+	kdbxHeaderEnd         = 0
+	kdbxHeaderCipher      = 2
+	kdbxHeaderCompression = 3
+	kdbxHeaderMasterSeed  = 4
+	kdbxHeaderIV          = 7
+	kdbxHeaderKDF         = 11
+
+	kdbxInnerEnd       = 0
+	kdbxInnerStreamID  = 1
+	kdbxInnerStreamKey = 2
+
+	kdbxStreamSalsa20  = 2
+	kdbxStreamChaCha20 = 3
+)
+
+// limits on what a database may ask of us; Argon2 costs are bounded by
+// zcrypto.Argon2Key as for password hashes, and AES-KDF rounds to a few
+// seconds' work
+const (
+	kdbxMaxAESRounds = 100_000_000
+	kdbxMaxXML       = 512 << 20
+
+	// kdbxArgon2Version is the only Argon2 version KeePass writes
+	kdbxArgon2Version = 0x13
+)
+
+// KDF and cipher UUIDs, as they appear in the header
+var (
+	kdbxAESKDF   = kdbxUUID("c9d9f39a628a4460bf740d08c18a4fea")
+	kdbxArgon2d  = kdbxUUID("ef636ddf8c29444b91f7a9a403e30a0c")
+	kdbxArgon2id = kdbxUUID("9e298b1956db4773b23dfc3ec6f0a1e6")
+
+	kdbxAES256   = kdbxUUID("31c1f2e6bf714350be5805216afc5aff")
+	kdbxChaCha20 = kdbxUUID("d6038a2b8b6f4cb5a524339a31dbb59a")
+	kdbxTwofish  = kdbxUUID("ad68f29f576f4bb9a36ad47af965346c")
+)
+
+// Salsa20 nonce for protected values, fixed by KeePass
+var kdbxSalsa20Nonce = []byte{0xE8, 0x30, 0x09, 0x4B, 0x97, 0x20, 0x5D, 0x2A}
+
+// kdbxUUID decodes a UUID literal from the table above.
+func kdbxUUID(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+// KeePass reads a KeePass KDBX 4 database, as written by KeePass 2.35
+// and later and by KeePassXC. It opens with password, keyFile or both, as
+// the database was set up; pass nil for whichever is not used. KDBX 3.1
+// databases are not supported; save them once in KeePass or KeePassXC to
+// upgrade them. Entry history and the recycle bin are skipped.
+func KeePass(data, password, keyFile []byte) ([]Record, error) {
+	c := &kdbxCursor{b: data}
+	if c.u32() != kdbxSignature1 || c.u32() != kdbxSignature2 {
+		return nil, errors.New("not a keepass database")
+	}
+	if major := c.u32() >> 16; major != 4 {
+		return nil, fmt.Errorf("unsupported kdbx version %d; save the database in kdbx 4 format", major)
+	}
+
+	header := map[byte][]byte{}
+	for {
+		id := c.u8()
+		value := c.next(int(c.u32()))
+		if c.bad {
+			return nil, errors.New("malformed kdbx header")
+		}
+		if id == kdbxHeaderEnd {
+			break
+		}
+		header[id] = value
+	}
+	headerData := data[:len(data)-len(c.b)]
+	headerHash := c.next(sha256.Size)
+	headerMAC := c.next(sha256.Size)
+	if c.bad {
+		return nil, errors.New("malformed kdbx header")
+	}
+	if sum := sha256.Sum256(headerData); !hmac.Equal(sum[:], headerHash) {
+		return nil, errors.New("kdbx header is corrupt")
+	}
+
+	if len(password) == 0 && len(keyFile) == 0 {
+		return nil, ErrPasswordRequired
+	}
+
+	seed := header[kdbxHeaderMasterSeed]
+	if len(seed) != 32 {
+		return nil, errors.New("malformed kdbx master seed")
+	}
+
+	composite := kdbxCompositeKey(password, keyFile)
+	defer zcrypto.Erase(composite)
+	transformed, err := kdbxTransformKey(header[kdbxHeaderKDF], composite)
+	if err != nil {
+		return nil, err
+	}
+	defer zcrypto.Erase(transformed)
+
+	encKey := sha256.Sum256(slices.Concat(seed, transformed))
+	macBase := sha512.Sum512(slices.Concat(seed, transformed, []byte{1}))
+	defer zcrypto.Erase(encKey[:])
+	defer zcrypto.Erase(macBase[:])
+
+	if !hmac.Equal(kdbxBlockMAC(macBase[:], math.MaxUint64, headerData), headerMAC) {
+		return nil, ErrWrongPassword
+	}
+
+	ct, err := kdbxReadBlocks(c.b, macBase[:])
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := kdbxDecrypt(header[kdbxHeaderCipher], encKey[:], header[kdbxHeaderIV], ct)
+	if err != nil {
+		return nil, err
+	}
+	defer zcrypto.Erase(payload)
+
+	if compression := header[kdbxHeaderCompression]; len(compression) == 4 && binary.LittleEndian.Uint32(compression) == 1 {
+		zr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("decompress kdbx payload: %w", err)
+		}
+		inflated, err := io.ReadAll(io.LimitReader(zr, kdbxMaxXML+1))
+		if err != nil {
+			return nil, fmt.Errorf("decompress kdbx payload: %w", err)
+		}
+		zcrypto.Erase(payload)
+		payload = inflated
+		if len(payload) > kdbxMaxXML {
+			return nil, errors.New("kdbx payload too large")
+		}
+	}
+
+	// the inner header carries the key for protected values
+	ic := &kdbxCursor{b: payload}
+	var (
+		streamID  uint32
+		streamKey []byte
+	)
+	for {
+		id := ic.u8()
+		value := ic.next(int(ic.u32()))
+		if ic.bad {
+			return nil, errors.New("malformed kdbx inner header")
+		}
+		if id == kdbxInnerEnd {
+			break
+		}
+		switch id {
+		case kdbxInnerStreamID:
+			if len(value) == 4 {
+				streamID = binary.LittleEndian.Uint32(value)
+			}
+		case kdbxInnerStreamKey:
+			streamKey = value
+		}
+	}
+	doc := ic.b
+
+	stream, err := kdbxInnerStream(streamID, streamKey, len(doc))
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := kdbxParseXML(doc, stream)
+	if err != nil {
+		return nil, err
+	}
+	return kdbxRecords(root), nil
+}
+
+// kdbxCompositeKey combines the password and key file as KeePass does:
+// SHA-256 over the hash of each component that is present.
+func kdbxCompositeKey(password, keyFile []byte) []byte {
+	h := sha256.New()
+	if len(password) > 0 {
+		p := sha256.Sum256(password)
+		h.Write(p[:])
+		zcrypto.Erase(p[:])
+	}
+	if len(keyFile) > 0 {
+		k := kdbxKeyFileKey(keyFile)
+		h.Write(k)
+		zcrypto.Erase(k)
+	}
+	return h.Sum(nil)
+}
+
+// kdbxKeyFileKey returns the 32-byte key a key file stands for: the key in
+// an XML key file (versions 1 and 2), a raw 32-byte or 64-hex-digit key,
+// or else the SHA-256 of the whole file.
+func kdbxKeyFileKey(file []byte) []byte {
+	var kf struct {
+		Meta struct {
+			Version string `xml:"Version"`
+		} `xml:"Meta"`
+		Key struct {
+			Data string `xml:"Data"`
+		} `xml:"Key"`
+	}
+	if bytes.Contains(file, []byte("<KeyFile")) && xml.Unmarshal(file, &kf) == nil {
+		data := strings.Join(strings.Fields(kf.Key.Data), "")
+		var key []byte
+		if strings.HasPrefix(kf.Meta.Version, "2.") {
+			key, _ = hex.DecodeString(data)
+		} else {
+			key, _ = base64.StdEncoding.DecodeString(data)
+		}
+		if len(key) == 32 {
+			return key
+		}
+	}
+
+	if len(file) == 32 {
+		return bytes.Clone(file)
+	}
+	if len(file) == 64 {
+		if key, err := hex.DecodeString(string(file)); err == nil {
+			return key
+		}
+	}
+	sum := sha256.Sum256(file)
+	return sum[:]
+}
+
+// kdbxTransformKey runs the database's KDF over the composite key.
+func kdbxTransformKey(params, composite []byte) ([]byte, error) {
+	p, err := parseVariantDict(params)
+	if err != nil {
+		return nil, err
+	}
+	salt := p["S"]
+
+	switch uuid := p["$UUID"]; {
+	case bytes.Equal(uuid, kdbxAESKDF):
+		rounds, ok := vdUint(p, "R")
+		if !ok || len(salt) != 32 {
+			return nil, errors.New("malformed kdbx aes-kdf parameters")
+		}
+		if rounds > kdbxMaxAESRounds {
+			return nil, fmt.Errorf("kdbx aes-kdf rounds %d out of range", rounds)
+		}
+		block, err := aes.NewCipher(salt)
+		if err != nil {
+			return nil, fmt.Errorf("create cipher: %w", err)
+		}
+		k := bytes.Clone(composite)
+		defer zcrypto.Erase(k)
+		for range rounds {
+			block.Encrypt(k[:16], k[:16])
+			block.Encrypt(k[16:], k[16:])
+		}
+		sum := sha256.Sum256(k)
+		return sum[:], nil
+
+	case bytes.Equal(uuid, kdbxArgon2d), bytes.Equal(uuid, kdbxArgon2id):
+		parallelism, okP := vdUint(p, "P")
+		memory, okM := vdUint(p, "M")
+		iterations, okI := vdUint(p, "I")
+		version, okV := vdUint(p, "V")
+		if !okP || !okM || !okI || !okV || len(salt) == 0 {
+			return nil, errors.New("malformed kdbx argon2 parameters")
+		}
+		if version != kdbxArgon2Version {
+			return nil, fmt.Errorf("unsupported argon2 version %#x", version)
+		}
+		if parallelism > math.MaxUint8 {
+			return nil, errors.New("kdbx argon2 parameters out of range")
+		}
+		variant := zcrypto.Argon2d
+		if bytes.Equal(uuid, kdbxArgon2id) {
+			variant = zcrypto.Argon2id
+		}
+		key, err := zcrypto.Argon2Key(variant, composite, salt, zcrypto.Argon2Params{
+			Time:    uint32(min(iterations, math.MaxUint32)),
+			Memory:  uint32(min(memory/1024, math.MaxUint32)),
+			Threads: uint8(parallelism),
+			Secret:  p["K"],
+			Data:    p["A"],
+		}, zcrypto.KeySize)
+		if err != nil {
+			return nil, fmt.Errorf("kdbx kdf: %w", err)
+		}
+		return key, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported kdbx kdf %x", uuid)
+	}
+}
+
+// parseVariantDict parses a KDBX VariantDictionary into its raw values by
+// name. Callers know each value's type from its name.
+func parseVariantDict(b []byte) (map[string][]byte, error) {
+	c := &kdbxCursor{b: b}
+	if v := c.next(2); c.bad || v[1] != 0x01 {
+		return nil, errors.New("unsupported kdbx variant dictionary version")
+	}
+	m := map[string][]byte{}
+	for {
+		typ := c.u8()
+		if c.bad {
+			return nil, errors.New("malformed kdbx variant dictionary")
+		}
+		if typ == 0 {
+			return m, nil
+		}
+		key := c.next(int(int32(c.u32())))
+		value := c.next(int(int32(c.u32())))
+		if c.bad {
+			return nil, errors.New("malformed kdbx variant dictionary")
+		}
+		m[string(key)] = value
+	}
+}
+
+// vdUint reads a 32- or 64-bit unsigned VariantDictionary value.
+func vdUint(m map[string][]byte, key string) (uint64, bool) {
+	switch v := m[key]; len(v) {
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(v)), true
+	case 8:
+		return binary.LittleEndian.Uint64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// kdbxBlockMAC returns the HMAC-SHA256 KDBX 4 stores for block index, with
+// the per-block key derived from the HMAC base key. The header uses index
+// 2^64-1 and, unlike blocks, MACs only its bytes.
+func kdbxBlockMAC(base []byte, index uint64, data []byte) []byte {
+	var idx [8]byte
+	binary.LittleEndian.PutUint64(idx[:], index)
+	key := sha512.Sum512(slices.Concat(idx[:], base))
+	defer zcrypto.Erase(key[:])
+
+	m := hmac.New(sha256.New, key[:])
+	if index == math.MaxUint64 {
+		m.Write(data)
+		return m.Sum(nil)
+	}
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(len(data)))
+	m.Write(idx[:])
+	m.Write(size[:])
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+// kdbxReadBlocks verifies and joins the HMAC blocks that follow the
+// header: a MAC, a 32-bit length and the data, ending with an empty block.
+func kdbxReadBlocks(b []byte, macBase []byte) ([]byte, error) {
+	c := &kdbxCursor{b: b}
+	var out []byte
+	for index := uint64(0); ; index++ {
+		mac := c.next(sha256.Size)
+		data := c.next(int(int32(c.u32())))
+		if c.bad {
+			return nil, errors.New("kdbx database is truncated")
+		}
+		if !hmac.Equal(kdbxBlockMAC(macBase, index, data), mac) {
+			return nil, fmt.Errorf("kdbx block %d is corrupt", index)
+		}
+		if len(data) == 0 {
+			return out, nil
+		}
+		out = append(out, data...)
+	}
+}
+
+// kdbxDecrypt decrypts the payload with the database's cipher. Twofish is
+// long deprecated but KeePass still offers it.
+func kdbxDecrypt(uuid, key, iv, ct []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(uuid, kdbxAES256):
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("create cipher: %w", err)
+		}
+		return cbcDecrypt(block, iv, ct)
+	case bytes.Equal(uuid, kdbxTwofish):
+		block, err := twofish.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("create cipher: %w", err)
+		}
+		return cbcDecrypt(block, iv, ct)
+	case bytes.Equal(uuid, kdbxChaCha20):
+		s, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, fmt.Errorf("create cipher: %w", err)
+		}
+		plain := make([]byte, len(ct))
+		s.XORKeyStream(plain, ct)
+		return plain, nil
+	default:
+		return nil, fmt.Errorf("unsupported kdbx cipher %x", uuid)
+	}
+}
+
+// kdbxInnerStream returns the keystream that protected values are XORed
+// with. Salsa20 has no streaming API in x/crypto, so its keystream is
+// generated up front; protected values never outgrow the document.
+func kdbxInnerStream(id uint32, key []byte, size int) (cipher.Stream, error) {
+	switch id {
+	case kdbxStreamChaCha20:
+		h := sha512.Sum512(key)
+		defer zcrypto.Erase(h[:])
+		s, err := chacha20.NewUnauthenticatedCipher(h[:32], h[32:44])
+		if err != nil {
+			return nil, fmt.Errorf("create cipher: %w", err)
+		}
+		return s, nil
+	case kdbxStreamSalsa20:
+		k := sha256.Sum256(key)
+		defer zcrypto.Erase(k[:])
+		ks := make([]byte, size)
+		salsa20.XORKeyStream(ks, ks, kdbxSalsa20Nonce, &k)
+		return &kdbxKeystream{ks: ks}, nil
+	default:
+		return nil, fmt.Errorf("unsupported kdbx inner stream %d", id)
+	}
+}
+
+// kdbxKeystream is a cipher.Stream over a precomputed keystream.
+type kdbxKeystream struct {
+	ks []byte
+}
+
+func (s *kdbxKeystream) XORKeyStream(dst, src []byte) {
+	// callers size the keystream for the whole document, so running out
+	// means a malformed file; XORing with zeros leaves it garbled, not unsafe
+	n := min(len(src), len(s.ks))
+	for i := range n {
+		dst[i] = src[i] ^ s.ks[i]
+	}
+	copy(dst[n:], src[n:])
+	s.ks = s.ks[n:]
+}
+
+// kdbxNode is an element of the database XML.
+type kdbxNode struct {
+	name      string
+	protected bool
+	text      string
+	children  []*kdbxNode
+}
+
+// child returns the first child element called name, or an empty node.
+func (n *kdbxNode) child(name string) *kdbxNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return &kdbxNode{}
+}
+
+// kdbxParseXML parses the database XML into a tree, unprotecting values as
+// it goes. Protected values must be XORed in document order, history
+// included, so this happens while parsing rather than while converting.
+func kdbxParseXML(doc []byte, stream cipher.Stream) (*kdbxNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	root := &kdbxNode{}
+	stack := []*kdbxNode{root}
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse kdbx xml: %w", err)
+		}
+
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &kdbxNode{name: t.Name.Local}
+			for _, a := range t.Attr {
+				if a.Name.Local == "Protected" && strings.EqualFold(a.Value, "true") {
+					n.protected = true
+				}
+			}
+			top.children = append(top.children, n)
+			stack = append(stack, n)
+		case xml.CharData:
+			top.text += string(t)
+		case xml.EndElement:
+			if top.protected {
+				raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(top.text))
+				if err != nil {
+					return nil, errors.New("malformed kdbx protected value")
+				}
+				stream.XORKeyStream(raw, raw)
+				top.text = string(raw)
+				zcrypto.Erase(raw)
+			}
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return root, nil
+}
+
+// kdbxRecords converts the entries of a parsed database. The root group's
+// name is left out of folder paths, since every entry is under it.
+func kdbxRecords(doc *kdbxNode) []Record {
+	file := doc.child("KeePassFile")
+	recycleBin := ""
+	if meta := file.child("Meta"); !strings.EqualFold(meta.child("RecycleBinEnabled").text, "false") {
+		recycleBin = meta.child("RecycleBinUUID").text
+	}
+
+	var records []Record
+	var walk func(g *kdbxNode, path string)
+	walk = func(g *kdbxNode, path string) {
+		for _, c := range g.children {
+			switch c.name {
+			case "Entry":
+				records = append(records, kdbxRecord(c, path))
+			case "Group":
+				if recycleBin != "" && c.child("UUID").text == recycleBin {
+					continue
+				}
+				sub := c.child("Name").text
+				if path != "" {
+					sub = path + "/" + sub
+				}
+				walk(c, sub)
+			}
+		}
+	}
+	walk(file.child("Root").child("Group"), "")
+	return records
+}
+
+// kdbxRecord converts an entry.
+func kdbxRecord(e *kdbxNode, folder string) Record {
+	rec := Record{
+		Folder:   folder,
+		Tags:     splitTags(e.child("Tags").text),
+		Created:  kdbxTime(e.child("Times").child("CreationTime").text),
+		Modified: kdbxTime(e.child("Times").child("LastModificationTime").text),
+	}
+
+	for _, s := range e.children {
+		if s.name != "String" {
+			continue
+		}
+		key, value := s.child("Key").text, s.child("Value")
+		switch {
+		case key == "Title":
+			rec.Title = value.text
+		case key == "UserName":
+			rec.Username = value.text
+		case key == "Password":
+			rec.Password = value.text
+		case key == "URL", strings.HasPrefix(key, "KP2A_URL"):
+			rec.addURL(value.text)
+		case key == "Notes":
+			rec.Notes = value.text
+		case (key == "otp" || key == "TimeOtp-Secret-Base32") && rec.TOTP == "":
+			rec.TOTP = value.text
+		default:
+			rec.addField(key, value.text, value.protected)
+		}
+	}
+
+	rec.Kind = Login
+	if rec.Username == "" && rec.Password == "" && len(rec.URLs) == 0 {
+		rec.Kind = Note
+	}
+	if rec.Title == "" {
+		rec.Title = defaultTitle(&rec)
+	}
+	return rec
+}
+
+// kdbxTime parses a KDBX time: base64 of a little-endian count of seconds
+// since 0001-01-01 UTC in KDBX 4, or ISO 8601 in older files.
+func kdbxTime(s string) time.Time {
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == 8 {
+		const unixOffset = 62135596800 // seconds from 0001-01-01 to 1970-01-01
+		return time.Unix(int64(binary.LittleEndian.Uint64(b))-unixOffset, 0).UTC()
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	return time.Time{}
+}
+
+// kdbxCursor reads little-endian values from a byte slice. A read past the
+// end sets bad and returns zero values, so callers check once per record.
+type kdbxCursor struct {
+	b   []byte
+	bad bool
+}
+
+func (c *kdbxCursor) next(n int) []byte {
+	if c.bad || n < 0 || n > len(c.b) {
+		c.bad = true
+		return nil
+	}
+	v := c.b[:n]
+	c.b = c.b[n:]
+	return v
+}
+
+func (c *kdbxCursor) u8() byte {
+	if v := c.next(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (c *kdbxCursor) u32() uint32 {
+	if v := c.next(4); v != nil {
+		return binary.LittleEndian.Uint32(v)
+	}
+	return 0
+}
@@ -0,0 +1,474 @@
+package zimport_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+	"github.com/zarlcorp/core/pkg/zimport"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20"
+)
+
+// kdbxConfig picks the algorithms a test database is written with.
+type kdbxConfig struct {
+	kdf      string // "aes", "argon2d" or "argon2id"
+	secret   []byte // argon2 secret key, K
+	cipher   string // "aes" or "chacha20"
+	stream   uint32 // 2 Salsa20, 3 ChaCha20
+	compress bool
+
+	// cost, if set, replaces the AES-KDF rounds or Argon2 passes; the KDF
+	// is then not run, so the database does not open
+	cost uint64
+}
+
+// kdbxWrite writes a minimal KDBX 4 database. body returns the XML and
+// calls protect on each protected value in document order.
+func kdbxWrite(t *testing.T, cfg kdbxConfig, password, keyFileKey []byte, body func(protect func(string) string) string) []byte {
+	t.Helper()
+	uuid := func(s string) []byte {
+		b, _ := hex.DecodeString(s)
+		return b
+	}
+	le32 := func(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+	le64 := func(v uint64) []byte { return binary.LittleEndian.AppendUint64(nil, v) }
+	random := func(n int) []byte {
+		b, err := zcrypto.RandBytes(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	// composite key
+	h := sha256.New()
+	if len(password) > 0 {
+		p := sha256.Sum256(password)
+		h.Write(p[:])
+	}
+	if len(keyFileKey) > 0 {
+		h.Write(keyFileKey)
+	}
+	composite := h.Sum(nil)
+
+	// KDF parameters as a VariantDictionary
+	vd := []byte{0x00, 0x01}
+	item := func(typ byte, key string, value []byte) {
+		vd = append(vd, typ)
+		vd = append(vd, le32(uint32(len(key)))...)
+		vd = append(vd, key...)
+		vd = append(vd, le32(uint32(len(value)))...)
+		vd = append(vd, value...)
+	}
+	salt := random(32)
+	var transformed []byte
+	switch cfg.kdf {
+	case "aes":
+		item(0x42, "$UUID", uuid("c9d9f39a628a4460bf740d08c18a4fea"))
+		if cfg.cost > 0 {
+			item(0x05, "R", le64(cfg.cost))
+			item(0x42, "S", salt)
+			transformed = make([]byte, 32)
+			break
+		}
+		item(0x05, "R", le64(1000))
+		item(0x42, "S", salt)
+		block, _ := aes.NewCipher(salt)
+		k := bytes.Clone(composite)
+		for range 1000 {
+			block.Encrypt(k[:16], k[:16])
+			block.Encrypt(k[16:], k[16:])
+		}
+		sum := sha256.Sum256(k)
+		transformed = sum[:]
+	case "argon2d", "argon2id":
+		variant := zcrypto.Argon2d
+		if cfg.kdf == "argon2id" {
+			variant = zcrypto.Argon2id
+			item(0x42, "$UUID", uuid("9e298b1956db4773b23dfc3ec6f0a1e6"))
+		} else {
+			item(0x42, "$UUID", uuid("ef636ddf8c29444b91f7a9a403e30a0c"))
+		}
+		item(0x42, "S", salt)
+		item(0x04, "P", le32(2))
+		item(0x05, "M", le64(1<<20))
+		item(0x04, "V", le32(0x13))
+		if cfg.secret != nil {
+			item(0x42, "K", cfg.secret)
+		}
+		if cfg.cost > 0 {
+			item(0x05, "I", le64(cfg.cost))
+			transformed = make([]byte, 32)
+			break
+		}
+		item(0x05, "I", le64(2))
+		var err error
+		transformed, err = zcrypto.Argon2Key(variant, composite, salt, zcrypto.Argon2Params{Time: 2, Memory: 1024, Threads: 2, Secret: cfg.secret}, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	vd = append(vd, 0)
+
+	seed := random(32)
+	var cipherID, iv []byte
+	switch cfg.cipher {
+	case "aes":
+		cipherID, iv = uuid("31c1f2e6bf714350be5805216afc5aff"), random(16)
+	case "chacha20":
+		cipherID, iv = uuid("d6038a2b8b6f4cb5a524339a31dbb59a"), random(12)
+	}
+	compression := uint32(0)
+	if cfg.compress {
+		compression = 1
+	}
+
+	header := le32(0x9AA2D903)
+	header = append(header, le32(0xB54BFB67)...)
+	header = append(header, le32(0x00040001)...)
+	field := func(id byte, value []byte) {
+		header = append(header, id)
+		header = append(header, le32(uint32(len(value)))...)
+		header = append(header, value...)
+	}
+	field(2, cipherID)
+	field(3, le32(compression))
+	field(4, seed)
+	field(7, iv)
+	field(11, vd)
+	field(0, []byte("\r\n\r\n"))
+
+	encKey := sha256.Sum256(append(bytes.Clone(seed), transformed...))
+	macBase := sha512.Sum512(append(append(bytes.Clone(seed), transformed...), 1))
+	blockKey := func(index uint64) []byte {
+		k := sha512.Sum512(append(le64(index), macBase[:]...))
+		return k[:]
+	}
+
+	out := bytes.Clone(header)
+	headerHash := sha256.Sum256(header)
+	out = append(out, headerHash[:]...)
+	m := hmac.New(sha256.New, blockKey(math.MaxUint64))
+	m.Write(header)
+	out = m.Sum(out)
+
+	// inner header and XML, with protected values XORed in document order
+	streamKey := random(64)
+	var stream cipher.Stream
+	switch cfg.stream {
+	case 2:
+		k := sha256.Sum256(streamKey)
+		ks := make([]byte, 4096)
+		salsa20.XORKeyStream(ks, ks, []byte{0xE8, 0x30, 0x09, 0x4B, 0x97, 0x20, 0x5D, 0x2A}, &k)
+		stream = &testKeystream{ks}
+	case 3:
+		k := sha512.Sum512(streamKey)
+		stream, _ = chacha20.NewUnauthenticatedCipher(k[:32], k[32:44])
+	}
+	doc := body(func(s string) string {
+		b := []byte(s)
+		stream.XORKeyStream(b, b)
+		return base64.StdEncoding.EncodeToString(b)
+	})
+
+	var inner []byte
+	inner = append(inner, 1)
+	inner = append(inner, le32(4)...)
+	inner = append(inner, le32(cfg.stream)...)
+	inner = append(inner, 2)
+	inner = append(inner, le32(uint32(len(streamKey)))...)
+	inner = append(inner, streamKey...)
+	inner = append(inner, 0, 0, 0, 0, 0)
+	inner = append(inner, doc...)
+
+	if cfg.compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(inner)
+		zw.Close()
+		inner = buf.Bytes()
+	}
+
+	var ct []byte
+	switch cfg.cipher {
+	case "aes":
+		pad := aes.BlockSize - len(inner)%aes.BlockSize
+		for range pad {
+			inner = append(inner, byte(pad))
+		}
+		block, _ := aes.NewCipher(encKey[:])
+		ct = make([]byte, len(inner))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(ct, inner)
+	case "chacha20":
+		s, _ := chacha20.NewUnauthenticatedCipher(encKey[:], iv)
+		ct = make([]byte, len(inner))
+		s.XORKeyStream(ct, inner)
+	}
+
+	for index, data := range [][]byte{ct, nil} {
+		m := hmac.New(sha256.New, blockKey(uint64(index)))
+		m.Write(le64(uint64(index)))
+		m.Write(le32(uint32(len(data))))
+		m.Write(data)
+		out = m.Sum(out)
+		out = append(out, le32(uint32(len(data)))...)
+		out = append(out, data...)
+	}
+	return out
+}
+
+type testKeystream struct{ ks []byte }
+
+func (s *testKeystream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		dst[i] = src[i] ^ s.ks[i]
+	}
+	s.ks = s.ks[len(src):]
+}
+
+// kdbxTimeValue encodes a time as KDBX 4 does.
+func kdbxTimeValue(t time.Time) string {
+	secs := uint64(t.Unix() + 62135596800)
+	return base64.StdEncoding.EncodeToString(binary.LittleEndian.AppendUint64(nil, secs))
+}
+
+var (
+	kdbxCreated  = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	kdbxModified = time.Date(2024, 2, 3, 4, 5, 6, 0, time.UTC)
+)
+
+func kdbxBody(protect func(string) string) string {
+	// the order of protect calls is document order
+	return fmt.Sprintf(`<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+	<Meta>
+		<RecycleBinEnabled>True</RecycleBinEnabled>
+		<RecycleBinUUID>YmluYmluYmluYmluYmluYg==</RecycleBinUUID>
+	</Meta>
+	<Root>
+		<Group>
+			<UUID>cm9vdHJvb3Ryb290cm9vdA==</UUID>
+			<Name>Database</Name>
+			<Entry>
+				<UUID>ZW50cnllbnRyeWVudHJ5ZQ==</UUID>
+				<Tags>network;home</Tags>
+				<Times>
+					<CreationTime>%s</CreationTime>
+					<LastModificationTime>%s</LastModificationTime>
+				</Times>
+				<String><Key>Title</Key><Value>Router</Value></String>
+				<String><Key>UserName</Key><Value>admin</Value></String>
+				<String><Key>Password</Key><Value Protected="True">%s</Value></String>
+				<String><Key>URL</Key><Value>https://192.168.1.1</Value></String>
+				<String><Key>KP2A_URL_1</Key><Value>https://router.local</Value></String>
+				<String><Key>otp</Key><Value Protected="True">%s</Value></String>
+				<String><Key>PIN</Key><Value Protected="True">%s</Value></String>
+				<String><Key>Model</Key><Value>X200</Value></String>
+				<History>
+					<Entry>
+						<String><Key>Title</Key><Value>Router</Value></String>
+						<String><Key>Password</Key><Value Protected="True">%s</Value></String>
+					</Entry>
+				</History>
+			</Entry>
+			<Group>
+				<UUID>ZW1haWxlbWFpbGVtYWlsZQ==</UUID>
+				<Name>Email</Name>
+				<Entry>
+					<String><Key>UserName</Key><Value>alice@example.com</Value></String>
+					<String><Key>Password</Key><Value Protected="True">%s</Value></String>
+					<String><Key>URL</Key><Value>https://mail.example.com/login</Value></String>
+				</Entry>
+				<Group>
+					<Name>Old &amp; unused</Name>
+					<Entry>
+						<String><Key>Title</Key><Value>Recovery codes</Value></String>
+						<String><Key>Notes</Key><Value>1111
+2222</Value></String>
+						<String><Key>Password</Key><Value Protected="True"></Value></String>
+					</Entry>
+				</Group>
+			</Group>
+			<Group>
+				<UUID>YmluYmluYmluYmluYmluYg==</UUID>
+				<Name>Recycle Bin</Name>
+				<Entry>
+					<String><Key>Title</Key><Value>Deleted</Value></String>
+					<String><Key>Password</Key><Value Protected="True">%s</Value></String>
+				</Entry>
+			</Group>
+		</Group>
+	</Root>
+</KeePassFile>`,
+		kdbxTimeValue(kdbxCreated), kdbxTimeValue(kdbxModified),
+		protect("hunter2"),
+		protect("otpauth://totp/router?secret=JBSWY3DPEHPK3PXP"),
+		protect("0000"),
+		protect("old-password"),
+		protect("mail-password"),
+		protect("deleted-password"),
+	)
+}
+
+var kdbxWant = []zimport.Record{
+	{
+		Kind:     zimport.Login,
+		Title:    "Router",
+		Username: "admin",
+		Password: "hunter2",
+		URLs:     []string{"https://192.168.1.1", "https://router.local"},
+		TOTP:     "otpauth://totp/router?secret=JBSWY3DPEHPK3PXP",
+		Tags:     []string{"network", "home"},
+		Fields: []zimport.Field{
+			{Name: "PIN", Value: "0000", Hidden: true},
+			{Name: "Model", Value: "X200"},
+		},
+		Created:  kdbxCreated,
+		Modified: kdbxModified,
+	},
+	{
+		Kind:     zimport.Login,
+		Title:    "mail.example.com",
+		Username: "alice@example.com",
+		Password: "mail-password",
+		URLs:     []string{"https://mail.example.com/login"},
+		Folder:   "Email",
+	},
+	{
+		Kind:   zimport.Note,
+		Title:  "Recovery codes",
+		Notes:  "1111\n2222",
+		Folder: "Email/Old & unused",
+	},
+}
+
+func TestKeePass(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  kdbxConfig
+	}{
+		{"aes-kdf aes chacha20 gzip", kdbxConfig{kdf: "aes", cipher: "aes", stream: 3, compress: true}},
+		{"argon2d chacha20 salsa20", kdbxConfig{kdf: "argon2d", cipher: "chacha20", stream: 2}},
+		{"argon2id aes chacha20", kdbxConfig{kdf: "argon2id", cipher: "aes", stream: 3, compress: true}},
+		{"argon2id with secret", kdbxConfig{kdf: "argon2id", secret: []byte("pepper"), cipher: "chacha20", stream: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := kdbxWrite(t, tt.cfg, []byte("correct horse"), nil, kdbxBody)
+
+			got, err := zimport.KeePass(data, []byte("correct horse"), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, kdbxWant) {
+				t.Errorf("got  %+v\nwant %+v", got, kdbxWant)
+			}
+
+			if _, err := zimport.KeePass(data, []byte("wrong horse"), nil); !errors.Is(err, zimport.ErrWrongPassword) {
+				t.Errorf("wrong password: err = %v, want ErrWrongPassword", err)
+			}
+			if _, err := zimport.KeePass(data, nil, nil); !errors.Is(err, zimport.ErrPasswordRequired) {
+				t.Errorf("no password: err = %v, want ErrPasswordRequired", err)
+			}
+		})
+	}
+}
+
+func TestKeePassKeyFile(t *testing.T) {
+	key := bytes.Repeat([]byte{0xA5}, 32)
+	cfg := kdbxConfig{kdf: "aes", cipher: "aes", stream: 3}
+
+	keyFiles := map[string][]byte{
+		"raw": key,
+		"hex": []byte(hex.EncodeToString(key)),
+		"xml v1": []byte(`<?xml version="1.0" encoding="utf-8"?>
+<KeyFile><Meta><Version>1.00</Version></Meta><Key><Data>` + base64.StdEncoding.EncodeToString(key) + `</Data></Key></KeyFile>`),
+		"xml v2": []byte(`<?xml version="1.0" encoding="utf-8"?>
+<KeyFile>
+	<Meta><Version>2.0</Version></Meta>
+	<Key><Data Hash="00000000">
+		A5A5A5A5 A5A5A5A5 A5A5A5A5 A5A5A5A5
+		A5A5A5A5 A5A5A5A5 A5A5A5A5 A5A5A5A5
+	</Data></Key>
+</KeyFile>`),
+	}
+	for name, file := range keyFiles {
+		t.Run(name, func(t *testing.T) {
+			// key file only, then password and key file together
+			data := kdbxWrite(t, cfg, nil, key, kdbxBody)
+			if _, err := zimport.KeePass(data, nil, file); err != nil {
+				t.Fatalf("key file: %v", err)
+			}
+
+			data = kdbxWrite(t, cfg, []byte("pw"), key, kdbxBody)
+			if _, err := zimport.KeePass(data, []byte("pw"), file); err != nil {
+				t.Fatalf("password and key file: %v", err)
+			}
+			if _, err := zimport.KeePass(data, []byte("pw"), nil); !errors.Is(err, zimport.ErrWrongPassword) {
+				t.Errorf("missing key file: err = %v, want ErrWrongPassword", err)
+			}
+		})
+	}
+
+	t.Run("other file", func(t *testing.T) {
+		file := []byte("any file at all can be a key file")
+		sum := sha256.Sum256(file)
+		data := kdbxWrite(t, cfg, nil, sum[:], kdbxBody)
+		if _, err := zimport.KeePass(data, nil, file); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestKeePassInvalid(t *testing.T) {
+	cfg := kdbxConfig{kdf: "aes", cipher: "aes", stream: 3}
+	valid := kdbxWrite(t, cfg, []byte("pw"), nil, kdbxBody)
+
+	kdbx3 := bytes.Clone(valid)
+	binary.LittleEndian.PutUint32(kdbx3[8:], 0x00030001)
+
+	headerChanged := bytes.Clone(valid)
+	headerChanged[20] ^= 1
+
+	blockChanged := bytes.Clone(valid)
+	blockChanged[len(blockChanged)-80] ^= 1
+
+	tests := map[string][]byte{
+		"empty":          nil,
+		"not keepass":    []byte("this is not a keepass database"),
+		"kdbx 3":         kdbx3,
+		"header changed": headerChanged,
+		"block changed":  blockChanged,
+		"truncated":      valid[:len(valid)-40],
+
+		// costs beyond what zcrypto accepts for password hashes
+		"aes-kdf rounds": kdbxWrite(t, kdbxConfig{kdf: "aes", cipher: "aes", stream: 3, cost: 1 << 30}, []byte("pw"), nil, kdbxBody),
+		"argon2 passes":  kdbxWrite(t, kdbxConfig{kdf: "argon2d", cipher: "aes", stream: 3, cost: 65}, []byte("pw"), nil, kdbxBody),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := zimport.KeePass(data, []byte("pw"), nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Is(err, zimport.ErrWrongPassword) {
+				t.Errorf("err = %v, want an error other than ErrWrongPassword", err)
+			}
+		})
+	}
+}
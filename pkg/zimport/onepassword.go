@@ -0,0 +1,266 @@
+package zimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+// onePUXMaxData caps how much of export.data we read, against zip bombs.
+const onePUXMaxData = 512 << 20
+
+// 1Password category UUIDs with a matching Kind; every other
+// category imports as a note
+var onePasswordKinds = map[string]Kind{
+	"001": Login,    // Login
+	"002": Card,     // Credit Card
+	"003": Note,     // Secure Note
+	"004": Identity, // Identity
+	"005": Login,    // Password
+	"114": SSHKey,   // SSH Key
+}
+
+type onePUXExport struct {
+	Accounts []struct {
+		Vaults []struct {
+			Attrs struct {
+				Name string `json:"name"`
+			} `json:"attrs"`
+			Items []onePUXItem `json:"items"`
+		} `json:"vaults"`
+	} `json:"accounts"`
+}
+
+type onePUXItem struct {
+	FavIndex     int    `json:"favIndex"`
+	CreatedAt    int64  `json:"createdAt"`
+	UpdatedAt    int64  `json:"updatedAt"`
+	State        string `json:"state"`
+	CategoryUUID string `json:"categoryUuid"`
+
+	Details struct {
+		LoginFields []struct {
+			Value       string `json:"value"`
+			Name        string `json:"name"`
+			FieldType   string `json:"fieldType"`
+			Designation string `json:"designation"`
+		} `json:"loginFields"`
+		NotesPlain string `json:"notesPlain"`
+		Password   string `json:"password"`
+		Sections   []struct {
+			Title  string `json:"title"`
+			Fields []struct {
+				Title string                     `json:"title"`
+				ID    string                     `json:"id"`
+				Value map[string]json.RawMessage `json:"value"`
+			} `json:"fields"`
+		} `json:"sections"`
+	} `json:"details"`
+
+	Overview struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+		URLs  []struct {
+			URL string `json:"url"`
+		} `json:"urls"`
+		Tags []string `json:"tags"`
+	} `json:"overview"`
+}
+
+// OnePassword reads a 1Password .1pux export. 1Password writes these
+// unencrypted, so delete the file once imported, with Shred. Items in the
+// trash are skipped; the vault name becomes each record's folder.
+func OnePassword(data []byte) ([]Record, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open 1pux archive: %w", err)
+	}
+
+	f, err := zr.Open("export.data")
+	if err != nil {
+		return nil, fmt.Errorf("open 1pux archive: %w", err)
+	}
+	defer f.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(f, onePUXMaxData+1))
+	if err != nil {
+		return nil, fmt.Errorf("read 1pux export: %w", err)
+	}
+	defer zcrypto.Erase(raw)
+	if len(raw) > onePUXMaxData {
+		return nil, errors.New("1pux export too large")
+	}
+
+	var exp onePUXExport
+	if err := json.Unmarshal(raw, &exp); err != nil {
+		return nil, fmt.Errorf("parse 1pux export: %w", err)
+	}
+
+	var records []Record
+	for _, account := range exp.Accounts {
+		for _, vault := range account.Vaults {
+			for _, item := range vault.Items {
+				if item.State == "trashed" {
+					continue
+				}
+				records = append(records, onePasswordRecord(&item, vault.Attrs.Name))
+			}
+		}
+	}
+	return records, nil
+}
+
+// onePasswordRecord converts a 1PUX item.
+func onePasswordRecord(item *onePUXItem, vault string) Record {
+	kind, ok := onePasswordKinds[item.CategoryUUID]
+	if !ok {
+		kind = Note
+	}
+
+	rec := Record{
+		Kind:     kind,
+		Title:    item.Overview.Title,
+		Password: item.Details.Password,
+		Notes:    item.Details.NotesPlain,
+		Folder:   vault,
+		Tags:     item.Overview.Tags,
+		Favorite: item.FavIndex > 0,
+	}
+	if item.CreatedAt > 0 {
+		rec.Created = time.Unix(item.CreatedAt, 0).UTC()
+	}
+	if item.UpdatedAt > 0 {
+		rec.Modified = time.Unix(item.UpdatedAt, 0).UTC()
+	}
+	if item.State == "archived" {
+		rec.Tags = append(rec.Tags, "archived")
+	}
+
+	rec.addURL(item.Overview.URL)
+	for _, u := range item.Overview.URLs {
+		rec.addURL(u.URL)
+	}
+
+	for _, f := range item.Details.LoginFields {
+		switch {
+		case f.Designation == "username" && rec.Username == "":
+			rec.Username = f.Value
+		case f.Designation == "password" && rec.Password == "":
+			rec.Password = f.Value
+		default:
+			name := f.Name
+			if name == "" {
+				name = f.Designation
+			}
+			rec.addField(name, f.Value, f.FieldType == "P")
+		}
+	}
+
+	for _, s := range item.Details.Sections {
+		for _, f := range s.Fields {
+			name := f.Title
+			if name == "" {
+				name = f.ID
+			}
+			if s.Title != "" {
+				name = s.Title + ": " + name
+			}
+			for typ, v := range f.Value {
+				if typ == "totp" && rec.TOTP == "" {
+					rec.TOTP = onePUXString(v)
+					continue
+				}
+				value, hidden := onePUXValue(typ, v)
+				rec.addField(name, value, hidden)
+			}
+		}
+	}
+
+	if rec.Title == "" {
+		rec.Title = defaultTitle(&rec)
+	}
+	return rec
+}
+
+// onePUXValue renders a section field value of the given type, and
+// reports whether 1Password conceals it.
+func onePUXValue(typ string, v json.RawMessage) (string, bool) {
+	switch typ {
+	case "concealed", "creditCardNumber":
+		return onePUXString(v), true
+	case "sshKey":
+		var key struct {
+			PrivateKey string `json:"privateKey"`
+		}
+		if json.Unmarshal(v, &key) != nil {
+			return "", true
+		}
+		return key.PrivateKey, true
+	case "date":
+		var n int64
+		if json.Unmarshal(v, &n) == nil && n != 0 {
+			return time.Unix(n, 0).UTC().Format(time.DateOnly), false
+		}
+		return "", false
+	case "monthYear":
+		var n int
+		if json.Unmarshal(v, &n) == nil && n != 0 {
+			return fmt.Sprintf("%02d/%04d", n%100, n/100), false
+		}
+		return "", false
+	case "email":
+		var e struct {
+			Address string `json:"email_address"`
+		}
+		if json.Unmarshal(v, &e) == nil {
+			return e.Address, false
+		}
+		return onePUXString(v), false
+	case "address":
+		var a struct {
+			Street, City, State, Zip, Country string
+		}
+		if json.Unmarshal(v, &a) != nil {
+			return "", false
+		}
+		var lines []string
+		for _, l := range []string{a.Street, strings.TrimSpace(a.City + " " + a.State + " " + a.Zip), a.Country} {
+			if l = strings.TrimSpace(l); l != "" {
+				lines = append(lines, l)
+			}
+		}
+		return strings.Join(lines, "\n"), false
+	default:
+		return onePUXString(v), false
+	}
+}
+
+// onePUXString returns a value that should be a JSON string; numbers and
+// booleans are formatted, anything else is dropped.
+func onePUXString(v json.RawMessage) string {
+	d := json.NewDecoder(bytes.NewReader(v))
+	d.UseNumber()
+
+	var x any
+	if d.Decode(&x) != nil {
+		return ""
+	}
+	switch x := x.(type) {
+	case string:
+		return x
+	case json.Number:
+		return x.String()
+	case bool:
+		return strconv.FormatBool(x)
+	default:
+		return ""
+	}
+}
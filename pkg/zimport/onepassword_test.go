@@ -0,0 +1,141 @@
+package zimport_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zimport"
+)
+
+// onePUX zips export.data as 1Password does.
+func onePUX(t *testing.T, exportData string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("export.data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(exportData)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOnePassword(t *testing.T) {
+	data := onePUX(t, `{"accounts": [{"vaults": [{
+		"attrs": {"name": "Personal"},
+		"items": [
+			{
+				"favIndex": 1, "createdAt": 1700000000, "updatedAt": 1700000100,
+				"state": "active", "categoryUuid": "001",
+				"details": {
+					"loginFields": [
+						{"value": "alice", "name": "email", "fieldType": "E", "designation": "username"},
+						{"value": "hunter2", "name": "password", "fieldType": "P", "designation": "password"},
+						{"value": "on", "name": "remember", "fieldType": "C"}
+					],
+					"notesPlain": "main account",
+					"sections": [{"title": "Security", "fields": [
+						{"title": "one-time password", "id": "TOTP_1", "value": {"totp": "otpauth://totp/gh?secret=JBSWY3DPEHPK3PXP"}},
+						{"title": "recovery code", "id": "rc", "value": {"concealed": "abcd-efgh"}},
+						{"title": "", "id": "since", "value": {"date": 1577836800}}
+					]}]
+				},
+				"overview": {
+					"title": "GitHub",
+					"url": "https://github.com",
+					"urls": [{"url": "https://github.com"}, {"url": "https://gist.github.com"}],
+					"tags": ["dev"]
+				}
+			},
+			{
+				"state": "archived", "categoryUuid": "002",
+				"details": {"sections": [{"title": "", "fields": [
+					{"title": "number", "id": "ccnum", "value": {"creditCardNumber": "4111111111111111"}},
+					{"title": "expiry date", "id": "expiry", "value": {"monthYear": 202612}}
+				]}]},
+				"overview": {"title": "Visa"}
+			},
+			{"state": "trashed", "categoryUuid": "003", "overview": {"title": "Deleted"}},
+			{
+				"state": "active", "categoryUuid": "101",
+				"details": {"notesPlain": "", "sections": [{"title": "", "fields": [
+					{"title": "routing number", "id": "routingNo", "value": {"string": "021000021"}}
+				]}]},
+				"overview": {"title": "Bank"}
+			}
+		]
+	}]}]}`)
+
+	got, err := zimport.OnePassword(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []zimport.Record{
+		{
+			Kind:     zimport.Login,
+			Title:    "GitHub",
+			Username: "alice",
+			Password: "hunter2",
+			URLs:     []string{"https://github.com", "https://gist.github.com"},
+			TOTP:     "otpauth://totp/gh?secret=JBSWY3DPEHPK3PXP",
+			Notes:    "main account",
+			Folder:   "Personal",
+			Tags:     []string{"dev"},
+			Favorite: true,
+			Fields: []zimport.Field{
+				{Name: "remember", Value: "on"},
+				{Name: "Security: recovery code", Value: "abcd-efgh", Hidden: true},
+				{Name: "Security: since", Value: "2020-01-01"},
+			},
+			Created:  time.Unix(1700000000, 0).UTC(),
+			Modified: time.Unix(1700000100, 0).UTC(),
+		},
+		{
+			Kind:   zimport.Card,
+			Title:  "Visa",
+			Folder: "Personal",
+			Tags:   []string{"archived"},
+			Fields: []zimport.Field{
+				{Name: "number", Value: "4111111111111111", Hidden: true},
+				{Name: "expiry date", Value: "12/2026"},
+			},
+		},
+		{
+			Kind:   zimport.Note,
+			Title:  "Bank",
+			Folder: "Personal",
+			Fields: []zimport.Field{{Name: "routing number", Value: "021000021"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestOnePasswordInvalid(t *testing.T) {
+	if _, err := zimport.OnePassword([]byte("not a zip")); err == nil {
+		t.Error("accepted a non-zip file")
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := zw.Create("export.attributes"); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	if _, err := zimport.OnePassword(buf.Bytes()); err == nil {
+		t.Error("accepted an archive without export.data")
+	}
+
+	if _, err := zimport.OnePassword(onePUX(t, "{")); err == nil {
+		t.Error("accepted malformed json")
+	}
+}
@@ -0,0 +1,115 @@
+package zimport
+
+import (
+	"crypto/cipher"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kind is the kind of item an Record holds.
+type Kind string
+
+// Import kinds. Items of other kinds, such as 1Password bank accounts,
+// import as notes with their details in Fields.
+const (
+	Login    Kind = "login"
+	Note     Kind = "note"
+	Card     Kind = "card"
+	Identity Kind = "identity"
+	SSHKey   Kind = "ssh-key"
+)
+
+var (
+	// ErrPasswordRequired is returned when an export is encrypted and
+	// no password was given, so callers can prompt for one and try again.
+	ErrPasswordRequired = errors.New("export is password-protected")
+
+	// ErrWrongPassword is returned when an encrypted export does not
+	// open with the given password or key file.
+	ErrWrongPassword = errors.New("wrong password or key file")
+)
+
+// Record is an item imported from another password manager, in a
+// form that does not depend on where it came from.
+type Record struct {
+	Kind     Kind
+	Title    string
+	Username string
+	Password string
+	URLs     []string
+
+	// TOTP is the one-time password secret as the source exported it,
+	// usually an otpauth:// URI for zcrypto.ParseOTPAuthURI, or a bare
+	// base32 secret.
+	TOTP string
+
+	Notes string
+
+	// Folder is the folder, group or vault the item was in, with nested
+	// folders separated by "/".
+	Folder string
+
+	Tags     []string
+	Favorite bool
+
+	// Fields holds everything else: custom fields, card and identity
+	// details, and CSV columns that are not recognised.
+	Fields []Field
+
+	Created  time.Time
+	Modified time.Time
+}
+
+// Field is a named value of an Record. Hidden values, such as
+// a card's security code, should be masked when shown.
+type Field struct {
+	Name   string
+	Value  string
+	Hidden bool
+}
+
+// addField appends a field unless its value is empty.
+func (r *Record) addField(name, value string, hidden bool) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	r.Fields = append(r.Fields, Field{Name: name, Value: value, Hidden: hidden})
+}
+
+// addURL appends a URL unless it is empty or already present.
+func (r *Record) addURL(u string) {
+	u = strings.TrimSpace(u)
+	if u == "" {
+		return
+	}
+	if slices.Contains(r.URLs, u) {
+		return
+	}
+	r.URLs = append(r.URLs, u)
+}
+
+// cbcDecrypt decrypts CBC ciphertext and removes its PKCS#7 padding. The
+// callers authenticate ciphertext before decrypting it, so padding errors
+// reveal nothing.
+func cbcDecrypt(block cipher.Block, iv, ct []byte) ([]byte, error) {
+	n := block.BlockSize()
+	if len(iv) != n || len(ct) == 0 || len(ct)%n != 0 {
+		return nil, errors.New("malformed cbc ciphertext")
+	}
+
+	plain := make([]byte, len(ct))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, ct)
+
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > n {
+		return nil, errors.New("malformed cbc padding")
+	}
+	for _, b := range plain[len(plain)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("malformed cbc padding")
+		}
+	}
+	return plain[:len(plain)-pad], nil
+}
@@ -0,0 +1,361 @@
+package zstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+// maxAttachmentName bounds the attachment name stored inside a blob.
+const maxAttachmentName = 1024
+
+// Attachments are stored beside their record as streamed blobs:
+//
+//	<collection>/<id>.att/<file>.blob
+//
+// file is a keyed hash of the record id and attachment name, so names are
+// not visible on disk. Each blob is encrypted with zcrypto's chunked stream
+// format under a key bound to the record id and file, so a blob cannot be
+// moved to another record or renamed without failing authentication. The
+// plaintext starts with the uvarint-prefixed attachment name followed by
+// the attachment contents. A blob is written to a temp file beside it,
+// <file>.blob.<random>.tmp, and renamed into place once complete, so a
+// failed write keeps the previous attachment.
+
+// PutAttachment streams r into an encrypted attachment called name on the
+// record id, replacing any existing attachment with the same name. Memory
+// use is constant regardless of the attachment size. Returns ErrNotFound if
+// the record does not exist.
+func (c *Collection[V]) PutAttachment(id, name string, r io.Reader) error {
+	if name == "" || len(name) > maxAttachmentName {
+		return fmt.Errorf("attachment name must be 1 to %d bytes", maxAttachmentName)
+	}
+
+	if _, err := c.load(id); err != nil {
+		return err
+	}
+
+	return c.putAttachment(id, name, r)
+}
+
+// putAttachment writes the blob for name on the record id without checking
+// that the record exists.
+func (b *bucket) putAttachment(id, name string, r io.Reader) error {
+	file, err := b.attachmentFile(id, name)
+	if err != nil {
+		return err
+	}
+
+	dir := b.attachmentDir(id)
+	if err := b.store.fs.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create attachment directory: %w", err)
+	}
+
+	suffix, err := zcrypto.RandBytes(8)
+	if err != nil {
+		return fmt.Errorf("generate temp name: %w", err)
+	}
+
+	// a unique temp file, so concurrent writers of the same attachment
+	// never share one
+	path := filepath.Join(dir, file+".blob")
+	tmp := path + "." + hex.EncodeToString(suffix) + tempSuffix
+	err = replaceWith(b.store.fs, path, tmp, func(w io.Writer) error {
+		return b.writeAttachment(w, id, file, name, r)
+	})
+	if err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// writeAttachment encrypts the name header and contents of r into f.
+func (b *bucket) writeAttachment(f io.Writer, id, file, name string, r io.Reader) error {
+	key, err := b.attachmentKey(id, file)
+	if err != nil {
+		return err
+	}
+	defer zcrypto.Erase(key)
+
+	w, err := zcrypto.NewEncryptWriter(key, f)
+	if err != nil {
+		return fmt.Errorf("encrypt attachment: %w", err)
+	}
+
+	header := binary.AppendUvarint(nil, uint64(len(name)))
+	header = append(header, name...)
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("encrypt attachment: %w", err)
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("encrypt attachment: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("encrypt attachment: %w", err)
+	}
+
+	return nil
+}
+
+// OpenAttachment returns a reader that decrypts the named attachment on the
+// record id as it is read. Returns ErrNotFound if the record or attachment
+// does not exist, or the record has expired. The caller must close the
+// returned reader.
+func (c *Collection[V]) OpenAttachment(id, name string) (io.ReadCloser, error) {
+	if _, err := c.load(id); err != nil {
+		return nil, err
+	}
+
+	file, err := c.attachmentFile(id, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, got, err := c.openAttachment(id, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if got != name {
+		rc.Close()
+		return nil, fmt.Errorf("attachment %s: name mismatch", file)
+	}
+
+	return rc, nil
+}
+
+// Attachments returns the names of all attachments on the record id in
+// lexical order. Returns ErrNotFound if the record does not exist or has
+// expired.
+func (c *Collection[V]) Attachments(id string) ([]string, error) {
+	if _, err := c.load(id); err != nil {
+		return nil, err
+	}
+
+	files, err := c.attachmentFiles(id)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		rc, name, err := c.openAttachment(id, file)
+		if err != nil {
+			return nil, err
+		}
+		rc.Close()
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeleteAttachment removes the named attachment from the record id.
+// Returns ErrNotFound if the attachment does not exist.
+func (c *Collection[V]) DeleteAttachment(id, name string) error {
+	file, err := c.attachmentFile(id, name)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(c.attachmentDir(id), file+".blob")
+	if err := c.store.fs.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("remove %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// attachmentReader decrypts a blob and closes the underlying file.
+type attachmentReader struct {
+	io.Reader
+	f io.Closer
+}
+
+func (a *attachmentReader) Close() error { return a.f.Close() }
+
+// openAttachment opens the blob stored under file and reads its name header.
+func (b *bucket) openAttachment(id, file string) (io.ReadCloser, string, error) {
+	path := filepath.Join(b.attachmentDir(id), file+".blob")
+	f, err := b.store.fs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, "", ErrNotFound
+		}
+		return nil, "", fmt.Errorf("open %s: %w", path, err)
+	}
+
+	name, r, err := b.readAttachmentHeader(f, id, file)
+	if err != nil {
+		f.Close()
+		return nil, "", fmt.Errorf("decrypt %s: %w", path, err)
+	}
+
+	return &attachmentReader{Reader: r, f: f}, name, nil
+}
+
+// readAttachmentHeader starts decrypting f and consumes the name header.
+func (b *bucket) readAttachmentHeader(f io.Reader, id, file string) (string, io.Reader, error) {
+	key, err := b.attachmentKey(id, file)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zcrypto.Erase(key)
+
+	dr, err := zcrypto.NewDecryptReader(key, f)
+	if err != nil {
+		return "", nil, err
+	}
+
+	br := bufio.NewReader(dr)
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", nil, fmt.Errorf("read name: %w", err)
+	}
+	if n == 0 || n > maxAttachmentName {
+		return "", nil, errors.New("invalid name length")
+	}
+
+	name := make([]byte, n)
+	if _, err := io.ReadFull(br, name); err != nil {
+		return "", nil, fmt.Errorf("read name: %w", err)
+	}
+
+	return string(name), br, nil
+}
+
+// attachmentFiles returns the blob file names stored for the record id.
+func (b *bucket) attachmentFiles(id string) ([]string, error) {
+	dir := b.attachmentDir(id)
+
+	var files []string
+	err := b.store.fs.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			if path != dir {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if file, ok := strings.CutSuffix(d.Name(), ".blob"); ok {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// removeAttachments deletes every attachment on the record id.
+func (b *bucket) removeAttachments(id string) error {
+	return removeAttachmentDir(b.store, b.attachmentDir(id))
+}
+
+// copyAttachments re-encrypts every attachment on fromID in from into toID
+// in to. The buckets may belong to different stores.
+func copyAttachments(from *bucket, fromID string, to *bucket, toID string) error {
+	files, err := from.attachmentFiles(fromID)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		rc, name, err := from.openAttachment(fromID, file)
+		if err != nil {
+			return err
+		}
+
+		err = to.putAttachment(toID, name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeAttachmentDir deletes every blob in dir, and any temp file left by
+// an interrupted write, and then dir itself.
+func removeAttachmentDir(s *Store, dir string) error {
+	var paths []string
+	err := s.fs.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() && attachmentFileName(d.Name()) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := s.fs.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove %s: %w", path, err)
+		}
+	}
+
+	// best-effort: backends that track empty directories remove them here
+	_ = s.fs.Remove(dir)
+	return nil
+}
+
+// attachmentFileName reports whether name is a blob or a blob's temp file.
+func attachmentFileName(name string) bool {
+	return strings.HasSuffix(name, ".blob") || (strings.Contains(name, ".blob.") && strings.HasSuffix(name, tempSuffix))
+}
+
+// attachmentDir returns the directory holding the record's attachments.
+func (b *bucket) attachmentDir(id string) string {
+	return filepath.Join(b.name, id+".att")
+}
+
+// attachmentFile returns the on-disk name of an attachment, a keyed hash of
+// the record id and attachment name.
+func (b *bucket) attachmentFile(id, name string) (string, error) {
+	h, err := zcrypto.ExpandKey(b.key, nil, []byte("attachment-name\x00"+id+"\x00"+name))
+	if err != nil {
+		return "", fmt.Errorf("derive attachment name: %w", err)
+	}
+	return hex.EncodeToString(h[:16]), nil
+}
+
+// attachmentKey derives the stream key for one blob.
+func (b *bucket) attachmentKey(id, file string) ([]byte, error) {
+	key, err := zcrypto.ExpandKey(b.key, nil, []byte("attachment\x00"+id+"\x00"+file))
+	if err != nil {
+		return nil, fmt.Errorf("derive attachment key: %w", err)
+	}
+	return key, nil
+}
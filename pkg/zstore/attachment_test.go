@@ -0,0 +1,355 @@
+package zstore_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+	"github.com/zarlcorp/core/pkg/zfilesystem"
+	"github.com/zarlcorp/core/pkg/zstore"
+)
+
+func TestAttachmentRoundTrip(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	col, err := zstore.NewCollection[string](s, "docs")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.Put("d1", "passport"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	// spans several stream chunks
+	want, err := zcrypto.RandBytes(3*zcrypto.ChunkSize + 123)
+	if err != nil {
+		t.Fatalf("rand: %v", err)
+	}
+
+	if err := col.PutAttachment("d1", "scan.pdf", bytes.NewReader(want)); err != nil {
+		t.Fatalf("put attachment: %v", err)
+	}
+
+	rc, err := col.OpenAttachment("d1", "scan.pdf")
+	if err != nil {
+		t.Fatalf("open attachment: %v", err)
+	}
+	defer rc.Close()
+
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read attachment: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("attachment mismatch: got %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func TestAttachmentNameNotOnDisk(t *testing.T) {
+	mfs := zfilesystem.NewMemFS()
+	s, err := zstore.Open(mfs, []byte("password"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	col, err := zstore.NewCollection[string](s, "docs")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.Put("d1", "x"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := col.PutAttachment("d1", "secret-name.txt", strings.NewReader("data")); err != nil {
+		t.Fatalf("put attachment: %v", err)
+	}
+
+	err = mfs.WalkDir(".", func(path string, _ fs.DirEntry, err error) error {
+		if strings.Contains(path, "secret-name") {
+			t.Errorf("attachment name visible in path %q", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+}
+
+func TestAttachmentRequiresRecord(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	col, err := zstore.NewCollection[string](s, "docs")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+
+	err = col.PutAttachment("missing", "a", strings.NewReader("data"))
+	if !errors.Is(err, zstore.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAttachmentNotFound(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	col, err := zstore.NewCollection[string](s, "docs")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.Put("d1", "x"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	if _, err := col.OpenAttachment("d1", "nope"); !errors.Is(err, zstore.ErrNotFound) {
+		t.Fatalf("open: expected ErrNotFound, got %v", err)
+	}
+	if _, err := col.OpenAttachment("missing", "nope"); !errors.Is(err, zstore.ErrNotFound) {
+		t.Fatalf("open on missing record: expected ErrNotFound, got %v", err)
+	}
+	if _, err := col.Attachments("missing"); !errors.Is(err, zstore.ErrNotFound) {
+		t.Fatalf("attachments on missing record: expected ErrNotFound, got %v", err)
+	}
+	if err := col.DeleteAttachment("d1", "nope"); !errors.Is(err, zstore.ErrNotFound) {
+		t.Fatalf("delete: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAttachmentFailedWriteKeepsOld(t *testing.T) {
+	mfs := zfilesystem.NewMemFS()
+	s, err := zstore.Open(mfs, []byte("password"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	col, err := zstore.NewCollection[string](s, "docs")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.Put("d1", "x"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := col.PutAttachment("d1", "a", strings.NewReader("v1")); err != nil {
+		t.Fatalf("put attachment: %v", err)
+	}
+
+	broken := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("disconnected")))
+	if err := col.PutAttachment("d1", "a", broken); err == nil {
+		t.Fatal("expected error from a failed write")
+	}
+
+	rc, err := col.OpenAttachment("d1", "a")
+	if err != nil {
+		t.Fatalf("open attachment: %v", err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(got) != "v1" {
+		t.Fatalf("attachment = %q, want the old %q", got, "v1")
+	}
+
+	var files []string
+	err = mfs.WalkDir("docs/d1.att", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("files = %v, want only the blob", files)
+	}
+}
+
+func TestAttachmentsListAndDelete(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	col, err := zstore.NewCollection[string](s, "docs")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.Put("d1", "x"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	for _, name := range []string{"b.key", "a.pdf", "c.png"} {
+		if err := col.PutAttachment("d1", name, strings.NewReader(name)); err != nil {
+			t.Fatalf("put attachment %s: %v", name, err)
+		}
+	}
+
+	// replacing an attachment keeps a single entry
+	if err := col.PutAttachment("d1", "a.pdf", strings.NewReader("v2")); err != nil {
+		t.Fatalf("replace attachment: %v", err)
+	}
+
+	names, err := col.Attachments("d1")
+	if err != nil {
+		t.Fatalf("attachments: %v", err)
+	}
+	if want := []string{"a.pdf", "b.key", "c.png"}; !slices.Equal(names, want) {
+		t.Fatalf("attachments = %v, want %v", names, want)
+	}
+
+	if err := col.DeleteAttachment("d1", "b.key"); err != nil {
+		t.Fatalf("delete attachment: %v", err)
+	}
+
+	names, err = col.Attachments("d1")
+	if err != nil {
+		t.Fatalf("attachments: %v", err)
+	}
+	if want := []string{"a.pdf", "c.png"}; !slices.Equal(names, want) {
+		t.Fatalf("attachments = %v, want %v", names, want)
+	}
+
+	// attachments do not count as records
+	n, err := col.Len()
+	if err != nil {
+		t.Fatalf("len: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("len = %d, want 1", n)
+	}
+}
+
+func TestDeleteRemovesAttachments(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	col, err := zstore.NewCollection[string](s, "docs")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.Put("d1", "x"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := col.PutAttachment("d1", "a", strings.NewReader("data")); err != nil {
+		t.Fatalf("put attachment: %v", err)
+	}
+
+	if err := col.Delete("d1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	// re-creating the record must not resurrect old attachments
+	if err := col.Put("d1", "y"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	names, err := col.Attachments("d1")
+	if err != nil {
+		t.Fatalf("attachments: %v", err)
+	}
+	if len(names) != 0 {
+		t.Fatalf("attachments = %v, want none", names)
+	}
+}
+
+func TestSweepRemovesExpiredAttachments(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	clock := newFakeClock()
+	s.SetNowForTest(clock.Now)
+
+	col, err := zstore.NewCollection[string](s, "docs")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.PutWithTTL("tmp", "x", time.Minute); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := col.PutAttachment("tmp", "a", strings.NewReader("data")); err != nil {
+		t.Fatalf("put attachment: %v", err)
+	}
+
+	clock.Advance(time.Hour)
+
+	// an expired record's attachments are gone before the sweep
+	if _, err := col.OpenAttachment("tmp", "a"); !errors.Is(err, zstore.ErrNotFound) {
+		t.Fatalf("open: expected ErrNotFound, got %v", err)
+	}
+	if _, err := col.Attachments("tmp"); !errors.Is(err, zstore.ErrNotFound) {
+		t.Fatalf("attachments: expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := s.Sweep(context.Background()); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+
+	// and on disk after it
+	if err := col.Put("tmp", "y"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	names, err := col.Attachments("tmp")
+	if err != nil {
+		t.Fatalf("attachments: %v", err)
+	}
+	if len(names) != 0 {
+		t.Fatalf("attachments = %v, want none", names)
+	}
+}
+
+func TestAttachmentTamperedBlob(t *testing.T) {
+	mfs := zfilesystem.NewMemFS()
+	s, err := zstore.Open(mfs, []byte("password"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	col, err := zstore.NewCollection[string](s, "docs")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	for _, id := range []string{"d1", "d2"} {
+		if err := col.Put(id, "x"); err != nil {
+			t.Fatalf("put: %v", err)
+		}
+	}
+	if err := col.PutAttachment("d1", "a", strings.NewReader("data")); err != nil {
+		t.Fatalf("put attachment: %v", err)
+	}
+
+	var blob string
+	err = mfs.WalkDir("docs/d1.att", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			blob = path
+		}
+		return err
+	})
+	if err != nil || blob == "" {
+		t.Fatalf("find blob: %v", err)
+	}
+
+	data, err := mfs.ReadFile(blob)
+	if err != nil {
+		t.Fatalf("read blob: %v", err)
+	}
+
+	// moving a blob to another record must fail authentication
+	moved := strings.Replace(blob, "d1.att", "d2.att", 1)
+	if err := mfs.WriteFile(moved, data, 0o600); err != nil {
+		t.Fatalf("write blob: %v", err)
+	}
+	if _, err := col.Attachments("d2"); err == nil {
+		t.Fatal("expected error reading blob moved to another record")
+	}
+}
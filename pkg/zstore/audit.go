@@ -0,0 +1,419 @@
+package zstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+	"github.com/zarlcorp/core/pkg/zfilesystem"
+)
+
+const (
+	auditDir     = ".audit"
+	auditHead    = ".audit/head"
+	auditPending = ".audit/pending"
+
+	// key derivation labels start with a NUL byte so they can never collide
+	// with a collection name, which is also used as an HKDF info parameter
+	auditEncInfo = "\x00zstore/audit/enc"
+	auditMACInfo = "\x00zstore/audit/mac"
+)
+
+// ErrAuditTampered is returned by AuditLog when the log fails verification.
+var ErrAuditTampered = errors.New("audit log tampered")
+
+// ErrAuditNotRecorded is returned, wrapped, by an operation that succeeded
+// but whose audit entry could not be written. The operation's effect is
+// persisted; only the log is missing it.
+var ErrAuditNotRecorded = errors.New("audit entry not recorded")
+
+// AuditEvent names the kind of operation an audit entry records.
+type AuditEvent string
+
+// Audit events.
+const (
+	AuditCreate       AuditEvent = "create"        // store initialized
+	AuditUnlock       AuditEvent = "unlock"        // store opened with the right password
+	AuditUnlockFailed AuditEvent = "unlock_failed" // wrong password supplied
+	AuditPut          AuditEvent = "put"           // record written
+	AuditDelete       AuditEvent = "delete"        // record deleted
+	AuditExpire       AuditEvent = "expire"        // expired record removed by Sweep
+	AuditMerge        AuditEvent = "merge"         // record changed by Merge
+)
+
+// AuditEntry is one verified entry of the audit log.
+type AuditEntry struct {
+	Seq        uint64     `json:"seq"`
+	Time       time.Time  `json:"time"`
+	Event      AuditEvent `json:"event"`
+	Collection string     `json:"col,omitempty"`
+	ID         string     `json:"id,omitempty"`
+}
+
+// The audit log is a sequence of files, one per entry:
+//
+//	.audit/<seq>.entry   encrypt(entry) || mac
+//	.audit/head          encrypt({seq, mac}) of the latest entry
+//
+// Each mac is HMAC-SHA256 over the previous entry's mac and this entry's
+// ciphertext, so editing, removing or reordering entries breaks the chain.
+// The head records where the chain should end, so dropping entries from
+// the end is detected too. Every append also anchors the head's seq and
+// mac in the store's verification token, which cannot be removed without
+// making the store unopenable, so deleting the whole .audit directory or
+// restoring an older copy of it is detected as well. An attacker who
+// restores an older copy of the verification token along with the log can
+// still roll it back; keep off-device backups if that matters.
+//
+// Failed unlocks happen before any key is available, so they are appended
+// in plaintext to .audit/pending and folded into the chain by the next
+// successful unlock. Deleting the pending file hides those attempts.
+
+// auditLog appends authenticated entries for a Store.
+type auditLog struct {
+	mu     sync.Mutex
+	fs     zfilesystem.ReadWriteFileFS
+	encKey []byte
+	macKey []byte
+	seq    uint64
+	last   []byte // mac of the entry at seq
+
+	// anchor records the head in the verification token
+	anchor func(auditHeadRecord) error
+}
+
+// auditHeadRecord is the encrypted content of the head file.
+type auditHeadRecord struct {
+	Seq uint64 `json:"seq"`
+	MAC []byte `json:"mac"`
+}
+
+// openAuditLog derives the audit keys and loads the head of the chain. If
+// the store's anchor is ahead of the head, the head was removed or rolled
+// back; the log carries on from the anchor so the gap stays visible to
+// AuditLog rather than a fresh chain hiding it.
+func openAuditLog(s *Store) (*auditLog, error) {
+	encKey, err := zcrypto.ExpandKey(s.masterKey, s.salt, []byte(auditEncInfo))
+	if err != nil {
+		return nil, fmt.Errorf("derive audit key: %w", err)
+	}
+	macKey, err := zcrypto.ExpandKey(s.masterKey, s.salt, []byte(auditMACInfo))
+	if err != nil {
+		zcrypto.Erase(encKey)
+		return nil, fmt.Errorf("derive audit key: %w", err)
+	}
+
+	a := &auditLog{fs: s.fs, encKey: encKey, macKey: macKey, anchor: s.writeAuditAnchor}
+
+	head, err := a.readHead()
+	if err != nil {
+		zcrypto.Erase(encKey)
+		zcrypto.Erase(macKey)
+		return nil, err
+	}
+	a.seq, a.last = head.Seq, head.MAC
+	if s.auditAnchor.Seq > 0 && s.auditAnchor.Seq >= head.Seq {
+		a.seq, a.last = s.auditAnchor.Seq, s.auditAnchor.MAC
+	}
+
+	s.mu.Lock()
+	s.subKeys = append(s.subKeys, encKey, macKey)
+	s.mu.Unlock()
+
+	return a, nil
+}
+
+// readHead decrypts the head file. A missing head means an empty log.
+func (a *auditLog) readHead() (auditHeadRecord, error) {
+	ct, err := a.fs.ReadFile(auditHead)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return auditHeadRecord{}, nil
+		}
+		return auditHeadRecord{}, fmt.Errorf("read audit head: %w", err)
+	}
+
+	plain, err := zcrypto.Decrypt(a.encKey, ct)
+	if err != nil {
+		return auditHeadRecord{}, fmt.Errorf("%w: head: %v", ErrAuditTampered, err)
+	}
+
+	var h auditHeadRecord
+	if err := json.Unmarshal(plain, &h); err != nil {
+		return auditHeadRecord{}, fmt.Errorf("%w: head: %v", ErrAuditTampered, err)
+	}
+
+	return h, nil
+}
+
+// append adds an entry to the chain and advances the head.
+func (a *auditLog) append(t time.Time, event AuditEvent, collection, id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e := AuditEntry{
+		Seq:        a.seq + 1,
+		Time:       t,
+		Event:      event,
+		Collection: collection,
+		ID:         id,
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal audit entry: %w", err)
+	}
+
+	ct, err := zcrypto.Encrypt(a.encKey, data)
+	if err != nil {
+		return fmt.Errorf("encrypt audit entry: %w", err)
+	}
+
+	if err := a.fs.MkdirAll(auditDir, 0o700); err != nil {
+		return fmt.Errorf("create audit directory: %w", err)
+	}
+
+	mac := a.chain(a.last, ct)
+	if err := a.fs.WriteFile(auditEntryPath(e.Seq), append(ct, mac...), 0o600); err != nil {
+		return fmt.Errorf("write audit entry: %w", err)
+	}
+
+	head, err := json.Marshal(auditHeadRecord{Seq: e.Seq, MAC: mac})
+	if err != nil {
+		return fmt.Errorf("marshal audit head: %w", err)
+	}
+
+	hct, err := zcrypto.Encrypt(a.encKey, head)
+	if err != nil {
+		return fmt.Errorf("encrypt audit head: %w", err)
+	}
+
+	if err := a.fs.WriteFile(auditHead, hct, 0o600); err != nil {
+		return fmt.Errorf("write audit head: %w", err)
+	}
+
+	a.seq, a.last = e.Seq, mac
+
+	// a head ahead of the anchor is harmless, so the anchor goes last
+	if err := a.anchor(auditHeadRecord{Seq: e.Seq, MAC: mac}); err != nil {
+		return fmt.Errorf("anchor audit head: %w", err)
+	}
+	return nil
+}
+
+// chain computes the mac linking ct to the previous entry.
+func (a *auditLog) chain(prev, ct []byte) []byte {
+	h := hmac.New(sha256.New, a.macKey)
+	h.Write(prev)
+	h.Write(ct)
+	return h.Sum(nil)
+}
+
+// read returns every entry after verifying the whole chain against the head,
+// and the head against the latest position this log has seen anchored.
+func (a *auditLog) read() ([]AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	head, err := a.readHead()
+	if err != nil {
+		return nil, err
+	}
+
+	if head.Seq < a.seq {
+		return nil, fmt.Errorf("%w: head at entry %d, anchored at %d", ErrAuditTampered, head.Seq, a.seq)
+	}
+
+	var paths []string
+	err = a.fs.WalkDir(auditDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path != auditDir {
+			return fs.SkipDir
+		}
+		if strings.HasSuffix(d.Name(), ".entry") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list audit entries: %w", err)
+	}
+
+	if uint64(len(paths)) != head.Seq {
+		return nil, fmt.Errorf("%w: head expects %d entries, found %d", ErrAuditTampered, head.Seq, len(paths))
+	}
+
+	entries := make([]AuditEntry, 0, len(paths))
+	var prev []byte
+	for i := range head.Seq {
+		seq := i + 1
+		data, err := a.fs.ReadFile(auditEntryPath(seq))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("%w: entry %d missing", ErrAuditTampered, seq)
+			}
+			return nil, fmt.Errorf("read audit entry %d: %w", seq, err)
+		}
+
+		if len(data) < sha256.Size {
+			return nil, fmt.Errorf("%w: entry %d truncated", ErrAuditTampered, seq)
+		}
+		ct, mac := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+
+		if !hmac.Equal(mac, a.chain(prev, ct)) {
+			return nil, fmt.Errorf("%w: entry %d breaks the chain", ErrAuditTampered, seq)
+		}
+
+		plain, err := zcrypto.Decrypt(a.encKey, ct)
+		if err != nil {
+			return nil, fmt.Errorf("%w: entry %d: %v", ErrAuditTampered, seq, err)
+		}
+
+		var e AuditEntry
+		if err := json.Unmarshal(plain, &e); err != nil || e.Seq != seq {
+			return nil, fmt.Errorf("%w: entry %d malformed", ErrAuditTampered, seq)
+		}
+
+		if seq == a.seq && !hmac.Equal(mac, a.last) {
+			return nil, fmt.Errorf("%w: entry %d does not match the anchor", ErrAuditTampered, seq)
+		}
+
+		entries = append(entries, e)
+		prev = mac
+	}
+
+	if !bytes.Equal(prev, head.MAC) {
+		return nil, fmt.Errorf("%w: head does not match last entry", ErrAuditTampered)
+	}
+
+	return entries, nil
+}
+
+// ingestPending moves failed unlocks recorded while locked into the chain.
+func (a *auditLog) ingestPending() error {
+	data, err := a.fs.ReadFile(auditPending)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("read pending audit entries: %w", err)
+	}
+
+	for line := range strings.Lines(string(data)) {
+		t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(line))
+		if err != nil {
+			// an unparsable line is still evidence of an attempt
+			t = time.Time{}
+		}
+		if err := a.append(t, AuditUnlockFailed, "", ""); err != nil {
+			return err
+		}
+	}
+
+	if err := a.fs.Remove(auditPending); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove pending audit entries: %w", err)
+	}
+
+	return nil
+}
+
+// recordFailedUnlock appends a failed unlock to the plaintext pending file.
+func recordFailedUnlock(fsys zfilesystem.ReadWriteFileFS, t time.Time) error {
+	data, err := fsys.ReadFile(auditPending)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("read pending audit entries: %w", err)
+	}
+
+	if err := fsys.MkdirAll(auditDir, 0o700); err != nil {
+		return fmt.Errorf("create audit directory: %w", err)
+	}
+
+	data = append(data, t.UTC().Format(time.RFC3339Nano)+"\n"...)
+	if err := fsys.WriteFile(auditPending, data, 0o600); err != nil {
+		return fmt.Errorf("write pending audit entries: %w", err)
+	}
+
+	return nil
+}
+
+// auditEntryPath returns the file holding entry seq. Zero padding keeps
+// lexical and numeric order the same.
+func auditEntryPath(seq uint64) string {
+	return filepath.Join(auditDir, fmt.Sprintf("%016x.entry", seq))
+}
+
+// audit appends an entry if the audit log is enabled.
+func (s *Store) audit(event AuditEvent, collection, id string) error {
+	if s.auditLog == nil {
+		return nil
+	}
+	if err := s.auditLog.append(s.now(), event, collection, id); err != nil {
+		return fmt.Errorf("%w: %w", ErrAuditNotRecorded, err)
+	}
+	return nil
+}
+
+// writeAuditAnchor replaces the verification token with one that also
+// records head, so the audit log cannot be removed or rolled back without
+// replacing the token too. The token is replaced by rename, never
+// rewritten in place: a damaged token would lock the owner out.
+func (s *Store) writeAuditAnchor(head auditHeadRecord) error {
+	data, err := json.Marshal(head)
+	if err != nil {
+		return fmt.Errorf("marshal audit anchor: %w", err)
+	}
+
+	token, err := zcrypto.Encrypt(s.masterKey, append([]byte(verifyText+"\x00"), data...))
+	if err != nil {
+		return fmt.Errorf("encrypt verification token: %w", err)
+	}
+
+	if err := replaceFile(s.fs, verifyFile, token); err != nil {
+		return fmt.Errorf("write verification token: %w", err)
+	}
+	return nil
+}
+
+// AuditLog reads and verifies the store's audit log, returning its entries
+// oldest first. It returns an error wrapping ErrAuditTampered if any entry
+// was edited, removed or reordered, or the log was truncated. The log is
+// only written when the store is opened with WithAuditLog; AuditLog returns
+// nil if it was never enabled.
+func (s *Store) AuditLog() ([]AuditEntry, error) {
+	a, err := s.auditReader()
+	if err != nil {
+		return nil, err
+	}
+	return a.read()
+}
+
+// auditReader returns the audit log, opening it read-only on first use if
+// the store was opened without WithAuditLog.
+func (s *Store) auditReader() (*auditLog, error) {
+	if s.auditLog != nil {
+		return s.auditLog, nil
+	}
+
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	if s.auditView == nil {
+		a, err := openAuditLog(s)
+		if err != nil {
+			return nil, err
+		}
+		s.auditView = a
+	}
+	return s.auditView, nil
+}
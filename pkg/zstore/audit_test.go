@@ -0,0 +1,398 @@
+package zstore_test
+
+import (
+	"context"
+	"errors"
+	iofs "io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zfilesystem"
+	"github.com/zarlcorp/core/pkg/zstore"
+)
+
+func auditEvents(t *testing.T, s *zstore.Store) []zstore.AuditEvent {
+	t.Helper()
+
+	entries, err := s.AuditLog()
+	if err != nil {
+		t.Fatalf("audit log: %v", err)
+	}
+
+	events := make([]zstore.AuditEvent, len(entries))
+	for i, e := range entries {
+		if e.Seq != uint64(i+1) {
+			t.Fatalf("entry %d has seq %d", i, e.Seq)
+		}
+		events[i] = e.Event
+	}
+	return events
+}
+
+func assertEvents(t *testing.T, got []zstore.AuditEvent, want ...zstore.AuditEvent) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	fs := zfilesystem.NewMemFS()
+	password := []byte("password")
+
+	s1, err := zstore.Open(fs, password, zstore.WithAuditLog())
+	if err != nil {
+		t.Fatalf("first open: %v", err)
+	}
+
+	col, err := zstore.NewCollection[string](s1, "notes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.Put("n1", "hello"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := col.Delete("n1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	s1.Close()
+
+	for range 2 {
+		if _, err := zstore.Open(fs, []byte("wrong"), zstore.WithAuditLog()); !errors.Is(err, zstore.ErrWrongPassword) {
+			t.Fatalf("expected ErrWrongPassword, got %v", err)
+		}
+	}
+
+	s2, err := zstore.Open(fs, password, zstore.WithAuditLog())
+	if err != nil {
+		t.Fatalf("second open: %v", err)
+	}
+	defer s2.Close()
+
+	assertEvents(t, auditEvents(t, s2),
+		zstore.AuditCreate,
+		zstore.AuditPut,
+		zstore.AuditDelete,
+		zstore.AuditUnlockFailed,
+		zstore.AuditUnlockFailed,
+		zstore.AuditUnlock,
+	)
+
+	entries, err := s2.AuditLog()
+	if err != nil {
+		t.Fatalf("audit log: %v", err)
+	}
+	if e := entries[1]; e.Collection != "notes" || e.ID != "n1" {
+		t.Fatalf("put entry = %+v", e)
+	}
+	if entries[3].Time.IsZero() {
+		t.Fatal("failed unlock has no timestamp")
+	}
+}
+
+func TestAuditLogExpire(t *testing.T) {
+	s, err := zstore.Open(zfilesystem.NewMemFS(), []byte("password"), zstore.WithAuditLog())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	clock := newFakeClock()
+	s.SetNowForTest(clock.Now)
+
+	col, err := zstore.NewCollection[string](s, "codes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.PutWithTTL("otp", "123456", time.Minute); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	clock.Advance(time.Hour)
+	if _, err := s.Sweep(context.Background()); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+
+	assertEvents(t, auditEvents(t, s), zstore.AuditCreate, zstore.AuditPut, zstore.AuditExpire)
+}
+
+func TestAuditLogDisabled(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	col, err := zstore.NewCollection[string](s, "notes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.Put("n1", "hello"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	if events := auditEvents(t, s); len(events) != 0 {
+		t.Fatalf("events = %v, want none", events)
+	}
+}
+
+func TestAuditLogTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		mangle func(t *testing.T, fs *zfilesystem.MemFS)
+	}{
+		{
+			name: "edited entry",
+			mangle: func(t *testing.T, fs *zfilesystem.MemFS) {
+				path := ".audit/0000000000000002.entry"
+				data, err := fs.ReadFile(path)
+				if err != nil {
+					t.Fatalf("read: %v", err)
+				}
+				data[20] ^= 1
+				if err := fs.WriteFile(path, data, 0o600); err != nil {
+					t.Fatalf("write: %v", err)
+				}
+			},
+		},
+		{
+			name: "removed entry",
+			mangle: func(t *testing.T, fs *zfilesystem.MemFS) {
+				if err := fs.Remove(".audit/0000000000000002.entry"); err != nil {
+					t.Fatalf("remove: %v", err)
+				}
+			},
+		},
+		{
+			name: "truncated log",
+			mangle: func(t *testing.T, fs *zfilesystem.MemFS) {
+				if err := fs.Remove(".audit/0000000000000003.entry"); err != nil {
+					t.Fatalf("remove: %v", err)
+				}
+			},
+		},
+		{
+			name: "swapped entries",
+			mangle: func(t *testing.T, fs *zfilesystem.MemFS) {
+				a, _ := fs.ReadFile(".audit/0000000000000001.entry")
+				b, _ := fs.ReadFile(".audit/0000000000000002.entry")
+				_ = fs.WriteFile(".audit/0000000000000001.entry", b, 0o600)
+				_ = fs.WriteFile(".audit/0000000000000002.entry", a, 0o600)
+			},
+		},
+		{
+			name: "removed head",
+			mangle: func(t *testing.T, fs *zfilesystem.MemFS) {
+				if err := fs.Remove(".audit/head"); err != nil {
+					t.Fatalf("remove: %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := zfilesystem.NewMemFS()
+			s, err := zstore.Open(fs, []byte("password"), zstore.WithAuditLog())
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			defer s.Close()
+
+			col, err := zstore.NewCollection[string](s, "notes")
+			if err != nil {
+				t.Fatalf("new collection: %v", err)
+			}
+			for _, id := range []string{"a", "b"} {
+				if err := col.Put(id, "v"); err != nil {
+					t.Fatalf("put: %v", err)
+				}
+			}
+
+			tt.mangle(t, fs)
+
+			if _, err := s.AuditLog(); !errors.Is(err, zstore.ErrAuditTampered) {
+				t.Fatalf("expected ErrAuditTampered, got %v", err)
+			}
+		})
+	}
+}
+
+// snapshotAudit copies every file under .audit.
+func snapshotAudit(t *testing.T, fs *zfilesystem.MemFS) map[string][]byte {
+	t.Helper()
+	files := make(map[string][]byte)
+	err := fs.WalkDir(".audit", func(path string, d iofs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(path)
+		files[path] = data
+		return err
+	})
+	if err != nil {
+		t.Fatalf("snapshot audit log: %v", err)
+	}
+	return files
+}
+
+// restoreAudit replaces the files under .audit with files.
+func restoreAudit(t *testing.T, fs *zfilesystem.MemFS, files map[string][]byte) {
+	t.Helper()
+	for path := range snapshotAudit(t, fs) {
+		if err := fs.Remove(path); err != nil {
+			t.Fatalf("remove %s: %v", path, err)
+		}
+	}
+	for path, data := range files {
+		if err := fs.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+}
+
+func TestAuditLogRollback(t *testing.T) {
+	tests := []struct {
+		name    string
+		restore func(before map[string][]byte) map[string][]byte
+	}{
+		{"deleted log", func(map[string][]byte) map[string][]byte { return nil }},
+		{"older log", func(before map[string][]byte) map[string][]byte { return before }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := zfilesystem.NewMemFS()
+			password := []byte("password")
+
+			s, err := zstore.Open(fs, password, zstore.WithAuditLog())
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			col, err := zstore.NewCollection[string](s, "notes")
+			if err != nil {
+				t.Fatalf("new collection: %v", err)
+			}
+			if err := col.Put("a", "v"); err != nil {
+				t.Fatalf("put: %v", err)
+			}
+			before := snapshotAudit(t, fs)
+			if err := col.Put("b", "v"); err != nil {
+				t.Fatalf("put: %v", err)
+			}
+			s.Close()
+
+			restoreAudit(t, fs, tt.restore(before))
+
+			// reading alone, and reopening with auditing, both notice
+			s, err = zstore.Open(fs, password)
+			if err != nil {
+				t.Fatalf("open without audit: %v", err)
+			}
+			if _, err := s.AuditLog(); !errors.Is(err, zstore.ErrAuditTampered) {
+				t.Fatalf("read-only: expected ErrAuditTampered, got %v", err)
+			}
+			s.Close()
+
+			s, err = zstore.Open(fs, password, zstore.WithAuditLog())
+			if err != nil {
+				t.Fatalf("reopen: %v", err)
+			}
+			defer s.Close()
+			if _, err := s.AuditLog(); !errors.Is(err, zstore.ErrAuditTampered) {
+				t.Fatalf("reopened: expected ErrAuditTampered, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAuditLogReadOnlyKeys(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	if _, err := s.AuditLog(); err != nil {
+		t.Fatalf("audit log: %v", err)
+	}
+	n := len(s.SubKeysForTest())
+
+	for range 3 {
+		if _, err := s.AuditLog(); err != nil {
+			t.Fatalf("audit log: %v", err)
+		}
+	}
+	if got := len(s.SubKeysForTest()); got != n {
+		t.Fatalf("sub-keys grew from %d to %d", n, got)
+	}
+}
+
+// fullDiskFS fails writes to files under names starting with prefix
+// halfway through, as a full disk or a crash would.
+type fullDiskFS struct {
+	*zfilesystem.MemFS
+	prefix string
+}
+
+func (f *fullDiskFS) WriteFile(name string, data []byte, perm iofs.FileMode) error {
+	if strings.HasPrefix(name, f.prefix) {
+		_ = f.MemFS.WriteFile(name, data[:len(data)/2], perm)
+		return errors.New("no space left on device")
+	}
+	return f.MemFS.WriteFile(name, data, perm)
+}
+
+func (f *fullDiskFS) OpenFile(name string, flag int, perm iofs.FileMode) (zfilesystem.File, error) {
+	file, err := f.MemFS.OpenFile(name, flag, perm)
+	if err != nil || !strings.HasPrefix(name, f.prefix) {
+		return file, err
+	}
+	return &fullDiskFile{File: file}, nil
+}
+
+type fullDiskFile struct {
+	zfilesystem.File
+}
+
+func (f *fullDiskFile) Write(p []byte) (int, error) {
+	n, _ := f.File.Write(p[:len(p)/2])
+	return n, errors.New("no space left on device")
+}
+
+func TestAuditAnchorFailedWrite(t *testing.T) {
+	mfs := zfilesystem.NewMemFS()
+	password := []byte("password")
+
+	s, err := zstore.Open(mfs, password, zstore.WithAuditLog())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	col, err := zstore.NewCollection[string](s, "notes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.Put("a", "v"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	s.Close()
+
+	// the disk fills up while the verification token is being replaced
+	full := &fullDiskFS{MemFS: mfs, prefix: "verify"}
+	s, err = zstore.Open(full, password, zstore.WithAuditLog())
+	if !errors.Is(err, zstore.ErrAuditNotRecorded) {
+		t.Fatalf("open on a full disk: expected ErrAuditNotRecorded, got %v", err)
+	}
+	if s != nil {
+		s.Close()
+	}
+
+	s, err = zstore.Open(mfs, password, zstore.WithAuditLog())
+	if err != nil {
+		t.Fatalf("reopen after a failed token write: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.AuditLog(); err != nil {
+		t.Fatalf("audit log: %v", err)
+	}
+}
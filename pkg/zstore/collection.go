@@ -7,100 +7,146 @@ import (
 	"io/fs"
 	"path/filepath"
 	"strings"
-
-	"github.com/zarlcorp/core/pkg/zcrypto"
+	"sync"
+	"time"
 )
 
 // Collection is a typed, encrypted key-value collection within a Store.
 // Each collection has its own HKDF-derived sub-key so compromising one
 // collection does not expose another.
 type Collection[V any] struct {
+	bucket
+}
+
+// bucket holds the untyped state and record operations shared by
+// Collection and store-wide operations such as Sweep and Merge.
+type bucket struct {
 	store *Store
 	name  string
 	key   []byte
+	mu    *sync.Mutex // shared by every bucket of the same collection
 }
 
 // NewCollection returns a typed collection backed by the given store.
 // It creates a subdirectory for the collection and derives a sub-key
 // via HKDF using the collection name as the info parameter.
 func NewCollection[V any](store *Store, name string) (*Collection[V], error) {
-	if err := store.fs.MkdirAll(name, 0o700); err != nil {
+	b, err := store.bucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Collection[V]{bucket: *b}, nil
+}
+
+// bucket returns the untyped bucket for the named collection, creating its
+// directory if needed.
+func (s *Store) bucket(name string) (*bucket, error) {
+	if err := s.fs.MkdirAll(name, 0o700); err != nil {
 		return nil, fmt.Errorf("create collection directory: %w", err)
 	}
+	return s.openBucket(name)
+}
 
-	key, err := zcrypto.ExpandKey(store.masterKey, store.salt, []byte(name))
+// openBucket returns the untyped bucket for the named collection without
+// touching the filesystem, for reading a store that must not change.
+func (s *Store) openBucket(name string) (*bucket, error) {
+	key, err := s.collectionKey(name)
 	if err != nil {
 		return nil, fmt.Errorf("derive collection key: %w", err)
 	}
 
-	store.subKeys = append(store.subKeys, key)
-
-	return &Collection[V]{store: store, name: name, key: key}, nil
+	return &bucket{store: s, name: name, key: key, mu: s.collectionLock(name)}, nil
 }
 
 // Put encrypts and stores a value under the given id.
 func (c *Collection[V]) Put(id string, value V) error {
-	data, err := json.Marshal(value)
-	if err != nil {
-		return fmt.Errorf("marshal value: %w", err)
+	return c.put(id, value, time.Time{})
+}
+
+// PutWithTTL encrypts and stores a value that expires after ttl. Once
+// expired, Get and List treat the record as not found; it is removed from
+// disk by Store.Sweep or the janitor. The expiry is stored inside the
+// ciphertext. A non-positive ttl stores a value that never expires.
+func (c *Collection[V]) PutWithTTL(id string, value V, ttl time.Duration) error {
+	var expires time.Time
+	if ttl > 0 {
+		expires = c.store.now().Add(ttl)
 	}
+	return c.put(id, value, expires)
+}
 
-	ct, err := zcrypto.Encrypt(c.key, data)
+// put marshals value into a record envelope, encrypts it, and writes it as
+// a new revision of id.
+func (c *Collection[V]) put(id string, value V, expires time.Time) error {
+	data, err := json.Marshal(value)
 	if err != nil {
-		return fmt.Errorf("encrypt value: %w", err)
+		return fmt.Errorf("marshal value: %w", err)
 	}
 
-	path := filepath.Join(c.name, id+".enc")
-	if err := c.store.fs.WriteFile(path, ct, 0o600); err != nil {
-		return fmt.Errorf("write %s: %w", path, err)
+	r := record{Value: data, Expires: expires}
+	if err := c.commit(id, &r); err != nil {
+		return err
 	}
 
-	return nil
+	return c.store.audit(AuditPut, c.name, id)
 }
 
 // Get reads, decrypts, and unmarshals a value by id.
-// Returns ErrNotFound if the id does not exist.
+// Returns ErrNotFound if the id does not exist or has expired.
 func (c *Collection[V]) Get(id string) (V, error) {
 	var zero V
 
-	path := filepath.Join(c.name, id+".enc")
-	ct, err := c.store.fs.ReadFile(path)
-	if err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
-			return zero, ErrNotFound
-		}
-		return zero, fmt.Errorf("read %s: %w", path, err)
-	}
-
-	plain, err := zcrypto.Decrypt(c.key, ct)
+	r, err := c.load(id)
 	if err != nil {
-		return zero, fmt.Errorf("decrypt %s: %w", path, err)
+		return zero, err
 	}
 
 	var v V
-	if err := json.Unmarshal(plain, &v); err != nil {
-		return zero, fmt.Errorf("unmarshal %s: %w", path, err)
+	if err := json.Unmarshal(r.Value, &v); err != nil {
+		return zero, fmt.Errorf("unmarshal %s: %w", c.path(id), err)
 	}
 
 	return v, nil
 }
 
-// Delete removes a value by id. Returns ErrNotFound if the id does not exist.
+// Delete removes a value by id along with any attachments. A small
+// encrypted tombstone is kept in its place so the deletion carries over
+// when replicas are merged; Sweep removes it once it is older than the
+// WithTombstoneHorizon. Returns ErrNotFound if the id does not exist or
+// has expired, as Get does.
 func (c *Collection[V]) Delete(id string) error {
-	path := filepath.Join(c.name, id+".enc")
-	if err := c.store.fs.Remove(path); err != nil {
-		if errors.Is(err, fs.ErrNotExist) {
-			return ErrNotFound
-		}
-		return fmt.Errorf("remove %s: %w", path, err)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, ok, err := c.head(id)
+	if err != nil {
+		return err
 	}
-	return nil
+	if !ok || prev.Deleted || prev.expired(c.store.now()) {
+		return ErrNotFound
+	}
+
+	tomb := record{Deleted: true}
+	if err := newRevision(&tomb, c.store.now(), prev); err != nil {
+		return err
+	}
+
+	if err := c.write(id, tomb); err != nil {
+		return err
+	}
+
+	if err := c.removeAttachments(id); err != nil {
+		return fmt.Errorf("remove attachments: %w", err)
+	}
+
+	return c.store.audit(AuditDelete, c.name, id)
 }
 
-// List decrypts and returns all values in the collection.
+// List decrypts and returns all unexpired values in the collection.
 // The order is not guaranteed.
 func (c *Collection[V]) List() ([]V, error) {
 	var values []V
+	now := c.store.now()
 
 	err := c.store.fs.WalkDir(c.name, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -118,13 +164,17 @@ func (c *Collection[V]) List() ([]V, error) {
 			return fmt.Errorf("read %s: %w", path, err)
 		}
 
-		plain, err := zcrypto.Decrypt(c.key, ct)
+		r, err := openRecord(c.key, ct)
 		if err != nil {
-			return fmt.Errorf("decrypt %s: %w", path, err)
+			return fmt.Errorf("open %s: %w", path, err)
+		}
+
+		if r.expired(now) {
+			return nil
 		}
 
 		var v V
-		if err := json.Unmarshal(plain, &v); err != nil {
+		if err := json.Unmarshal(r.Value, &v); err != nil {
 			return fmt.Errorf("unmarshal %s: %w", path, err)
 		}
 
@@ -139,6 +189,7 @@ func (c *Collection[V]) List() ([]V, error) {
 }
 
 // Len returns the number of encrypted entries without decrypting them.
+// Expired records are counted until they are swept.
 func (c *Collection[V]) Len() (int, error) {
 	count := 0
 
@@ -157,3 +208,111 @@ func (c *Collection[V]) Len() (int, error) {
 
 	return count, nil
 }
+
+// path returns the filesystem path of the record with the given id.
+func (b *bucket) path(id string) string {
+	return filepath.Join(b.name, id+".enc")
+}
+
+// tombPath returns the filesystem path of the tombstone for id.
+func (b *bucket) tombPath(id string) string {
+	return filepath.Join(b.name, id+".tomb")
+}
+
+// load reads and decrypts the live record for id.
+// Returns ErrNotFound if the id does not exist or has expired.
+func (b *bucket) load(id string) (record, error) {
+	r, err := b.read(b.path(id))
+	if err != nil {
+		return record{}, err
+	}
+
+	if r.expired(b.store.now()) {
+		return record{}, ErrNotFound
+	}
+
+	return r, nil
+}
+
+// head returns the latest revision of id, live or tombstone, regardless of
+// expiry. ok is false if the id has never been written.
+func (b *bucket) head(id string) (r record, ok bool, err error) {
+	for _, path := range []string{b.path(id), b.tombPath(id)} {
+		r, err := b.read(path)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return record{}, false, err
+		}
+		return r, true, nil
+	}
+	return record{}, false, nil
+}
+
+// read decrypts the record stored at path.
+func (b *bucket) read(path string) (record, error) {
+	ct, err := b.store.fs.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return record{}, ErrNotFound
+		}
+		return record{}, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	r, err := openRecord(b.key, ct)
+	if err != nil {
+		return record{}, fmt.Errorf("open %s: %w", path, err)
+	}
+
+	return r, nil
+}
+
+// revise stamps r as the next revision of id.
+func (b *bucket) revise(id string, r *record) error {
+	prev, ok, err := b.head(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return newRevision(r, b.store.now())
+	}
+	return newRevision(r, b.store.now(), prev)
+}
+
+// commit stamps r as the next revision of id and writes it, holding the
+// collection lock so the read of the previous revision and the write are
+// not interleaved with another writer or Sweep.
+func (b *bucket) commit(id string, r *record) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.revise(id, r); err != nil {
+		return err
+	}
+	return b.write(id, *r)
+}
+
+// write encrypts r and stores it as the current revision of id, replacing
+// either a live record or a tombstone.
+func (b *bucket) write(id string, r record) error {
+	ct, err := sealRecord(b.key, r)
+	if err != nil {
+		return fmt.Errorf("encrypt value: %w", err)
+	}
+
+	path, stale := b.path(id), b.tombPath(id)
+	if r.Deleted {
+		path, stale = stale, path
+	}
+
+	if err := b.store.fs.WriteFile(path, ct, 0o600); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+
+	if err := b.store.fs.Remove(stale); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove %s: %w", stale, err)
+	}
+
+	return nil
+}
@@ -3,12 +3,16 @@ module github.com/zarlcorp/core/pkg/zstore
 go 1.26.0
 
 require (
-	github.com/zarlcorp/core/pkg/zcrypto v0.1.0
-	github.com/zarlcorp/core/pkg/zfilesystem v0.1.0
+	github.com/zarlcorp/core/pkg/zcrypto v0.2.0
+	github.com/zarlcorp/core/pkg/zfilesystem v0.2.0
 )
 
 require (
+	filippo.io/age v1.3.1 // indirect
+	filippo.io/edwards25519 v1.1.0 // indirect
+	filippo.io/hpke v0.4.0 // indirect
 	github.com/zarlcorp/core/pkg/zsync v0.1.0 // indirect
 	golang.org/x/crypto v0.48.0 // indirect
 	golang.org/x/sys v0.41.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
 )
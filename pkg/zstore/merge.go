@@ -0,0 +1,319 @@
+package zstore
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrKeyMismatch is returned by Merge when the two stores were not opened
+// with the same master key.
+var ErrKeyMismatch = errors.New("stores do not share a master key")
+
+// mergeMu serialises Merge calls. Each holds a collection lock in both
+// stores at once, so two merges in opposite directions could otherwise
+// deadlock.
+var mergeMu sync.Mutex
+
+// RecordRef identifies a record within a store.
+type RecordRef struct {
+	Collection string
+	ID         string
+}
+
+// Conflict describes a record edited independently on both replicas.
+type Conflict struct {
+	RecordRef
+
+	// FromSrc reports whether the version kept under ID came from src.
+	FromSrc bool
+
+	// CopyID is the id under which the losing edit was preserved in the
+	// same collection. It is empty when the losing side had deleted the
+	// record, since there is no edit to keep.
+	CopyID string
+}
+
+// MergeReport lists what Merge changed in dst.
+type MergeReport struct {
+	Added     []RecordRef // records present only in src
+	Updated   []RecordRef // records src had edited since dst's version
+	Deleted   []RecordRef // records src had deleted since dst's version
+	Conflicts []Conflict  // records edited on both sides
+}
+
+// Merge reconciles src into dst, two replicas of the same store that share
+// a master key, typically copies of one store directory that were edited on
+// different machines. Src is not modified; run Merge in the other direction
+// as well to bring both replicas to the same state.
+//
+// Each record carries an encrypted revision history. When one side's
+// version descends from the other's, the newer version wins. When both
+// sides changed a record independently, the most recently modified version
+// is kept under the original id and the other is preserved under a new id
+// reported in the Conflict, so neither side's edits are silently dropped.
+// An edit always wins over a concurrent deletion. Attachments follow the
+// version of the record they belong to.
+//
+// Records on both sides are processed in a deterministic order. Each is
+// reconciled under the collection lock of both stores, so Put, Delete and
+// Sweep on either store wait for it rather than interleaving; attachments
+// must not be changed while Merge runs.
+func Merge(dst, src *Store) (*MergeReport, error) {
+	if subtle.ConstantTimeCompare(dst.masterKey, src.masterKey) != 1 {
+		return nil, ErrKeyMismatch
+	}
+	if dst == src {
+		return &MergeReport{}, nil
+	}
+
+	mergeMu.Lock()
+	defer mergeMu.Unlock()
+
+	refs, err := src.records()
+	if err != nil {
+		return nil, fmt.Errorf("list source records: %w", err)
+	}
+
+	m := merger{dst: dst, src: src, report: &MergeReport{}}
+	for _, ref := range refs {
+		changed, err := m.merge(ref)
+		if err != nil {
+			return nil, fmt.Errorf("merge %s/%s: %w", ref.Collection, ref.ID, err)
+		}
+		if changed {
+			if err := dst.audit(AuditMerge, ref.Collection, ref.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return m.report, nil
+}
+
+// merger carries the state of one Merge call.
+type merger struct {
+	dst, src *Store
+	report   *MergeReport
+}
+
+// merge reconciles a single record and reports whether dst changed.
+func (m *merger) merge(ref RecordRef) (bool, error) {
+	sb, err := m.src.openBucket(ref.Collection)
+	if err != nil {
+		return false, err
+	}
+	db, err := m.dst.bucket(ref.Collection)
+	if err != nil {
+		return false, err
+	}
+
+	// hold both locks from reading the heads until dst is written, so a
+	// concurrent write on either side is neither lost nor half copied
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	s, _, err := sb.head(ref.ID)
+	if err != nil {
+		return false, err
+	}
+	if !s.Deleted && s.expired(m.src.now()) {
+		return false, nil
+	}
+
+	d, ok, err := db.head(ref.ID)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case !ok:
+		if err := m.take(sb, db, ref.ID, s); err != nil {
+			return false, err
+		}
+		if !s.Deleted {
+			m.report.Added = append(m.report.Added, ref)
+		}
+		return true, nil
+
+	case same(s, d), d.descends(s):
+		return false, nil
+
+	case s.descends(d):
+		if err := m.take(sb, db, ref.ID, s); err != nil {
+			return false, err
+		}
+		if s.Deleted {
+			m.report.Deleted = append(m.report.Deleted, ref)
+		} else {
+			m.report.Updated = append(m.report.Updated, ref)
+		}
+		return true, nil
+	}
+
+	return true, m.resolve(sb, db, ref, s, d)
+}
+
+// resolve handles a record changed independently on both sides.
+func (m *merger) resolve(sb, db *bucket, ref RecordRef, s, d record) error {
+	// concurrent deletions agree; just join their histories
+	if s.Deleted && d.Deleted {
+		later, earlier := d, s
+		if newer(s, d) {
+			later, earlier = s, d
+		}
+		tomb := record{Deleted: true}
+		derivedRevision(&tomb, "merge", later.Modified, later, earlier)
+		return db.write(ref.ID, tomb)
+	}
+
+	fromSrc := wins(s, d)
+	winner, loser := d, s
+	winBucket, loseBucket := db, sb
+	if fromSrc {
+		winner, loser = s, d
+		winBucket, loseBucket = sb, db
+	}
+
+	c := Conflict{RecordRef: ref, FromSrc: fromSrc}
+
+	if !loser.Deleted {
+		c.CopyID = conflictID(ref.ID, loser.Rev)
+
+		cp := loser
+		derivedRevision(&cp, "copy", loser.Modified, loser)
+		if err := db.write(c.CopyID, cp); err != nil {
+			return err
+		}
+		if err := copyAttachments(loseBucket, ref.ID, db, c.CopyID); err != nil {
+			return fmt.Errorf("copy attachments: %w", err)
+		}
+	}
+
+	// the merged revision descends from both sides, so the next merge in
+	// either direction fast-forwards instead of conflicting again
+	merged := winner
+	derivedRevision(&merged, "merge", winner.Modified, winner, loser)
+
+	if fromSrc {
+		if err := db.removeAttachments(ref.ID); err != nil {
+			return fmt.Errorf("remove attachments: %w", err)
+		}
+		if err := copyAttachments(winBucket, ref.ID, db, ref.ID); err != nil {
+			return fmt.Errorf("copy attachments: %w", err)
+		}
+	}
+
+	if err := db.write(ref.ID, merged); err != nil {
+		return err
+	}
+
+	m.report.Conflicts = append(m.report.Conflicts, c)
+	return nil
+}
+
+// take replaces dst's version of id, and its attachments, with src's.
+func (m *merger) take(sb, db *bucket, id string, s record) error {
+	if err := db.removeAttachments(id); err != nil {
+		return fmt.Errorf("remove attachments: %w", err)
+	}
+
+	if !s.Deleted {
+		if err := copyAttachments(sb, id, db, id); err != nil {
+			return fmt.Errorf("copy attachments: %w", err)
+		}
+	}
+
+	return db.write(id, s)
+}
+
+// records lists every record and tombstone in the store in lexical order.
+func (s *Store) records() ([]RecordRef, error) {
+	seen := make(map[RecordRef]bool)
+
+	err := s.fs.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		name := filepath.Dir(path)
+		if name == "." {
+			return nil
+		}
+
+		id, ok := strings.CutSuffix(d.Name(), ".enc")
+		if !ok {
+			id, ok = strings.CutSuffix(d.Name(), ".tomb")
+		}
+		if ok {
+			seen[RecordRef{Collection: name, ID: id}] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]RecordRef, 0, len(seen))
+	for ref := range seen {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Collection != refs[j].Collection {
+			return refs[i].Collection < refs[j].Collection
+		}
+		return refs[i].ID < refs[j].ID
+	})
+
+	return refs, nil
+}
+
+// same reports whether two versions are identical. Records written before
+// revisions existed have no Rev and are compared by content.
+func same(a, b record) bool {
+	if a.Rev != "" || b.Rev != "" {
+		return a.Rev == b.Rev
+	}
+	return a.Deleted == b.Deleted && a.Expires.Equal(b.Expires) && bytes.Equal(a.Value, b.Value)
+}
+
+// wins reports whether s is kept over d in a conflict: edits beat
+// deletions, then the newer version wins.
+func wins(s, d record) bool {
+	if s.Deleted != d.Deleted {
+		return !s.Deleted
+	}
+	return newer(s, d)
+}
+
+// newer reports whether a was modified after b, breaking ties by revision
+// and then content so every replica makes the same choice.
+func newer(a, b record) bool {
+	switch {
+	case !a.Modified.Equal(b.Modified):
+		return a.Modified.After(b.Modified)
+	case a.Rev != b.Rev:
+		return a.Rev > b.Rev
+	default:
+		return bytes.Compare(a.Value, b.Value) > 0
+	}
+}
+
+// conflictID returns the id a losing edit is preserved under.
+func conflictID(id, rev string) string {
+	if rev == "" {
+		rev = "legacy"
+	}
+	return id + ".conflict-" + rev[:min(len(rev), 8)]
+}
@@ -0,0 +1,422 @@
+package zstore_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zfilesystem"
+	"github.com/zarlcorp/core/pkg/zstore"
+)
+
+// replicaPair is a store copied to a second filesystem and opened on both.
+type replicaPair struct {
+	a, b   *zstore.Collection[string]
+	sa, sb *zstore.Store
+	fa, fb *zfilesystem.MemFS
+	clock  *fakeClock
+}
+
+// replicas opens a store, lets setup populate it, then copies its directory
+// to a second filesystem, like a user copying a vault between machines.
+func replicas(t *testing.T, setup func(*zstore.Collection[string])) *replicaPair {
+	t.Helper()
+
+	p := &replicaPair{clock: newFakeClock(), fa: zfilesystem.NewMemFS()}
+	p.sa, p.a = openReplica(t, p.fa, p.clock)
+	if setup != nil {
+		setup(p.a)
+	}
+
+	p.fb = copyFS(t, p.fa)
+	p.sb, p.b = openReplica(t, p.fb, p.clock)
+	return p
+}
+
+// openReplica opens the notes collection of the store on mfs.
+func openReplica(t *testing.T, mfs *zfilesystem.MemFS, clock *fakeClock) (*zstore.Store, *zstore.Collection[string]) {
+	t.Helper()
+
+	s, err := zstore.Open(mfs, []byte("password"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.SetNowForTest(clock.Now)
+
+	c, err := zstore.NewCollection[string](s, "notes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	return s, c
+}
+
+// copyFS returns a copy of every file in src.
+func copyFS(t *testing.T, src *zfilesystem.MemFS) *zfilesystem.MemFS {
+	t.Helper()
+
+	dst := zfilesystem.NewMemFS()
+	err := src.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := src.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return dst.WriteFile(path, data, 0o600)
+	})
+	if err != nil {
+		t.Fatalf("copy filesystem: %v", err)
+	}
+	return dst
+}
+
+func mustPut(t *testing.T, c *zstore.Collection[string], id, v string) {
+	t.Helper()
+	if err := c.Put(id, v); err != nil {
+		t.Fatalf("put %s: %v", id, err)
+	}
+}
+
+func mustMerge(t *testing.T, dst, src *zstore.Store) *zstore.MergeReport {
+	t.Helper()
+	r, err := zstore.Merge(dst, src)
+	if err != nil {
+		t.Fatalf("merge: %v", err)
+	}
+	return r
+}
+
+func sortedList(t *testing.T, c *zstore.Collection[string]) []string {
+	t.Helper()
+	got, err := c.List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestMergeKeyMismatch(t *testing.T) {
+	s1 := openTestStore(t)
+	defer s1.Close()
+
+	s2, err := zstore.Open(zfilesystem.NewMemFS(), []byte("other-password"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s2.Close()
+
+	if _, err := zstore.Merge(s1, s2); !errors.Is(err, zstore.ErrKeyMismatch) {
+		t.Fatalf("expected ErrKeyMismatch, got %v", err)
+	}
+}
+
+func TestMergeFastForward(t *testing.T) {
+	p := replicas(t, func(c *zstore.Collection[string]) {
+		mustPut(t, c, "edit", "v1")
+		mustPut(t, c, "keep", "same")
+		mustPut(t, c, "gone", "bye")
+	})
+
+	p.clock.Advance(time.Minute)
+	mustPut(t, p.b, "edit", "v2")
+	mustPut(t, p.b, "new", "fresh")
+	if err := p.b.Delete("gone"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	r := mustMerge(t, p.sa, p.sb)
+
+	ref := func(id string) []zstore.RecordRef {
+		return []zstore.RecordRef{{Collection: "notes", ID: id}}
+	}
+	if !slices.Equal(r.Added, ref("new")) {
+		t.Errorf("added = %v", r.Added)
+	}
+	if !slices.Equal(r.Updated, ref("edit")) {
+		t.Errorf("updated = %v", r.Updated)
+	}
+	if !slices.Equal(r.Deleted, ref("gone")) {
+		t.Errorf("deleted = %v", r.Deleted)
+	}
+	if len(r.Conflicts) != 0 {
+		t.Errorf("conflicts = %v", r.Conflicts)
+	}
+
+	if got, want := sortedList(t, p.a), []string{"fresh", "same", "v2"}; !slices.Equal(got, want) {
+		t.Fatalf("list = %v, want %v", got, want)
+	}
+	if _, err := p.a.Get("gone"); !errors.Is(err, zstore.ErrNotFound) {
+		t.Fatalf("expected deleted record to be gone, got %v", err)
+	}
+
+	// merging the older replica back changes nothing
+	r = mustMerge(t, p.sb, p.sa)
+	if len(r.Added)+len(r.Updated)+len(r.Deleted)+len(r.Conflicts) != 0 {
+		t.Fatalf("reverse merge changed %+v", r)
+	}
+}
+
+func TestMergeKeepsNewerDestination(t *testing.T) {
+	p := replicas(t, func(c *zstore.Collection[string]) {
+		mustPut(t, c, "k", "v1")
+	})
+
+	p.clock.Advance(time.Minute)
+	mustPut(t, p.a, "k", "v2")
+
+	r := mustMerge(t, p.sa, p.sb)
+	if len(r.Updated)+len(r.Conflicts) != 0 {
+		t.Fatalf("merge changed %+v", r)
+	}
+
+	got, err := p.a.Get("k")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got != "v2" {
+		t.Fatalf("got %q, want %q", got, "v2")
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	p := replicas(t, func(c *zstore.Collection[string]) {
+		mustPut(t, c, "k", "base")
+	})
+
+	p.clock.Advance(time.Minute)
+	mustPut(t, p.a, "k", "laptop")
+	p.clock.Advance(time.Minute)
+	mustPut(t, p.b, "k", "desktop")
+
+	r := mustMerge(t, p.sa, p.sb)
+	if len(r.Conflicts) != 1 {
+		t.Fatalf("conflicts = %v, want 1", r.Conflicts)
+	}
+
+	c := r.Conflicts[0]
+	if c.ID != "k" || !c.FromSrc || c.CopyID == "" {
+		t.Fatalf("conflict = %+v", c)
+	}
+
+	// the later edit wins and the other is preserved
+	got, err := p.a.Get("k")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got != "desktop" {
+		t.Fatalf("got %q, want %q", got, "desktop")
+	}
+
+	cp, err := p.a.Get(c.CopyID)
+	if err != nil {
+		t.Fatalf("get copy: %v", err)
+	}
+	if cp != "laptop" {
+		t.Fatalf("copy = %q, want %q", cp, "laptop")
+	}
+
+	// merging back converges both replicas
+	mustMerge(t, p.sb, p.sa)
+	if ga, gb := sortedList(t, p.a), sortedList(t, p.b); !slices.Equal(ga, gb) {
+		t.Fatalf("replicas diverge: %v vs %v", ga, gb)
+	}
+
+	for _, pair := range [][2]*zstore.Store{{p.sa, p.sb}, {p.sb, p.sa}} {
+		r := mustMerge(t, pair[0], pair[1])
+		if len(r.Added)+len(r.Updated)+len(r.Deleted)+len(r.Conflicts) != 0 {
+			t.Fatalf("converged merge changed %+v", r)
+		}
+	}
+}
+
+func TestMergeIndependentConflictResolution(t *testing.T) {
+	p := replicas(t, func(c *zstore.Collection[string]) {
+		mustPut(t, c, "k", "base")
+	})
+
+	p.clock.Advance(time.Minute)
+	mustPut(t, p.a, "k", "laptop")
+	mustPut(t, p.b, "k", "desktop")
+
+	// each side merges a snapshot of the other before either result is
+	// copied back
+	snapA, _ := openReplica(t, copyFS(t, p.fa), p.clock)
+	snapB, _ := openReplica(t, copyFS(t, p.fb), p.clock)
+
+	ra := mustMerge(t, p.sa, snapB)
+	rb := mustMerge(t, p.sb, snapA)
+	if len(ra.Conflicts) != 1 || len(rb.Conflicts) != 1 {
+		t.Fatalf("conflicts = %v and %v", ra.Conflicts, rb.Conflicts)
+	}
+
+	if ga, gb := sortedList(t, p.a), sortedList(t, p.b); !slices.Equal(ga, gb) {
+		t.Fatalf("replicas diverge: %v vs %v", ga, gb)
+	}
+
+	// both sides resolved the conflict identically
+	r := mustMerge(t, p.sa, p.sb)
+	if len(r.Added)+len(r.Updated)+len(r.Deleted)+len(r.Conflicts) != 0 {
+		t.Fatalf("resolved conflict reappeared: %+v", r)
+	}
+}
+
+func TestMergeEditBeatsDelete(t *testing.T) {
+	p := replicas(t, func(c *zstore.Collection[string]) {
+		mustPut(t, c, "k", "base")
+	})
+
+	p.clock.Advance(time.Minute)
+	mustPut(t, p.a, "k", "edited")
+	p.clock.Advance(time.Minute)
+	if err := p.b.Delete("k"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	r := mustMerge(t, p.sa, p.sb)
+	if len(r.Conflicts) != 1 || r.Conflicts[0].FromSrc || r.Conflicts[0].CopyID != "" {
+		t.Fatalf("conflicts = %+v", r.Conflicts)
+	}
+
+	got, err := p.a.Get("k")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got != "edited" {
+		t.Fatalf("got %q, want %q", got, "edited")
+	}
+
+	// and the edit propagates back over the deletion
+	mustMerge(t, p.sb, p.sa)
+	got, err = p.b.Get("k")
+	if err != nil {
+		t.Fatalf("get after reverse merge: %v", err)
+	}
+	if got != "edited" {
+		t.Fatalf("got %q, want %q", got, "edited")
+	}
+}
+
+func TestMergeAttachments(t *testing.T) {
+	p := replicas(t, func(c *zstore.Collection[string]) {
+		mustPut(t, c, "doc", "v1")
+	})
+
+	p.clock.Advance(time.Minute)
+	mustPut(t, p.b, "doc", "v2")
+	if err := p.b.PutAttachment("doc", "scan.pdf", strings.NewReader("pdf bytes")); err != nil {
+		t.Fatalf("put attachment: %v", err)
+	}
+
+	mustMerge(t, p.sa, p.sb)
+
+	rc, err := p.a.OpenAttachment("doc", "scan.pdf")
+	if err != nil {
+		t.Fatalf("open attachment: %v", err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read attachment: %v", err)
+	}
+	if string(data) != "pdf bytes" {
+		t.Fatalf("attachment = %q, want %q", data, "pdf bytes")
+	}
+}
+
+// mkdirCountFS counts MkdirAll calls.
+type mkdirCountFS struct {
+	*zfilesystem.MemFS
+	mkdirs int
+}
+
+func (f *mkdirCountFS) MkdirAll(path string, perm fs.FileMode) error {
+	f.mkdirs++
+	return f.MemFS.MkdirAll(path, perm)
+}
+
+func TestMergeLeavesSourceUntouched(t *testing.T) {
+	p := replicas(t, func(c *zstore.Collection[string]) {
+		mustPut(t, c, "a", "1")
+	})
+
+	mfs := &mkdirCountFS{MemFS: copyFS(t, p.fa)}
+	src, err := zstore.Open(mfs, []byte("password"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer src.Close()
+	src.SetNowForTest(p.clock.Now)
+
+	before := mfs.mkdirs
+	mustMerge(t, p.sb, src)
+	if mfs.mkdirs != before {
+		t.Fatalf("merge created %d directories in the source", mfs.mkdirs-before)
+	}
+}
+
+func TestMergeConcurrentWriters(t *testing.T) {
+	p := replicas(t, func(c *zstore.Collection[string]) {
+		for i := range 10 {
+			mustPut(t, c, fmt.Sprint("seed", i), "v")
+		}
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for side, c := range []*zstore.Collection[string]{p.a, p.b} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 50 {
+				if err := c.Put(fmt.Sprint("new", side, "-", i), "v"); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	// merges in opposite directions must not deadlock on each other's locks
+	for _, pair := range [][2]*zstore.Store{{p.sa, p.sb}, {p.sb, p.sa}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				if _, err := zstore.Merge(pair[0], pair[1]); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	mustMerge(t, p.sa, p.sb)
+	mustMerge(t, p.sb, p.sa)
+	if a, b := sortedList(t, p.a), sortedList(t, p.b); !slices.Equal(a, b) || len(a) != 110 {
+		t.Fatalf("replicas diverged: %d and %d records", len(a), len(b))
+	}
+}
+
+func TestMergeSelf(t *testing.T) {
+	p := replicas(t, func(c *zstore.Collection[string]) {
+		mustPut(t, c, "a", "1")
+	})
+	if r := mustMerge(t, p.sa, p.sa); len(r.Added)+len(r.Updated)+len(r.Conflicts) != 0 {
+		t.Fatalf("merging a store into itself changed it: %+v", r)
+	}
+}
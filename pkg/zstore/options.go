@@ -1,13 +1,95 @@
 package zstore
 
+import "time"
+
 // options holds configuration for the store.
-type options struct{}
+type options struct {
+	janitorInterval  time.Duration
+	janitorError     func(error)
+	tombstoneHorizon time.Duration
+	audit            bool
+	backoffBase      time.Duration
+	backoffMax       time.Duration
+	wipeAfter        int
+	now              func() time.Time
+}
 
 // Option configures a Store.
 type Option func(*options)
 
+// WithJanitor starts a background goroutine that calls Sweep every interval
+// to hard-delete expired records. The janitor stops when the store is closed.
+// Zero (default) disables it; expired records are then only removed by an
+// explicit Sweep.
+func WithJanitor(interval time.Duration) Option {
+	return func(o *options) {
+		o.janitorInterval = interval
+	}
+}
+
+// WithJanitorErrors calls fn with every error returned by the background
+// janitor's Sweep, including records it skipped because they could not be
+// read or decrypted. fn runs on the janitor goroutine. By default such
+// errors are discarded and the janitor tries again on the next tick.
+func WithJanitorErrors(fn func(error)) Option {
+	return func(o *options) {
+		o.janitorError = fn
+	}
+}
+
+// WithTombstoneHorizon sets how long Delete's tombstones are kept before
+// Sweep removes them. A replica that has not been merged within the horizon
+// may bring a deleted record back, so it should exceed the longest time a
+// copy of the store can go without syncing. The default is 90 days; a
+// non-positive horizon keeps tombstones forever.
+func WithTombstoneHorizon(d time.Duration) Option {
+	return func(o *options) {
+		o.tombstoneHorizon = d
+	}
+}
+
+// WithAuditLog records unlocks, failed password attempts, and every record
+// write and deletion in an encrypted, HMAC-chained audit log that can be
+// read and verified with Store.AuditLog. If an entry cannot be written
+// after the operation it records has been persisted, the operation returns
+// an error matching ErrAuditNotRecorded.
+func WithAuditLog() Option {
+	return func(o *options) {
+		o.audit = true
+	}
+}
+
+// WithBackoff sets how long Open refuses attempts after repeated wrong
+// passwords. After three failures in a row every further attempt is refused
+// with a *ThrottleError until a delay has passed, starting at base and
+// doubling with each failure up to limit. A missing or damaged counter
+// costs limit. The default is one second up to one hour; a non-positive
+// base disables the delay.
+func WithBackoff(base, limit time.Duration) Option {
+	return func(o *options) {
+		o.backoffBase = base
+		o.backoffMax = max(base, limit)
+	}
+}
+
+// WithWipeAfter destroys the store when n wrong passwords are entered in a
+// row, removing its records, attachments, audit log and keys from the
+// filesystem; other files there are left alone. Open then returns an error
+// matching both ErrWrongPassword and ErrWiped. Zero (default) never wipes.
+// Use it only with backups: anyone who can call Open can erase the store.
+func WithWipeAfter(n int) Option {
+	return func(o *options) {
+		o.wipeAfter = n
+	}
+}
+
 func applyOptions(opts []Option) options {
-	var o options
+	o := options{
+		tombstoneHorizon: defaultTombstoneHorizon,
+		backoffBase:      defaultBackoffBase,
+		backoffMax:       defaultBackoffMax,
+		now:              time.Now,
+	}
 	for _, opt := range opts {
 		opt(&o)
 	}
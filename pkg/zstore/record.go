@@ -0,0 +1,137 @@
+package zstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zcrypto"
+)
+
+// recordV1 prefixes the plaintext of enveloped records. JSON never starts
+// with this byte, so records written before envelopes existed still decode
+// as a bare value.
+const recordV1 = 0x01
+
+// maxHistory bounds the number of ancestor revisions kept per record. Two
+// replicas that diverged further apart than this merge as a conflict.
+const maxHistory = 64
+
+// record is the encrypted envelope around a stored value. Metadata such as
+// the expiry and revision history lives inside the ciphertext so it is both
+// hidden and authenticated.
+type record struct {
+	Value   json.RawMessage `json:"v,omitempty"`
+	Expires time.Time       `json:"exp,omitzero"`
+
+	// Rev identifies this write. History lists the revisions it replaced,
+	// newest first, so Merge can tell whether one replica's version
+	// descends from the other's.
+	Rev      string    `json:"rev,omitempty"`
+	History  []string  `json:"hist,omitempty"`
+	Modified time.Time `json:"mod,omitzero"`
+
+	// Deleted marks a tombstone left by Delete so deletions propagate.
+	Deleted bool `json:"del,omitempty"`
+}
+
+// newRevision stamps r as a new local revision replacing the given parents.
+func newRevision(r *record, now time.Time, parents ...record) error {
+	rev, err := zcrypto.RandHex(16)
+	if err != nil {
+		return fmt.Errorf("generate revision: %w", err)
+	}
+
+	stamp(r, rev, now, parents)
+	return nil
+}
+
+// derivedRevision stamps r with a revision computed from tag and its
+// parents, so replicas that perform the same merge independently arrive at
+// the same revision instead of conflicting again.
+func derivedRevision(r *record, tag string, now time.Time, parents ...record) {
+	h := sha256.New()
+	h.Write([]byte(tag))
+	for _, p := range parents {
+		h.Write([]byte{0})
+		h.Write([]byte(p.Rev))
+	}
+
+	stamp(r, hex.EncodeToString(h.Sum(nil)[:16]), now, parents)
+}
+
+// stamp sets r's revision and builds its history from the parents.
+func stamp(r *record, rev string, now time.Time, parents []record) {
+	var hist []string
+	for _, p := range parents {
+		if p.Rev != "" {
+			hist = append(hist, p.Rev)
+		}
+	}
+	for _, p := range parents {
+		hist = append(hist, p.History...)
+	}
+
+	// keep the first occurrence of each revision, preserving order
+	seen := make(map[string]bool, len(hist))
+	hist = slices.DeleteFunc(hist, func(h string) bool {
+		if seen[h] {
+			return true
+		}
+		seen[h] = true
+		return false
+	})
+
+	r.Rev = rev
+	r.History = hist[:min(len(hist), maxHistory)]
+	r.Modified = now
+}
+
+// descends reports whether r replaced other, directly or transitively.
+func (r record) descends(other record) bool {
+	return other.Rev != "" && slices.Contains(r.History, other.Rev)
+}
+
+// expired reports whether the record has an expiry at or before now.
+func (r record) expired(now time.Time) bool {
+	return !r.Expires.IsZero() && !now.Before(r.Expires)
+}
+
+// sealRecord marshals and encrypts a record under key.
+func sealRecord(key []byte, r record) ([]byte, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("marshal record: %w", err)
+	}
+
+	ct, err := zcrypto.Encrypt(key, append([]byte{recordV1}, data...))
+	if err != nil {
+		return nil, fmt.Errorf("encrypt record: %w", err)
+	}
+
+	return ct, nil
+}
+
+// openRecord decrypts and unmarshals a record sealed by sealRecord. Legacy
+// ciphertexts holding a bare JSON value are returned as a record without
+// metadata.
+func openRecord(key, ct []byte) (record, error) {
+	plain, err := zcrypto.Decrypt(key, ct)
+	if err != nil {
+		return record{}, fmt.Errorf("decrypt record: %w", err)
+	}
+
+	if len(plain) == 0 || plain[0] != recordV1 {
+		return record{Value: plain}, nil
+	}
+
+	var r record
+	if err := json.Unmarshal(plain[1:], &r); err != nil {
+		return record{}, fmt.Errorf("unmarshal record: %w", err)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,278 @@
+package zstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zfilesystem"
+)
+
+const (
+	throttleFile = "throttle"
+
+	// freeAttempts is how many wrong passwords in a row are allowed before
+	// Open starts making the caller wait
+	freeAttempts = 3
+
+	defaultBackoffBase = time.Second
+	defaultBackoffMax  = time.Hour
+)
+
+// ErrThrottled is matched by errors.Is when Open refuses to try a password
+// because of earlier failed unlocks. Use errors.As with a *ThrottleError to
+// find out how long to wait.
+var ErrThrottled = errors.New("too many failed unlocks")
+
+// ErrWiped is returned, together with ErrWrongPassword, when a wrong password
+// reached the limit set by WithWipeAfter and the store was destroyed.
+var ErrWiped = errors.New("store wiped")
+
+// ThrottleError reports that the store is refusing unlocks until Until. Open
+// returns it on its own when an attempt is made too early, and joined with
+// ErrWrongPassword when a wrong password starts or extends the wait.
+type ThrottleError struct {
+	Failures int           // consecutive failed unlocks
+	Wait     time.Duration // time left until the next attempt is accepted
+	Until    time.Time     // when the next attempt is accepted
+}
+
+// Error implements the error interface.
+func (e *ThrottleError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", ErrThrottled, e.Wait.Round(time.Second))
+}
+
+// Is reports whether target is ErrThrottled.
+func (e *ThrottleError) Is(target error) bool {
+	return target == ErrThrottled
+}
+
+// Failed unlocks are counted in a small file at the root of the store:
+//
+//	throttle   json({failures, until}) || sha256(json)
+//
+// No key is available before the password is checked, so the counter
+// cannot be authenticated: the checksum only detects damage. Instead the
+// throttle fails closed. Every store is created with a counter, a
+// successful unlock writes a zeroed one, and a counter that is missing or
+// fails its checksum is treated as if the longest delay had just started,
+// so deleting or editing the file costs more time than it saves. The owner
+// pays that delay too if the file is lost, which the atomic write makes
+// unlikely. Failures toward WithWipeAfter restart from zero, so damage can
+// never trigger a wipe. A forged file with a valid checksum still resets
+// the count; the throttle slows guessing through Open, and against someone
+// who can rewrite the store or copies it to guess offline, Argon2id is the
+// only defence.
+
+// throttleState is the persisted failed-unlock counter.
+type throttleState struct {
+	Failures int       `json:"failures"`
+	Until    time.Time `json:"until,omitzero"`
+}
+
+// throttle enforces the failed-unlock policy for one Open call.
+type throttle struct {
+	fs zfilesystem.ReadWriteFileFS
+	o  options
+}
+
+// check loads the counter and returns a *ThrottleError if an attempt is not
+// allowed at now. A missing or damaged counter is replaced with one that
+// starts the longest delay.
+func (t *throttle) check(now time.Time) (throttleState, error) {
+	st, ok, err := t.load(now)
+	if err != nil {
+		return throttleState{}, err
+	}
+
+	if !ok {
+		st = throttleState{}
+		if t.o.backoffBase > 0 {
+			st.Until = now.Add(t.o.backoffMax)
+		}
+		if err := t.save(st); err != nil {
+			return throttleState{}, err
+		}
+	}
+
+	if now.Before(st.Until) {
+		return st, &ThrottleError{Failures: st.Failures, Wait: st.Until.Sub(now), Until: st.Until}
+	}
+
+	return st, nil
+}
+
+// fail records a wrong password on top of st and returns the error Open
+// should report.
+func (t *throttle) fail(st throttleState, now time.Time) error {
+	st.Failures++
+
+	if t.o.wipeAfter > 0 && st.Failures >= t.o.wipeAfter {
+		if err := wipe(t.fs); err != nil {
+			return fmt.Errorf("%w: wipe store: %w", ErrWrongPassword, err)
+		}
+		return fmt.Errorf("%w: %w", ErrWrongPassword, ErrWiped)
+	}
+
+	wait := t.o.delay(st.Failures)
+	st.Until = time.Time{}
+	if wait > 0 {
+		st.Until = now.Add(wait)
+	}
+
+	if err := t.save(st); err != nil {
+		return errors.Join(ErrWrongPassword, err)
+	}
+
+	if wait == 0 {
+		return ErrWrongPassword
+	}
+	return fmt.Errorf("%w; %w", ErrWrongPassword, &ThrottleError{Failures: st.Failures, Wait: wait, Until: st.Until})
+}
+
+// reset clears the counter after a successful unlock. The zeroed counter is
+// kept rather than removed, since a missing one is treated as tampering.
+func (t *throttle) reset() error {
+	if err := t.save(throttleState{}); err != nil {
+		return fmt.Errorf("reset throttle: %w", err)
+	}
+	return nil
+}
+
+// load reads the counter. ok is false if the file is missing or fails its
+// checksum. A wait further off than the longest delay is clamped to it, so
+// an edited file cannot lock the store for longer.
+func (t *throttle) load(now time.Time) (st throttleState, ok bool, err error) {
+	data, err := t.fs.ReadFile(throttleFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return throttleState{}, false, nil
+		}
+		return throttleState{}, false, fmt.Errorf("read throttle: %w", err)
+	}
+
+	if len(data) < sha256.Size {
+		return throttleState{}, false, nil
+	}
+	body, sum := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	want := sha256.Sum256(body)
+
+	if !bytes.Equal(sum, want[:]) || json.Unmarshal(body, &st) != nil || st.Failures < 0 {
+		return throttleState{}, false, nil
+	}
+
+	if limit := now.Add(t.o.backoffMax); st.Until.After(limit) {
+		st.Until = limit
+	}
+	return st, true, nil
+}
+
+// save writes the checksummed counter, replacing the old one atomically
+// where the backend allows.
+func (t *throttle) save(st throttleState) error {
+	body, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("marshal throttle: %w", err)
+	}
+
+	sum := sha256.Sum256(body)
+	if err := replaceFile(t.fs, throttleFile, append(body, sum[:]...)); err != nil {
+		return fmt.Errorf("write throttle: %w", err)
+	}
+	return nil
+}
+
+// throttled reports whether failed unlocks are counted at all. They are
+// unless WithBackoff disables the delay and WithWipeAfter is not set.
+func (o options) throttled() bool {
+	return o.backoffBase > 0 || o.wipeAfter > 0
+}
+
+// delay returns how long to wait after the given number of consecutive
+// failures: nothing for the first few, then doubling from the base up to
+// the maximum.
+func (o options) delay(failures int) time.Duration {
+	if o.backoffBase <= 0 || failures < freeAttempts {
+		return 0
+	}
+
+	d := o.backoffBase
+	for i := freeAttempts; i < failures && d < o.backoffMax; i++ {
+		d *= 2
+	}
+	return min(d, o.backoffMax)
+}
+
+// wipe removes the store's own files: records, tombstones and attachments
+// in every collection, the audit log, and then the salt, verification token
+// and throttle counter. Collections are found the way Sweep finds them, so
+// nested names such as "a/b" are wiped too. Directories are removed where
+// the backend tracks them and they are left empty. Anything else sharing
+// the filesystem is left alone.
+func wipe(fsys zfilesystem.ReadWriteFileFS) error {
+	var files, dirs []string
+	err := fsys.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == "." {
+			return nil
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		}
+
+		if storeFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// keys last, so a wipe cut short leaves nothing that opens
+	for _, name := range []string{throttleFile + tempSuffix, throttleFile, saltFile, verifyFile + tempSuffix, verifyFile} {
+		files = append(files, name)
+	}
+
+	for _, path := range files {
+		if err := fsys.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove %s: %w", path, err)
+		}
+	}
+
+	// children come after their parents in walk order; a directory that
+	// still holds someone else's files stays
+	for _, dir := range slices.Backward(dirs) {
+		_ = fsys.Remove(dir)
+	}
+	return nil
+}
+
+// storeFile reports whether the file at path is one zstore writes below
+// the root: an audit log file, a record or tombstone in a collection, or an
+// attachment blob, or its temp file, beside a record.
+func storeFile(path string) bool {
+	dir, name := filepath.Split(filepath.Clean(path))
+	dir = filepath.Clean(dir)
+	switch {
+	case dir == ".":
+		return false
+	case dir == auditDir:
+		return strings.HasSuffix(name, ".entry") || name == filepath.Base(auditHead) || name == filepath.Base(auditPending)
+	case strings.HasSuffix(name, ".enc"), strings.HasSuffix(name, ".tomb"):
+		return true
+	case strings.HasSuffix(dir, ".att") && filepath.Dir(dir) != ".":
+		return attachmentFileName(name)
+	}
+	return false
+}
@@ -0,0 +1,281 @@
+package zstore_test
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zfilesystem"
+	"github.com/zarlcorp/core/pkg/zstore"
+)
+
+// newLockedStore creates a store on a fresh filesystem and closes it.
+func newLockedStore(t *testing.T) *zfilesystem.MemFS {
+	t.Helper()
+
+	mfs := zfilesystem.NewMemFS()
+	s, err := zstore.Open(mfs, []byte("correct"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	s.Close()
+	return mfs
+}
+
+// throttleWait returns the wait reported by err, or zero if it carries none.
+func throttleWait(err error) time.Duration {
+	var te *zstore.ThrottleError
+	if !errors.As(err, &te) {
+		return 0
+	}
+	return te.Wait
+}
+
+func TestOpenThrottle(t *testing.T) {
+	mfs := newLockedStore(t)
+	clock := newFakeClock()
+	opts := []zstore.Option{zstore.WithClockForTest(clock.Now)}
+
+	attempt := func(password string) error {
+		s, err := zstore.Open(mfs, []byte(password), opts...)
+		if err == nil {
+			s.Close()
+		}
+		return err
+	}
+
+	// the first failures are free
+	for range 2 {
+		err := attempt("wrong")
+		if !errors.Is(err, zstore.ErrWrongPassword) || errors.Is(err, zstore.ErrThrottled) {
+			t.Fatalf("expected plain ErrWrongPassword, got %v", err)
+		}
+	}
+
+	// the third starts a one second wait
+	err := attempt("wrong")
+	if !errors.Is(err, zstore.ErrWrongPassword) || throttleWait(err) != time.Second {
+		t.Fatalf("expected wrong password with 1s wait, got %v", err)
+	}
+
+	// even the right password is refused until it passes
+	err = attempt("correct")
+	if errors.Is(err, zstore.ErrWrongPassword) || !errors.Is(err, zstore.ErrThrottled) {
+		t.Fatalf("expected ErrThrottled, got %v", err)
+	}
+	if w := throttleWait(err); w != time.Second {
+		t.Fatalf("wait = %v, want 1s", w)
+	}
+
+	// and the wait doubles with each further failure
+	clock.Advance(time.Second)
+	if w := throttleWait(attempt("wrong")); w != 2*time.Second {
+		t.Fatalf("wait = %v, want 2s", w)
+	}
+
+	clock.Advance(2 * time.Second)
+	if err := attempt("correct"); err != nil {
+		t.Fatalf("open after wait: %v", err)
+	}
+
+	// success resets the count
+	err = attempt("wrong")
+	if !errors.Is(err, zstore.ErrWrongPassword) || errors.Is(err, zstore.ErrThrottled) {
+		t.Fatalf("expected plain ErrWrongPassword after reset, got %v", err)
+	}
+}
+
+func TestOpenThrottleLimit(t *testing.T) {
+	mfs := newLockedStore(t)
+	clock := newFakeClock()
+	opts := []zstore.Option{
+		zstore.WithClockForTest(clock.Now),
+		zstore.WithBackoff(time.Second, 5*time.Second),
+	}
+
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		_, err := zstore.Open(mfs, []byte("wrong"), opts...)
+		if !errors.Is(err, zstore.ErrWrongPassword) {
+			t.Fatalf("attempt %d: expected ErrWrongPassword, got %v", i+1, err)
+		}
+		if got := throttleWait(err); got != w {
+			t.Fatalf("attempt %d: wait = %v, want %v", i+1, got, w)
+		}
+		clock.Advance(w)
+	}
+}
+
+func TestOpenThrottleDisabled(t *testing.T) {
+	mfs := newLockedStore(t)
+	opts := []zstore.Option{zstore.WithBackoff(0, 0)}
+
+	for range 5 {
+		_, err := zstore.Open(mfs, []byte("wrong"), opts...)
+		if !errors.Is(err, zstore.ErrWrongPassword) || errors.Is(err, zstore.ErrThrottled) {
+			t.Fatalf("expected plain ErrWrongPassword, got %v", err)
+		}
+	}
+
+	s, err := zstore.Open(mfs, []byte("correct"), opts...)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	s.Close()
+}
+
+func TestOpenThrottleTampered(t *testing.T) {
+	tests := []struct {
+		name   string
+		damage func(*zfilesystem.MemFS) error
+	}{
+		{"deleted", func(mfs *zfilesystem.MemFS) error {
+			return mfs.Remove("throttle")
+		}},
+		{"corrupt", func(mfs *zfilesystem.MemFS) error {
+			return mfs.WriteFile("throttle", []byte{0xff}, 0o600)
+		}},
+		{"no checksum", func(mfs *zfilesystem.MemFS) error {
+			return mfs.WriteFile("throttle", []byte(`{"failures":100}`), 0o600)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mfs := newLockedStore(t)
+			clock := newFakeClock()
+			opts := []zstore.Option{
+				zstore.WithClockForTest(clock.Now),
+				zstore.WithWipeAfter(2),
+			}
+
+			if err := tt.damage(mfs); err != nil {
+				t.Fatalf("damage: %v", err)
+			}
+
+			// a damaged counter starts the longest delay, for everyone
+			_, err := zstore.Open(mfs, []byte("correct"), opts...)
+			if !errors.Is(err, zstore.ErrThrottled) || throttleWait(err) != time.Hour {
+				t.Fatalf("expected a 1h wait, got %v", err)
+			}
+
+			// but does not count towards a wipe
+			clock.Advance(time.Hour)
+			_, err = zstore.Open(mfs, []byte("wrong"), opts...)
+			if !errors.Is(err, zstore.ErrWrongPassword) || errors.Is(err, zstore.ErrWiped) {
+				t.Fatalf("expected ErrWrongPassword without a wipe, got %v", err)
+			}
+
+			s, err := zstore.Open(mfs, []byte("correct"), opts...)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			s.Close()
+		})
+	}
+}
+
+func TestOpenWipeAfter(t *testing.T) {
+	mfs := zfilesystem.NewMemFS()
+	opts := []zstore.Option{zstore.WithBackoff(0, 0), zstore.WithWipeAfter(3), zstore.WithAuditLog()}
+
+	s, err := zstore.Open(mfs, []byte("correct"), opts...)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	col, err := zstore.NewCollection[string](s, "secrets")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.Put("k", "v"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	// a collection with a nested name, with an attachment
+	nested, err := zstore.NewCollection[string](s, "a/b")
+	if err != nil {
+		t.Fatalf("new nested collection: %v", err)
+	}
+	if err := nested.Put("k", "v"); err != nil {
+		t.Fatalf("put nested: %v", err)
+	}
+	if err := nested.PutAttachment("k", "scan", strings.NewReader("blob")); err != nil {
+		t.Fatalf("put attachment: %v", err)
+	}
+	s.Close()
+
+	for range 2 {
+		_, err := zstore.Open(mfs, []byte("wrong"), opts...)
+		if !errors.Is(err, zstore.ErrWrongPassword) || errors.Is(err, zstore.ErrWiped) {
+			t.Fatalf("expected ErrWrongPassword, got %v", err)
+		}
+	}
+
+	_, err = zstore.Open(mfs, []byte("wrong"), opts...)
+	if !errors.Is(err, zstore.ErrWrongPassword) || !errors.Is(err, zstore.ErrWiped) {
+		t.Fatalf("expected ErrWiped, got %v", err)
+	}
+
+	var left []string
+	err = mfs.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			left = append(left, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	if len(left) != 0 {
+		t.Fatalf("files left after wipe: %v", left)
+	}
+
+	// the next open starts a new, empty store
+	s, err = zstore.Open(mfs, []byte("other"), opts...)
+	if err != nil {
+		t.Fatalf("open after wipe: %v", err)
+	}
+	defer s.Close()
+
+	col, err = zstore.NewCollection[string](s, "secrets")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if _, err := col.Get("k"); !errors.Is(err, zstore.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after wipe, got %v", err)
+	}
+}
+
+func TestOpenWipeAfterSharedDirectory(t *testing.T) {
+	mfs := newLockedStore(t)
+	opts := []zstore.Option{zstore.WithWipeAfter(1)}
+
+	unrelated := map[string]string{
+		"notes.txt":        "keep",
+		"photos/cat.jpg":   "keep",
+		"photos/a.att/x.y": "keep",
+	}
+	for path, data := range unrelated {
+		if err := mfs.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	_, err := zstore.Open(mfs, []byte("wrong"), opts...)
+	if !errors.Is(err, zstore.ErrWiped) {
+		t.Fatalf("expected ErrWiped, got %v", err)
+	}
+
+	for path := range unrelated {
+		if _, err := mfs.ReadFile(path); err != nil {
+			t.Fatalf("wipe removed unrelated %s: %v", path, err)
+		}
+	}
+	for _, path := range []string{"salt", "verify"} {
+		if _, err := mfs.ReadFile(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("%s survived the wipe: %v", path, err)
+		}
+	}
+}
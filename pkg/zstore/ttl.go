@@ -0,0 +1,218 @@
+package zstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultTombstoneHorizon is how long Sweep keeps tombstones unless
+// WithTombstoneHorizon says otherwise.
+const defaultTombstoneHorizon = 90 * 24 * time.Hour
+
+// Sweep hard-deletes every expired record across all collections, along
+// with its attachments, and returns the number of records removed.
+// Attachments left behind by records that no longer exist are removed too,
+// as are tombstones older than the WithTombstoneHorizon.
+// Records that cannot be read or decrypted are skipped rather than ending
+// the sweep; they are reported, joined, in the returned error alongside
+// the count of records that were removed. It stops early if ctx is
+// cancelled.
+func (s *Store) Sweep(ctx context.Context) (int, error) {
+	now := s.now()
+
+	var (
+		expired []string
+		tombs   []string
+		skipped []error
+	)
+	live := make(map[string]bool)
+	attDirs := make(map[string]bool)
+
+	err := s.fs.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if strings.HasSuffix(d.Name(), ".att") {
+				attDirs[path] = true
+			}
+			return nil
+		}
+		tomb := strings.HasSuffix(d.Name(), ".tomb")
+		if !tomb && !strings.HasSuffix(d.Name(), ".enc") {
+			return nil
+		}
+
+		// records live at <collection>/<id>.enc, tombstones beside them at
+		// <collection>/<id>.tomb; skip anything at the root
+		name := filepath.Dir(path)
+		if name == "." {
+			return nil
+		}
+
+		key, err := s.collectionKey(name)
+		if err != nil {
+			return fmt.Errorf("derive collection key: %w", err)
+		}
+
+		ct, err := s.fs.ReadFile(path)
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("read %s: %w", path, err))
+			live[path] = true
+			return nil
+		}
+
+		r, err := openRecord(key, ct)
+		if err != nil {
+			skipped = append(skipped, fmt.Errorf("open %s: %w", path, err))
+			live[path] = true
+			return nil
+		}
+
+		switch {
+		case tomb:
+			if s.tombstoneExpired(r, now) {
+				tombs = append(tombs, path)
+			}
+		case r.expired(now):
+			expired = append(expired, path)
+		default:
+			live[path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Join(append(skipped, err)...)
+	}
+
+	for _, path := range tombs {
+		stale := func(r record) bool { return s.tombstoneExpired(r, now) }
+		if _, err := s.removeIf(path, stale); err != nil {
+			return 0, errors.Join(append(skipped, err)...)
+		}
+	}
+
+	removed := 0
+	for _, path := range expired {
+		stale := func(r record) bool { return r.expired(now) }
+		ok, err := s.removeIf(path, stale)
+		if err != nil {
+			return removed, errors.Join(append(skipped, err)...)
+		}
+		if !ok {
+			// refreshed since the walk
+			live[path] = true
+			continue
+		}
+		removed++
+
+		name, file := filepath.Split(path)
+		if err := s.audit(AuditExpire, filepath.Clean(name), strings.TrimSuffix(file, ".enc")); err != nil {
+			return removed, errors.Join(append(skipped, err)...)
+		}
+	}
+
+	// attachments of expired or deleted records
+	for dir := range attDirs {
+		if live[strings.TrimSuffix(dir, ".att")+".enc"] {
+			continue
+		}
+		if err := s.removeOrphanAttachments(dir); err != nil {
+			return removed, errors.Join(append(skipped, fmt.Errorf("remove attachments: %w", err))...)
+		}
+	}
+
+	return removed, errors.Join(skipped...)
+}
+
+// tombstoneExpired reports whether r is a tombstone older than the store's
+// tombstone horizon at now.
+func (s *Store) tombstoneExpired(r record, now time.Time) bool {
+	if !r.Deleted || s.tombstoneHorizon <= 0 {
+		return false
+	}
+	return !now.Before(r.Modified.Add(s.tombstoneHorizon))
+}
+
+// removeIf removes the record or tombstone at path if stale still holds
+// for it, rechecking under the collection lock so a record refreshed by a
+// concurrent Put since Sweep read it survives. ok reports whether the file
+// was removed.
+func (s *Store) removeIf(path string, stale func(record) bool) (ok bool, err error) {
+	b, err := s.bucket(filepath.Dir(path))
+	if err != nil {
+		return false, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, err := b.read(path)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !stale(r) {
+		return false, nil
+	}
+
+	if err := s.fs.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("remove %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// removeOrphanAttachments removes the attachment directory dir unless its
+// record was written since Sweep looked, checked under the collection lock.
+func (s *Store) removeOrphanAttachments(dir string) error {
+	b, err := s.bucket(filepath.Dir(dir))
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	path := strings.TrimSuffix(dir, ".att") + ".enc"
+	if _, err := s.fs.ReadFile(path); !errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return removeAttachmentDir(s, dir)
+}
+
+// startJanitor runs Sweep every interval until Close is called. Sweep
+// errors are not fatal: they go to the WithJanitorErrors callback, if any,
+// and the janitor tries again on the next tick.
+func (s *Store) startJanitor(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopJanitor = cancel
+	s.janitorDone = make(chan struct{})
+
+	go func() {
+		defer close(s.janitorDone)
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if _, err := s.Sweep(ctx); err != nil && ctx.Err() == nil && s.janitorError != nil {
+					s.janitorError(err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,445 @@
+package zstore_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zarlcorp/core/pkg/zfilesystem"
+	"github.com/zarlcorp/core/pkg/zstore"
+)
+
+// fakeClock is a manually advanced clock safe for concurrent use.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestPutWithTTLExpires(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	clock := newFakeClock()
+	s.SetNowForTest(clock.Now)
+
+	col, err := zstore.NewCollection[string](s, "codes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+
+	if err := col.PutWithTTL("otp", "123456", time.Minute); err != nil {
+		t.Fatalf("put with ttl: %v", err)
+	}
+
+	got, err := col.Get("otp")
+	if err != nil {
+		t.Fatalf("get before expiry: %v", err)
+	}
+	if got != "123456" {
+		t.Fatalf("got %q, want %q", got, "123456")
+	}
+
+	clock.Advance(time.Minute)
+
+	_, err = col.Get("otp")
+	if !errors.Is(err, zstore.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after expiry, got %v", err)
+	}
+}
+
+func TestPutWithTTLNonPositiveNeverExpires(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	clock := newFakeClock()
+	s.SetNowForTest(clock.Now)
+
+	col, err := zstore.NewCollection[string](s, "codes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+
+	if err := col.PutWithTTL("k", "v", 0); err != nil {
+		t.Fatalf("put with ttl: %v", err)
+	}
+
+	clock.Advance(1000 * time.Hour)
+
+	if _, err := col.Get("k"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+}
+
+func TestPutClearsTTL(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	clock := newFakeClock()
+	s.SetNowForTest(clock.Now)
+
+	col, err := zstore.NewCollection[string](s, "codes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+
+	if err := col.PutWithTTL("k", "short", time.Second); err != nil {
+		t.Fatalf("put with ttl: %v", err)
+	}
+	if err := col.Put("k", "forever"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	clock.Advance(time.Hour)
+
+	got, err := col.Get("k")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got != "forever" {
+		t.Fatalf("got %q, want %q", got, "forever")
+	}
+}
+
+func TestListSkipsExpired(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	clock := newFakeClock()
+	s.SetNowForTest(clock.Now)
+
+	col, err := zstore.NewCollection[string](s, "ids")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+
+	if err := col.Put("keep", "kept"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := col.PutWithTTL("burn", "burned", time.Minute); err != nil {
+		t.Fatalf("put with ttl: %v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+
+	got, err := col.List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(got) != 1 || got[0] != "kept" {
+		t.Fatalf("list = %v, want [kept]", got)
+	}
+
+	// expired records still occupy disk until swept
+	n, err := col.Len()
+	if err != nil {
+		t.Fatalf("len: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("len = %d, want 2 before sweep", n)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	clock := newFakeClock()
+	s.SetNowForTest(clock.Now)
+
+	codes, err := zstore.NewCollection[string](s, "codes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	ids, err := zstore.NewCollection[int](s, "ids")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+
+	if err := codes.PutWithTTL("a", "1", time.Minute); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := codes.PutWithTTL("b", "2", time.Hour); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := ids.PutWithTTL("c", 3, time.Minute); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := ids.Put("d", 4); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+
+	removed, err := s.Sweep(context.Background())
+	if err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if removed != 2 {
+		t.Fatalf("removed = %d, want 2", removed)
+	}
+
+	for _, tc := range []struct {
+		name string
+		len  func() (int, error)
+		want int
+	}{
+		{"codes", codes.Len, 1},
+		{"ids", ids.Len, 1},
+	} {
+		n, err := tc.len()
+		if err != nil {
+			t.Fatalf("%s len: %v", tc.name, err)
+		}
+		if n != tc.want {
+			t.Fatalf("%s len = %d, want %d", tc.name, n, tc.want)
+		}
+	}
+}
+
+func TestSweepCancelled(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	col, err := zstore.NewCollection[string](s, "codes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.Put("k", "v"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := s.Sweep(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestSweepAcrossOpens(t *testing.T) {
+	fs := zfilesystem.NewMemFS()
+	password := []byte("password")
+
+	s1, err := zstore.Open(fs, password)
+	if err != nil {
+		t.Fatalf("first open: %v", err)
+	}
+	col, err := zstore.NewCollection[string](s1, "codes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.PutWithTTL("k", "v", time.Millisecond); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	s1.Close()
+
+	// a fresh store sweeps collections it has never opened
+	s2, err := zstore.Open(fs, password)
+	if err != nil {
+		t.Fatalf("second open: %v", err)
+	}
+	defer s2.Close()
+
+	s2.SetNowForTest(func() time.Time { return time.Now().Add(time.Hour) })
+
+	removed, err := s2.Sweep(context.Background())
+	if err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("removed = %d, want 1", removed)
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	fs := zfilesystem.NewMemFS()
+	s, err := zstore.Open(fs, []byte("password"), zstore.WithJanitor(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	col, err := zstore.NewCollection[string](s, "codes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.PutWithTTL("k", "v", time.Millisecond); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		n, err := col.Len()
+		if err != nil {
+			t.Fatalf("len: %v", err)
+		}
+		if n == 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("janitor did not remove expired record")
+}
+
+func TestSweepSkipsUnreadableRecords(t *testing.T) {
+	fs := zfilesystem.NewMemFS()
+	s, err := zstore.Open(fs, []byte("password"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	clock := newFakeClock()
+	s.SetNowForTest(clock.Now)
+
+	col, err := zstore.NewCollection[string](s, "codes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	for _, id := range []string{"a", "b"} {
+		if err := col.PutWithTTL(id, "v", time.Minute); err != nil {
+			t.Fatalf("put %s: %v", id, err)
+		}
+	}
+	if err := fs.WriteFile("codes/bad.enc", []byte("not a record"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	clock.Advance(2 * time.Minute)
+
+	removed, err := s.Sweep(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for the unreadable record")
+	}
+	if removed != 2 {
+		t.Fatalf("removed = %d, want 2", removed)
+	}
+
+	// the unreadable record is left alone
+	if _, err := fs.ReadFile("codes/bad.enc"); err != nil {
+		t.Fatalf("unreadable record removed: %v", err)
+	}
+}
+
+func TestJanitorErrors(t *testing.T) {
+	fs := zfilesystem.NewMemFS()
+	if err := fs.MkdirAll("codes", 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := fs.WriteFile("codes/bad.enc", []byte("not a record"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	errs := make(chan error, 1)
+	report := func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+
+	s, err := zstore.Open(fs, []byte("password"),
+		zstore.WithJanitor(5*time.Millisecond), zstore.WithJanitorErrors(report))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("janitor reported a nil error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("janitor did not report the unreadable record")
+	}
+}
+
+func TestSweepTombstones(t *testing.T) {
+	fs := zfilesystem.NewMemFS()
+	s, err := zstore.Open(fs, []byte("password"), zstore.WithTombstoneHorizon(24*time.Hour))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	clock := newFakeClock()
+	s.SetNowForTest(clock.Now)
+
+	col, err := zstore.NewCollection[string](s, "notes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.Put("k", "v"); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if err := col.Delete("k"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	tombstone := func() bool {
+		t.Helper()
+		_, err := fs.ReadFile("notes/k.tomb")
+		return err == nil
+	}
+
+	clock.Advance(23 * time.Hour)
+	if _, err := s.Sweep(context.Background()); err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if !tombstone() {
+		t.Fatal("tombstone removed before the horizon")
+	}
+
+	clock.Advance(time.Hour)
+	removed, err := s.Sweep(context.Background())
+	if err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if removed != 0 {
+		t.Fatalf("removed = %d, want 0: tombstones are not records", removed)
+	}
+	if tombstone() {
+		t.Fatal("tombstone kept past the horizon")
+	}
+}
+
+func TestDeleteExpired(t *testing.T) {
+	s := openTestStore(t)
+	defer s.Close()
+
+	clock := newFakeClock()
+	s.SetNowForTest(clock.Now)
+
+	col, err := zstore.NewCollection[string](s, "codes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+	if err := col.PutWithTTL("otp", "123456", time.Minute); err != nil {
+		t.Fatalf("put with ttl: %v", err)
+	}
+
+	clock.Advance(time.Minute)
+
+	if err := col.Delete("otp"); !errors.Is(err, zstore.ErrNotFound) {
+		t.Fatalf("delete expired: expected ErrNotFound, got %v", err)
+	}
+}
@@ -14,11 +14,69 @@
 //
 //	col := zstore.Collection[MyType](s, "things")
 //	err = col.Put("id1", MyType{Name: "hello"})
+//
+// # Expiring Records
+//
+// PutWithTTL stores a record with an encrypted expiry. Expired records are
+// invisible to Get and List and are hard-deleted by Sweep, or periodically
+// by a janitor enabled with WithJanitor.
+//
+//	s, err := zstore.Open(fs, password, zstore.WithJanitor(time.Minute))
+//	err = col.PutWithTTL("otp", code, 5*time.Minute)
+//
+// # Attachments
+//
+// Large blobs are streamed through zcrypto's chunked encryption and linked
+// to a record id. They are removed when the record is deleted or swept.
+//
+//	err = col.PutAttachment("id1", "scan.pdf", f)
+//	rc, err := col.OpenAttachment("id1", "scan.pdf")
+//
+// # Replicas
+//
+// Every write records an encrypted revision history, and Delete leaves a
+// tombstone, so two copies of a store edited on different machines can be
+// reconciled with Merge. Concurrent edits are reported as conflicts and the
+// losing version is kept under a new id rather than dropped. Sweep removes
+// tombstones older than 90 days, or the WithTombstoneHorizon.
+//
+//	report, err := zstore.Merge(laptop, desktop)
+//
+// # Audit Log
+//
+// WithAuditLog records unlocks, failed unlocks, writes, deletions, expiries
+// and merges in an encrypted, HMAC-chained log. AuditLog verifies the chain
+// and reports ErrAuditTampered if entries were edited, removed or reordered,
+// or the log was deleted or rolled back.
+//
+//	s, err := zstore.Open(fs, password, zstore.WithAuditLog())
+//	entries, err := s.AuditLog()
+//
+// # Failed Unlocks
+//
+// Open counts wrong passwords in a row. After three, every attempt is
+// refused with a *ThrottleError until an exponentially growing delay has
+// passed, from one second up to an hour unless WithBackoff changes it. The
+// counter fails closed: if it is deleted or damaged, the next attempt waits
+// the longest delay. WithWipeAfter additionally destroys the store after
+// too many failures; it is off by default.
+//
+//	var te *zstore.ThrottleError
+//	if errors.As(err, &te) {
+//	    fmt.Printf("locked, try again in %s\n", te.Wait)
+//	}
 package zstore
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/zarlcorp/core/pkg/zcrypto"
 	"github.com/zarlcorp/core/pkg/zfilesystem"
@@ -28,6 +86,9 @@ const (
 	saltFile   = "salt"
 	verifyFile = "verify"
 	verifyText = "zstore-verify-ok"
+
+	// tempSuffix marks a file being written before it replaces another
+	tempSuffix = ".tmp"
 )
 
 // ErrNotFound is returned when a key does not exist.
@@ -43,7 +104,27 @@ type Store struct {
 	fs        zfilesystem.ReadWriteFileFS
 	masterKey []byte
 	salt      []byte
-	subKeys   [][]byte
+
+	mu      sync.Mutex
+	subKeys [][]byte
+	keys    map[string][]byte      // collection name -> sub-key
+	locks   map[string]*sync.Mutex // collection name -> write lock
+
+	now func() time.Time // injectable for testing
+
+	tombstoneHorizon time.Duration // non-positive keeps tombstones forever
+
+	auditLog    *auditLog       // nil unless WithAuditLog is used
+	auditAnchor auditHeadRecord // audit head recorded in the verification token
+
+	// auditView is the read-only log AuditLog opens without WithAuditLog
+	auditMu   sync.Mutex
+	auditView *auditLog
+
+	// janitor fields, only set when WithJanitor is used
+	stopJanitor  context.CancelFunc
+	janitorDone  chan struct{}
+	janitorError func(error) // nil discards errors
 }
 
 // Open creates or opens a store. On first run it generates a salt, derives a
@@ -51,18 +132,113 @@ type Store struct {
 // On subsequent runs it reads the salt, derives the key, and verifies the
 // password by decrypting the verification token.
 func Open(fs zfilesystem.ReadWriteFileFS, password []byte, opts ...Option) (*Store, error) {
-	_ = applyOptions(opts)
+	o := applyOptions(opts)
 
+	var s *Store
+	event := AuditUnlock
 	salt, err := fs.ReadFile(saltFile)
 	if err != nil {
-		return initStore(fs, password)
+		event = AuditCreate
+		s, err = initStore(fs, password)
+	} else {
+		s, err = unlock(fs, password, salt, o)
+	}
+	if err != nil {
+		return nil, err
 	}
+	s.now = o.now
+	s.tombstoneHorizon = o.tombstoneHorizon
 
-	return openStore(fs, password, salt)
+	if o.audit {
+		if err := s.enableAudit(event); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+
+	if o.janitorInterval > 0 {
+		s.janitorError = o.janitorError
+		s.startJanitor(o.janitorInterval)
+	}
+
+	return s, nil
+}
+
+// unlock opens an existing store, enforcing the failed-unlock policy if
+// one is set: it refuses attempts while a backoff is in effect, counts
+// wrong passwords, and clears the count on success.
+func unlock(fs zfilesystem.ReadWriteFileFS, password, salt []byte, o options) (*Store, error) {
+	t := &throttle{fs: fs, o: o}
+	now := o.now()
+
+	var st throttleState
+	if o.throttled() {
+		var err error
+		if st, err = t.check(now); err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := openStore(fs, password, salt)
+	if errors.Is(err, ErrWrongPassword) {
+		// audit before counting, since the failure may wipe the store
+		var aerr error
+		if o.audit {
+			aerr = recordFailedUnlock(fs, now)
+		}
+		if o.throttled() {
+			err = t.fail(st, now)
+		}
+		if aerr != nil {
+			err = errors.Join(err, fmt.Errorf("audit: %w", aerr))
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if o.throttled() {
+		if err := t.reset(); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// enableAudit opens the audit log, folds in failed unlocks recorded while
+// the store was locked, and records this open.
+func (s *Store) enableAudit(event AuditEvent) error {
+	a, err := openAuditLog(s)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+
+	if err := a.ingestPending(); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	s.auditLog = a
+	return s.audit(event, "", "")
+}
+
+// newStore returns a Store holding the given master key and salt.
+func newStore(fs zfilesystem.ReadWriteFileFS, key, salt []byte) *Store {
+	return &Store{
+		fs:        fs,
+		masterKey: key,
+		salt:      salt,
+		keys:      make(map[string][]byte),
+		locks:     make(map[string]*sync.Mutex),
+		now:       time.Now,
+	}
 }
 
 // initStore handles first-run initialization: generate salt, derive key,
-// encrypt a verification token, persist both.
+// encrypt a verification token, persist both along with an empty
+// failed-unlock counter.
 func initStore(fs zfilesystem.ReadWriteFileFS, password []byte) (*Store, error) {
 	salt, err := zcrypto.RandBytes(zcrypto.SaltSize)
 	if err != nil {
@@ -87,7 +263,13 @@ func initStore(fs zfilesystem.ReadWriteFileFS, password []byte) (*Store, error)
 		return nil, fmt.Errorf("write verification token: %w", err)
 	}
 
-	return &Store{fs: fs, masterKey: key, salt: salt}, nil
+	// a store without a counter reads as tampered with
+	t := &throttle{fs: fs}
+	if err := t.save(throttleState{}); err != nil {
+		return nil, err
+	}
+
+	return newStore(fs, key, salt), nil
 }
 
 // openStore handles subsequent opens: derive key from existing salt, verify
@@ -104,16 +286,121 @@ func openStore(fs zfilesystem.ReadWriteFileFS, password, salt []byte) (*Store, e
 	}
 
 	plain, err := zcrypto.Decrypt(key, token)
-	if err != nil || string(plain) != verifyText {
+	text, anchor, anchored := bytes.Cut(plain, []byte{0})
+	if err != nil || string(text) != verifyText {
 		zcrypto.Erase(key)
 		return nil, ErrWrongPassword
 	}
 
-	return &Store{fs: fs, masterKey: key, salt: salt}, nil
+	s := newStore(fs, key, salt)
+	if anchored {
+		if err := json.Unmarshal(anchor, &s.auditAnchor); err != nil {
+			zcrypto.Erase(key)
+			return nil, fmt.Errorf("unmarshal audit anchor: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// replaceFile writes data to name through a temporary file that is synced
+// and then renamed over name, so a failed or interrupted write leaves the
+// old contents in place. Backends without Rename get a plain write.
+func replaceFile(fsys zfilesystem.ReadWriteFileFS, name string, data []byte) error {
+	return replaceWith(fsys, name, name+tempSuffix, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// replaceWith streams write into tmp, syncs it and renames it over name.
+// On backends without Rename, write goes straight to name and a failed
+// write removes it.
+func replaceWith(fsys zfilesystem.ReadWriteFileFS, name, tmp string, write func(io.Writer) error) error {
+	rfs, ok := fsys.(zfilesystem.RenameFS)
+	if !ok {
+		tmp = name
+	}
+
+	if err := writeSynced(fsys, tmp, write); err != nil {
+		_ = fsys.Remove(tmp)
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	if err := rfs.Rename(tmp, name); err != nil {
+		_ = fsys.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// writeSynced creates name, fills it with write and syncs it to disk if the
+// backend's files have a Sync method, as *os.File does.
+func writeSynced(fsys zfilesystem.ReadWriteFileFS, name string, write func(io.Writer) error) error {
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if s, ok := f.(interface{ Sync() error }); ok {
+		if err := s.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
 }
 
-// Close erases the master key and all sub-keys from memory.
+// collectionKey returns the sub-key for the named collection, deriving it
+// via HKDF on first use.
+func (s *Store) collectionKey(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.keys[name]; ok {
+		return key, nil
+	}
+
+	key, err := zcrypto.ExpandKey(s.masterKey, s.salt, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+
+	s.keys[name] = key
+	s.subKeys = append(s.subKeys, key)
+	return key, nil
+}
+
+// collectionLock returns the mutex serialising writes to the named
+// collection, so Sweep never removes a record a concurrent Put refreshed.
+func (s *Store) collectionLock(name string) *sync.Mutex {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mu, ok := s.locks[name]
+	if !ok {
+		mu = new(sync.Mutex)
+		s.locks[name] = mu
+	}
+	return mu
+}
+
+// Close stops the janitor, if running, and erases the master key and all
+// sub-keys from memory.
 func (s *Store) Close() error {
+	if s.stopJanitor != nil {
+		s.stopJanitor()
+		<-s.janitorDone
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	zcrypto.Erase(s.masterKey)
 	for _, k := range s.subKeys {
 		zcrypto.Erase(k)
@@ -1,7 +1,21 @@
 package zstore
 
+import "time"
+
 // MasterKeyForTest returns the master key slice for test assertions.
 func (s *Store) MasterKeyForTest() []byte { return s.masterKey }
 
 // SubKeysForTest returns the sub-key slices for test assertions.
 func (s *Store) SubKeysForTest() [][]byte { return s.subKeys }
+
+// SetNowForTest replaces the store's clock.
+func (s *Store) SetNowForTest(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// WithClockForTest replaces the clock used while opening the store.
+func WithClockForTest(now func() time.Time) Option {
+	return func(o *options) { o.now = now }
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/zarlcorp/core/pkg/zcrypto"
 	"github.com/zarlcorp/core/pkg/zfilesystem"
 	"github.com/zarlcorp/core/pkg/zstore"
 )
@@ -411,6 +412,37 @@ func TestDataPersistsAcrossOpens(t *testing.T) {
 	}
 }
 
+func TestGetLegacyRecord(t *testing.T) {
+	fs := zfilesystem.NewMemFS()
+	s, err := zstore.Open(fs, []byte("password"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+
+	col, err := zstore.NewCollection[string](s, "notes")
+	if err != nil {
+		t.Fatalf("new collection: %v", err)
+	}
+
+	// records written before envelopes were introduced hold a bare value
+	ct, err := zcrypto.Encrypt(s.SubKeysForTest()[0], []byte(`"legacy"`))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if err := fs.WriteFile("notes/old.enc", ct, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	got, err := col.Get("old")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got != "legacy" {
+		t.Fatalf("got %q, want %q", got, "legacy")
+	}
+}
+
 // openTestStore creates a store with an in-memory filesystem for testing.
 func openTestStore(t *testing.T) *zstore.Store {
 	t.Helper()